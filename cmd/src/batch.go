@@ -18,11 +18,33 @@ The commands are:
 
 	apply                 applies a batch spec to create or update a batch
 	                      change
+	apply-local           applies the changes of a batch spec to a local clone
+	                      of a repository
+	changesets            lists the changesets of a batch change and applies
+	                      actions to many of them at once
+	close                 closes a batch change
+	debug                 starts a shell in the container of a batch spec step
+	delete                deletes a batch change
+	get                   shows a batch change and its changesets
+	lint                  finds mistakes in a batch spec
+	list                  lists batch changes
+	lock                  pins the container images of a batch spec to their
+	                      registry digests
+	logs                  shows the logs of a run of a batch spec
+	lsp                   starts a language server for batch specs
 	new                   creates a new batch spec YAML file
+	plan                  shows what executing a batch spec would do, without
+	                      executing it
 	preview               creates a batch spec to be previewed or applied
 	repos,repositories    queries the exact repositories that a batch spec will
 	                      apply to
+	run                   executes a batch spec and writes the resulting diffs
+	                      to a directory
 	validate              validates a batch spec
+	watch                 shows the changesets of a batch change while they are
+	                      published, reviewed and merged
+	worker                starts a worker that executes batch spec steps for
+	                      other machines
 
 Use "src batch [command] -h" for more information about a command.
 
@@ -20,16 +20,26 @@ Usage:
 
     src batch apply -f FILE [command options]
 
+Before applying the batch spec, it shows what applying it does to each
+changeset and asks for confirmation. Pass -yes to apply it without
+confirmation, for example in CI pipelines.
+
 Examples:
 
     $ src batch apply -f batch.spec.yaml
   
     $ src batch apply -f batch.spec.yaml -namespace myorg
 
+    $ src batch apply -f batch.spec.yaml -yes
+
 `
 
 	flagSet := flag.NewFlagSet("apply", flag.ExitOnError)
 	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+	var (
+		yesFlag  = flagSet.Bool("yes", false, "Apply the batch spec without asking for confirmation.")
+		diffFlag = flagSet.Bool("diff", false, "Show the diffs of the changesets that are created or pushed to in the preview.")
+	)
 
 	handler := func(args []string) error {
 		if err := flagSet.Parse(args); err != nil {
@@ -48,7 +58,7 @@ Examples:
 			execUI = &ui.JSONLines{}
 		} else {
 			out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
-			execUI = &ui.TUI{Out: out}
+			execUI = &ui.TUI{Out: out, StreamOutput: flags.streamOutput}
 		}
 
 		err := executeBatchSpec(ctx, execUI, executeBatchSpecOpts{
@@ -56,6 +66,8 @@ Examples:
 			client: cfg.apiClient(flags.api, flagSet.Output()),
 
 			applyBatchSpec: true,
+			yes:            *yesFlag,
+			showDiffs:      *diffFlag,
 		})
 		if err != nil {
 			return cmderrors.ExitCode(1, nil)
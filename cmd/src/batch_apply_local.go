@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch apply-local' applies the changes a batch spec makes to a repository
+to a local clone of that repository. It creates the branch of the changeset and
+commits the changes with the commit message and author of the changeset.
+
+The changes are either taken from the results of a previous 'src batch preview'
+or 'src batch apply' that are in the cache, or from the output of a previous
+'src batch run'.
+
+If the changes don't apply cleanly, nothing in the local clone is changed.
+
+Usage:
+
+    src batch apply-local -repo NAME (-f FILE | -manifest FILE) [command options]
+
+Examples:
+
+  Apply the cached changes a batch spec makes to github.com/sourcegraph/src-cli
+  to the clone in the current directory:
+
+    $ src batch apply-local -f batch.spec.yaml -repo github.com/sourcegraph/src-cli
+
+  Apply the changes written by 'src batch run -out ./patches' to the clone in
+  ~/src/src-cli:
+
+    $ src batch apply-local -manifest ./patches/manifest.json -repo github.com/sourcegraph/src-cli -C ~/src/src-cli
+
+`
+
+	flagSet := flag.NewFlagSet("apply-local", flag.ExitOnError)
+
+	var (
+		fileFlag     = flagSet.String("f", "", "The batch spec file to read. The changes are taken from the cached results of executing it.")
+		manifestFlag = flagSet.String("manifest", "", "The manifest written by 'src batch run'. The changes are taken from the patches next to it.")
+		repoFlag     = flagSet.String("repo", "", "The name of the repository whose changes to apply. Required.")
+		pathFlag     = flagSet.String("path", "", "The path of the workspace in the repository, if the batch spec has multiple workspaces in it. Only used with -f.")
+		branchFlag   = flagSet.String("branch", "", "The branch of the changeset to apply, if there are multiple changesets for the repository.")
+		dirFlag      = flagSet.String("C", ".", "The local clone of the repository to apply the changes to.")
+		cacheDirFlag = flagSet.String("cache", batchDefaultCacheDir(), "Directory for caching results and repository archives.")
+		nativeFlag   = flagSet.Bool("native", false, "Take the changes from the cached results of executing the batch spec with -workspace native. Only used with -f.")
+		apiFlags     = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		if *repoFlag == "" {
+			return cmderrors.Usage("a repository must be given with -repo")
+		}
+		if (*fileFlag == "") == (*manifestFlag == "") {
+			return cmderrors.Usage("exactly one of -f or -manifest must be given")
+		}
+
+		if err := checkExecutable("git", "version"); err != nil {
+			return err
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+
+		var (
+			changes []localChanges
+			err     error
+		)
+		if *manifestFlag != "" {
+			changes, err = localChangesFromManifest(*manifestFlag, *repoFlag)
+		} else {
+			svc := service.New(&service.Opts{
+				Client:     cfg.apiClient(apiFlags, flagSet.Output()),
+				AllowFiles: true,
+			})
+			if err := svc.DetermineFeatureFlags(ctx); err != nil {
+				return err
+			}
+
+			spec, ext, _, err := parseBatchSpecWithExtensions(fileFlag, svc)
+			if err != nil {
+				ui := &ui.TUI{Out: out}
+				ui.ParsingBatchSpecFailure(err)
+				return err
+			}
+
+			changes, err = localChangesFromCache(ctx, svc, spec, ext, *cacheDirFlag, *nativeFlag, *repoFlag, *pathFlag)
+		}
+		if err != nil {
+			return err
+		}
+
+		selected, err := selectLocalChanges(changes, *repoFlag, *branchFlag)
+		if err != nil {
+			return err
+		}
+
+		pending := out.Pending(output.Linef("", output.StylePending, "Applying changes to branch %q in %s", selected.commit.Branch, *dirFlag))
+		err = workspace.ApplyToLocalClone(ctx, *dirFlag, os.TempDir(), []byte(selected.diff), selected.commit)
+		if err != nil {
+			pending.Destroy()
+			var conflictErr *workspace.ApplyConflictError
+			if errors.As(err, &conflictErr) {
+				out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "The changes don't apply cleanly to %s, nothing was changed:", *dirFlag))
+				out.Write(strings.TrimSpace(conflictErr.Output))
+				return cmderrors.ExitCode(1, nil)
+			}
+			return err
+		}
+		pending.Complete(output.Linef(output.EmojiSuccess, output.StyleSuccess, "Committed changes to branch %q in %s", selected.commit.Branch, *dirFlag))
+
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// localChanges are the changes of a single changeset that can be applied to a
+// local clone.
+type localChanges struct {
+	commit workspace.LocalCommit
+	diff   string
+}
+
+// localChangesFromManifest reads the changes for the given repository from a
+// manifest written by 'src batch run'.
+func localChangesFromManifest(path, repo string) ([]localChanges, error) {
+	manifest, dir, err := service.ReadLocalManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []localChanges
+	for _, cs := range manifest.Changesets {
+		if cs.Repository != repo {
+			continue
+		}
+
+		patch, err := os.ReadFile(filepath.Join(dir, cs.Patch))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading patch for %s", repo)
+		}
+
+		changes = append(changes, localChanges{
+			commit: workspace.LocalCommit{
+				Branch:      cs.Branch,
+				BaseRev:     cs.BaseRev,
+				Message:     cs.CommitMessage,
+				AuthorName:  cs.AuthorName,
+				AuthorEmail: cs.AuthorEmail,
+			},
+			diff: string(patch),
+		})
+	}
+
+	return changes, nil
+}
+
+// localChangesFromCache returns the changes for the given repository from the
+// cached results of executing the batch spec.
+func localChangesFromCache(ctx context.Context, svc *service.Service, spec *batcheslib.BatchSpec, ext *specext.Extensions, cacheDir string, native bool, repo, path string) ([]localChanges, error) {
+	repos, err := svc.ResolveRepositories(ctx, spec)
+	if err != nil {
+		_, unsupported := err.(batches.UnsupportedRepoSet)
+		_, ignored := err.(batches.IgnoredRepoSet)
+		if !unsupported && !ignored {
+			return nil, errors.Wrap(err, "resolving repositories")
+		}
+	}
+
+	var matching []*graphql.Repository
+	for _, r := range repos {
+		if r.Name == repo {
+			matching = append(matching, r)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, errors.Newf("the batch spec doesn't apply to the repository %s", repo)
+	}
+
+	workspaces, err := svc.DetermineWorkspaces(ctx, matching, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	allTasks, err := svc.BuildTasks(ctx, spec, ext, workspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*executor.Task
+	for _, task := range allTasks {
+		if path != "" && task.Path != path {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		return nil, errors.Newf("the batch spec has no workspace at path %q in the repository %s", path, repo)
+	}
+
+	coord := svc.NewCoordinator(executor.NewCoordinatorOpts{
+		CacheDir: cacheDir,
+		Cache:    executor.NewDiskCache(cacheDir),
+		Native:   native,
+	})
+	uncached, specs, err := coord.CheckCache(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+	if len(uncached) != 0 {
+		return nil, errors.Newf("no cached results for %s: execute the batch spec with 'src batch preview' first, or use 'src batch run' and -manifest", repo)
+	}
+
+	var changes []localChanges
+	for _, s := range specs {
+		if s.Type() == batcheslib.ChangesetSpecDescriptionTypeExisting {
+			continue
+		}
+
+		c := localChanges{
+			commit: workspace.LocalCommit{
+				Branch:  strings.TrimPrefix(s.HeadRef, "refs/heads/"),
+				BaseRev: s.BaseRev,
+			},
+		}
+		for i, commit := range s.Commits {
+			if i == 0 {
+				c.commit.Message = commit.Message
+				c.commit.AuthorName = commit.AuthorName
+				c.commit.AuthorEmail = commit.AuthorEmail
+			}
+			c.diff += commit.Diff
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
+
+// selectLocalChanges selects the changes to apply. If there are changes for
+// multiple changesets, branch has to select one of them.
+func selectLocalChanges(changes []localChanges, repo, branch string) (localChanges, error) {
+	if len(changes) == 0 {
+		return localChanges{}, errors.Newf("the batch spec doesn't change anything in %s", repo)
+	}
+
+	if branch == "" {
+		if len(changes) == 1 {
+			return changes[0], nil
+		}
+
+		branches := make([]string, len(changes))
+		for i, c := range changes {
+			branches[i] = c.commit.Branch
+		}
+		return localChanges{}, cmderrors.Usagef("there are multiple changesets for %s, select one with -branch: %s", repo, strings.Join(branches, ", "))
+	}
+
+	for _, c := range changes {
+		if c.commit.Branch == branch {
+			return c, nil
+		}
+	}
+	return localChanges{}, errors.Newf("there is no changeset with the branch %q for %s", branch, repo)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+var batchChangesetsCommands commander
+
+func init() {
+	usage := `'src batch changesets' lists the changesets of a batch change and applies
+actions to many of them at once.
+
+Usage:
+
+	src batch changesets command [command options]
+
+The commands are:
+
+	close        closes changesets on their code hosts
+	comment      comments on changesets
+	detach       detaches changesets from the batch change
+	list         lists changesets
+	merge        merges changesets
+	reenqueue    retries publishing failed changesets
+
+All commands select the changesets of a batch change with the same flags, like
+-batch-change, -state and -repo. The actions use bulk operations of the
+Sourcegraph instance, wait until they are done and report the result for each
+changeset.
+
+Use "src batch changesets [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("changesets", flag.ExitOnError)
+	handler := func(args []string) error {
+		batchChangesetsCommands.run(flagSet, "src batch changesets", usage, args)
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		aliases: []string{"changeset"},
+		handler: handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+// changesetFilterFlags are the flags that select the changesets of a batch
+// change.
+type changesetFilterFlags struct {
+	batchChange string
+	namespace   string
+	state       string
+	reviewState string
+	checkState  string
+	repo        string
+}
+
+func newChangesetFilterFlags(flagSet *flag.FlagSet) *changesetFilterFlags {
+	f := &changesetFilterFlags{}
+	flagSet.StringVar(&f.batchChange, "batch-change", "", "The name of the batch change. (required)")
+	flagSet.StringVar(&f.namespace, "namespace", "", "The user or organization of the batch change. Default is the current user.")
+	flagSet.StringVar(&f.namespace, "n", "", "Alias for -namespace.")
+	flagSet.StringVar(
+		&f.state, "state", "",
+		`Only select changesets in this state: "unpublished", "scheduled", "processing", "open", "draft", "closed", "merged", "deleted", "retrying" or "failed".`,
+	)
+	flagSet.StringVar(
+		&f.reviewState, "review-state", "",
+		`Only select changesets with this review state: "approved", "changes-requested", "pending", "commented" or "dismissed".`,
+	)
+	flagSet.StringVar(
+		&f.checkState, "check-state", "",
+		`Only select changesets with this state of their checks: "pending", "passed" or "failed".`,
+	)
+	flagSet.StringVar(
+		&f.repo, "repo", "",
+		`Only select changesets in repositories whose names match this glob pattern. (e.g. "github.com/myorg/*")`,
+	)
+	return f
+}
+
+var (
+	changesetStates       = []string{"unpublished", "scheduled", "processing", "open", "draft", "closed", "merged", "deleted", "retrying", "failed"}
+	changesetReviewStates = []string{"approved", "changes-requested", "pending", "commented", "dismissed"}
+	changesetCheckStates  = []string{"pending", "passed", "failed"}
+)
+
+// changesets returns the batch change and its changesets selected by the
+// flags.
+func (f *changesetFilterFlags) changesets(ctx context.Context, svc *service.Service) (*graphql.BatchChange, []*graphql.Changeset, error) {
+	if f.batchChange == "" {
+		return nil, nil, cmderrors.Usage("-batch-change is required")
+	}
+
+	opts := graphql.ListChangesetsOpts{}
+	for _, filter := range []struct {
+		flag    string
+		value   string
+		allowed []string
+		target  *string
+	}{
+		{"state", f.state, changesetStates, &opts.State},
+		{"review-state", f.reviewState, changesetReviewStates, &opts.ReviewState},
+		{"check-state", f.checkState, changesetCheckStates, &opts.CheckState},
+	} {
+		value, err := parseEnumFlag(filter.flag, filter.value, filter.allowed)
+		if err != nil {
+			return nil, nil, err
+		}
+		*filter.target = value
+	}
+
+	batchChange, err := svc.GetBatchChange(ctx, f.namespace, f.batchChange)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts.BatchChange = batchChange.ID
+
+	changesets, err := svc.ListChangesets(ctx, opts, f.repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batchChange, changesets, nil
+}
+
+// parseEnumFlag returns the GraphQL enum value for the value of the given
+// flag, which must be one of allowed, or "" if the value is empty.
+func parseEnumFlag(flag, value string, allowed []string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return strings.ToUpper(strings.ReplaceAll(a, "-", "_")), nil
+		}
+	}
+	return "", cmderrors.Usagef("invalid -%s %q, must be one of: %s", flag, value, strings.Join(allowed, ", "))
+}
+
+const changesetTemplate = `
+{{- if eq .Typename "HiddenExternalChangeset" -}}
+    {{- color "search-border" }}(hidden changeset){{ color "nc" -}}
+{{- else -}}
+    {{- color "success" }}{{ .Repository.Name }}{{ color "nc" }}
+    {{- with .ExternalID }} #{{ . }}{{ end }} {{ .Title -}}
+{{- end -}}
+{{- " " }}{{ color "search-border" }}({{ .State | lower }}
+{{- with .ReviewState }}, review {{ . | lower }}{{ end -}}
+{{- with .CheckState }}, checks {{ . | lower }}{{ end -}}
+){{ color "nc" -}}
+`
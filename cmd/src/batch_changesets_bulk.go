@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// bulkOperationPollInterval is how often the state of a bulk operation is
+// checked while waiting for it.
+const bulkOperationPollInterval = 2 * time.Second
+
+// changesetBulkAction is a command that applies a bulk operation to the
+// selected changesets of a batch change.
+type changesetBulkAction struct {
+	name     string
+	label    string
+	examples string
+	// flags adds the flags that are specific to the action to the flag set
+	// and returns the function that starts the bulk operation.
+	flags func(flagSet *flag.FlagSet) func(ctx context.Context, svc *service.Service, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error)
+}
+
+var changesetBulkActions = []changesetBulkAction{
+	{
+		name:  "merge",
+		label: "Merging",
+		examples: `  Merge the open changesets of a batch change whose checks passed:
+
+    $ src batch changesets merge -batch-change=hello-world -state=open -check-state=passed
+`,
+		flags: func(flagSet *flag.FlagSet) func(context.Context, *service.Service, graphql.BatchChangeID, []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+			squash := flagSet.Bool("squash", false, "Squash the commits of the changesets when merging them.")
+			return func(ctx context.Context, svc *service.Service, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+				return svc.MergeChangesets(ctx, batchChange, changesets, *squash)
+			}
+		},
+	},
+	{
+		name:  "close",
+		label: "Closing",
+		examples: `  Close the open changesets of a batch change in the repositories of an
+  organization:
+
+    $ src batch changesets close -batch-change=hello-world -state=open -repo='github.com/myorg/*'
+`,
+		flags: func(flagSet *flag.FlagSet) func(context.Context, *service.Service, graphql.BatchChangeID, []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+			return func(ctx context.Context, svc *service.Service, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+				return svc.CloseChangesets(ctx, batchChange, changesets)
+			}
+		},
+	},
+	{
+		name:  "comment",
+		label: "Commenting on",
+		examples: `  Ask for reviews of the open changesets of a batch change:
+
+    $ src batch changesets comment -batch-change=hello-world -state=open -review-state=pending -body='Could you take a look?'
+`,
+		flags: func(flagSet *flag.FlagSet) func(context.Context, *service.Service, graphql.BatchChangeID, []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+			body := flagSet.String("body", "", "The body of the comment, in Markdown. (required)")
+			return func(ctx context.Context, svc *service.Service, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+				if *body == "" {
+					return nil, cmderrors.Usage("-body is required")
+				}
+				return svc.CreateChangesetComments(ctx, batchChange, changesets, *body)
+			}
+		},
+	},
+	{
+		name:  "reenqueue",
+		label: "Re-enqueueing",
+		examples: `  Retry publishing the failed changesets of a batch change:
+
+    $ src batch changesets reenqueue -batch-change=hello-world -state=failed
+`,
+		flags: func(flagSet *flag.FlagSet) func(context.Context, *service.Service, graphql.BatchChangeID, []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+			return func(ctx context.Context, svc *service.Service, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+				return svc.ReenqueueChangesets(ctx, batchChange, changesets)
+			}
+		},
+	},
+	{
+		name:  "detach",
+		label: "Detaching",
+		examples: `  Detach the closed changesets from a batch change:
+
+    $ src batch changesets detach -batch-change=hello-world -state=closed
+`,
+		flags: func(flagSet *flag.FlagSet) func(context.Context, *service.Service, graphql.BatchChangeID, []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+			return func(ctx context.Context, svc *service.Service, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+				return svc.DetachChangesets(ctx, batchChange, changesets)
+			}
+		},
+	},
+}
+
+func init() {
+	for _, action := range changesetBulkActions {
+		registerChangesetBulkAction(action)
+	}
+}
+
+func registerChangesetBulkAction(action changesetBulkAction) {
+	usage := `
+The changesets are selected like with 'src batch changesets list'. The command
+waits until the operation is done and prints the result for each changeset.
+It exits with status 1 if the operation failed for any of them.
+
+Examples:
+
+` + action.examples + `
+`
+
+	flagSet := flag.NewFlagSet(action.name, flag.ExitOnError)
+	var (
+		filterFlags = newChangesetFilterFlags(flagSet)
+		start       = action.flags(flagSet)
+		formatFlag  = flagSet.String("f", changesetBulkResultTemplate, `Format for the result of each changeset, using the syntax of Go package text/template. (e.g. "{{.Changeset.ID}}: {{.Error}}" or "{{.|json}}")`)
+		apiFlags    = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{Client: cfg.apiClient(apiFlags, flagSet.Output())})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		batchChange, changesets, err := filterFlags.changesets(ctx, svc)
+		if err != nil {
+			return err
+		}
+
+		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+		if len(changesets) == 0 {
+			out.WriteLine(output.Line(output.EmojiWarning, output.StyleWarning, "No changesets match the filters."))
+			return nil
+		}
+
+		ids := make([]graphql.ChangesetID, 0, len(changesets))
+		for _, c := range changesets {
+			ids = append(ids, c.ID)
+		}
+		op, err := start(ctx, svc, batchChange.ID, ids)
+		if err != nil {
+			return err
+		}
+		if op == nil {
+			return nil
+		}
+
+		label := fmt.Sprintf("%s %d changeset%s", action.label, len(ids), pluralS(len(ids)))
+		progress := out.Progress([]output.ProgressBar{{Label: label, Max: 1.0}}, nil)
+		op, err = svc.WaitForBulkOperation(ctx, op, bulkOperationPollInterval, func(op *graphql.BulkOperation) {
+			progress.SetValue(0, op.Progress)
+		})
+		if err != nil {
+			progress.Destroy()
+			return err
+		}
+		progress.Complete()
+
+		errs := op.ChangesetErrors()
+		for _, c := range changesets {
+			if err := execTemplate(tmpl, changesetBulkResult{Changeset: c, Error: errs[c.ID]}); err != nil {
+				return err
+			}
+		}
+
+		if len(errs) > 0 {
+			out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "The operation failed for %d of %d changesets.", len(errs), len(ids)))
+			return cmderrors.ExitCode(1, nil)
+		}
+		return nil
+	}
+
+	batchChangesetsCommands = append(batchChangesetsCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch changesets %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// changesetBulkResult is the result of a bulk operation for one changeset.
+type changesetBulkResult struct {
+	Changeset *graphql.Changeset `json:"changeset"`
+	// Error is empty if the operation succeeded for the changeset.
+	Error string `json:"error,omitempty"`
+}
+
+const changesetBulkResultTemplate = `
+{{- if .Error }}{{ color "warning" }}✗{{ color "nc" }}{{ else }}{{ color "success" }}✓{{ color "nc" }}{{ end }} ` +
+	`{{ with .Changeset }}` + changesetTemplate + `{{ end }}` + `
+{{- with .Error }}
+  {{ color "warning" }}{{ . }}{{ color "nc" }}{{ end -}}
+`
+
+func pluralS(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List the changesets of a batch change:
+
+    $ src batch changesets list -batch-change=hello-world
+
+  List the open changesets whose checks failed in the repositories of an
+  organization:
+
+    $ src batch changesets list -batch-change=hello-world -state=open -check-state=failed -repo='github.com/myorg/*'
+
+  Print the changesets as JSON:
+
+    $ src batch changesets list -batch-change=hello-world -f '{{.|json}}'
+
+`
+
+	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
+	var (
+		filterFlags = newChangesetFilterFlags(flagSet)
+		formatFlag  = flagSet.String("f", changesetTemplate, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Title}}" or "{{.|json}}")`)
+		apiFlags    = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{Client: cfg.apiClient(apiFlags, flagSet.Output())})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		_, changesets, err := filterFlags.changesets(ctx, svc)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range changesets {
+			if err := execTemplate(tmpl, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	batchChangesetsCommands = append(batchChangesetsCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch changesets %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
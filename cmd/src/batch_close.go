@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch close' closes a batch change. Its changesets stay open on the code
+hosts, unless -close-changesets is given.
+
+Usage:
+
+    src batch close [command options] NAME
+
+Examples:
+
+  Close a batch change of the current user:
+
+    $ src batch close hello-world
+
+  Close a batch change of an organization and all of its open changesets:
+
+    $ src batch close -namespace=myorg -close-changesets hello-world
+
+`
+
+	flagSet := flag.NewFlagSet("close", flag.ExitOnError)
+	var (
+		namespaceFlag       = flagSet.String("namespace", "", "The user or organization of the batch change. Default is the current user.")
+		closeChangesetsFlag = flagSet.Bool("close-changesets", false, "Also close the open changesets of the batch change on the code hosts.")
+		formatFlag          = flagSet.String("f", batchCloseTemplate, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}}" or "{{.|json}}")`)
+		apiFlags            = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 1 {
+			return cmderrors.Usage("expected the name of a batch change")
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{Client: cfg.apiClient(apiFlags, flagSet.Output())})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		batchChange, err := svc.GetBatchChange(ctx, *namespaceFlag, flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		closed, err := svc.CloseBatchChange(ctx, batchChange.ID, *closeChangesetsFlag)
+		if err != nil {
+			return err
+		}
+		if closed == nil {
+			return nil
+		}
+
+		absoluteBatchChangeURLs(closed)
+		return execTemplate(tmpl, closed)
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+const batchCloseTemplate = `
+{{- color "success" }}Closed {{ .Namespace.NamespaceName }}/{{ .Name }}{{ color "nc" }}: {{ .URL }}`
@@ -15,14 +15,21 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/hashicorp/go-multierror"
+	"github.com/mattn/go-isatty"
 
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/dashboard"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/batches/report"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/batches/secrets"
 	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
 	"github.com/sourcegraph/src-cli/internal/batches/ui"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
@@ -42,8 +49,25 @@ type batchExecuteFlags struct {
 	parallelism      int
 	timeout          time.Duration
 	workspace        string
+	nativeAllow      string
+	workers          string
+	workerToken      string
+	localRepos       string
+	localReposFile   string
+	workspaceSource  string
+	artifactsDir     string
+	locked           bool
 	cleanArchives    bool
 	skipErrors       bool
+	report           string
+	reportJUnit      string
+	interactive      bool
+	streamOutput     bool
+	debugOnFailure   bool
+	logDir           string
+	serve            string
+	repo             string
+	workspacePath    string
 
 	// EXPERIMENTAL
 	textOnly bool
@@ -80,6 +104,46 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 			"The user or organization namespace to place the batch change within. Default is the currently authenticated user.",
 		)
 		flagSet.StringVar(&caf.namespace, "n", "", "Alias for -namespace.")
+		flagSet.StringVar(
+			&caf.workers, "workers", "",
+			"Comma-separated list of addresses of 'src batch worker' processes, for example \"host1:9091,host2:9091\". If given, the steps are executed by the workers instead of on this machine.",
+		)
+		flagSet.StringVar(
+			&caf.workerToken, "worker-token", os.Getenv("SRC_BATCH_WORKER_TOKEN"),
+			"The token to authenticate with the workers given in -workers. Can also be set with the environment variable SRC_BATCH_WORKER_TOKEN.",
+		)
+		flagSet.StringVar(
+			&caf.artifactsDir, "artifacts-dir", "",
+			"Directory to export the artifacts of the steps to, in a directory per repository, like DIR/github.com/sourcegraph/src-cli/report.sarif.",
+		)
+		flagSet.BoolVar(
+			&caf.locked, "locked", false,
+			"Refuse to execute the batch spec if the registry digests of its container images don't match the batch.lock.json next to it. See 'src batch lock'.",
+		)
+		flagSet.StringVar(
+			&caf.report, "report", "",
+			"File to write a JSON report of the execution to, with the repository, path, cache status, steps, changed files, error and log file of every task.",
+		)
+		flagSet.StringVar(
+			&caf.reportJUnit, "report-junit", "",
+			"File to write the report of the execution to as JUnit XML, with a test case for every task.",
+		)
+		flagSet.BoolVar(
+			&caf.interactive, "interactive", false,
+			"If tasks fail, go through them after the execution: show their logs, the run script and environment of the failed steps, and execute them again.",
+		)
+		flagSet.BoolVar(
+			&caf.streamOutput, "stream-output", false,
+			"Show the standard output and standard error of the steps while they're executed, in addition to writing them to the log files.",
+		)
+		flagSet.BoolVar(
+			&caf.debugOnFailure, "debug-on-failure", false,
+			"If a step exits with a non-zero code, start an interactive shell in its container, with the same mounts, environment and working directory, before its workspace is cleaned up. Implies -j 1.",
+		)
+		flagSet.StringVar(
+			&caf.logDir, "log-dir", os.Getenv("SRC_BATCH_LOG_DIR"),
+			"Directory to keep the logs of the tasks in as JSON lines, in a directory per run, which can be queried and followed with 'src batch logs'. Can also be set with the environment variable SRC_BATCH_LOG_DIR.",
+		)
 	}
 
 	flagSet.StringVar(
@@ -119,7 +183,23 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 
 	flagSet.StringVar(
 		&caf.workspace, "workspace", "auto",
-		`Workspace mode to use ("auto", "bind", or "volume")`,
+		`Workspace mode to use ("auto", "bind", "volume", or "native"). "native" runs steps directly on the host, without Docker and without any isolation.`,
+	)
+	flagSet.StringVar(
+		&caf.nativeAllow, "native-allow-commands", "",
+		`Comma-separated list of commands that steps may run in the "native" workspace mode, for example "sed,comby". Note that commands such as sh or xargs can be used to run any other command.`,
+	)
+	flagSet.StringVar(
+		&caf.workspaceSource, "workspace-source", "zip",
+		`Where workspaces are created from ("zip" or "git"). "zip" downloads a ZIP archive of every repository and commit. "git" keeps a bare mirror of every repository in the cache directory, fetches only new commits into it and creates the workspaces as git worktrees, which include the history of the repository.`,
+	)
+	flagSet.StringVar(
+		&caf.localRepos, "local-repos", "",
+		"Directory that contains local clones of repositories at their names, like DIR/github.com/sourcegraph/src-cli. The workspaces of these repositories are created from the clones instead of archives downloaded from Sourcegraph. The HEAD of a clone must be the commit the batch spec is executed on.",
+	)
+	flagSet.StringVar(
+		&caf.localReposFile, "local-repos-file", "",
+		"YAML file that maps the names of repositories to the paths of their local clones. Takes precedence over -local-repos.",
 	)
 
 	flagSet.BoolVar(verbose, "v", false, "print verbose output")
@@ -127,6 +207,29 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, workspaceExecution bool, cacheD
 	return caf
 }
 
+// addBatchServeFlag adds the flag that serves the state of the execution on
+// an address.
+func addBatchServeFlag(flagSet *flag.FlagSet, caf *batchExecuteFlags) {
+	flagSet.StringVar(
+		&caf.serve, "serve", "",
+		`Address to serve the state of the execution on while it runs, like ":8080": an HTML view of the tasks, their steps, output, diffs and logs, and the same state as JSON at /api/state and as server-sent events at /api/events.`,
+	)
+}
+
+// addBatchTaskFilterFlags adds the flags that narrow the execution down to a
+// repository or a workspace, which is useful while developing the steps of a
+// batch spec.
+func addBatchTaskFilterFlags(flagSet *flag.FlagSet, caf *batchExecuteFlags) {
+	flagSet.StringVar(
+		&caf.repo, "repo", "",
+		"Only execute the steps in this repository, given as NAME or NAME@REV, where REV is a branch or commit. The batch spec and the cache keys of the steps stay the same.",
+	)
+	flagSet.StringVar(
+		&caf.workspacePath, "workspace-path", "",
+		`Only execute the steps in the workspaces at this path, relative to the root of the repository. Use "." for the root.`,
+	)
+}
+
 func batchDefaultCacheDir() string {
 	uc, err := os.UserCacheDir()
 	if err != nil {
@@ -192,6 +295,14 @@ type executeBatchSpecOpts struct {
 	flags *batchExecuteFlags
 
 	applyBatchSpec bool
+	// yes applies the batch spec without asking for confirmation.
+	yes bool
+	// showDiffs shows the diffs of the changesets in the preview of applying
+	// the batch spec.
+	showDiffs bool
+	// outDir, if set, is the directory the diffs of the changeset specs are
+	// written to. Nothing is uploaded to Sourcegraph then.
+	outDir string
 
 	client api.Client
 }
@@ -206,6 +317,19 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 		}
 	}()
 
+	if opts.flags.serve != "" {
+		dash := dashboard.New(opts.flags.file)
+		url, stop, serveErr := dash.Serve(opts.flags.serve)
+		if serveErr != nil {
+			return serveErr
+		}
+		defer stop()
+		defer func() { dash.Finish(err) }()
+
+		ui = dash.UI(ui)
+		ui.ServingDashboard(url)
+	}
+
 	svc := service.New(&service.Opts{
 		AllowUnsupported: opts.flags.allowUnsupported,
 		AllowIgnored:     opts.flags.allowIgnored,
@@ -221,13 +345,17 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 		return err
 	}
 
-	if err := checkExecutable("docker", "version"); err != nil {
-		return err
+	// Docker is neither needed for native workspaces nor when the workers
+	// execute the steps.
+	if opts.flags.workspace != "native" && opts.flags.workers == "" {
+		if err := checkExecutable("docker", "version"); err != nil {
+			return err
+		}
 	}
 
 	// Parse flags and build up our service and executor options.
 	ui.ParsingBatchSpec()
-	batchSpec, rawSpec, err := parseBatchSpec(&opts.flags.file, svc)
+	batchSpec, specExt, rawSpec, err := parseBatchSpecWithExtensions(&opts.flags.file, svc)
 	if err != nil {
 		var multiErr *multierror.Error
 		if errors.As(err, &multiErr) {
@@ -241,32 +369,20 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 	}
 	ui.ParsingBatchSpecSuccess()
 
-	ui.ResolvingNamespace()
-	namespace, err := svc.ResolveNamespace(ctx, opts.flags.namespace)
-	if err != nil {
-		return err
-	}
-	ui.ResolvingNamespaceSuccess(namespace)
-
-	var workspaceCreator workspace.Creator
-
-	if len(batchSpec.Steps) > 0 {
-		ui.PreparingContainerImages()
-		images, err := svc.EnsureDockerImages(ctx, batchSpec.Steps, ui.PreparingContainerImagesProgress)
+	// Without uploading anything, there's no need for a namespace.
+	var namespace string
+	if opts.outDir == "" {
+		ui.ResolvingNamespace()
+		namespace, err = svc.ResolveNamespace(ctx, opts.flags.namespace)
 		if err != nil {
 			return err
 		}
-		ui.PreparingContainerImagesSuccess()
+		ui.ResolvingNamespaceSuccess(namespace)
+	}
 
-		ui.DeterminingWorkspaceCreatorType()
-		workspaceCreator = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, opts.flags.tempDir, images)
-		if workspaceCreator.Type() == workspace.CreatorTypeVolume {
-			_, err = svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage)
-			if err != nil {
-				return err
-			}
-		}
-		ui.DeterminingWorkspaceCreatorTypeSuccess(workspaceCreator.Type())
+	workspaceCreator, err := prepareWorkspaceCreator(ctx, ui, svc, opts.flags, svc.ExpandSteps(batchSpec.Steps, specExt))
+	if err != nil {
+		return err
 	}
 
 	ui.ResolvingRepositories()
@@ -290,21 +406,96 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 	}
 	ui.DeterminingWorkspacesSuccess(len(workspaces))
 
+	localClones, err := batchLocalClones(opts.flags)
+	if err != nil {
+		return err
+	}
+	gitMirrors, err := batchGitMirrors(opts.flags, specExt)
+	if err != nil {
+		return err
+	}
+
+	if opts.flags.artifactsDir != "" && opts.flags.workers != "" {
+		// The artifacts stay on the machines of the workers.
+		return cmderrors.Usage("-artifacts-dir can't be used with -workers")
+	}
+
+	var secretValues secrets.Values
+	if len(specExt.Secrets) > 0 {
+		if opts.flags.workers != "" {
+			// Secrets are never sent anywhere.
+			return cmderrors.Usage("batch specs with secrets can't be executed with -workers")
+		}
+		if secretValues, err = secrets.Resolve(ctx, specExt.Secrets); err != nil {
+			return err
+		}
+	}
+
+	if opts.flags.interactive {
+		if opts.flags.textOnly {
+			return cmderrors.Usage("-interactive can't be used with -text-only")
+		}
+		if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			return cmderrors.Usage("-interactive requires stdin to be a terminal")
+		}
+	}
+
+	parallelism := opts.flags.parallelism
+	if opts.flags.debugOnFailure {
+		if opts.flags.textOnly || opts.flags.workers != "" || opts.flags.workspace == "native" {
+			return cmderrors.Usage("-debug-on-failure can't be used with -text-only, -workers or -workspace native")
+		}
+		if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			return cmderrors.Usage("-debug-on-failure requires stdin to be a terminal")
+		}
+		// The shells can't share the terminal.
+		parallelism = 1
+	}
+
+	var runDir string
+	if opts.flags.logDir != "" {
+		var runID string
+		if runID, runDir, err = log.NewRun(opts.flags.logDir); err != nil {
+			return err
+		}
+		ui.WritingLogs(opts.flags.logDir, runID)
+	}
+
 	// EXECUTION OF TASKS
-	coord := svc.NewCoordinator(executor.NewCoordinatorOpts{
+	//
+	// Errors are skipped with -interactive too, since the specs of the tasks
+	// that succeeded are still needed after going through the failed ones.
+	coordOpts := executor.NewCoordinatorOpts{
 		Creator:       workspaceCreator,
 		CacheDir:      opts.flags.cacheDir,
+		LocalClones:   localClones,
+		GitMirrors:    gitMirrors,
+		Secrets:       secretValues,
 		Cache:         executor.NewDiskCache(opts.flags.cacheDir),
-		SkipErrors:    opts.flags.skipErrors,
+		SkipErrors:    opts.flags.skipErrors || opts.flags.interactive,
 		CleanArchives: opts.flags.cleanArchives,
-		Parallelism:   opts.flags.parallelism,
+		Parallelism:   parallelism,
 		Timeout:       opts.flags.timeout,
 		KeepLogs:      opts.flags.keepLogs,
 		TempDir:       opts.flags.tempDir,
-	})
+		Workers:       splitWorkers(opts.flags.workers),
+		WorkerToken:   opts.flags.workerToken,
+		LogDir:        runDir,
+	}
+	if opts.flags.debugOnFailure {
+		coordOpts.DebugShell = ui.DebugShell
+	}
+	coord := svc.NewCoordinator(coordOpts)
 
 	ui.CheckingCache()
-	tasks := svc.BuildTasks(ctx, batchSpec, workspaces)
+	tasks, err := svc.BuildTasks(ctx, batchSpec, specExt, workspaces)
+	if err != nil {
+		return err
+	}
+	tasks, err = service.NewTaskFilter(opts.flags.repo, opts.flags.workspacePath).Filter(tasks)
+	if err != nil {
+		return err
+	}
 	var (
 		specs         []*batcheslib.ChangesetSpec
 		uncachedTasks []*executor.Task
@@ -320,16 +511,46 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 	}
 	ui.CheckingCacheSuccess(len(specs), len(uncachedTasks))
 
-	taskExecUI := ui.ExecutingTasks(*verbose, opts.flags.parallelism)
-	freshSpecs, logFiles, execErr := coord.Execute(ctx, uncachedTasks, batchSpec, taskExecUI)
-	// Add external changeset specs.
-	importedSpecs, importErr := svc.CreateImportChangesetSpecs(ctx, batchSpec)
+	taskExecUI := ui.ExecutingTasks(*verbose, parallelism)
+	recorder := report.NewRecorder(batchSpec.Name, tasks, uncachedTasks, opts.flags.keepLogs)
+	freshSpecs, logFiles, execErr := coord.Execute(ctx, uncachedTasks, batchSpec, recorder.UI(taskExecUI))
+	if execErr != nil && opts.flags.interactive {
+		taskExecUI.Failed(execErr)
+		var rerunSpecs []*batcheslib.ChangesetSpec
+		rerunSpecs, execErr = ui.TriageFailures(ctx, execErr, func(ctx context.Context, task *executor.Task) ([]*batcheslib.ChangesetSpec, error) {
+			// The task is executed with the same cache state as before,
+			// since the results of failed tasks aren't cached.
+			rerunUI := ui.ExecutingTasks(*verbose, 1)
+			specs, _, err := svc.NewCoordinator(coordOpts).Execute(ctx, []*executor.Task{task}, batchSpec, recorder.UI(rerunUI))
+			if err != nil {
+				rerunUI.Failed(err)
+				return nil, err
+			}
+			rerunUI.Success()
+			return specs, nil
+		})
+		freshSpecs = append(freshSpecs, rerunSpecs...)
+	}
+	// The reports are written even if the execution failed, since that's
+	// when they're needed most.
+	if err := writeBatchReports(opts.flags, recorder.Report()); err != nil {
+		return err
+	}
+	// Add external changeset specs. They have no diff, so they're not needed
+	// when writing the diffs to disk.
+	var (
+		importedSpecs []*batcheslib.ChangesetSpec
+		importErr     error
+	)
+	if opts.outDir == "" {
+		importedSpecs, importErr = svc.CreateImportChangesetSpecs(ctx, batchSpec)
+	}
 	var errs *multierror.Error
 	if execErr != nil {
-		err = multierror.Append(err, execErr)
+		errs = multierror.Append(errs, execErr)
 	}
 	if importErr != nil {
-		err = multierror.Append(err, importErr)
+		errs = multierror.Append(errs, importErr)
 	}
 	err = errs.ErrorOrNil()
 	if err != nil && !opts.flags.skipErrors {
@@ -352,6 +573,12 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 		ui.LogFilesKept(logFiles)
 	}
 
+	if opts.flags.artifactsDir != "" {
+		if err := coord.ExportArtifacts(tasks, opts.flags.artifactsDir); err != nil {
+			return errors.Wrap(err, "exporting artifacts")
+		}
+	}
+
 	specs = append(specs, freshSpecs...)
 	specs = append(specs, importedSpecs...)
 
@@ -360,6 +587,16 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 		return err
 	}
 
+	if opts.outDir != "" {
+		ui.WritingChangesetSpecs(len(specs))
+		manifest, err := service.WriteChangesetSpecs(opts.outDir, repos, specs)
+		if err != nil {
+			return err
+		}
+		ui.WritingChangesetSpecsSuccess(opts.outDir, len(manifest.Changesets))
+		return nil
+	}
+
 	ids := make([]graphql.ChangesetSpecID, len(specs))
 
 	if len(specs) > 0 {
@@ -387,11 +624,41 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 	previewURL := cfg.Endpoint + url
 	ui.CreatingBatchSpecSuccess(previewURL)
 
+	// Instances with campaigns instead of batch changes can't preview
+	// applying batch specs. Nobody reads the preview when applying without
+	// confirmation in text-only mode, so it's skipped then, too.
+	if svc.Features().BatchChanges && !(opts.yes && opts.flags.textOnly) {
+		specsByID := make(map[graphql.ChangesetSpecID]*batcheslib.ChangesetSpec, len(specs))
+		for i, spec := range specs {
+			specsByID[ids[i]] = spec
+		}
+
+		ui.PreviewingApply()
+		// The preview is informational only, so failing to build it doesn't
+		// stop the batch spec from being applied.
+		if previews, err := svc.PreviewApply(ctx, id, specsByID, repos); err != nil {
+			ui.PreviewingApplyFailed(err)
+		} else {
+			ui.PreviewingApplySuccess(previews, opts.showDiffs)
+		}
+	}
+
 	if !opts.applyBatchSpec {
 		ui.PreviewBatchSpec(previewURL)
 		return
 	}
 
+	if !opts.yes {
+		ok, err := ui.ConfirmApply(previewURL)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			ui.ApplyingBatchSpecCancelled(previewURL)
+			return cmderrors.ExitCode1
+		}
+	}
+
 	ui.ApplyingBatchSpec()
 	batch, err := svc.ApplyBatchChange(ctx, id)
 	if err != nil {
@@ -405,19 +672,166 @@ func executeBatchSpec(ctx context.Context, ui ui.ExecUI, opts executeBatchSpecOp
 // parseBatchSpec parses and validates the given batch spec. If the spec has
 // validation errors, they are returned.
 func parseBatchSpec(file *string, svc *service.Service) (*batcheslib.BatchSpec, string, error) {
+	spec, _, raw, err := parseBatchSpecWithExtensions(file, svc)
+	return spec, raw, err
+}
+
+// parseBatchSpecWithExtensions is like parseBatchSpec, but also returns the
+// src-cli extensions of the batch spec. The returned raw batch spec doesn't
+// contain them, since it's meant for Sourcegraph.
+func parseBatchSpecWithExtensions(file *string, svc *service.Service) (*batcheslib.BatchSpec, *specext.Extensions, string, error) {
 	f, err := batchOpenFileFlag(file)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, "", err
 	}
 	defer f.Close()
 
 	data, err := io.ReadAll(f)
 	if err != nil {
-		return nil, "", errors.Wrap(err, "reading batch spec")
+		return nil, nil, "", errors.Wrap(err, "reading batch spec")
+	}
+
+	spec, ext, raw, err := svc.ParseBatchSpecWithExtensions(data)
+	if err != nil {
+		return nil, nil, string(data), err
+	}
+	return spec, ext, string(raw), nil
+}
+
+// prepareWorkspaceCreator pulls the images the given steps need and returns
+// the workspace.Creator to use for them, according to the -workspace flag. If
+// there are no steps, it returns nil.
+func prepareWorkspaceCreator(ctx context.Context, execUI ui.ExecUI, svc *service.Service, flags *batchExecuteFlags, steps []batcheslib.Step) (workspace.Creator, error) {
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	if flags.locked && (flags.workers != "" || flags.workspace == "native") {
+		// The images aren't pulled on this machine.
+		return nil, cmderrors.Usage("-locked can't be used with -workers or -workspace native")
+	}
+
+	if flags.workers != "" {
+		// The workers use their own workspace creators.
+		return nil, nil
+	}
+
+	if flags.workspace == "native" {
+		// Steps aren't run in containers, so there's no need to pull images.
+		execUI.DeterminingWorkspaceCreatorType()
+		creator := workspace.NewNativeCreator(flags.cacheDir, strings.Split(flags.nativeAllow, ","))
+		execUI.DeterminingWorkspaceCreatorTypeSuccess(creator.Type())
+		return creator, nil
+	}
+
+	execUI.PreparingContainerImages()
+	images, err := svc.EnsureDockerImages(ctx, steps, execUI.PreparingContainerImagesProgress)
+	if err != nil {
+		return nil, err
+	}
+	execUI.PreparingContainerImagesSuccess()
+
+	if flags.locked {
+		if err := checkLockedImages(ctx, flags.file, images); err != nil {
+			return nil, err
+		}
+	}
+
+	execUI.DeterminingWorkspaceCreatorType()
+	creator := workspace.NewCreator(ctx, flags.workspace, flags.cacheDir, flags.tempDir, images)
+	if creator.Type() == workspace.CreatorTypeVolume {
+		if _, err := svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage); err != nil {
+			return nil, err
+		}
 	}
+	execUI.DeterminingWorkspaceCreatorTypeSuccess(creator.Type())
+
+	return creator, nil
+}
+
+// splitWorkers splits the value of the -workers flag into addresses.
+func splitWorkers(workers string) []string {
+	var addrs []string
+	for _, w := range strings.Split(workers, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			addrs = append(addrs, w)
+		}
+	}
+	return addrs
+}
 
-	spec, err := svc.ParseBatchSpec(data)
-	return spec, string(data), err
+// writeBatchReports writes the report of the execution to the files given by
+// the -report and -report-junit flags, if they're set.
+func writeBatchReports(flags *batchExecuteFlags, rep *report.Report) error {
+	for _, r := range []struct {
+		file  string
+		write func(io.Writer, *report.Report) error
+	}{
+		{flags.report, report.WriteJSON},
+		{flags.reportJUnit, report.WriteJUnit},
+	} {
+		if r.file == "" {
+			continue
+		}
+		f, err := os.Create(r.file)
+		if err != nil {
+			return errors.Wrap(err, "creating report")
+		}
+		if err := r.write(f, rep); err != nil {
+			f.Close()
+			return errors.Wrapf(err, "writing report to %q", r.file)
+		}
+		if err := f.Close(); err != nil {
+			return errors.Wrapf(err, "writing report to %q", r.file)
+		}
+	}
+	return nil
+}
+
+// batchLocalClones returns the local clones of repositories given by the
+// -local-repos and -local-repos-file flags, or nil if neither is set.
+func batchLocalClones(flags *batchExecuteFlags) (*repozip.LocalClones, error) {
+	if flags.localRepos == "" && flags.localReposFile == "" {
+		return nil, nil
+	}
+	if flags.workers != "" {
+		// The workers create the workspaces on their machines.
+		return nil, cmderrors.Usage("local repositories can't be used with -workers")
+	}
+
+	clones := &repozip.LocalClones{Dir: flags.localRepos}
+	if flags.localReposFile != "" {
+		paths, err := repozip.ReadLocalClonesFile(flags.localReposFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading local repositories file")
+		}
+		clones.Paths = paths
+	}
+	return clones, nil
+}
+
+// batchGitMirrors returns whether the workspaces are created from git mirrors,
+// according to the -workspace-source flag.
+func batchGitMirrors(flags *batchExecuteFlags, specExt *specext.Extensions) (bool, error) {
+	switch flags.workspaceSource {
+	case "zip":
+		return false, nil
+	case "git":
+		if flags.workers != "" {
+			// The workers create the workspaces on their machines.
+			return false, cmderrors.Usage("-workspace-source git can't be used with -workers")
+		}
+		if flags.localRepos != "" || flags.localReposFile != "" {
+			return false, cmderrors.Usage("local repositories can't be used with -workspace-source git")
+		}
+		if len(specExt.WorkspacePaths()) > 0 {
+			// The worktrees always have all files of the repository.
+			return false, cmderrors.Usage("batch specs with paths can't be executed with -workspace-source git")
+		}
+		return true, nil
+	default:
+		return false, cmderrors.Usagef("invalid -workspace-source %q, must be \"zip\" or \"git\"", flags.workspaceSource)
+	}
 }
 
 func checkExecutable(cmd string, args ...string) error {
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mattn/go-isatty"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/secrets"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch debug' starts an interactive shell in the container of a step of a
+batch spec, in the workspace the step is executed in, instead of executing the
+step. The shell has the same mounts, environment and working directory as the
+step, and its run script is mounted into the container.
+
+The workspace contains the changes of the steps before the step. They're
+restored from the cache if these steps were executed before, and executed
+otherwise. The workspace is cleaned up once the shell exits.
+
+Usage:
+
+    src batch debug -f FILE -step N [-repo NAME[@REV]] [-workspace-path PATH] [command options]
+
+Examples:
+
+  Debug the second step of a batch spec in a repository:
+
+    $ src batch debug -f batch.spec.yaml -step 2 -repo github.com/sourcegraph/src-cli
+
+  Debug the first step in a workspace of a repository:
+
+    $ src batch debug -f batch.spec.yaml -step 1 -repo github.com/sourcegraph/sourcegraph@main -workspace-path client/web
+
+`
+
+	flagSet := flag.NewFlagSet("debug", flag.ExitOnError)
+	flags := newBatchExecuteFlags(flagSet, true, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+	addBatchTaskFilterFlags(flagSet, flags)
+	stepFlag := flagSet.Int("step", 0, "The step to start the shell for, starting at 1. Required.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		if *stepFlag < 1 {
+			return cmderrors.Usage("the step must be given with -step, starting at 1")
+		}
+
+		if flags.workspace == "native" {
+			return cmderrors.Usage("steps in the native workspace mode don't have a container to debug")
+		}
+
+		if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			return cmderrors.Usage("'src batch debug' requires stdin to be a terminal")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+		execUI := &ui.TUI{Out: out}
+
+		if err := debugBatchStep(ctx, execUI, flags, *stepFlag, cfg.apiClient(flags.api, flagSet.Output())); err != nil {
+			return cmderrors.ExitCode(1, nil)
+		}
+
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// debugBatchStep resolves the workspace of the batch spec the flags narrow
+// the execution down to and starts the debug shell of the given step (1-based)
+// in it.
+func debugBatchStep(ctx context.Context, execUI ui.ExecUI, flags *batchExecuteFlags, step int, client api.Client) (err error) {
+	defer func() {
+		if err != nil {
+			execUI.ExecutionError(err)
+		}
+	}()
+
+	svc := service.New(&service.Opts{
+		AllowFiles: true,
+		Client:     client,
+	})
+
+	if err := svc.DetermineFeatureFlags(ctx); err != nil {
+		return err
+	}
+
+	if err := checkExecutable("git", "version"); err != nil {
+		return err
+	}
+	if err := checkExecutable("docker", "version"); err != nil {
+		return err
+	}
+
+	execUI.ParsingBatchSpec()
+	batchSpec, specExt, _, err := parseBatchSpecWithExtensions(&flags.file, svc)
+	if err != nil {
+		var multiErr *multierror.Error
+		if errors.As(err, &multiErr) {
+			execUI.ParsingBatchSpecFailure(multiErr)
+			return cmderrors.ExitCode(2, nil)
+		}
+		return err
+	}
+	execUI.ParsingBatchSpecSuccess()
+
+	workspaceCreator, err := prepareWorkspaceCreator(ctx, execUI, svc, flags, svc.ExpandSteps(batchSpec.Steps, specExt))
+	if err != nil {
+		return err
+	}
+
+	execUI.ResolvingRepositories()
+	repos, err := svc.ResolveRepositories(ctx, batchSpec)
+	if err != nil {
+		if repoSet, ok := err.(batches.UnsupportedRepoSet); ok {
+			execUI.ResolvingRepositoriesDone(repos, repoSet, nil)
+		} else if repoSet, ok := err.(batches.IgnoredRepoSet); ok {
+			execUI.ResolvingRepositoriesDone(repos, nil, repoSet)
+		} else {
+			return errors.Wrap(err, "resolving repositories")
+		}
+	} else {
+		execUI.ResolvingRepositoriesDone(repos, nil, nil)
+	}
+
+	execUI.DeterminingWorkspaces()
+	workspaces, err := svc.DetermineWorkspaces(ctx, repos, batchSpec)
+	if err != nil {
+		return err
+	}
+	execUI.DeterminingWorkspacesSuccess(len(workspaces))
+
+	localClones, err := batchLocalClones(flags)
+	if err != nil {
+		return err
+	}
+	gitMirrors, err := batchGitMirrors(flags, specExt)
+	if err != nil {
+		return err
+	}
+
+	var secretValues secrets.Values
+	if len(specExt.Secrets) > 0 {
+		if secretValues, err = secrets.Resolve(ctx, specExt.Secrets); err != nil {
+			return err
+		}
+	}
+
+	tasks, err := svc.BuildTasks(ctx, batchSpec, specExt, workspaces)
+	if err != nil {
+		return err
+	}
+	tasks, err = service.NewTaskFilter(flags.repo, flags.workspacePath).Filter(tasks)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return errors.New("the batch spec has no workspaces")
+	}
+	if len(tasks) > 1 {
+		return cmderrors.Usagef("%d workspaces match: narrow them down to one with -repo NAME@REV and -workspace-path", len(tasks))
+	}
+
+	coord := svc.NewCoordinator(executor.NewCoordinatorOpts{
+		Creator:       workspaceCreator,
+		CacheDir:      flags.cacheDir,
+		LocalClones:   localClones,
+		GitMirrors:    gitMirrors,
+		Secrets:       secretValues,
+		Cache:         executor.NewDiskCache(flags.cacheDir),
+		CleanArchives: flags.cleanArchives,
+		Timeout:       flags.timeout,
+		TempDir:       flags.tempDir,
+	})
+	return coord.Debug(ctx, tasks[0], step, execUI.DebugShell)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch delete' deletes a batch change. Like in the web UI, only closed
+batch changes can be deleted: close it with 'src batch close' first.
+
+Usage:
+
+    src batch delete [command options] NAME
+
+Examples:
+
+  Delete a batch change of the current user:
+
+    $ src batch close hello-world && src batch delete hello-world
+
+  Delete a batch change of an organization:
+
+    $ src batch delete -namespace=myorg hello-world
+
+`
+
+	flagSet := flag.NewFlagSet("delete", flag.ExitOnError)
+	var (
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization of the batch change. Default is the current user.")
+		formatFlag    = flagSet.String("f", batchDeleteTemplate, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}}" or "{{.|json}}")`)
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 1 {
+			return cmderrors.Usage("expected the name of a batch change")
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{Client: cfg.apiClient(apiFlags, flagSet.Output())})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		batchChange, err := svc.GetBatchChange(ctx, *namespaceFlag, flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+		if batchChange.State != "CLOSED" {
+			return errors.Newf("batch change %q is not closed, close it with 'src batch close' first", batchChange.Name)
+		}
+
+		if err := svc.DeleteBatchChange(ctx, batchChange.ID); err != nil {
+			return err
+		}
+
+		// The batch change is printed as it was before it was deleted.
+		absoluteBatchChangeURLs(batchChange)
+		return execTemplate(tmpl, batchChange)
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+const batchDeleteTemplate = `
+{{- color "success" }}Deleted {{ .Namespace.NamespaceName }}/{{ .Name }}{{ color "nc" }}`
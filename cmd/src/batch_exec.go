@@ -13,7 +13,6 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
 	"github.com/sourcegraph/src-cli/internal/batches/service"
 	"github.com/sourcegraph/src-cli/internal/batches/ui"
-	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
 
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
@@ -93,8 +92,10 @@ func executeBatchSpecInWorkspaces(ctx context.Context, ui *ui.JSONLines, opts ex
 	if err := checkExecutable("git", "version"); err != nil {
 		return err
 	}
-	if err := checkExecutable("docker", "version"); err != nil {
-		return err
+	if opts.flags.workspace != "native" {
+		if err := checkExecutable("docker", "version"); err != nil {
+			return err
+		}
 	}
 
 	// Read the input file that contains the raw spec and the workspaces in
@@ -108,25 +109,9 @@ func executeBatchSpecInWorkspaces(ctx context.Context, ui *ui.JSONLines, opts ex
 	// we can convert it to a RepoWorkspace and build a task only for that one.
 	repoWorkspace := convertWorkspace(input.Workspace)
 
-	var workspaceCreator workspace.Creator
-
-	if len(input.Workspace.Steps) > 0 {
-		ui.PreparingContainerImages()
-		images, err := svc.EnsureDockerImages(ctx, input.Workspace.Steps, ui.PreparingContainerImagesProgress)
-		if err != nil {
-			return err
-		}
-		ui.PreparingContainerImagesSuccess()
-
-		ui.DeterminingWorkspaceCreatorType()
-		workspaceCreator = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, opts.flags.tempDir, images)
-		if workspaceCreator.Type() == workspace.CreatorTypeVolume {
-			_, err = svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage)
-			if err != nil {
-				return err
-			}
-		}
-		ui.DeterminingWorkspaceCreatorTypeSuccess(workspaceCreator.Type())
+	workspaceCreator, err := prepareWorkspaceCreator(ctx, ui, svc, opts.flags, input.Workspace.Steps)
+	if err != nil {
+		return err
 	}
 
 	// EXECUTION OF TASKS
@@ -145,7 +130,10 @@ func executeBatchSpecInWorkspaces(ctx context.Context, ui *ui.JSONLines, opts ex
 	// `src batch exec` uses server-side caching for changeset specs, so we
 	// only need to call `CheckStepResultsCache` to make sure that per-step cache entries
 	// are loaded and set on the tasks.
-	tasks := svc.BuildTasks(ctx, input.Spec, []service.RepoWorkspace{repoWorkspace})
+	tasks, err := svc.BuildTasks(ctx, input.Spec, nil, []service.RepoWorkspace{repoWorkspace})
+	if err != nil {
+		return err
+	}
 	if err := coord.CheckStepResultsCache(ctx, tasks); err != nil {
 		return err
 	}
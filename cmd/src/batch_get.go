@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch get' shows a batch change and the number of its changesets in each
+state.
+
+Usage:
+
+    src batch get [command options] NAME
+
+Examples:
+
+  Show a batch change of the current user:
+
+    $ src batch get hello-world
+
+  Show a batch change of an organization:
+
+    $ src batch get -namespace=myorg hello-world
+
+  Print the batch change as JSON:
+
+    $ src batch get -f '{{.|json}}' hello-world
+
+`
+
+	flagSet := flag.NewFlagSet("get", flag.ExitOnError)
+	var (
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization of the batch change. Default is the current user.")
+		formatFlag    = flagSet.String("f", batchGetTemplate, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}}" or "{{.|json}}")`)
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 1 {
+			return cmderrors.Usage("expected the name of a batch change")
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{Client: cfg.apiClient(apiFlags, flagSet.Output())})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		batchChange, err := svc.GetBatchChange(ctx, *namespaceFlag, flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		absoluteBatchChangeURLs(batchChange)
+		return execTemplate(tmpl, batchChange)
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+const batchGetTemplate = `
+{{- color "success" }}{{ .Namespace.NamespaceName }}/{{ .Name }}{{ color "nc" }}
+{{- if eq .State "CLOSED" }} {{ color "warning" }}(closed){{ color "nc" }}{{ end }}
+{{ with .Description }}{{ . }}
+{{ end -}}
+{{ color "search-repository" }}{{ .URL }}{{ color "nc" }}
+
+{{ with .ChangesetsStats -}}
+{{ .Total }} changeset{{ if ne .Total 1 }}s{{ end }}:
+  {{ pad .Unpublished 4 " " }} unpublished
+  {{ pad .Draft 4 " " }} draft
+  {{ pad .Open 4 " " }} open
+  {{ pad .Merged 4 " " }} merged
+  {{ pad .Closed 4 " " }} closed
+  {{ pad .Deleted 4 " " }} deleted
+{{- end -}}
+`
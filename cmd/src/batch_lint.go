@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/lint"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch lint' finds mistakes in the given batch spec that 'src batch validate'
+doesn't, because the batch spec is valid, but won't do what it's meant to do.
+
+It checks, among other things, that templates only reference variables and
+outputs that exist, that 'if:' conditions can be true, that changesets in the
+same repository have different branches, that container images are pinned to
+a version, that steps don't write outside the workspace and that the globs in
+'workspaces.in' match the repositories the batch spec applies to.
+
+Every problem is reported with its position in the batch spec, a severity and
+a suggested fix. The exit code is 1 if there are errors.
+
+Usage:
+
+    src batch lint -f FILE [command options]
+
+Examples:
+
+    $ src batch lint -f batch.spec.yaml
+
+  Lint the batch spec without connecting to Sourcegraph, and print the problems
+  as JSON for an editor:
+
+    $ src batch lint -f batch.spec.yaml -offline -json
+
+`
+
+	flagSet := flag.NewFlagSet("lint", flag.ExitOnError)
+	var (
+		fileFlag    = flagSet.String("f", "", "The batch spec file to read.")
+		jsonFlag    = flagSet.Bool("json", false, "Print the problems as JSON.")
+		offlineFlag = flagSet.Bool("offline", false, "Don't connect to Sourcegraph. All features are assumed to be available and the checks that need the repositories are skipped.")
+		apiFlags    = api.NewFlags(flagSet)
+	)
+
+	var (
+		allowUnsupported bool
+		allowIgnored     bool
+	)
+	flagSet.BoolVar(
+		&allowUnsupported, "allow-unsupported", false,
+		"Allow unsupported code hosts.",
+	)
+	flagSet.BoolVar(
+		&allowIgnored, "force-override-ignore", false,
+		"Do not ignore repositories that have a .batchignore file.",
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		f, err := batchOpenFileFlag(fileFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return errors.Wrap(err, "reading batch spec")
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{
+			Client:           cfg.apiClient(apiFlags, flagSet.Output()),
+			AllowUnsupported: allowUnsupported,
+			AllowIgnored:     allowIgnored,
+			AllowFiles:       true,
+		})
+
+		if *offlineFlag {
+			svc.AssumeLatestFeatureFlags()
+		} else if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		result := batchLintResult{File: *fileFlag}
+		if result.File == "" {
+			result.File = "-"
+		}
+
+		diags, err := lintBatchSpec(ctx, svc, data, *offlineFlag)
+		if err != nil {
+			return err
+		}
+		result.Diagnostics = diags
+		for _, d := range diags {
+			switch d.Severity {
+			case lint.SeverityError:
+				result.Errors++
+			case lint.SeverityWarning:
+				result.Warnings++
+			}
+		}
+
+		if *jsonFlag {
+			data, err := marshalIndent(result)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			tmpl, err := parseTemplate(batchLintTemplate)
+			if err != nil {
+				return err
+			}
+			if err := execTemplate(tmpl, result); err != nil {
+				return err
+			}
+		}
+
+		if result.Errors > 0 {
+			return cmderrors.ExitCode(1, nil)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+type batchLintResult struct {
+	File        string            `json:"file"`
+	Diagnostics []lint.Diagnostic `json:"diagnostics"`
+	Errors      int               `json:"errors"`
+	Warnings    int               `json:"warnings"`
+}
+
+// lintBatchSpec validates the batch spec against the schema and lints it. The
+// schema errors are returned as diagnostics, too.
+func lintBatchSpec(ctx context.Context, svc *service.Service, data []byte, offline bool) ([]lint.Diagnostic, error) {
+	opts := lint.Options{}
+
+	var schemaDiags []lint.Diagnostic
+	spec, err := svc.ParseBatchSpec(data)
+	if err != nil {
+		schemaDiags = lint.SchemaDiagnostics(data, err)
+		for i := range schemaDiags {
+			schemaDiags[i].Fix = "see https://docs.sourcegraph.com/batch_changes/references/batch_spec_yaml_reference"
+		}
+	} else if !offline && len(spec.Workspaces) > 0 {
+		// Only the workspace configuration needs the repositories, so we
+		// don't resolve them if there is none.
+		repos, err := svc.ResolveRepositories(ctx, spec)
+		if err != nil {
+			_, unsupported := err.(batches.UnsupportedRepoSet)
+			_, ignored := err.(batches.IgnoredRepoSet)
+			if !unsupported && !ignored {
+				return nil, errors.Wrap(err, "resolving repositories")
+			}
+		}
+		opts.Repos = make([]string, 0, len(repos))
+		for _, repo := range repos {
+			opts.Repos = append(opts.Repos, repo.Name)
+		}
+	}
+
+	diags := lint.Lint(data, opts)
+	for _, d := range diags {
+		if d.Rule == "yaml" {
+			// The schema errors only repeat that the YAML is invalid.
+			return diags, nil
+		}
+	}
+	return append(schemaDiags, diags...), nil
+}
+
+const batchLintTemplate = `
+{{- range .Diagnostics -}}
+    {{- $.File -}}
+    {{- if ne .Line 0 -}}:{{ .Line }}:{{ .Column }}{{- end -}}
+    {{- ": " -}}
+    {{- if eq .Severity "error" -}}{{- color "warning" -}}{{- .Severity -}}{{- color "nc" -}}{{- else -}}{{- .Severity -}}{{- end -}}
+    {{- ": " -}}{{ .Message }} {{ color "search-border" }}({{ .Rule }}){{ color "nc" }}
+    {{- "\n" -}}
+    {{- if ne .Fix "" -}}{{- "    " -}}{{ color "success" }}fix:{{ color "nc" }} {{ .Fix }}{{- "\n" -}}{{- end -}}
+{{- end -}}
+
+{{- if eq (len .Diagnostics) 0 -}}
+    {{- color "success" -}}No problems found.{{- color "nc" -}}
+{{- else -}}
+    {{- "\n" -}}{{ .Errors }} error{{ if ne .Errors 1 }}s{{ end }}, {{ .Warnings }} warning{{ if ne .Warnings 1 }}s{{ end }}
+{{- end -}}
+`
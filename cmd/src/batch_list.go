@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch list' lists the batch changes on a Sourcegraph instance.
+
+Usage:
+
+    src batch list [command options]
+
+Examples:
+
+  List all batch changes:
+
+    $ src batch list
+
+  List the open batch changes of an organization:
+
+    $ src batch list -namespace=myorg -state=open
+
+  Print the batch changes as JSON:
+
+    $ src batch list -f '{{.|json}}'
+
+`
+
+	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
+	var (
+		namespaceFlag = flagSet.String("namespace", "", "Only list the batch changes of this user or organization.")
+		stateFlag     = flagSet.String("state", "", `Only list the batch changes in this state: "open" or "closed".`)
+		firstFlag     = flagSet.Int("first", 1000, "Returns the first n batch changes. (use -1 for unlimited)")
+		formatFlag    = flagSet.String("f", batchListTemplate, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}}" or "{{.|json}}")`)
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		state, err := parseEnumFlag("state", *stateFlag, []string{"open", "closed"})
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := parseTemplate(*formatFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{Client: cfg.apiClient(apiFlags, flagSet.Output())})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		batchChanges, err := svc.ListBatchChanges(ctx, *namespaceFlag, graphql.ListBatchChangesOpts{
+			State: state,
+			First: *firstFlag,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, batchChange := range batchChanges {
+			absoluteBatchChangeURLs(batchChange)
+			if err := execTemplate(tmpl, batchChange); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+const batchListTemplate = `
+{{- .Namespace.NamespaceName }}/{{ .Name }}
+{{- if eq .State "CLOSED" }} {{ color "warning" }}(closed){{ color "nc" }}{{ end -}}
+{{- " " }}{{ .ChangesetsStats.Total }} changeset{{ if ne .ChangesetsStats.Total 1 }}s{{ end -}}
+`
+
+// absoluteBatchChangeURLs prefixes the URLs of the batch change with the
+// endpoint, so that they can be opened from the output.
+func absoluteBatchChangeURLs(batchChange *graphql.BatchChange) {
+	batchChange.URL = cfg.Endpoint + batchChange.URL
+	batchChange.Namespace.URL = cfg.Endpoint + batchChange.Namespace.URL
+}
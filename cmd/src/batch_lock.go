@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
+	"github.com/sourcegraph/src-cli/internal/batches/lockfile"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch lock' pins the container images of the steps of a batch spec to
+their registry digests, by writing them to a lockfile named batch.lock.json
+next to the batch spec.
+
+Images that don't exist locally are pulled. To lock newer versions of images
+that exist locally, pull them with 'docker pull' first. Images that have
+only been built locally have no registry digest and can't be locked.
+
+Commit the lockfile together with the batch spec and execute the batch spec
+with -locked, so that it refuses to run with images that don't match the
+lockfile.
+
+Usage:
+
+    src batch lock -f FILE [command options]
+
+Examples:
+
+    $ src batch lock -f batch.spec.yaml
+
+    $ src batch preview -f batch.spec.yaml -locked
+
+`
+
+	flagSet := flag.NewFlagSet("lock", flag.ExitOnError)
+	var (
+		fileFlag = flagSet.String("f", "", "The batch spec file to read.")
+		outFlag  = flagSet.String("o", "", "The lockfile to write. Default is batch.lock.json next to the batch spec.")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		if err := checkExecutable("docker", "version"); err != nil {
+			return err
+		}
+
+		// Nothing is sent to Sourcegraph, so the batch spec only has to be
+		// valid for the latest version.
+		svc := service.New(&service.Opts{AllowFiles: true})
+		svc.AssumeLatestFeatureFlags()
+
+		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+		execUI := &ui.TUI{Out: out}
+		spec, ext, _, err := parseBatchSpecWithExtensions(fileFlag, svc)
+		if err != nil {
+			execUI.ParsingBatchSpecFailure(err)
+			return err
+		}
+		if len(spec.Steps) == 0 {
+			return errors.New("the batch spec has no steps")
+		}
+
+		execUI.PreparingContainerImages()
+		images, err := svc.EnsureDockerImages(ctx, svc.ExpandSteps(spec.Steps, ext), execUI.PreparingContainerImagesProgress)
+		if err != nil {
+			return err
+		}
+		execUI.PreparingContainerImagesSuccess()
+		digests, err := imageRepoDigests(ctx, images)
+		if err != nil {
+			return err
+		}
+
+		path := *outFlag
+		if path == "" {
+			path = lockfile.PathFor(*fileFlag)
+		}
+		if err := (&lockfile.Lockfile{Images: digests}).Write(path); err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(digests))
+		for name := range digests {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s: %s\n", name, digests[name])
+		}
+		fmt.Printf("Wrote %s.\n", path)
+
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// imageRepoDigests returns the registry digests of the given images, by the
+// names they have in the batch spec.
+func imageRepoDigests(ctx context.Context, images map[string]docker.Image) (map[string]string, error) {
+	digests := make(map[string]string, len(images))
+	for name, img := range images {
+		digest, err := img.RepoDigest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		digests[name] = digest
+	}
+	return digests, nil
+}
+
+// checkLockedImages returns an error if the given images don't match the
+// lockfile of the batch spec in the given file.
+func checkLockedImages(ctx context.Context, specFile string, images map[string]docker.Image) error {
+	l, err := lockfile.Read(lockfile.PathFor(specFile))
+	if err != nil {
+		return err
+	}
+	digests, err := imageRepoDigests(ctx, images)
+	if err != nil {
+		return err
+	}
+	return l.Check(digests)
+}
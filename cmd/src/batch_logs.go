@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch logs' shows the logs of a run of 'src batch apply', 'src batch
+preview' or 'src batch run' that was executed with -log-dir. Every line of the
+logs belongs to a task, the step it's about and a stream: "stdout" and
+"stderr" for the output of a step, and "log" for everything else.
+
+The logs of a run that's still executing can be followed with -follow. Without
+a run ID, the IDs of the runs in the log directory are listed, oldest first.
+The run ID "latest" is the most recent run.
+
+Usage:
+
+    src batch logs [RUN_ID] [-repo NAME] [-step N] [-follow] [command options]
+
+Examples:
+
+  List the runs:
+
+    $ src batch logs -log-dir ~/batch-logs
+
+  Show the output of the second step in a repository:
+
+    $ src batch logs -log-dir ~/batch-logs 20211019T120000Z-1234567 -repo github.com/sourcegraph/src-cli -step 2
+
+  Follow the logs of the most recent run from another terminal:
+
+    $ src batch logs -log-dir ~/batch-logs latest -follow
+
+`
+
+	flagSet := flag.NewFlagSet("logs", flag.ExitOnError)
+	var (
+		logDirFlag = flagSet.String("log-dir", os.Getenv("SRC_BATCH_LOG_DIR"), "The directory the logs were written to with -log-dir. Can also be set with the environment variable SRC_BATCH_LOG_DIR.")
+		repoFlag   = flagSet.String("repo", "", "Only show the logs of the tasks in this repository.")
+		stepFlag   = flagSet.Int("step", 0, "Only show the logs about this step, starting at 1.")
+		followFlag = flagSet.Bool("follow", false, "Keep showing the logs as they are written, until interrupted.")
+		jsonFlag   = flagSet.Bool("json", false, "Print the lines of the logs as JSON.")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		// The flags can also come after the run ID.
+		var runID string
+		if flagSet.NArg() > 0 {
+			runID = flagSet.Arg(0)
+			if err := flagSet.Parse(flagSet.Args()[1:]); err != nil {
+				return err
+			}
+		}
+		if flagSet.NArg() != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		if *logDirFlag == "" {
+			return cmderrors.Usage("the log directory must be given with -log-dir or SRC_BATCH_LOG_DIR")
+		}
+
+		runs, err := log.Runs(*logDirFlag)
+		if err != nil {
+			return err
+		}
+		if runID == "" {
+			for _, id := range runs {
+				fmt.Println(id)
+			}
+			return nil
+		}
+		if runID == "latest" {
+			if len(runs) == 0 {
+				return cmderrors.Usagef("there are no runs in %s", *logDirFlag)
+			}
+			runID = runs[len(runs)-1]
+		}
+
+		r, err := log.NewRunReader(*logDirFlag, runID, log.Query{Repository: *repoFlag, Step: *stepFlag})
+		if err != nil {
+			return err
+		}
+
+		print := printBatchLogEntry
+		if *jsonFlag {
+			enc := json.NewEncoder(os.Stdout)
+			print = func(e log.Entry) {
+				_ = enc.Encode(e)
+			}
+		}
+
+		if *followFlag {
+			ctx, cancel := contextCancelOnInterrupt(context.Background())
+			defer cancel()
+
+			return r.Follow(ctx, 500*time.Millisecond, print)
+		}
+
+		entries, err := r.Read()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			print(e)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// printBatchLogEntry prints the entry like a line of the text logs, prefixed
+// with its task.
+func printBatchLogEntry(e log.Entry) {
+	task := e.Task.Repository
+	if e.Task.Path != "" {
+		task += ":" + e.Task.Path
+	}
+
+	line := e.Time.Format(time.RFC3339Nano) + " " + task
+	if e.Step != 0 {
+		line += fmt.Sprintf(" [Step %d]", e.Step)
+	}
+	if e.Stream != log.StreamLog {
+		line += " " + e.Stream + " |"
+	}
+	fmt.Println(line + " " + e.Message)
+}
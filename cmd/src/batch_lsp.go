@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/lsp"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch lsp' starts a language server for batch specs, which editors can
+talk to over stdin and stdout using the Language Server Protocol.
+
+It provides completion and documentation for the keys of batch specs and the
+variables in templates, shows the problems that 'src batch validate' and
+'src batch lint' find while editing, and adds a code lens to every entry in
+'on' that shows the repositories it matches.
+
+Everything but the code lenses works without a connection to Sourcegraph.
+
+Usage:
+
+    src batch lsp [command options]
+
+Examples:
+
+  Configure your editor to start the language server for batch spec files with:
+
+    $ src batch lsp
+
+`
+
+	flagSet := flag.NewFlagSet("lsp", flag.ExitOnError)
+	apiFlags := api.NewFlags(flagSet)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		// stdout is reserved for the protocol.
+		logger := log.New(os.Stderr, "src batch lsp: ", log.LstdFlags)
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			Client:     cfg.apiClient(apiFlags, os.Stderr),
+			AllowFiles: true,
+		})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			logger.Printf("working offline, all features are assumed to be available: %s", err)
+			svc.AssumeLatestFeatureFlags()
+		}
+
+		srv := lsp.NewServer(lsp.Options{
+			ParseBatchSpec: svc.ParseBatchSpec,
+			ResolveRepositoriesOn: func(ctx context.Context, on *batcheslib.OnQueryOrRepository) ([]string, error) {
+				repos, _, err := svc.ResolveRepositoriesOn(ctx, on)
+				if err != nil {
+					return nil, err
+				}
+				names := make([]string, 0, len(repos))
+				for _, repo := range repos {
+					names = append(names, repo.Name)
+				}
+				return names, nil
+			},
+		})
+
+		if err := srv.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+			logger.Print(err)
+			return cmderrors.ExitCode(1, nil)
+		}
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch plan' shows what executing a batch spec would do, without executing
+any steps: which workspaces the steps would run in, which of them are cached,
+which container images would be pulled, the outputs of the steps with their
+schemas, and which changesets and branches are expected.
+
+Usage:
+
+    src batch plan -f FILE [command options]
+
+Examples:
+
+    $ src batch plan -f batch.spec.yaml
+
+    $ src batch plan -f batch.spec.yaml -json
+
+`
+
+	flagSet := flag.NewFlagSet("plan", flag.ExitOnError)
+
+	var (
+		fileFlag     = flagSet.String("f", "", "The batch spec file to read.")
+		jsonFlag     = flagSet.Bool("json", false, "Print the plan as JSON.")
+		cacheDirFlag = flagSet.String("cache", batchDefaultCacheDir(), "Directory for caching results and repository archives.")
+		nativeFlag   = flagSet.Bool("native", false, "Check the cached results of executing the batch spec with -workspace native.")
+		apiFlags     = api.NewFlags(flagSet)
+	)
+
+	var (
+		allowUnsupported bool
+		allowIgnored     bool
+	)
+	flagSet.BoolVar(
+		&allowUnsupported, "allow-unsupported", false,
+		"Allow unsupported code hosts.",
+	)
+	flagSet.BoolVar(
+		&allowIgnored, "force-override-ignore", false,
+		"Do not ignore repositories that have a .batchignore file.",
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{
+			Client:           cfg.apiClient(apiFlags, flagSet.Output()),
+			AllowUnsupported: allowUnsupported,
+			AllowIgnored:     allowIgnored,
+			AllowFiles:       true,
+		})
+
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+		spec, ext, _, err := parseBatchSpecWithExtensions(fileFlag, svc)
+		if err != nil {
+			ui := &ui.TUI{Out: out}
+			ui.ParsingBatchSpecFailure(err)
+			return err
+		}
+
+		plan, err := planBatchSpec(ctx, svc, spec, ext, *cacheDirFlag, *nativeFlag)
+		if err != nil {
+			return err
+		}
+
+		if *jsonFlag {
+			data, err := marshalIndent(plan)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		tmpl, err := parseTemplate(batchPlanTemplate)
+		if err != nil {
+			return err
+		}
+		return execTemplate(tmpl, plan)
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// batchPlan is what executing a batch spec would do.
+type batchPlan struct {
+	Workspaces []*batchPlanWorkspace `json:"workspaces"`
+	// Skipped are the repositories that matched the batch spec, but are
+	// skipped because they are on unsupported code hosts or ignored.
+	Skipped []string `json:"skipped"`
+	// Images are the container images the steps use.
+	Images []docker.ImageInfo `json:"images"`
+	// Outputs are the outputs of the steps.
+	Outputs []batchPlanOutput `json:"outputs"`
+
+	Changesets batchPlanChangesets `json:"changesets"`
+
+	// Derived values for the human readable output.
+	CachedCount    int   `json:"-"`
+	PartialCount   int   `json:"-"`
+	UncachedCount  int   `json:"-"`
+	PullCount      int   `json:"-"`
+	PullSize       int64 `json:"-"`
+	PullSizeKnown  bool  `json:"-"`
+	MaxRepoNameLen int   `json:"-"`
+}
+
+type batchPlanCacheStatus string
+
+const (
+	batchPlanCacheHit     batchPlanCacheStatus = "HIT"
+	batchPlanCachePartial batchPlanCacheStatus = "PARTIAL"
+	batchPlanCacheMiss    batchPlanCacheStatus = "MISS"
+)
+
+type batchPlanWorkspace struct {
+	Repository string `json:"repository"`
+	BaseRef    string `json:"baseRef"`
+	BaseRev    string `json:"baseRev"`
+	Path       string `json:"path"`
+
+	Cache batchPlanCacheStatus `json:"cache"`
+	// Steps is the number of steps that would be executed. Cached steps are
+	// not executed again.
+	Steps int `json:"steps"`
+
+	// Changesets is the number of changesets this workspace results in. If
+	// the workspace isn't cached, this is the maximum number.
+	Changesets int `json:"changesets"`
+	// Branches are the branches the changesets would be created on. Branch
+	// names that depend on the results of the steps can't be known in advance,
+	// so those are given as the template in changesetTemplate.branch.
+	Branches []string `json:"branches"`
+}
+
+type batchPlanOutput struct {
+	// Step is the number of the step, starting at 1.
+	Step   int    `json:"step"`
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	// Schema is the JSON schema the output is validated against, if it has
+	// one.
+	Schema interface{} `json:"schema,omitempty"`
+
+	// SchemaJSON is the schema for the human readable output.
+	SchemaJSON string `json:"-"`
+}
+
+type batchPlanChangesets struct {
+	// Cached is the number of changesets that result from cached results.
+	Cached int `json:"cached"`
+	// MaxExecuted is the maximum number of changesets that result from
+	// executing the steps. Workspaces in which the steps don't change
+	// anything don't result in a changeset.
+	MaxExecuted int `json:"maxExecuted"`
+	// Imported is the number of changesets that are imported.
+	Imported int `json:"imported"`
+}
+
+// planBatchSpec determines what executing the given batch spec would do. It
+// resolves the repositories, determines the workspaces and checks the cache,
+// but doesn't execute any steps or pull any images.
+func planBatchSpec(ctx context.Context, svc *service.Service, spec *batcheslib.BatchSpec, ext *specext.Extensions, cacheDir string, native bool) (*batchPlan, error) {
+	plan := &batchPlan{
+		Workspaces: []*batchPlanWorkspace{},
+		Skipped:    []string{},
+		Images:     []docker.ImageInfo{},
+		Outputs:    planOutputs(spec, ext),
+	}
+
+	repos, err := svc.ResolveRepositories(ctx, spec)
+	if err != nil {
+		if repoSet, ok := err.(batches.UnsupportedRepoSet); ok {
+			for repo := range repoSet {
+				plan.Skipped = append(plan.Skipped, repo.Name)
+			}
+		} else if repoSet, ok := err.(batches.IgnoredRepoSet); ok {
+			for repo := range repoSet {
+				plan.Skipped = append(plan.Skipped, repo.Name)
+			}
+		} else {
+			return nil, errors.Wrap(err, "resolving repositories")
+		}
+	}
+	sort.Strings(plan.Skipped)
+
+	workspaces, err := svc.DetermineWorkspaces(ctx, repos, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	coord := svc.NewCoordinator(executor.NewCoordinatorOpts{
+		CacheDir: cacheDir,
+		Cache:    executor.NewDiskCache(cacheDir),
+		Native:   native,
+	})
+
+	tasks, err := svc.BuildTasks(ctx, spec, ext, workspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := map[string]struct{}{}
+	for _, task := range tasks {
+		ws, err := planTask(ctx, coord, task)
+		if err != nil {
+			return nil, err
+		}
+		plan.Workspaces = append(plan.Workspaces, ws)
+
+		switch ws.Cache {
+		case batchPlanCacheHit:
+			plan.CachedCount++
+			plan.Changesets.Cached += ws.Changesets
+		case batchPlanCachePartial:
+			plan.PartialCount++
+			plan.Changesets.MaxExecuted += ws.Changesets
+		default:
+			plan.UncachedCount++
+			plan.Changesets.MaxExecuted += ws.Changesets
+		}
+
+		if len(ws.Repository) > plan.MaxRepoNameLen {
+			plan.MaxRepoNameLen = len(ws.Repository)
+		}
+
+		if ws.Cache != batchPlanCacheHit {
+			for _, step := range task.Steps {
+				containers[step.Container] = struct{}{}
+			}
+		}
+	}
+
+	for _, ic := range spec.ImportChangesets {
+		plan.Changesets.Imported += len(ic.ExternalIDs)
+	}
+
+	// Only the images of steps that will actually be executed are needed.
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	plan.PullSizeKnown = true
+	for _, name := range names {
+		info, err := docker.InspectImage(ctx, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "inspecting image %q", name)
+		}
+		plan.Images = append(plan.Images, info)
+
+		if !info.Local {
+			plan.PullCount++
+			plan.PullSize += info.Size
+			if info.Size == 0 {
+				plan.PullSizeKnown = false
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// planOutputs returns the outputs of the steps of the batch spec, ordered by
+// step and name.
+func planOutputs(spec *batcheslib.BatchSpec, ext *specext.Extensions) []batchPlanOutput {
+	outputs := []batchPlanOutput{}
+	schemas := ext.StepOutputSchemas()
+	for i, step := range spec.Steps {
+		names := make([]string, 0, len(step.Outputs))
+		for name := range step.Outputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			output := batchPlanOutput{
+				Step:   i + 1,
+				Name:   name,
+				Format: step.Outputs[name].Format,
+			}
+			if output.Format == "" {
+				output.Format = "text"
+			}
+			if i < len(schemas) && schemas[i][name] != nil {
+				output.Schema = schemas[i][name]
+				if data, err := json.Marshal(output.Schema); err == nil {
+					output.SchemaJSON = string(data)
+				}
+			}
+			outputs = append(outputs, output)
+		}
+	}
+	return outputs
+}
+
+// planTask checks the cache for the given Task and determines the changesets
+// it results in.
+func planTask(ctx context.Context, coord *executor.Coordinator, task *executor.Task) (*batchPlanWorkspace, error) {
+	ws := &batchPlanWorkspace{
+		Repository: task.Repository.Name,
+		BaseRef:    task.Repository.BaseRef(),
+		BaseRev:    task.Repository.Rev(),
+		Path:       task.Path,
+		Branches:   []string{},
+	}
+
+	uncached, specs, err := coord.CheckCache(ctx, []*executor.Task{task})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(uncached) == 0 {
+		ws.Cache = batchPlanCacheHit
+		ws.Changesets = len(specs)
+		for _, spec := range specs {
+			ws.Branches = append(ws.Branches, strings.TrimPrefix(spec.HeadRef, "refs/heads/"))
+		}
+		return ws, nil
+	}
+
+	ws.Cache = batchPlanCacheMiss
+	ws.Steps = len(task.Steps)
+	if task.CachedResultFound {
+		ws.Cache = batchPlanCachePartial
+		ws.Steps = len(task.Steps) - (task.CachedResult.StepIndex + 1)
+	}
+
+	if task.Template != nil {
+		ws.Branches = append(ws.Branches, task.Template.Branch)
+	}
+	if task.TransformChanges != nil {
+		for _, group := range task.TransformChanges.Group {
+			if group.Repository != "" && group.Repository != task.Repository.Name {
+				continue
+			}
+			ws.Branches = append(ws.Branches, group.Branch)
+		}
+	}
+	ws.Changesets = len(ws.Branches)
+
+	return ws, nil
+}
+
+const batchPlanTemplate = `
+{{- color "logo" -}}✱{{- color "nc" -}}
+{{- " " -}}
+{{- if eq (len .Workspaces) 0 -}}{{- color "warning" -}}{{- else -}}{{- color "success" -}}{{- end -}}
+{{- len .Workspaces }} workspace{{ if ne (len .Workspaces) 1 }}s{{ end }}{{- color "nc" -}}
+{{- " (" -}}{{ .CachedCount }} cached, {{ .PartialCount }} partially cached, {{ .UncachedCount }} uncached{{- ")\n" -}}
+
+{{- range .Workspaces -}}
+    {{- "  " -}}
+    {{- if eq .Cache "HIT" -}}{{- color "success" -}}{{- else -}}{{- color "warning" -}}{{- end -}}
+    {{- padRight .Cache 7 " " -}}{{- color "nc" -}}{{- " " -}}
+    {{- padRight .Repository $.MaxRepoNameLen " " -}}
+    {{- if ne .Path "" -}}{{ " " }}{{ color "search-filename" }}{{ .Path }}{{ color "nc" }}{{- end -}}
+    {{- if ne .Cache "HIT" -}}{{ color "search-border" }}{{ " (" }}{{ .Steps }} step{{ if ne .Steps 1 }}s{{ end }} to execute){{ color "nc" }}{{- end -}}
+    {{- "\n" -}}
+    {{- range .Branches -}}
+        {{- "          " -}}{{ color "search-branch" }}{{ . }}{{ color "nc" }}{{- "\n" -}}
+    {{- end -}}
+{{- end -}}
+
+{{- if ne (len .Skipped) 0 -}}
+    {{- "\n" -}}{{- color "warning" -}}{{- len .Skipped }} skipped repositor{{ if eq (len .Skipped) 1 }}y{{ else }}ies{{ end }}:{{- color "nc" -}}{{- "\n" -}}
+    {{- range .Skipped -}}{{- "  " -}}{{ . }}{{- "\n" -}}{{- end -}}
+{{- end -}}
+
+{{- "\n" -}}
+{{- color "logo" -}}✱{{- color "nc" -}}
+{{- " " -}}{{ len .Images }} container image{{ if ne (len .Images) 1 }}s{{ end }}
+{{- if ne .PullCount 0 -}}
+    {{- ", " -}}{{ color "warning" }}{{ .PullCount }} to pull{{- if ne .PullSize 0 -}}{{- " (" -}}{{ if not .PullSizeKnown }}at least {{ end }}{{ humanizeBytes .PullSize }}){{- end -}}{{ color "nc" }}
+{{- end -}}
+{{- "\n" -}}
+{{- range .Images -}}
+    {{- "  " -}}
+    {{- if .Local -}}{{- color "success" -}}{{ padRight "local" 5 " " }}{{- else -}}{{- color "warning" -}}{{ padRight "pull" 5 " " }}{{- end -}}{{- color "nc" -}}
+    {{- " " -}}{{ .Name }}
+    {{- if ne .Size 0 }} {{ color "search-border" }}({{ humanizeBytes .Size }}){{ color "nc" }}{{- end -}}
+    {{- "\n" -}}
+{{- end -}}
+
+{{- if ne (len .Outputs) 0 -}}
+    {{- "\n" -}}
+    {{- color "logo" -}}✱{{- color "nc" -}}
+    {{- " " -}}{{ len .Outputs }} step output{{ if ne (len .Outputs) 1 }}s{{ end }}{{- "\n" -}}
+    {{- range .Outputs -}}
+        {{- "  " -}}step {{ .Step }} {{ .Name }} {{ color "search-border" }}({{ .Format }}){{ color "nc" }}
+        {{- if ne .SchemaJSON "" }} {{ .SchemaJSON }}{{- end -}}
+        {{- "\n" -}}
+    {{- end -}}
+{{- end -}}
+
+{{- "\n" -}}
+{{- color "logo" -}}✱{{- color "nc" -}}
+{{- " " -}}{{ .Changesets.Cached }} changeset{{ if ne .Changesets.Cached 1 }}s{{ end }} from cached results
+{{- ", up to " -}}{{ .Changesets.MaxExecuted }} from executing steps
+{{- if ne .Changesets.Imported 0 -}}, {{ .Changesets.Imported }} imported{{- end -}}
+`
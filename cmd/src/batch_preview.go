@@ -14,7 +14,14 @@ import (
 func init() {
 	usage := `
 'src batch preview' executes the steps in a batch spec and uploads it to a
-Sourcegraph instance, ready to be previewed and applied.
+Sourcegraph instance, ready to be previewed and applied. It shows what
+applying the batch spec would do to each changeset: whether it would be
+created, have new commits pushed, be updated, closed, reopened or detached.
+
+With -repo or -workspace-path, only the changeset specs of the matching
+workspaces are uploaded, so the preview shows the other changesets of an
+existing batch change as detached or closed. They're meant for trying out the
+steps on a single repository.
 
 Usage:
 
@@ -24,10 +31,19 @@ Examples:
 
     $ src batch preview -f batch.spec.yaml
 
+    $ src batch preview -f batch.spec.yaml -diff
+
+    $ src batch preview -f batch.spec.yaml -repo github.com/sourcegraph/src-cli -stream-output
+
+    $ src batch preview -f batch.spec.yaml -serve :8080
+
 `
 
 	flagSet := flag.NewFlagSet("preview", flag.ExitOnError)
 	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+	addBatchTaskFilterFlags(flagSet, flags)
+	addBatchServeFlag(flagSet, flags)
+	diffFlag := flagSet.Bool("diff", false, "Show the diffs of the changesets that would be created or pushed to.")
 
 	handler := func(args []string) error {
 		if err := flagSet.Parse(args); err != nil {
@@ -46,7 +62,7 @@ Examples:
 			execUI = &ui.JSONLines{}
 		} else {
 			out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
-			execUI = &ui.TUI{Out: out}
+			execUI = &ui.TUI{Out: out, StreamOutput: flags.streamOutput}
 		}
 
 		err := executeBatchSpec(ctx, execUI, executeBatchSpecOpts{
@@ -55,6 +71,7 @@ Examples:
 
 			// Do not apply the uploaded batch spec
 			applyBatchSpec: false,
+			showDiffs:      *diffFlag,
 		})
 		if err != nil {
 			return cmderrors.ExitCode(1, nil)
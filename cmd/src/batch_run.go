@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+
+	"github.com/sourcegraph/sourcegraph/lib/output"
+)
+
+func init() {
+	usage := `
+'src batch run' executes the steps in a batch spec and writes the resulting
+diffs to a directory, instead of uploading them to a Sourcegraph instance.
+
+For every changeset, a .patch file is written, together with a manifest.json
+that contains the rendered changeset template of each changeset: its
+repository, branch, title, body and commit message. Apart from resolving the
+repositories and downloading their archives, nothing is sent to or requested
+from Sourcegraph.
+
+Usage:
+
+    src batch run -f FILE -out DIR [command options]
+
+Examples:
+
+    $ src batch run -f batch.spec.yaml -out ./patches
+
+  Execute the steps only in one workspace of a repository and show their
+  output while they run:
+
+    $ src batch run -f batch.spec.yaml -out ./patches -repo github.com/sourcegraph/sourcegraph@main -workspace-path client/web -stream-output
+
+  Serve the state of the execution, so that it can be followed in a browser:
+
+    $ src batch run -f batch.spec.yaml -out ./patches -serve :8080
+
+`
+
+	flagSet := flag.NewFlagSet("run", flag.ExitOnError)
+	flags := newBatchExecuteFlags(flagSet, false, batchDefaultCacheDir(), batchDefaultTempDirPrefix())
+	addBatchTaskFilterFlags(flagSet, flags)
+	addBatchServeFlag(flagSet, flags)
+	outFlag := flagSet.String("out", "", "The directory to write the patches and the manifest to. Required.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		if *outFlag == "" {
+			return cmderrors.Usage("an output directory must be given with -out")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		var execUI ui.ExecUI
+		if flags.textOnly {
+			execUI = &ui.JSONLines{}
+		} else {
+			out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+			execUI = &ui.TUI{Out: out, StreamOutput: flags.streamOutput}
+		}
+
+		err := executeBatchSpec(ctx, execUI, executeBatchSpecOpts{
+			flags:  flags,
+			client: cfg.apiClient(flags.api, flagSet.Output()),
+
+			outDir: *outFlag,
+		})
+		if err != nil {
+			return cmderrors.ExitCode(1, nil)
+		}
+
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+	"github.com/sourcegraph/src-cli/internal/batches/watch"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// Exit codes of 'src batch watch' with -until.
+const (
+	batchWatchUnreachableExitCode = 2
+	batchWatchTimeoutExitCode     = 3
+)
+
+func init() {
+	conditions := make([]string, 0, len(watch.Conditions))
+	for _, c := range watch.Conditions {
+		conditions = append(conditions, fmt.Sprintf("%q", c))
+	}
+
+	usage := `
+'src batch watch' polls a batch change and shows the state of its changesets
+until it's interrupted: how many of them are published, have passing checks,
+are approved and are merged, how many are in each state, which are failing,
+and what changed since the last poll.
+
+With -until, it stops once all changesets reach a state, which makes it
+usable in CI pipelines. The exit code is then:
+
+    0   the condition is met
+    1   an error occurred
+    2   the condition can no longer be met, because a changeset failed to be
+        published, or was closed or deleted before it was merged
+    3   the condition wasn't met within -timeout
+
+Usage:
+
+    src batch watch [command options] NAME
+
+Examples:
+
+  Watch a batch change of the current user:
+
+    $ src batch watch hello-world
+
+  Wait until all changesets of a batch change are published, for at most 30
+  minutes:
+
+    $ src batch apply -f batch.spec.yaml && src batch watch -until=all-published -timeout=30m hello-world
+
+`
+
+	flagSet := flag.NewFlagSet("watch", flag.ExitOnError)
+	var (
+		namespaceFlag = flagSet.String("namespace", "", "The user or organization of the batch change. Default is the current user.")
+		untilFlag     = flagSet.String("until", "", "Stop once all changesets meet this condition: "+strings.Join(conditions, " or ")+".")
+		intervalFlag  = flagSet.Duration("interval", 10*time.Second, "How often to poll the batch change.")
+		timeoutFlag   = flagSet.Duration("timeout", 0, "Stop with exit code 3 if the -until condition isn't met within this time. (default no timeout)")
+		apiFlags      = api.NewFlags(flagSet)
+	)
+	flagSet.StringVar(namespaceFlag, "n", "", "Alias for -namespace.")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 1 {
+			return cmderrors.Usage("expected the name of a batch change")
+		}
+
+		var until watch.Condition
+		if *untilFlag != "" {
+			var err error
+			if until, err = watch.ParseCondition(*untilFlag); err != nil {
+				return cmderrors.Usagef("invalid -until: %s", err)
+			}
+		}
+		if *timeoutFlag != 0 && until == "" {
+			return cmderrors.Usage("-timeout can only be used with -until")
+		}
+		if *intervalFlag <= 0 {
+			return cmderrors.Usage("-interval must be positive")
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		var deadline <-chan time.Time
+		if *timeoutFlag != 0 {
+			timer := time.NewTimer(*timeoutFlag)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		svc := service.New(&service.Opts{Client: cfg.apiClient(apiFlags, flagSet.Output())})
+		if err := svc.DetermineFeatureFlags(ctx); err != nil {
+			return err
+		}
+
+		batchChange, err := svc.GetBatchChange(ctx, *namespaceFlag, flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		out := output.NewOutput(flagSet.Output(), output.OutputOpts{Verbose: *verbose})
+		out.WriteLine(output.Linef(output.EmojiInfo, output.StyleReset, "Watching %s/%s: %s%s", batchChange.Namespace.NamespaceName, batchChange.Name, cfg.Endpoint, batchChange.URL))
+
+		watchUI := &ui.WatchTUI{Out: out}
+		defer watchUI.Done()
+
+		var previous []*graphql.Changeset
+		for first := true; ; first = false {
+			changesets, err := svc.ListChangesets(ctx, graphql.ListChangesetsOpts{BatchChange: batchChange.ID}, "")
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return nil
+				}
+				return err
+			}
+
+			var transitions []watch.Transition
+			if !first {
+				transitions = watch.Diff(previous, changesets)
+			}
+			watchUI.Update(changesets, transitions)
+			previous = changesets
+
+			if until != "" {
+				met, err := until.Check(changesets)
+				if err != nil {
+					watchUI.Done()
+					out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "%s: %s", until, err))
+					return cmderrors.ExitCode(batchWatchUnreachableExitCode, nil)
+				}
+				if met {
+					watchUI.Done()
+					out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess, "All changesets are %s.", strings.TrimPrefix(string(until), "all-")))
+					return nil
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-deadline:
+				watchUI.Done()
+				out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "%s wasn't met within %s.", until, *timeoutFlag))
+				return cmderrors.ExitCode(batchWatchTimeoutExitCode, nil)
+			case <-time.After(*intervalFlag):
+			}
+		}
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
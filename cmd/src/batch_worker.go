@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+'src batch worker' starts a worker that executes the steps of batch specs on
+behalf of 'src batch preview' and 'src batch apply' running on other machines.
+
+The worker downloads the repository archives from the Sourcegraph instance it
+is configured for, so it needs to be able to access the same instance as the
+machine that uses it.
+
+Usage:
+
+    src batch worker [command options]
+
+Examples:
+
+  Start a worker that executes 8 tasks in parallel:
+
+    $ SRC_BATCH_WORKER_TOKEN=secret src batch worker -addr :9091 -j 8
+
+  Use the worker, and another one, to execute a batch spec:
+
+    $ SRC_BATCH_WORKER_TOKEN=secret src batch preview -f batch.spec.yaml -workers host1:9091,host2:9091
+
+`
+
+	flagSet := flag.NewFlagSet("worker", flag.ExitOnError)
+	var (
+		apiFlags      = api.NewFlags(flagSet)
+		addrFlag      = flagSet.String("addr", "127.0.0.1:9091", "The address to listen on.")
+		tokenFlag     = flagSet.String("token", os.Getenv("SRC_BATCH_WORKER_TOKEN"), "The token coordinators have to send to use this worker. Required when not listening on a loopback address. Can also be set with the environment variable SRC_BATCH_WORKER_TOKEN.")
+		cacheDirFlag  = flagSet.String("cache", batchDefaultCacheDir(), "Directory for caching repository archives.")
+		tempDirFlag   = flagSet.String("tmp", batchDefaultTempDirPrefix(), "Directory for storing temporary data, such as log files.")
+		parallelFlag  = flagSet.Int("j", runtime.GOMAXPROCS(0), "The maximum number of tasks executed in parallel. Default is GOMAXPROCS.")
+		timeoutFlag   = flagSet.Duration("timeout", 60*time.Minute, "The maximum duration a single batch spec step can take.")
+		cleanFlag     = flagSet.Bool("clean-archives", true, "If true, deletes downloaded repository archives after executing batch spec steps.")
+		keepLogsFlag  = flagSet.Bool("keep-logs", false, "Retain logs after executing steps.")
+		workspaceFlag = flagSet.String("workspace", "auto", `Workspace mode to use ("auto", "bind", "volume", or "native").`)
+		nativeFlag    = flagSet.String("native-allow-commands", "", `Comma-separated list of commands that steps may run in the "native" workspace mode.`)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		if len(flagSet.Args()) != 0 {
+			return cmderrors.Usage("additional arguments not allowed")
+		}
+
+		if *tokenFlag == "" && !isLoopbackAddr(*addrFlag) {
+			return cmderrors.Usage("a -token is required when listening on a non-loopback address, since workers execute arbitrary commands")
+		}
+
+		if err := checkExecutable("git", "version"); err != nil {
+			return err
+		}
+		if *workspaceFlag != "native" {
+			if err := checkExecutable("docker", "version"); err != nil {
+				return err
+			}
+		}
+
+		ctx, cancel := contextCancelOnInterrupt(context.Background())
+		defer cancel()
+
+		svc := service.New(&service.Opts{
+			AllowFiles: true,
+			Client:     cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+
+		logf := func(format string, args ...interface{}) {
+			fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+		}
+
+		worker := svc.NewWorker(executor.NewWorkerOpts{
+			Logger: log.NewManager(*tempDirFlag, *keepLogsFlag),
+			PrepareCreator: func(ctx context.Context, steps []batcheslib.Step) (workspace.Creator, error) {
+				if *workspaceFlag == "native" {
+					return workspace.NewNativeCreator(*cacheDirFlag, strings.Split(*nativeFlag, ",")), nil
+				}
+
+				images, err := svc.EnsureDockerImages(ctx, steps, func(done, total int) {})
+				if err != nil {
+					return nil, err
+				}
+				creator := workspace.NewCreator(ctx, *workspaceFlag, *cacheDirFlag, *tempDirFlag, images)
+				if creator.Type() == workspace.CreatorTypeVolume {
+					if _, err := svc.EnsureImage(ctx, workspace.DockerVolumeWorkspaceImage); err != nil {
+						return nil, err
+					}
+				}
+				return creator, nil
+			},
+
+			Parallelism: *parallelFlag,
+			Timeout:     *timeoutFlag,
+			TempDir:     *tempDirFlag,
+			Token:       *tokenFlag,
+			Logf:        logf,
+		}, *cacheDirFlag, *cleanFlag)
+
+		server := &http.Server{Addr: *addrFlag, Handler: worker}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		logf("Listening on %s, executing up to %d tasks in parallel", *addrFlag, *parallelFlag)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		return nil
+	}
+
+	batchCommands = append(batchCommands, &command{
+		flagSet: flagSet,
+		handler: handler,
+		usageFunc: func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch %s':\n", flagSet.Name())
+			flagSet.PrintDefaults()
+			fmt.Println(usage)
+		},
+	})
+}
+
+// isLoopbackAddr returns true if the given listen address only accepts
+// connections from the local machine.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -15,7 +15,8 @@ import (
 func parseTemplate(text string) (*template.Template, error) {
 	tmpl := template.New("")
 	tmpl.Funcs(map[string]interface{}{
-		"join": strings.Join,
+		"join":  strings.Join,
+		"lower": strings.ToLower,
 		"json": func(v interface{}) (string, error) {
 			b, err := marshalIndent(v)
 			return string(b), err
@@ -83,6 +84,9 @@ func parseTemplate(text string) (*template.Template, error) {
 			}
 			return humanize.Time(t), nil
 		},
+		"humanizeBytes": func(n int64) string {
+			return humanize.Bytes(uint64(n))
+		},
 
 		// Register search-specific template functions
 		"searchSequentialLineNumber":        searchTemplateFuncs["searchSequentialLineNumber"],
@@ -0,0 +1,278 @@
+// Package dashboard serves the state of the execution of a batch spec over
+// HTTP, as an HTML page and a JSON and server-sent events API, so that it can
+// be followed from a browser.
+package dashboard
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+// State is the state of the execution.
+type State struct {
+	// Title is the title of the dashboard, which is the batch spec file.
+	Title string `json:"title"`
+	// Phase is what src is doing, like "Executing tasks".
+	Phase string `json:"phase"`
+	// Done is whether src is done, in which case Error is the error it failed
+	// with, if any.
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+	// CachedSpecs is the number of changeset specs found in the cache.
+	CachedSpecs int `json:"cachedSpecs"`
+	// URL is the URL of the batch spec or batch change on Sourcegraph, once
+	// it's known.
+	URL string `json:"url,omitempty"`
+
+	Progress Progress `json:"progress"`
+	Tasks    []*Task  `json:"tasks"`
+}
+
+// Progress counts the tasks that are executed.
+type Progress struct {
+	Total     int `json:"total"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// Status is the status of a task or a step.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusPreparing Status = "preparing"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+	StatusCached    Status = "cached"
+)
+
+// Task is a task that is executed.
+type Task struct {
+	// ID identifies the task in the API.
+	ID         int        `json:"id"`
+	Repository string     `json:"repository"`
+	Path       string     `json:"path,omitempty"`
+	Status     Status     `json:"status"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Steps      []*Step    `json:"steps"`
+	// Diffs are the diffs of the changeset specs built from the result of
+	// the task.
+	Diffs   []string `json:"diffs,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	HasLog  bool     `json:"hasLog"`
+	logFile string
+}
+
+// Step is a step of a task.
+type Step struct {
+	Step     int    `json:"step"`
+	Status   Status `json:"status"`
+	Run      string `json:"run,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Diff     string `json:"diff,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Output are the last lines of the standard output and standard error of
+	// the step, prefixed with the stream.
+	Output []string `json:"output,omitempty"`
+}
+
+// maxOutputLines is the number of lines of the output of a step that are
+// kept. The complete output is in the log of the task.
+const maxOutputLines = 200
+
+// Dashboard keeps the state of the execution, which it gets from the events
+// the UIs it wraps receive, and serves it. See Handler.
+type Dashboard struct {
+	mu    sync.Mutex
+	state State
+	tasks map[*executor.Task]*Task
+
+	subscribers map[chan struct{}]struct{}
+}
+
+func New(title string) *Dashboard {
+	return &Dashboard{
+		state:       State{Title: title, Phase: "Starting", Tasks: []*Task{}},
+		tasks:       make(map[*executor.Task]*Task),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Finish marks the execution as done, having failed with err if it's not
+// nil.
+func (d *Dashboard) Finish(err error) {
+	d.update(func(s *State) {
+		s.Done = true
+		s.Phase = "Done"
+		if err != nil {
+			s.Phase = "Failed"
+			s.Error = err.Error()
+		}
+	})
+}
+
+// update changes the state with fn and notifies the subscribers.
+func (d *Dashboard) update(fn func(s *State)) {
+	d.mu.Lock()
+	fn(&d.state)
+	d.state.Progress = progress(d.state.Tasks)
+	for ch := range d.subscribers {
+		// A subscriber that wasn't notified yet gets the latest state anyway.
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	d.mu.Unlock()
+}
+
+func (d *Dashboard) setPhase(phase string) {
+	d.update(func(s *State) { s.Phase = phase })
+}
+
+// updateTask changes the dashboard task of the given task with fn, if it's
+// known.
+func (d *Dashboard) updateTask(task *executor.Task, fn func(t *Task)) {
+	d.update(func(s *State) {
+		if t, ok := d.tasks[task]; ok {
+			fn(t)
+		}
+	})
+}
+
+// updateStep changes the given step (1-based) of the task with fn.
+func (d *Dashboard) updateStep(task *executor.Task, step int, fn func(s *Step)) {
+	d.updateTask(task, func(t *Task) {
+		if step >= 1 && step <= len(t.Steps) {
+			fn(t.Steps[step-1])
+		}
+	})
+}
+
+func (d *Dashboard) start(tasks []*executor.Task) {
+	d.update(func(s *State) {
+		for _, task := range tasks {
+			t, ok := d.tasks[task]
+			if !ok {
+				t = &Task{ID: len(s.Tasks) + 1, Repository: task.Repository.Name, Path: task.Path}
+				d.tasks[task] = t
+				s.Tasks = append(s.Tasks, t)
+			}
+			// Tasks are started again when they're executed again.
+			t.Status = StatusPending
+			t.StartedAt, t.FinishedAt = nil, nil
+			t.Diffs, t.Error = nil, ""
+			t.Steps = make([]*Step, len(task.Steps))
+			for i := range task.Steps {
+				t.Steps[i] = &Step{Step: i + 1, Status: StatusPending}
+			}
+		}
+	})
+}
+
+func (d *Dashboard) taskStarted(task *executor.Task) {
+	now := time.Now()
+	d.updateTask(task, func(t *Task) {
+		t.Status = StatusRunning
+		t.StartedAt = &now
+	})
+}
+
+func (d *Dashboard) taskFinished(task *executor.Task, err error) {
+	now := time.Now()
+	d.updateTask(task, func(t *Task) {
+		t.FinishedAt = &now
+		t.logFile = task.LogFile
+		t.HasLog = t.logFile != ""
+		if err == nil {
+			t.Status = StatusSucceeded
+			return
+		}
+		t.Status = StatusFailed
+		t.Error = err.Error()
+	})
+}
+
+func (d *Dashboard) changesetSpecsBuilt(task *executor.Task, specs []*batcheslib.ChangesetSpec) {
+	d.updateTask(task, func(t *Task) {
+		for _, spec := range specs {
+			for _, commit := range spec.Commits {
+				t.Diffs = append(t.Diffs, commit.Diff)
+			}
+		}
+	})
+}
+
+func (d *Dashboard) stepOutput(task *executor.Task, step int, stream string, p []byte) {
+	lines := strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")
+	d.updateStep(task, step, func(s *Step) {
+		for _, line := range lines {
+			s.Output = append(s.Output, stream+" | "+line)
+		}
+		if len(s.Output) > maxOutputLines {
+			s.Output = s.Output[len(s.Output)-maxOutputLines:]
+		}
+	})
+}
+
+// marshalState returns the state as JSON.
+func (d *Dashboard) marshalState() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return json.Marshal(d.state)
+}
+
+// logFile returns the log file of the task with the given ID.
+func (d *Dashboard) logFile(id int) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, t := range d.state.Tasks {
+		if t.ID == id {
+			return t.logFile, t.logFile != ""
+		}
+	}
+	return "", false
+}
+
+// subscribe returns a channel that receives a value when the state changes,
+// and a function that unsubscribes it.
+func (d *Dashboard) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	d.mu.Lock()
+	d.subscribers[ch] = struct{}{}
+	d.mu.Unlock()
+
+	return ch, func() {
+		d.mu.Lock()
+		delete(d.subscribers, ch)
+		d.mu.Unlock()
+	}
+}
+
+func progress(tasks []*Task) Progress {
+	p := Progress{Total: len(tasks)}
+	for _, t := range tasks {
+		switch t.Status {
+		case StatusRunning:
+			p.Running++
+		case StatusSucceeded:
+			p.Succeeded++
+		case StatusFailed:
+			p.Failed++
+		}
+	}
+	return p
+}
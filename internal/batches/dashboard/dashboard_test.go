@@ -0,0 +1,195 @@
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestDashboard(t *testing.T) {
+	newTask := func(repo string, steps int) *executor.Task {
+		return &executor.Task{
+			Repository: &graphql.Repository{Name: repo},
+			Steps:      make([]batcheslib.Step, steps),
+		}
+	}
+	succeeded := newTask("github.com/sourcegraph/succeeded", 2)
+	succeeded.Path = "docs"
+	failed := newTask("github.com/sourcegraph/failed", 1)
+	failed.LogFile = filepath.Join(t.TempDir(), "failed.log")
+	if err := os.WriteFile(failed.LogFile, []byte("the log"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := New("batch.spec.yaml")
+	ui := &taskExecUIWrapper{TaskExecutionUI: noopTaskExecutionUI{}, d: d}
+	ui.Start([]*executor.Task{succeeded, failed})
+
+	ui.TaskStarted(succeeded)
+	steps := ui.StepsExecutionUI(succeeded)
+	steps.SkippingStepsUpto(2)
+	steps.StepStarted(2, "echo hello", nil)
+	fmt.Fprint(steps.StepOutputWriter(context.Background(), succeeded, 2).StdoutWriter(), "hello\nworld\n")
+	steps.StepFinished(2, "the diff", nil, nil)
+	ui.TaskChangesetSpecsBuilt(succeeded, []*batcheslib.ChangesetSpec{
+		{Commits: []batcheslib.GitCommitDescription{{Diff: "the diff"}}},
+	})
+	ui.TaskFinished(succeeded, nil)
+
+	ui.TaskStarted(failed)
+	steps = ui.StepsExecutionUI(failed)
+	steps.StepStarted(1, "exit 1", nil)
+	steps.StepFailed(1, errors.New("exit status 1"), 1)
+	ui.TaskFinished(failed, errors.New("step 1 failed"))
+
+	d.Finish(nil)
+
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	var state State
+	getJSON(t, srv.URL+"/api/state", &state)
+	for _, task := range state.Tasks {
+		task.StartedAt, task.FinishedAt = nil, nil
+	}
+
+	zero, one := 0, 1
+	want := State{
+		Title:    "batch.spec.yaml",
+		Phase:    "Done",
+		Done:     true,
+		Progress: Progress{Total: 2, Succeeded: 1, Failed: 1},
+		Tasks: []*Task{
+			{
+				ID:         1,
+				Repository: "github.com/sourcegraph/succeeded",
+				Path:       "docs",
+				Status:     StatusSucceeded,
+				Steps: []*Step{
+					{Step: 1, Status: StatusCached},
+					{
+						Step:     2,
+						Status:   StatusSucceeded,
+						Run:      "echo hello",
+						ExitCode: &zero,
+						Diff:     "the diff",
+						Output:   []string{"stdout | hello", "stdout | world"},
+					},
+				},
+				Diffs: []string{"the diff"},
+			},
+			{
+				ID:         2,
+				Repository: "github.com/sourcegraph/failed",
+				Status:     StatusFailed,
+				Steps: []*Step{
+					{Step: 1, Status: StatusFailed, Run: "exit 1", ExitCode: &one, Error: "exit status 1"},
+				},
+				Error:  "step 1 failed",
+				HasLog: true,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, state, cmp.AllowUnexported(Task{})); diff != "" {
+		t.Errorf("wrong state (-want +got):\n%s", diff)
+	}
+
+	for path, wantStatus := range map[string]int{
+		"/api/tasks/2/log": http.StatusOK,
+		"/api/tasks/1/log": http.StatusNotFound,
+		"/api/tasks/3/log": http.StatusNotFound,
+		"/api/tasks/x/log": http.StatusNotFound,
+		"/unknown":         http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Errorf("GET %s: wrong status. want=%d, have=%d", path, wantStatus, resp.StatusCode)
+		}
+	}
+}
+
+func TestDashboard_Events(t *testing.T) {
+	d := New("batch.spec.yaml")
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	states := make(chan State)
+	go func() {
+		defer close(states)
+		s := bufio.NewScanner(resp.Body)
+		for s.Scan() {
+			data := strings.TrimPrefix(s.Text(), "data: ")
+			if data == s.Text() {
+				continue
+			}
+			var state State
+			if err := json.Unmarshal([]byte(data), &state); err != nil {
+				t.Error(err)
+				return
+			}
+			states <- state
+		}
+	}()
+
+	if state := <-states; state.Phase != "Starting" {
+		t.Fatalf("wrong phase of the first state: %q", state.Phase)
+	}
+
+	d.setPhase("Executing tasks")
+	if state := <-states; state.Phase != "Executing tasks" {
+		t.Fatalf("wrong phase of the second state: %q", state.Phase)
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: wrong status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type noopTaskExecutionUI struct{}
+
+func (noopTaskExecutionUI) Start([]*executor.Task)                                              {}
+func (noopTaskExecutionUI) Success()                                                            {}
+func (noopTaskExecutionUI) Failed(error)                                                        {}
+func (noopTaskExecutionUI) TaskStarted(*executor.Task)                                          {}
+func (noopTaskExecutionUI) TaskFinished(*executor.Task, error)                                  {}
+func (noopTaskExecutionUI) TaskChangesetSpecsBuilt(*executor.Task, []*batcheslib.ChangesetSpec) {}
+func (noopTaskExecutionUI) StepsExecutionUI(*executor.Task) executor.StepsExecutionUI {
+	return executor.NoopStepsExecUI{}
+}
@@ -0,0 +1,226 @@
+package dashboard
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// eventInterval is the minimum interval at which the state is sent to the
+// clients of the event stream, so that the output of busy steps doesn't
+// flood them.
+const eventInterval = 250 * time.Millisecond
+
+// Handler returns the HTTP handler of the dashboard, which serves:
+//
+//	/                      the HTML view of the execution
+//	/api/state             the State as JSON
+//	/api/events            the State as server-sent "state" events, sent
+//	                       whenever it changes
+//	/api/tasks/ID/log      the log file of the task with the ID
+func (d *Dashboard) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.serveIndex)
+	mux.HandleFunc("/api/state", d.serveState)
+	mux.HandleFunc("/api/events", d.serveEvents)
+	mux.HandleFunc("/api/tasks/", d.serveTaskLog)
+	return mux
+}
+
+// Serve serves the dashboard on the given address, like ":8080", in the
+// background. It returns the URL of the dashboard and a function that stops
+// serving it.
+func (d *Dashboard) Serve(addr string) (string, func() error, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "listening on %s", addr)
+	}
+
+	srv := &http.Server{Handler: d.Handler()}
+	go srv.Serve(l)
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		srv.Close()
+		return "", nil, err
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port), srv.Close, nil
+}
+
+func (d *Dashboard) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, indexHTML)
+}
+
+func (d *Dashboard) serveState(w http.ResponseWriter, r *http.Request) {
+	data, err := d.marshalState()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (d *Dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+
+	changed, unsubscribe := d.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for {
+		data, err := d.marshalState()
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: state\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(eventInterval):
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+func (d *Dashboard) serveTaskLog(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
+	idStr := strings.TrimSuffix(rest, "/log")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || idStr == rest {
+		http.NotFound(w, r)
+		return
+	}
+
+	path, ok := d.logFile(id)
+	if !ok {
+		http.Error(w, "the task has no log file", http.StatusNotFound)
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// Log files of tasks that succeeded are removed, unless logs are
+		// kept.
+		http.Error(w, "the log file was removed", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(content)
+}
+
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>src batch</title>
+<style>
+  body { font-family: sans-serif; margin: 2em; }
+  table { border-collapse: collapse; width: 100%; }
+  td, th { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+  pre { background: #f5f5f5; padding: 0.5em; overflow-x: auto; max-height: 30em; }
+  .status { font-weight: bold; }
+  .succeeded { color: #2a7d2a; } .failed { color: #c0392b; } .running, .preparing { color: #2266bb; }
+  .pending, .skipped, .cached { color: #888; }
+  tr.task { cursor: pointer; }
+</style>
+</head>
+<body>
+<h1 id="title">src batch</h1>
+<p><span id="phase"></span> <span id="progress"></span> <a id="url"></a></p>
+<p id="error" class="failed"></p>
+<table>
+  <thead><tr><th>Repository</th><th>Path</th><th>Status</th><th>Steps</th></tr></thead>
+  <tbody id="tasks"></tbody>
+</table>
+<script>
+const expanded = new Set();
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  Object.assign(e, attrs);
+  for (const c of children) e.append(c);
+  return e;
+}
+
+function details(task) {
+  const td = el("td", {colSpan: 4});
+  for (const step of task.steps) {
+    td.append(el("h4", {}, "Step " + step.step + ": ", el("span", {className: "status " + step.status}, step.status),
+      step.exitCode !== undefined ? " (exit code " + step.exitCode + ")" : ""));
+    if (step.error) td.append(el("p", {className: "failed"}, step.error));
+    if (step.run) td.append(el("pre", {}, step.run));
+    if (step.output) td.append(el("pre", {}, step.output.join("\n")));
+    if (step.diff) td.append(el("pre", {}, step.diff));
+  }
+  for (const diff of task.diffs || []) td.append(el("h4", {}, "Diff"), el("pre", {}, diff));
+  if (task.error) td.append(el("pre", {className: "failed"}, task.error));
+  if (task.hasLog) td.append(el("a", {href: "/api/tasks/" + task.id + "/log"}, "Log"));
+  return el("tr", {}, td);
+}
+
+function render(state) {
+  document.title = "src batch: " + state.title;
+  document.getElementById("title").textContent = state.title;
+  document.getElementById("phase").textContent = state.phase + ".";
+  const p = state.progress;
+  document.getElementById("progress").textContent = p.total ?
+    p.succeeded + " of " + p.total + " tasks succeeded, " + p.failed + " failed, " + p.running + " running." : "";
+  const url = document.getElementById("url");
+  url.href = state.url || "";
+  url.textContent = state.url || "";
+  document.getElementById("error").textContent = state.error || "";
+
+  const tbody = document.getElementById("tasks");
+  tbody.replaceChildren();
+  for (const task of state.tasks) {
+    const row = el("tr", {className: "task", onclick: () => {
+      expanded.has(task.id) ? expanded.delete(task.id) : expanded.add(task.id);
+      render(state);
+    }},
+      el("td", {}, task.repository),
+      el("td", {}, task.path || ""),
+      el("td", {className: "status " + task.status}, task.status),
+      el("td", {}, ...task.steps.map(s => el("span", {className: s.status, title: "Step " + s.step + ": " + s.status}, "● "))));
+    tbody.append(row);
+    if (expanded.has(task.id)) tbody.append(details(task));
+  }
+}
+
+const events = new EventSource("/api/events");
+events.addEventListener("state", e => {
+  const state = JSON.parse(e.data);
+  render(state);
+  if (state.done) events.close();
+});
+</script>
+</body>
+</html>
+`
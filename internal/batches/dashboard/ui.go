@@ -0,0 +1,219 @@
+package dashboard
+
+import (
+	"context"
+	"io"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/ui"
+)
+
+// UI returns an ExecUI that updates the dashboard with the events it
+// receives and passes them on to the given UI.
+func (d *Dashboard) UI(execUI ui.ExecUI) ui.ExecUI {
+	return &execUIWrapper{ExecUI: execUI, d: d}
+}
+
+type execUIWrapper struct {
+	ui.ExecUI
+	d *Dashboard
+}
+
+func (ui *execUIWrapper) ParsingBatchSpec() {
+	ui.d.setPhase("Parsing batch spec")
+	ui.ExecUI.ParsingBatchSpec()
+}
+
+func (ui *execUIWrapper) ResolvingNamespace() {
+	ui.d.setPhase("Resolving namespace")
+	ui.ExecUI.ResolvingNamespace()
+}
+
+func (ui *execUIWrapper) PreparingContainerImages() {
+	ui.d.setPhase("Preparing container images")
+	ui.ExecUI.PreparingContainerImages()
+}
+
+func (ui *execUIWrapper) ResolvingRepositories() {
+	ui.d.setPhase("Resolving repositories")
+	ui.ExecUI.ResolvingRepositories()
+}
+
+func (ui *execUIWrapper) DeterminingWorkspaces() {
+	ui.d.setPhase("Determining workspaces")
+	ui.ExecUI.DeterminingWorkspaces()
+}
+
+func (ui *execUIWrapper) CheckingCache() {
+	ui.d.setPhase("Checking cache")
+	ui.ExecUI.CheckingCache()
+}
+
+func (ui *execUIWrapper) CheckingCacheSuccess(cachedSpecsFound int, tasksToExecute int) {
+	ui.d.update(func(s *State) { s.CachedSpecs = cachedSpecsFound })
+	ui.ExecUI.CheckingCacheSuccess(cachedSpecsFound, tasksToExecute)
+}
+
+func (ui *execUIWrapper) ExecutingTasks(verbose bool, parallelism int) executor.TaskExecutionUI {
+	ui.d.setPhase("Executing tasks")
+	return &taskExecUIWrapper{TaskExecutionUI: ui.ExecUI.ExecutingTasks(verbose, parallelism), d: ui.d}
+}
+
+func (ui *execUIWrapper) UploadingChangesetSpecs(num int) {
+	ui.d.setPhase("Uploading changeset specs")
+	ui.ExecUI.UploadingChangesetSpecs(num)
+}
+
+func (ui *execUIWrapper) WritingChangesetSpecs(num int) {
+	ui.d.setPhase("Writing changeset specs")
+	ui.ExecUI.WritingChangesetSpecs(num)
+}
+
+func (ui *execUIWrapper) CreatingBatchSpec() {
+	ui.d.setPhase("Creating batch spec")
+	ui.ExecUI.CreatingBatchSpec()
+}
+
+func (ui *execUIWrapper) CreatingBatchSpecSuccess(previewURL string) {
+	ui.d.update(func(s *State) { s.URL = previewURL })
+	ui.ExecUI.CreatingBatchSpecSuccess(previewURL)
+}
+
+func (ui *execUIWrapper) ApplyingBatchSpec() {
+	ui.d.setPhase("Applying batch spec")
+	ui.ExecUI.ApplyingBatchSpec()
+}
+
+func (ui *execUIWrapper) ApplyingBatchSpecSuccess(batchChangeURL string) {
+	ui.d.update(func(s *State) { s.URL = batchChangeURL })
+	ui.ExecUI.ApplyingBatchSpecSuccess(batchChangeURL)
+}
+
+type taskExecUIWrapper struct {
+	executor.TaskExecutionUI
+	d *Dashboard
+}
+
+func (ui *taskExecUIWrapper) Start(tasks []*executor.Task) {
+	ui.d.start(tasks)
+	ui.TaskExecutionUI.Start(tasks)
+}
+
+func (ui *taskExecUIWrapper) TaskStarted(task *executor.Task) {
+	ui.d.taskStarted(task)
+	ui.TaskExecutionUI.TaskStarted(task)
+}
+
+func (ui *taskExecUIWrapper) TaskFinished(task *executor.Task, err error) {
+	ui.d.taskFinished(task, err)
+	ui.TaskExecutionUI.TaskFinished(task, err)
+}
+
+func (ui *taskExecUIWrapper) TaskChangesetSpecsBuilt(task *executor.Task, specs []*batcheslib.ChangesetSpec) {
+	ui.d.changesetSpecsBuilt(task, specs)
+	ui.TaskExecutionUI.TaskChangesetSpecsBuilt(task, specs)
+}
+
+func (ui *taskExecUIWrapper) StepsExecutionUI(task *executor.Task) executor.StepsExecutionUI {
+	return &stepsExecUIWrapper{StepsExecutionUI: ui.TaskExecutionUI.StepsExecutionUI(task), d: ui.d, task: task}
+}
+
+type stepsExecUIWrapper struct {
+	executor.StepsExecutionUI
+	d    *Dashboard
+	task *executor.Task
+}
+
+func (ui *stepsExecUIWrapper) SkippingStepsUpto(startStep int) {
+	ui.d.updateTask(ui.task, func(t *Task) {
+		for i := 0; i < startStep-1 && i < len(t.Steps); i++ {
+			t.Steps[i].Status = StatusCached
+		}
+	})
+	ui.StepsExecutionUI.SkippingStepsUpto(startStep)
+}
+
+func (ui *stepsExecUIWrapper) StepSkipped(step int) {
+	ui.d.updateStep(ui.task, step, func(s *Step) { s.Status = StatusSkipped })
+	ui.StepsExecutionUI.StepSkipped(step)
+}
+
+func (ui *stepsExecUIWrapper) StepPreparingStart(step int) {
+	ui.d.updateStep(ui.task, step, func(s *Step) { s.Status = StatusPreparing })
+	ui.StepsExecutionUI.StepPreparingStart(step)
+}
+
+func (ui *stepsExecUIWrapper) StepPreparingFailed(step int, err error) {
+	ui.d.updateStep(ui.task, step, func(s *Step) {
+		s.Status = StatusFailed
+		s.Error = err.Error()
+	})
+	ui.StepsExecutionUI.StepPreparingFailed(step, err)
+}
+
+func (ui *stepsExecUIWrapper) StepStarted(step int, runScript string, env map[string]string) {
+	ui.d.updateStep(ui.task, step, func(s *Step) {
+		s.Status = StatusRunning
+		s.Run = runScript
+	})
+	ui.StepsExecutionUI.StepStarted(step, runScript, env)
+}
+
+func (ui *stepsExecUIWrapper) StepOutputWriter(ctx context.Context, task *executor.Task, step int) executor.StepOutputWriter {
+	return &stepOutputWriter{
+		StepOutputWriter: ui.StepsExecutionUI.StepOutputWriter(ctx, task, step),
+		stdout:           &outputWriter{d: ui.d, task: task, step: step, stream: "stdout"},
+		stderr:           &outputWriter{d: ui.d, task: task, step: step, stream: "stderr"},
+	}
+}
+
+func (ui *stepsExecUIWrapper) StepFinished(step int, diff string, changes *git.Changes, outputs map[string]interface{}) {
+	ui.d.updateStep(ui.task, step, func(s *Step) {
+		exitCode := 0
+		s.Status = StatusSucceeded
+		s.ExitCode = &exitCode
+		s.Diff = diff
+	})
+	ui.StepsExecutionUI.StepFinished(step, diff, changes, outputs)
+}
+
+func (ui *stepsExecUIWrapper) StepFailed(step int, err error, exitCode int) {
+	ui.d.updateStep(ui.task, step, func(s *Step) {
+		s.Status = StatusFailed
+		s.Error = err.Error()
+		if exitCode != -1 {
+			s.ExitCode = &exitCode
+		}
+	})
+	ui.StepsExecutionUI.StepFailed(step, err, exitCode)
+}
+
+// stepOutputWriter writes the output of a step to the dashboard in addition
+// to the wrapped StepOutputWriter.
+type stepOutputWriter struct {
+	executor.StepOutputWriter
+	stdout, stderr io.Writer
+}
+
+func (w *stepOutputWriter) StdoutWriter() io.Writer {
+	return io.MultiWriter(w.StepOutputWriter.StdoutWriter(), w.stdout)
+}
+
+func (w *stepOutputWriter) StderrWriter() io.Writer {
+	return io.MultiWriter(w.StepOutputWriter.StderrWriter(), w.stderr)
+}
+
+type outputWriter struct {
+	d      *Dashboard
+	task   *executor.Task
+	step   int
+	stream string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.d.stepOutput(w.task, w.step, w.stream, p)
+	return len(p), nil
+}
@@ -3,6 +3,7 @@ package docker
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
@@ -29,6 +30,7 @@ var Root = UIDGID{UID: 0, GID: 0}
 type Image interface {
 	Digest(context.Context) (string, error)
 	Ensure(context.Context) error
+	RepoDigest(context.Context) (string, error)
 	UIDGID(context.Context) (UIDGID, error)
 }
 
@@ -103,6 +105,47 @@ func (image *image) Ensure(ctx context.Context) error {
 	return image.ensureErr
 }
 
+// RepoDigest returns the distribution digest of the image as a reference that
+// can be pulled, like my/image@sha256:xxx. Only images that have been pulled
+// from or pushed to a registry have one.
+func (image *image) RepoDigest(ctx context.Context) (string, error) {
+	if err := image.Ensure(ctx); err != nil {
+		return "", err
+	}
+
+	out, err := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{ json .RepoDigests }}", image.name).Output()
+	if err != nil {
+		return "", errors.Wrap(err, "inspecting image")
+	}
+	var digests []string
+	if err := json.Unmarshal(bytes.TrimSpace(out), &digests); err != nil {
+		return "", errors.Wrapf(err, "unexpected repository digests of %q", image.name)
+	}
+
+	// An image can be in more than one repository, so we look for the one it
+	// was referred to by.
+	repo := repository(image.name)
+	for _, digest := range digests {
+		if i := strings.LastIndex(digest, "@"); i >= 0 && repository(digest[:i]) == repo {
+			return digest, nil
+		}
+	}
+	return "", errors.Errorf("image %q has no registry digest: only images that have been pulled from or pushed to a registry have one", image.name)
+}
+
+// repository returns the repository of the given image reference without the
+// tag or digest, in the short form Docker uses for the images on Docker Hub.
+func repository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	ref = strings.TrimPrefix(ref, "docker.io/")
+	return strings.TrimPrefix(ref, "library/")
+}
+
 // UIDGID returns the user and group the container is configured to run as.
 func (image *image) UIDGID(ctx context.Context) (UIDGID, error) {
 	image.uidGidOnce.Do(func() {
@@ -280,6 +280,73 @@ func TestImage_UIDGID(t *testing.T) {
 	}
 }
 
+func TestImage_RepoDigest(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		expectations []*expect.Expectation
+		image        *image
+		want         string
+		wantErr      bool
+	}{
+		"success": {
+			expectations: []*expect.Expectation{
+				inspectSuccess("foo:1.0", "digest"),
+				repoDigests("foo:1.0", `["bar@sha256:aaa","foo@sha256:bbb"]`),
+			},
+			image: &image{name: "foo:1.0"},
+			want:  "foo@sha256:bbb",
+		},
+		"docker hub": {
+			expectations: []*expect.Expectation{
+				inspectSuccess("docker.io/library/alpine:3", "digest"),
+				repoDigests("docker.io/library/alpine:3", `["alpine@sha256:aaa"]`),
+			},
+			image: &image{name: "docker.io/library/alpine:3"},
+			want:  "alpine@sha256:aaa",
+		},
+		"registry with port": {
+			expectations: []*expect.Expectation{
+				inspectSuccess("localhost:5000/foo", "digest"),
+				repoDigests("localhost:5000/foo", `["localhost:5000/foo@sha256:aaa"]`),
+			},
+			image: &image{name: "localhost:5000/foo"},
+			want:  "localhost:5000/foo@sha256:aaa",
+		},
+		"local image": {
+			expectations: []*expect.Expectation{
+				inspectSuccess("foo", "digest"),
+				repoDigests("foo", `[]`),
+			},
+			image:   &image{name: "foo"},
+			wantErr: true,
+		},
+		"pull failure": {
+			expectations: []*expect.Expectation{
+				inspectFailure("foo"),
+				pullFailure("foo"),
+			},
+			image:   &image{name: "foo"},
+			wantErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expect.Commands(t, tc.expectations...)
+
+			have, err := tc.image.RepoDigest(ctx)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("unexpected nil error")
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %+v", err)
+			} else if have != tc.want {
+				t.Errorf("unexpected repository digest: have=%q want=%q", have, tc.want)
+			}
+		})
+	}
+}
+
 func TestUIDGID(t *testing.T) {
 	have := UIDGID{UID: 1000, GID: 0}.String()
 	want := "1000:0"
@@ -320,6 +387,13 @@ func pullSuccess(name string) *expect.Expectation {
 	)
 }
 
+func repoDigests(name, digests string) *expect.Expectation {
+	return expect.NewGlob(
+		expect.Behaviour{Stdout: []byte(digests + "\n")},
+		"docker", "image", "inspect", "--format", `\{\{ json .RepoDigests }}`, name,
+	)
+}
+
 func uidGid(digest string, behaviour expect.Behaviour) *expect.Expectation {
 	return expect.NewGlob(
 		behaviour,
@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"runtime"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// ImageInfo describes a Docker image as far as it can be determined without
+// pulling it.
+type ImageInfo struct {
+	Name string `json:"name"`
+	// Local is true if the image is already in the local cache and doesn't
+	// need to be pulled.
+	Local bool `json:"local"`
+	// Size is the size of the image in bytes. For local images, this is the
+	// size on disk. For remote images it's the compressed size of the layers
+	// that have to be downloaded. 0 means that the size is unknown.
+	Size int64 `json:"size"`
+}
+
+// InspectImage returns the ImageInfo for the image with the given name. Unlike
+// Image.Ensure, it never pulls the image: if it's not available locally, the
+// registry is asked for the size of the image with `docker manifest inspect`.
+// Since not every registry supports that, the size of remote images is best
+// effort and errors are not returned.
+func InspectImage(ctx context.Context, name string) (ImageInfo, error) {
+	info := ImageInfo{Name: name}
+
+	out, err := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{ .Size }}", name).Output()
+	if err == nil {
+		size, err := strconv.ParseInt(string(bytes.TrimSpace(out)), 10, 64)
+		if err != nil {
+			return info, errors.Wrapf(err, "malformed image size: %q", string(out))
+		}
+		info.Local = true
+		info.Size = size
+		return info, nil
+	}
+
+	out, err = exec.CommandContext(ctx, "docker", "manifest", "inspect", "--verbose", name).Output()
+	if err != nil {
+		return info, nil
+	}
+	info.Size = remoteImageSize(out)
+	return info, nil
+}
+
+// verboseManifest is the part of the output of `docker manifest inspect
+// --verbose` that's needed to compute the size of an image.
+type verboseManifest struct {
+	Descriptor struct {
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"Descriptor"`
+	SchemaV2Manifest struct {
+		Config struct {
+			Size int64 `json:"size"`
+		} `json:"config"`
+		Layers []struct {
+			Size int64 `json:"size"`
+		} `json:"layers"`
+	} `json:"SchemaV2Manifest"`
+}
+
+func (m verboseManifest) size() int64 {
+	size := m.SchemaV2Manifest.Config.Size
+	for _, l := range m.SchemaV2Manifest.Layers {
+		size += l.Size
+	}
+	return size
+}
+
+// remoteImageSize returns the size of the image described by the output of
+// `docker manifest inspect --verbose`, or 0 if it can't be determined. For
+// multi-platform images, the manifest for linux on the current architecture
+// is used, which is what Docker pulls.
+func remoteImageSize(out []byte) int64 {
+	var single verboseManifest
+	if err := json.Unmarshal(out, &single); err == nil {
+		return single.size()
+	}
+
+	var list []verboseManifest
+	if err := json.Unmarshal(out, &list); err != nil || len(list) == 0 {
+		return 0
+	}
+	for _, m := range list {
+		p := m.Descriptor.Platform
+		if p.OS == "linux" && p.Architecture == runtime.GOARCH {
+			return m.size()
+		}
+	}
+	return list[0].size()
+}
@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func TestInspectImage(t *testing.T) {
+	ctx := context.Background()
+
+	sizeInspect := func(behaviour expect.Behaviour) *expect.Expectation {
+		return expect.NewGlob(behaviour, "docker", "image", "inspect", "--format", `\{\{ .Size }}`, "foo")
+	}
+	manifestInspect := func(behaviour expect.Behaviour) *expect.Expectation {
+		return expect.NewGlob(behaviour, "docker", "manifest", "inspect", "--verbose", "foo")
+	}
+
+	for name, tc := range map[string]struct {
+		expectations []*expect.Expectation
+		want         ImageInfo
+		wantErr      bool
+	}{
+		"local": {
+			expectations: []*expect.Expectation{
+				sizeInspect(expect.Behaviour{Stdout: []byte("1234\n")}),
+			},
+			want: ImageInfo{Name: "foo", Local: true, Size: 1234},
+		},
+		"local with invalid output": {
+			expectations: []*expect.Expectation{
+				sizeInspect(expect.Behaviour{Stdout: []byte("big\n")}),
+			},
+			wantErr: true,
+		},
+		"remote": {
+			expectations: []*expect.Expectation{
+				sizeInspect(expect.Behaviour{ExitCode: 1}),
+				manifestInspect(expect.Behaviour{Stdout: []byte(`{
+					"SchemaV2Manifest": {"config": {"size": 10}, "layers": [{"size": 100}, {"size": 200}]}
+				}`)}),
+			},
+			want: ImageInfo{Name: "foo", Size: 310},
+		},
+		"remote multi-platform": {
+			expectations: []*expect.Expectation{
+				sizeInspect(expect.Behaviour{ExitCode: 1}),
+				manifestInspect(expect.Behaviour{Stdout: []byte(`[
+					{"Descriptor": {"platform": {"os": "windows", "architecture": "` + runtime.GOARCH + `"}}, "SchemaV2Manifest": {"layers": [{"size": 1}]}},
+					{"Descriptor": {"platform": {"os": "linux", "architecture": "` + runtime.GOARCH + `"}}, "SchemaV2Manifest": {"layers": [{"size": 2}]}}
+				]`)}),
+			},
+			want: ImageInfo{Name: "foo", Size: 2},
+		},
+		"remote unknown": {
+			expectations: []*expect.Expectation{
+				sizeInspect(expect.Behaviour{ExitCode: 1}),
+				manifestInspect(expect.Behaviour{ExitCode: 1}),
+			},
+			want: ImageInfo{Name: "foo"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expect.Commands(t, tc.expectations...)
+
+			have, err := InspectImage(ctx, "foo")
+			if tc.wantErr {
+				if err == nil {
+					t.Error("unexpected nil error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("unexpected image info (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
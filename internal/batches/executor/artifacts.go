@@ -0,0 +1,166 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+	"github.com/sourcegraph/src-cli/internal/batches/util"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+)
+
+// stepArtifacts returns the paths of the artifacts of the given step, relative
+// to the root of the repository.
+func (t *Task) stepArtifacts(step int) []string {
+	if step >= len(t.Artifacts) {
+		return nil
+	}
+
+	paths := make([]string, 0, len(t.Artifacts[step]))
+	for _, a := range t.Artifacts[step] {
+		paths = append(paths, path.Join(t.Path, a))
+	}
+	return paths
+}
+
+// allArtifacts returns the paths of the artifacts of all steps, relative to
+// the root of the repository.
+func (t *Task) allArtifacts() []string {
+	var paths []string
+	for i := range t.Artifacts {
+		paths = append(paths, t.stepArtifacts(i)...)
+	}
+	return paths
+}
+
+// hasArtifactsUpTo returns whether one of the steps up to and including the
+// given one has artifacts.
+func (t *Task) hasArtifactsUpTo(step int) bool {
+	for i := 0; i <= step && i < len(t.Artifacts); i++ {
+		if len(t.Artifacts[i]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareArtifacts excludes the artifacts of the task from the diff of the
+// workspace and returns the directory they are copied to, which is emptied
+// first, since the steps that produce them are executed again. If the task has
+// no ArtifactsDir, a temporary directory is used, which the returned function
+// removes.
+func prepareArtifacts(ctx context.Context, opts *executionOpts, w workspace.Workspace) (string, func(), error) {
+	artifacts := opts.task.allArtifacts()
+	if len(artifacts) == 0 {
+		return "", func() {}, nil
+	}
+
+	if err := w.ExcludeArtifacts(ctx, artifacts); err != nil {
+		return "", func() {}, err
+	}
+
+	if opts.task.ArtifactsDir == "" {
+		dir, err := os.MkdirTemp(opts.tempDir, "artifacts-")
+		if err != nil {
+			return "", func() {}, err
+		}
+		return dir, func() { os.RemoveAll(dir) }, nil
+	}
+
+	if err := os.RemoveAll(opts.task.ArtifactsDir); err != nil {
+		return "", func() {}, err
+	}
+	return opts.task.ArtifactsDir, func() {}, os.MkdirAll(opts.task.ArtifactsDir, 0755)
+}
+
+// collectArtifacts copies the artifacts of the given step out of the
+// workspace into dir and adds the ones it found to the output that holds
+// them.
+func collectArtifacts(ctx context.Context, opts *executionOpts, w workspace.Workspace, step int, dir string, outputs map[string]interface{}) error {
+	paths := opts.task.stepArtifacts(step)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	copied, err := w.CopyArtifacts(ctx, paths, dir)
+	if err != nil {
+		return err
+	}
+	opts.logger.StepLogf(step+1, "copied artifacts %q to %s", copied, dir)
+
+	// The output is a list, since the outputs are serialized to JSON in the
+	// cache, and it holds the paths relative to the path of the task, like
+	// they are given in the batch spec.
+	list, _ := outputs[specext.ArtifactsOutput].([]interface{})
+	seen := make(map[string]bool, len(list))
+	for _, a := range list {
+		if s, ok := a.(string); ok {
+			seen[s] = true
+		}
+	}
+	for _, p := range copied {
+		rel := strings.TrimPrefix(p, opts.task.Path+"/")
+		if !seen[rel] {
+			seen[rel] = true
+			list = append(list, rel)
+		}
+	}
+	outputs[specext.ArtifactsOutput] = list
+	return nil
+}
+
+// artifactsDir returns the directory the artifacts of the task are stored in,
+// next to its cached results, or "" if it has no artifacts.
+func (c *Coordinator) artifactsDir(task *Task) (string, error) {
+	if task.Artifacts == nil {
+		return "", nil
+	}
+
+	key := c.cacheKey(task, os.Environ())
+	k, err := key.Key()
+	if err != nil {
+		return "", errors.Wrap(err, "calculating execution cache key")
+	}
+
+	dir := c.opts.CacheDir
+	if dir == "" {
+		dir = c.opts.TempDir
+	}
+	return filepath.Join(dir, key.Slug(), k+"-artifacts"), nil
+}
+
+// ExportArtifacts copies the stored artifacts of the given Tasks into dir,
+// where they are placed at their paths in the repository, in a directory per
+// repository: dir/github.com/sourcegraph/src-cli/report.sarif.
+func (c *Coordinator) ExportArtifacts(tasks []*Task, dir string) error {
+	for _, task := range tasks {
+		src, err := c.artifactsDir(task)
+		if err != nil {
+			return err
+		}
+		if src == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return errors.Wrapf(err, "reading artifacts of %q", task.Repository.Name)
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(task.Repository.Name))
+		for _, e := range entries {
+			if err := util.CopyTree(filepath.Join(src, e.Name()), filepath.Join(dest, e.Name())); err != nil {
+				return errors.Wrapf(err, "exporting artifacts of %q", task.Repository.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+)
+
+func TestTask_Artifacts(t *testing.T) {
+	task := &Task{
+		Repository: testRepo1,
+		Path:       "api",
+		Steps:      []batcheslib.Step{{Run: "true"}, {Run: "semgrep"}, {Run: "true"}},
+		Artifacts:  [][]string{nil, {"report.sarif", "out"}, nil},
+	}
+
+	if diff := cmp.Diff([]string{"api/report.sarif", "api/out"}, task.allArtifacts()); diff != "" {
+		t.Errorf("wrong artifacts (-want +have):\n%s", diff)
+	}
+	for step, want := range []bool{false, true, true} {
+		if have := task.hasArtifactsUpTo(step); have != want {
+			t.Errorf("hasArtifactsUpTo(%d) = %t, want %t", step, have, want)
+		}
+	}
+}
+
+func TestCoordinator_ExportArtifacts(t *testing.T) {
+	cacheDir := t.TempDir()
+	c := &Coordinator{opts: NewCoordinatorOpts{CacheDir: cacheDir}}
+
+	task := &Task{
+		Repository: testRepo1,
+		Steps:      []batcheslib.Step{{Run: "semgrep"}},
+		Artifacts:  [][]string{{"reports/semgrep.sarif"}},
+	}
+	withoutArtifacts := &Task{Repository: testRepo2, Steps: task.Steps}
+
+	dir, err := c.artifactsDir(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(filepath.Dir(dir)) != cacheDir {
+		t.Errorf("artifacts aren't stored in the cache: %s", dir)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "reports"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "reports", "semgrep.sarif"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exportDir := t.TempDir()
+	if err := c.ExportArtifacts([]*Task{task, withoutArtifacts}, exportDir); err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := os.ReadFile(filepath.Join(exportDir, filepath.FromSlash(testRepo1.Name), "reports", "semgrep.sarif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(have) != "{}" {
+		t.Errorf("wrong content: %q", have)
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, filepath.FromSlash(testRepo2.Name))); !os.IsNotExist(err) {
+		t.Errorf("unexpected artifacts of task without artifacts: %v", err)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/log"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/batches/secrets"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 )
 
@@ -50,6 +51,20 @@ type NewCoordinatorOpts struct {
 	// something like ExecutionArgs, that we can pass around
 	CacheDir   string
 	SkipErrors bool
+	// LocalClones are the local clones the workspaces of repositories are
+	// created from, instead of archives downloaded from Sourcegraph.
+	LocalClones *repozip.LocalClones
+	// GitMirrors makes the workspaces be created from bare mirrors of the
+	// repositories in CacheDir, to which only new commits are fetched,
+	// instead of from ZIP archives.
+	GitMirrors bool
+	// Secrets are the values of the secrets of the batch spec, which are
+	// given to the steps that use them and masked in their output.
+	Secrets secrets.Values
+	// Native makes the cache keys the ones of executing the steps on the
+	// host, for Coordinators that only look up cached results and have no
+	// Creator. A native Creator implies it.
+	Native bool
 
 	// Used by batcheslib.BuildChangesetSpecs
 	Features batches.FeatureFlags
@@ -59,21 +74,52 @@ type NewCoordinatorOpts struct {
 	Timeout       time.Duration
 	KeepLogs      bool
 	TempDir       string
+
+	// Workers are the addresses of the workers the Tasks are sent to, instead
+	// of executing them locally. See Worker.
+	Workers []string
+	// WorkerToken is sent to the Workers to authenticate.
+	WorkerToken string
+	// LogDir, if set, is the directory of the run the logs of the tasks are
+	// written to as JSON lines, instead of text files in TempDir. See
+	// log.NewRun.
+	LogDir string
+
+	// DebugShell, if set, is started in the container of a step that exits
+	// with a non-zero code, before the workspace of the step is cleaned up.
+	DebugShell DebugShell
 }
 
 func NewCoordinator(opts NewCoordinatorOpts) *Coordinator {
-	logManager := log.NewManager(opts.TempDir, opts.KeepLogs)
-
-	exec := newExecutor(newExecutorOpts{
-		RepoArchiveRegistry: opts.RepoArchiveRegistry,
-		EnsureImage:         opts.EnsureImage,
-		Creator:             opts.Creator,
-		Logger:              logManager,
+	var logManager *log.Manager
+	if opts.LogDir != "" {
+		logManager = log.NewJSONManager(opts.LogDir)
+	} else {
+		logManager = log.NewManager(opts.TempDir, opts.KeepLogs)
+	}
 
-		Parallelism: opts.Parallelism,
-		Timeout:     opts.Timeout,
-		TempDir:     opts.TempDir,
-	})
+	var exec taskExecutor
+	if len(opts.Workers) > 0 {
+		exec = newRemoteExecutor(newRemoteExecutorOpts{
+			Logger: logManager,
+
+			Workers: opts.Workers,
+			Token:   opts.WorkerToken,
+		})
+	} else {
+		exec = newExecutor(newExecutorOpts{
+			RepoArchiveRegistry: opts.RepoArchiveRegistry,
+			EnsureImage:         opts.EnsureImage,
+			Creator:             opts.Creator,
+			Logger:              logManager,
+
+			Parallelism: opts.Parallelism,
+			Timeout:     opts.Timeout,
+			TempDir:     opts.TempDir,
+			Secrets:     opts.Secrets,
+			DebugShell:  opts.DebugShell,
+		})
+	}
 
 	return &Coordinator{
 		opts:       opts,
@@ -120,7 +166,7 @@ func (c *Coordinator) ClearCache(ctx context.Context, tasks []*Task) error {
 	globalEnv := os.Environ()
 
 	for _, task := range tasks {
-		cacheKey := task.cacheKey(globalEnv)
+		cacheKey := c.cacheKey(task, globalEnv)
 		if err := c.cache.Clear(ctx, cacheKey); err != nil {
 			return errors.Wrapf(err, "clearing cache for %q", task.Repository.Name)
 		}
@@ -130,6 +176,16 @@ func (c *Coordinator) ClearCache(ctx context.Context, tasks []*Task) error {
 				return errors.Wrapf(err, "clearing cache for step %d in %q", i, task.Repository.Name)
 			}
 		}
+
+		dir, err := c.artifactsDir(task)
+		if err != nil {
+			return err
+		}
+		if dir != "" {
+			if err := os.RemoveAll(dir); err != nil {
+				return errors.Wrapf(err, "clearing artifacts of %q", task.Repository.Name)
+			}
+		}
 	}
 	return nil
 }
@@ -138,7 +194,7 @@ func (c *Coordinator) checkCacheForTask(ctx context.Context, task *Task) (specs
 	globalEnv := os.Environ()
 
 	// Check if the task is cached.
-	cacheKey := task.cacheKey(globalEnv)
+	cacheKey := c.cacheKey(task, globalEnv)
 
 	var result execution.Result
 	result, found, err = c.cache.Get(ctx, cacheKey)
@@ -155,6 +211,7 @@ func (c *Coordinator) checkCacheForTask(ctx context.Context, task *Task) (specs
 
 		return specs, false, nil
 	}
+	task.ChangedFiles = result.ChangedFiles
 
 	// If the cached result resulted in an empty diff, we don't need to
 	// add it to the list of specs that are displayed to the user and
@@ -199,11 +256,32 @@ func (c Coordinator) buildChangesetSpecs(task *Task, result execution.Result) ([
 	})
 }
 
+// cacheKey returns the cache key of the task, when it's executed with the
+// Creator of the Coordinator.
+func (c *Coordinator) cacheKey(task *Task, globalEnv []string) *taskCacheKey {
+	key := task.cacheKey(globalEnv)
+	_, native := nativeCreator(c.opts.Creator)
+	key.Native = native || c.opts.Native
+	return key
+}
+
 func (c *Coordinator) loadCachedStepResults(ctx context.Context, task *Task, globalEnv []string) error {
+	return c.loadCachedStepResultsUpTo(ctx, task, globalEnv, len(task.Steps)-1)
+}
+
+// loadCachedStepResultsUpTo loads the cached result of the last step up to
+// and including the step with the given index into the task.
+func (c *Coordinator) loadCachedStepResultsUpTo(ctx context.Context, task *Task, globalEnv []string, last int) error {
 	// We start at the back so that we can find the _last_ cached step,
 	// then restart execution on the following step.
-	taskKey := task.cacheKey(globalEnv)
-	for i := len(task.Steps) - 1; i > -1; i-- {
+	taskKey := c.cacheKey(task, globalEnv)
+	for i := last; i > -1; i-- {
+		// The artifacts aren't in the diff of the cached results, so the
+		// steps after the ones that produce them would miss them.
+		if task.hasArtifactsUpTo(i) {
+			continue
+		}
+
 		key := cacheKeyForStep(taskKey, i)
 
 		result, found, err := c.cache.GetStepResult(ctx, key)
@@ -225,7 +303,7 @@ func (c *Coordinator) loadCachedStepResults(ctx context.Context, task *Task, glo
 func (c *Coordinator) cacheAndBuildSpec(ctx context.Context, taskResult taskResult, ui TaskExecutionUI) ([]*batcheslib.ChangesetSpec, error) {
 	// Add to the cache, even if no diff was produced.
 	globalEnv := os.Environ()
-	cacheKey := taskResult.task.cacheKey(globalEnv)
+	cacheKey := c.cacheKey(taskResult.task, globalEnv)
 	if err := c.cache.Set(ctx, cacheKey, taskResult.result); err != nil {
 		return nil, errors.Wrapf(err, "caching result for %q", taskResult.task.Repository.Name)
 	}
@@ -237,6 +315,7 @@ func (c *Coordinator) cacheAndBuildSpec(ctx context.Context, taskResult taskResu
 			return nil, errors.Wrapf(err, "caching result for step %d in %q", stepResult.StepIndex, taskResult.task.Repository.Name)
 		}
 	}
+	taskResult.task.ChangedFiles = taskResult.result.ChangedFiles
 
 	// If the steps didn't result in any diff, we don't need to create a
 	// changeset spec that's displayed to the user and send to the server.
@@ -254,6 +333,66 @@ func (c *Coordinator) cacheAndBuildSpec(ctx context.Context, taskResult taskResu
 	return specs, nil
 }
 
+// Debug starts shell in the container of the given step (1-based) of the
+// task, in the workspace the step would be executed in, instead of executing
+// the step. The steps before it are restored from the cache or, if they
+// aren't cached, executed.
+func (c *Coordinator) Debug(ctx context.Context, task *Task, step int, shell DebugShell) (err error) {
+	if len(c.opts.Workers) > 0 {
+		return errors.New("steps can't be debugged on workers")
+	}
+	if step < 1 || step > len(task.Steps) {
+		return errors.Newf("step %d doesn't exist, the batch spec has %d steps", step, len(task.Steps))
+	}
+
+	task.CachedResultFound = false
+	task.CachedResult = execution.AfterStepResult{}
+	if err := c.loadCachedStepResultsUpTo(ctx, task, os.Environ(), step-2); err != nil {
+		return err
+	}
+	if task.ArtifactsDir, err = c.artifactsDir(task); err != nil {
+		return err
+	}
+
+	log, err := c.logManager.AddTask(task.logTask())
+	if err != nil {
+		return errors.Wrap(err, "creating log file")
+	}
+	task.LogFile = log.Path()
+	defer func() {
+		if err != nil {
+			err = TaskExecutionErr{
+				Err:        err,
+				Logfile:    log.Path(),
+				Repository: task.Repository.Name,
+				Task:       task,
+			}
+			log.MarkErrored()
+		}
+		log.Close()
+	}()
+
+	_, _, err = runTask(ctx, &newExecutorOpts{
+		RepoArchiveRegistry: c.opts.RepoArchiveRegistry,
+		EnsureImage:         c.opts.EnsureImage,
+		Creator:             c.opts.Creator,
+		Logger:              c.logManager,
+
+		Timeout:    c.opts.Timeout,
+		TempDir:    c.opts.TempDir,
+		Secrets:    c.opts.Secrets,
+		DebugShell: shell,
+		DebugStep:  step,
+	}, task, log, NoopStepsExecUI{})
+	if errors.Is(err, errStepDebugged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return errors.Newf("step %d isn't executed in this workspace, because its if: condition is false", step)
+}
+
 // Execute executes the given Tasks and the importChangeset statements in the
 // given spec. It regularly calls the executionProgressPrinter with the
 // current TaskStatuses.
@@ -263,6 +402,14 @@ func (c *Coordinator) Execute(ctx context.Context, tasks []*Task, spec *batchesl
 		errs  *multierror.Error
 	)
 
+	for _, task := range tasks {
+		dir, err := c.artifactsDir(task)
+		if err != nil {
+			return nil, nil, err
+		}
+		task.ArtifactsDir = dir
+	}
+
 	ui.Start(tasks)
 
 	// Run executor
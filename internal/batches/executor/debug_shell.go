@@ -0,0 +1,37 @@
+package executor
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DebugShell runs cmd, which starts an interactive shell in the container of
+// the given step (1-based) of the task, with the terminal attached to it.
+// script is the path of the run script of the step in the container. The
+// workspace of the task is kept until DebugShell returns.
+type DebugShell func(task *Task, step int, script string, cmd *exec.Cmd) error
+
+// errStepDebugged is returned by executeSingleStep when the debug shell was
+// started instead of executing the step, which stops the execution of the
+// following steps.
+var errStepDebugged = errors.New("debug shell started instead of executing the step")
+
+// startDebugShell starts the debug shell of the options with cmd for the
+// step with index i.
+func startDebugShell(opts *executionOpts, i int, script string, cmd *exec.Cmd) error {
+	if opts.debugShell == nil {
+		return errors.New("no debug shell")
+	}
+
+	opts.logger.StepLogf(i+1, "debug shell: %q", strings.Join(cmd.Args, " "))
+	err := opts.debugShell(opts.task, i+1, script, cmd)
+	// The shell exits with the code of the last command run in it, which
+	// doesn't mean it failed.
+	exitErr := &exec.ExitError{}
+	if errors.As(err, &exitErr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/log"
+)
+
+func TestStartDebugShell(t *testing.T) {
+	task := &Task{Repository: &graphql.Repository{Name: "github.com/sourcegraph/src-cli"}}
+	logger, err := log.NewManager(t.TempDir(), false).AddTask(log.Task{Repository: task.Repository.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	run := func(task *Task, step int, script string, cmd *exec.Cmd) error {
+		return cmd.Run()
+	}
+	opts := &executionOpts{task: task, logger: logger, debugShell: run}
+
+	// The exit code of the shell is the one of the last command run in it.
+	if err := startDebugShell(opts, 0, "/tmp/run.sh", exec.Command("sh", "-c", "exit 3")); err != nil {
+		t.Errorf("unexpected error for exit code: %v", err)
+	}
+
+	var (
+		haveTask   *Task
+		haveStep   int
+		haveScript string
+	)
+	want := errors.New("no terminal")
+	opts.debugShell = func(task *Task, step int, script string, cmd *exec.Cmd) error {
+		haveTask, haveStep, haveScript = task, step, script
+		return want
+	}
+	if err := startDebugShell(opts, 1, "/tmp/run.sh", exec.Command("true")); err != want {
+		t.Errorf("wrong error: %v", err)
+	}
+	if haveTask != task || haveStep != 2 || haveScript != "/tmp/run.sh" {
+		t.Errorf("wrong arguments: task %v, step %d, script %q", haveTask, haveStep, haveScript)
+	}
+}
+
+func TestCoordinator_Debug_InvalidStep(t *testing.T) {
+	task := &Task{
+		Repository: &graphql.Repository{Name: "github.com/sourcegraph/src-cli"},
+		Steps:      make([]batcheslib.Step, 2),
+	}
+	shell := func(*Task, int, string, *exec.Cmd) error {
+		t.Fatal("no debug shell should be started")
+		return nil
+	}
+
+	for _, step := range []int{0, 3} {
+		err := NewCoordinator(NewCoordinatorOpts{TempDir: t.TempDir()}).Debug(context.Background(), task, step, shell)
+		if err == nil {
+			t.Errorf("step %d: no error", step)
+		}
+	}
+
+	err := NewCoordinator(NewCoordinatorOpts{TempDir: t.TempDir(), Workers: []string{"localhost:9091"}}).Debug(context.Background(), task, 1, shell)
+	if err == nil {
+		t.Error("no error with workers")
+	}
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
 	"github.com/sourcegraph/sourcegraph/lib/batches/execution/cache"
 	"github.com/sourcegraph/sourcegraph/lib/batches/git"
+
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 )
 
 var cacheRepo1 = batches.Repository{
@@ -127,3 +130,94 @@ func assertCacheMiss(t *testing.T, c ExecutionDiskCache, k *cache.ExecutionKey)
 		t.Fatalf("cache hit when miss was expected")
 	}
 }
+
+func TestTaskCacheKey_Paths(t *testing.T) {
+	task := &Task{
+		Repository: testRepo1,
+		Steps:      []batcheslib.Step{{Run: "echo 'Hello World'", Container: "alpine:3"}},
+	}
+
+	keys := func(t *testing.T) (string, string) {
+		t.Helper()
+
+		taskKey, err := task.cacheKey(nil).Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepKey, err := cacheKeyForStep(task.cacheKey(nil), 0).Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return taskKey, stepKey
+	}
+
+	// Without paths, the keys are the ones of the lib.
+	taskKey, stepKey := keys(t)
+	if want, _ := task.cacheKey(nil).ExecutionKeyWithGlobalEnv.Key(); taskKey != want {
+		t.Errorf("wrong task key: %q, want %q", taskKey, want)
+	}
+	if want, _ := cacheKeyForStep(task.cacheKey(nil), 0).StepsCacheKeyWithGlobalEnv.Key(); stepKey != want {
+		t.Errorf("wrong step key: %q, want %q", stepKey, want)
+	}
+
+	task.Paths = []string{"**/go.mod"}
+	pathsTaskKey, pathsStepKey := keys(t)
+	if pathsTaskKey == taskKey || pathsStepKey == stepKey {
+		t.Errorf("paths are not part of the keys")
+	}
+
+	task.Artifacts = [][]string{{"report.sarif"}}
+	artifactsTaskKey, artifactsStepKey := keys(t)
+	if artifactsTaskKey == pathsTaskKey || artifactsStepKey == pathsStepKey {
+		t.Errorf("artifacts are not part of the keys")
+	}
+
+	// Where secrets are put is part of the keys, but their values aren't
+	// known to them.
+	task.Secrets = [][]specext.StepSecret{{{Name: "token", Env: "GITHUB_TOKEN"}}}
+	secretsTaskKey, secretsStepKey := keys(t)
+	if secretsTaskKey == artifactsTaskKey || secretsStepKey == artifactsStepKey {
+		t.Errorf("secrets are not part of the keys")
+	}
+
+	task.OutputSchemas = []map[string]interface{}{{"count": map[string]interface{}{"type": "integer"}}}
+	schemasTaskKey, schemasStepKey := keys(t)
+	if schemasTaskKey == secretsTaskKey || schemasStepKey == secretsStepKey {
+		t.Errorf("output schemas are not part of the keys")
+	}
+}
+
+func TestCoordinatorCacheKey_Native(t *testing.T) {
+	task := &Task{
+		Repository: testRepo1,
+		Steps:      []batcheslib.Step{{Run: "echo 'Hello World'", Container: "alpine:3"}},
+	}
+
+	keys := func(t *testing.T, c *Coordinator) (string, string) {
+		t.Helper()
+
+		taskKey, err := c.cacheKey(task, nil).Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepKey, err := cacheKeyForStep(c.cacheKey(task, nil), 0).Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return taskKey, stepKey
+	}
+
+	// The same run script uses the programs of the host instead of the ones
+	// in the container, so the results can't be shared.
+	taskKey, stepKey := keys(t, &Coordinator{})
+	nativeTaskKey, nativeStepKey := keys(t, &Coordinator{opts: NewCoordinatorOpts{Creator: workspace.NewNativeCreator(t.TempDir(), nil)}})
+	if nativeTaskKey == taskKey || nativeStepKey == stepKey {
+		t.Errorf("native execution is not part of the keys")
+	}
+
+	// Coordinators that only look up cached results get the same keys.
+	lookupTaskKey, lookupStepKey := keys(t, &Coordinator{opts: NewCoordinatorOpts{Native: true}})
+	if lookupTaskKey != nativeTaskKey || lookupStepKey != nativeStepKey {
+		t.Errorf("keys of Native differ from the ones of a native Creator")
+	}
+}
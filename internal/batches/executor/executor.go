@@ -12,7 +12,7 @@ import (
 
 	"github.com/sourcegraph/src-cli/internal/batches/log"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
-	"github.com/sourcegraph/src-cli/internal/batches/util"
+	"github.com/sourcegraph/src-cli/internal/batches/secrets"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 
 	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
@@ -22,6 +22,8 @@ type TaskExecutionErr struct {
 	Err        error
 	Logfile    string
 	Repository string
+	// Task is the task that failed.
+	Task *Task
 }
 
 func (e TaskExecutionErr) Cause() error {
@@ -38,12 +40,18 @@ func (e TaskExecutionErr) Error() string {
 }
 
 func (e TaskExecutionErr) StatusText() string {
-	if stepErr, ok := e.Err.(stepFailedErr); ok {
+	if stepErr, ok := e.Err.(singleLineErr); ok {
 		return stepErr.SingleLineError()
 	}
 	return e.Err.Error()
 }
 
+// singleLineErr is implemented by errors that can be summarized in a single
+// line for the status bar of a task.
+type singleLineErr interface {
+	SingleLineError() string
+}
+
 // taskResult is a combination of a Task and the result of its execution.
 type taskResult struct {
 	task        *Task
@@ -62,6 +70,11 @@ type newExecutorOpts struct {
 	Parallelism int
 	Timeout     time.Duration
 	TempDir     string
+	Secrets     secrets.Values
+	DebugShell  DebugShell
+	// DebugStep is the step (1-based) DebugShell is started for instead of
+	// executing it. See Coordinator.Debug.
+	DebugStep int
 }
 
 type executor struct {
@@ -146,52 +159,70 @@ func (x *executor) do(ctx context.Context, task *Task, ui TaskExecutionUI) (err
 	ui.TaskStarted(task)
 
 	// Let's set up our logging.
-	log, err := x.opts.Logger.AddTask(util.SlugForPathInRepo(task.Repository.Name, task.Repository.Rev(), task.Path))
+	log, err := x.opts.Logger.AddTask(task.logTask())
 	if err != nil {
 		return errors.Wrap(err, "creating log file")
 	}
+	task.LogFile = log.Path()
 	defer func() {
 		if err != nil {
 			err = TaskExecutionErr{
 				Err:        err,
 				Logfile:    log.Path(),
 				Repository: task.Repository.Name,
+				Task:       task,
 			}
 			log.MarkErrored()
 		}
 		log.Close()
 	}()
 
+	result, stepResults, err := runTask(ctx, &x.opts, task, log, ui.StepsExecutionUI(task))
+	if err != nil {
+		return err
+	}
+
+	x.addResult(task, result, stepResults)
+
+	return nil
+}
+
+// runTask checks out the archive of the given Task and executes its steps,
+// using the given options, logger and UI.
+func runTask(ctx context.Context, opts *newExecutorOpts, task *Task, log log.TaskLogger, ui StepsExecutionUI) (execution.Result, []execution.AfterStepResult, error) {
 	// Now checkout the archive.
-	task.Archive = x.opts.RepoArchiveRegistry.Checkout(repozip.RepoRevision{RepoName: task.Repository.Name, Commit: task.Repository.Rev()}, task.ArchivePathToFetch())
+	task.Archive = opts.RepoArchiveRegistry.Checkout(repozip.RepoRevision{RepoName: task.Repository.Name, Commit: task.Repository.Rev()}, task.ArchivePathToFetch())
 
 	// Set up our timeout.
-	runCtx, cancel := context.WithTimeout(ctx, x.opts.Timeout)
+	runCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
 	defer cancel()
 
 	// Actually execute the steps.
-	opts := &executionOpts{
+	execOpts := &executionOpts{
 		task:        task,
 		logger:      log,
-		wc:          x.opts.Creator,
-		ensureImage: x.opts.EnsureImage,
-		tempDir:     x.opts.TempDir,
+		wc:          opts.Creator,
+		ensureImage: opts.EnsureImage,
+		tempDir:     opts.TempDir,
+		secrets:     opts.Secrets,
 
-		ui: ui.StepsExecutionUI(task),
+		ui: ui,
+
+		debugShell: opts.DebugShell,
+		debugStep:  opts.DebugStep,
 	}
 
-	result, stepResults, err := runSteps(runCtx, opts)
+	result, stepResults, err := runSteps(runCtx, execOpts)
 	if err != nil {
 		if reachedTimeout(runCtx, err) {
-			err = &errTimeoutReached{timeout: x.opts.Timeout}
+			err = &errTimeoutReached{timeout: opts.Timeout}
 		}
-		return err
+		return result, nil, err
 	}
 
-	x.addResult(task, result, stepResults)
-
-	return nil
+	return result, stepResults, nil
 }
+
 func (x *executor) addResult(task *Task, result execution.Result, stepResults []execution.AfterStepResult) {
 	x.resultsMu.Lock()
 	defer x.resultsMu.Unlock()
@@ -0,0 +1,33 @@
+package executor
+
+import (
+	"sort"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+// stepOutputSchemas returns the schemas of the outputs of the given step.
+func (t *Task) stepOutputSchemas(step int) map[string]interface{} {
+	if step >= len(t.OutputSchemas) {
+		return nil
+	}
+	return t.OutputSchemas[step]
+}
+
+// validateOutputs validates the outputs that have a schema against it.
+func validateOutputs(schemas map[string]interface{}, outputs map[string]interface{}) error {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := specext.ValidateOutput(schemas[name], outputs[name]); err != nil {
+			return errors.Wrapf(err, "output %q", name)
+		}
+	}
+	return nil
+}
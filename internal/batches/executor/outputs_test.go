@@ -0,0 +1,28 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateOutputs(t *testing.T) {
+	schemas := map[string]interface{}{
+		"count": map[string]interface{}{"type": "integer", "minimum": 0},
+	}
+
+	if err := validateOutputs(schemas, map[string]interface{}{"count": float64(3), "other": "x"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	err := validateOutputs(schemas, map[string]interface{}{"count": float64(-1)})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if want := `output "count": value doesn't match the schema`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error doesn't contain %q: %s", want, err)
+	}
+
+	if err := validateOutputs(schemas, map[string]interface{}{}); err == nil {
+		t.Error("no error for missing output")
+	}
+}
@@ -0,0 +1,371 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/neelance/parallel"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
+
+	"github.com/sourcegraph/src-cli/internal/batches/log"
+)
+
+type newRemoteExecutorOpts struct {
+	// Dependencies
+	Client *http.Client
+	Logger log.LogManager
+
+	// Config
+	Workers []string
+	Token   string
+}
+
+// remoteExecutor is a taskExecutor that doesn't execute Tasks itself, but
+// sends them to workers on other machines. Each worker gets as many Tasks at
+// the same time as it's configured to execute in parallel.
+type remoteExecutor struct {
+	opts newRemoteExecutorOpts
+
+	par           *parallel.Run
+	slots         chan string
+	doneEnqueuing chan struct{}
+
+	results   []taskResult
+	resultsMu sync.Mutex
+}
+
+var _ taskExecutor = &remoteExecutor{}
+
+func newRemoteExecutor(opts newRemoteExecutorOpts) *remoteExecutor {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	// We don't modify the slice we were given, since it belongs to the
+	// caller.
+	workers := make([]string, 0, len(opts.Workers))
+	for _, w := range opts.Workers {
+		workers = append(workers, workerURL(w))
+	}
+	opts.Workers = workers
+
+	return &remoteExecutor{
+		opts:          opts,
+		doneEnqueuing: make(chan struct{}),
+	}
+}
+
+// workerURL turns the given worker address, which can be given as host:port,
+// into a base URL.
+func workerURL(addr string) string {
+	addr = strings.TrimSuffix(strings.TrimSpace(addr), "/")
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return addr
+}
+
+func (x *remoteExecutor) Start(ctx context.Context, tasks []*Task, ui TaskExecutionUI) {
+	defer func() { close(x.doneEnqueuing) }()
+
+	// Every worker gets one slot per task that it can execute in parallel.
+	var slots []string
+	for _, worker := range x.opts.Workers {
+		info, err := x.info(ctx, worker)
+		if err != nil {
+			// We don't start executing anything if one of the workers is
+			// unavailable, since that would only slow everything down.
+			x.par = parallel.NewRun(1)
+			x.par.Error(errors.Wrapf(err, "connecting to worker %s", worker))
+			return
+		}
+		for i := 0; i < info.Parallelism; i++ {
+			slots = append(slots, worker)
+		}
+	}
+
+	x.par = parallel.NewRun(len(slots))
+	x.slots = make(chan string, len(slots))
+	for _, s := range slots {
+		x.slots <- s
+	}
+
+	for _, task := range tasks {
+		select {
+		case <-ctx.Done():
+			return
+		case worker := <-x.slots:
+			x.par.Acquire()
+
+			go func(task *Task, worker string) {
+				defer func() {
+					x.slots <- worker
+					x.par.Release()
+				}()
+
+				if err := x.do(ctx, worker, task, ui); err != nil {
+					x.par.Error(err)
+				}
+			}(task, worker)
+		}
+	}
+}
+
+func (x *remoteExecutor) Wait(ctx context.Context) ([]taskResult, error) {
+	<-x.doneEnqueuing
+
+	result := make(chan error, 1)
+
+	go func(ch chan error) {
+		ch <- x.par.Wait()
+	}(result)
+
+	select {
+	case <-ctx.Done():
+		return x.results, ctx.Err()
+	case err := <-result:
+		close(result)
+		if err != nil {
+			return x.results, err
+		}
+	}
+
+	return x.results, nil
+}
+
+func (x *remoteExecutor) info(ctx context.Context, worker string) (*workerInfo, error) {
+	req, err := x.newRequest(ctx, http.MethodGet, worker+workerInfoPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := x.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkWorkerResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var info workerInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, errors.Wrap(err, "decoding worker info")
+	}
+	if info.Parallelism < 1 {
+		info.Parallelism = 1
+	}
+	return &info, nil
+}
+
+func (x *remoteExecutor) do(ctx context.Context, worker string, task *Task, ui TaskExecutionUI) (err error) {
+	// Ensure that the status is updated when we're done.
+	defer func() {
+		ui.TaskFinished(task, err)
+	}()
+
+	// We're away!
+	ui.TaskStarted(task)
+
+	// The worker keeps its own logs, but it also sends us every line, so that
+	// the logs are available here too.
+	taskLog, err := x.opts.Logger.AddTask(task.logTask())
+	if err != nil {
+		return errors.Wrap(err, "creating log file")
+	}
+	task.LogFile = taskLog.Path()
+	defer func() {
+		if err != nil {
+			err = TaskExecutionErr{
+				Err:        err,
+				Logfile:    taskLog.Path(),
+				Repository: task.Repository.Name,
+				Task:       task,
+			}
+			taskLog.MarkErrored()
+		}
+		taskLog.Close()
+	}()
+	taskLog.Logf("Executing on worker %s", worker)
+
+	body, err := json.Marshal(newRemoteTask(task))
+	if err != nil {
+		return errors.Wrap(err, "encoding task")
+	}
+
+	req, err := x.newRequest(ctx, http.MethodPost, worker+workerTasksPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := x.opts.Client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "sending task to worker %s", worker)
+	}
+	defer resp.Body.Close()
+
+	if err := checkWorkerResponse(resp); err != nil {
+		return errors.Wrapf(err, "sending task to worker %s", worker)
+	}
+
+	result, stepResults, err := replayWorkerEvents(ctx, resp.Body, task, taskLog, ui.StepsExecutionUI(task))
+	if err != nil {
+		return err
+	}
+
+	x.addResult(task, result, stepResults)
+	return nil
+}
+
+func (x *remoteExecutor) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if x.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+x.opts.Token)
+	}
+	return req, nil
+}
+
+func (x *remoteExecutor) addResult(task *Task, result execution.Result, stepResults []execution.AfterStepResult) {
+	x.resultsMu.Lock()
+	defer x.resultsMu.Unlock()
+
+	x.results = append(x.results, taskResult{
+		task:        task,
+		result:      result,
+		stepResults: stepResults,
+	})
+}
+
+func checkWorkerResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return errors.Newf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// replayWorkerEvents reads the events sent by a worker and passes them on to
+// the given log and UI, until the worker sends the result.
+func replayWorkerEvents(ctx context.Context, r io.Reader, task *Task, taskLog log.TaskLogger, ui StepsExecutionUI) (execution.Result, []execution.AfterStepResult, error) {
+	var (
+		outputWriter StepOutputWriter
+		stdout       io.Writer
+		stderr       io.Writer
+	)
+	closeOutputWriter := func() {
+		if outputWriter != nil {
+			outputWriter.Close()
+			outputWriter, stdout, stderr = nil, nil, nil
+		}
+	}
+	defer closeOutputWriter()
+
+	errorOrNil := func(msg string) error {
+		if msg == "" {
+			return nil
+		}
+		return errors.New(msg)
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var e workerEvent
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+				return execution.Result{}, nil, errors.New("worker closed the connection before sending a result")
+			}
+			return execution.Result{}, nil, errors.Wrap(err, "reading events from worker")
+		}
+
+		switch e.Type {
+		case workerEventArchiveDownloadStarted:
+			ui.ArchiveDownloadStarted()
+		case workerEventArchiveDownloadFinished:
+			ui.ArchiveDownloadFinished(errorOrNil(e.Error))
+		case workerEventWorkspaceInitializationStarted:
+			ui.WorkspaceInitializationStarted()
+		case workerEventWorkspaceInitializationFinished:
+			ui.WorkspaceInitializationFinished()
+		case workerEventSkippingStepsUpto:
+			ui.SkippingStepsUpto(e.Step)
+		case workerEventStepSkipped:
+			ui.StepSkipped(e.Step)
+		case workerEventStepPreparingStart:
+			ui.StepPreparingStart(e.Step)
+		case workerEventStepPreparingSuccess:
+			ui.StepPreparingSuccess(e.Step)
+		case workerEventStepPreparingFailed:
+			ui.StepPreparingFailed(e.Step, errorOrNil(e.Error))
+		case workerEventStepStarted:
+			ui.StepStarted(e.Step, e.RunScript, e.Env)
+
+			closeOutputWriter()
+			outputWriter = ui.StepOutputWriter(ctx, task, e.Step)
+			stdout = io.MultiWriter(outputWriter.StdoutWriter(), taskLog.StepWriter(e.Step, log.StreamStdout))
+			stderr = io.MultiWriter(outputWriter.StderrWriter(), taskLog.StepWriter(e.Step, log.StreamStderr))
+		case workerEventStepStdout:
+			if stdout != nil {
+				io.WriteString(stdout, e.Message)
+			}
+		case workerEventStepStderr:
+			if stderr != nil {
+				io.WriteString(stderr, e.Message)
+			}
+		case workerEventCalculatingDiffStarted:
+			ui.CalculatingDiffStarted()
+		case workerEventCalculatingDiffFinished:
+			ui.CalculatingDiffFinished()
+		case workerEventStepFinished:
+			closeOutputWriter()
+			ui.StepFinished(e.Step, e.Diff, e.Changes, e.Outputs)
+		case workerEventStepFailed:
+			closeOutputWriter()
+			ui.StepFailed(e.Step, errorOrNil(e.Error), e.ExitCode)
+		case workerEventLog:
+			if e.Step > 0 {
+				taskLog.StepLogf(e.Step, "%s", e.Message)
+			} else {
+				taskLog.Log(e.Message)
+			}
+
+		case workerEventResult:
+			if e.Result == nil {
+				return execution.Result{}, nil, errors.New("worker sent an empty result")
+			}
+			return *e.Result, e.StepResults, nil
+		case workerEventError:
+			return execution.Result{}, nil, &remoteTaskErr{msg: e.Error, status: e.Status}
+
+		default:
+			taskLog.Logf("Ignoring unknown event from worker: %q", e.Type)
+		}
+	}
+}
+
+// remoteTaskErr is the error that's returned when the execution of a Task
+// failed on a worker.
+type remoteTaskErr struct {
+	msg    string
+	status string
+}
+
+func (e *remoteTaskErr) Error() string { return e.msg }
+
+func (e *remoteTaskErr) SingleLineError() string {
+	if e.status != "" {
+		return e.status
+	}
+	return strings.Split(e.msg, "\n")[0]
+}
@@ -0,0 +1,222 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
+	"github.com/sourcegraph/src-cli/internal/batches/mock"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+)
+
+func TestRemoteExecutor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test doesn't work on Windows because dummydocker is written in bash")
+	}
+
+	addToPath(t, "testdata/dummydocker")
+
+	archives := []mock.RepoArchive{
+		{RepoName: testRepo1.Name, Commit: testRepo1.Rev(), Files: map[string]string{
+			"README.md": "# Welcome to the README\n",
+		}},
+		{RepoName: testRepo2.Name, Commit: testRepo2.Rev(), Files: map[string]string{
+			"README.md": "# Sourcegraph README\n",
+		}},
+	}
+	steps := []batcheslib.Step{
+		{Run: `echo "foobar" >> README.md`, Container: "alpine:13"},
+	}
+	images := map[string]docker.Image{
+		"alpine:13": &mock.Image{RawDigest: "alpine:13"},
+	}
+
+	testTempDir, err := os.MkdirTemp("", "executor-remote-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(testTempDir) })
+
+	// The workers download the archives from this server.
+	ts := httptest.NewServer(mock.NewZipArchivesMux(t, nil, archives...))
+	t.Cleanup(ts.Close)
+
+	var clientBuffer bytes.Buffer
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &clientBuffer})
+
+	newWorkerServer := func(t *testing.T, token string) string {
+		worker := NewWorker(NewWorkerOpts{
+			RepoArchiveRegistry: repozip.NewArchiveRegistry(client, testTempDir, false),
+			EnsureImage:         imageMapEnsurer(images),
+			Logger:              mock.LogNoOpManager{},
+			PrepareCreator: func(ctx context.Context, steps []batcheslib.Step) (workspace.Creator, error) {
+				return workspace.NewCreator(ctx, "bind", testTempDir, testTempDir, images), nil
+			},
+
+			Parallelism: 1,
+			Timeout:     30 * time.Second,
+			TempDir:     testTempDir,
+			Token:       token,
+		})
+		server := httptest.NewServer(worker)
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+
+	newTasks := func() []*Task {
+		tasks := []*Task{{Repository: testRepo1}, {Repository: testRepo2}}
+		for _, task := range tasks {
+			task.Steps = steps
+			task.BatchChangeAttributes = &template.BatchChangeAttributes{Name: "remote-test"}
+		}
+		return tasks
+	}
+
+	t.Run("success", func(t *testing.T) {
+		workers := []string{newWorkerServer(t, "secret"), newWorkerServer(t, "secret")}
+
+		executor := newRemoteExecutor(newRemoteExecutorOpts{
+			Logger:  mock.LogNoOpManager{},
+			Workers: workers,
+			Token:   "secret",
+		})
+
+		dummyUI := newDummyTaskExecutionUI()
+		tasks := newTasks()
+		executor.Start(context.Background(), tasks, dummyUI)
+		results, err := executor.Wait(context.Background())
+		if err != nil {
+			t.Fatalf("execution failed: %s", err)
+		}
+
+		if have, want := len(results), len(tasks); have != want {
+			t.Fatalf("wrong number of results. want=%d, have=%d", want, have)
+		}
+		for _, r := range results {
+			if !strings.Contains(r.result.Diff, "+foobar") {
+				t.Errorf("result for %s doesn't contain the change: %q", r.task.Repository.Name, r.result.Diff)
+			}
+			if len(r.stepResults) != len(steps) {
+				t.Errorf("wrong number of step results for %s. want=%d, have=%d", r.task.Repository.Name, len(steps), len(r.stepResults))
+			}
+		}
+
+		if have, want := len(dummyUI.finished), len(tasks); have != want {
+			t.Errorf("wrong number of finished tasks. want=%d, have=%d", want, have)
+		}
+		if have := len(dummyUI.finishedWithErr); have != 0 {
+			t.Errorf("unexpected tasks finished with errors: %d", have)
+		}
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		executor := newRemoteExecutor(newRemoteExecutorOpts{
+			Logger:  mock.LogNoOpManager{},
+			Workers: []string{newWorkerServer(t, "secret")},
+			Token:   "wrong",
+		})
+
+		dummyUI := newDummyTaskExecutionUI()
+		executor.Start(context.Background(), newTasks(), dummyUI)
+		_, err := executor.Wait(context.Background())
+		if err == nil {
+			t.Fatal("unexpectedly no error")
+		}
+		if want := http.StatusText(http.StatusUnauthorized); !strings.Contains(err.Error(), want) {
+			t.Errorf("wrong error. want to include %q, have=%q", want, err)
+		}
+		if have := len(dummyUI.started); have != 0 {
+			t.Errorf("tasks were started even though the worker can't be used: %d", have)
+		}
+	})
+
+	t.Run("failing step", func(t *testing.T) {
+		executor := newRemoteExecutor(newRemoteExecutorOpts{
+			Logger:  mock.LogNoOpManager{},
+			Workers: []string{newWorkerServer(t, "")},
+		})
+
+		tasks := newTasks()[:1]
+		tasks[0].Steps = []batcheslib.Step{{Run: `exit 1`, Container: "alpine:13"}}
+
+		dummyUI := newDummyTaskExecutionUI()
+		executor.Start(context.Background(), tasks, dummyUI)
+		_, err := executor.Wait(context.Background())
+		if err == nil {
+			t.Fatal("unexpectedly no error")
+		}
+		if want := "run: exit 1"; !strings.Contains(err.Error(), want) {
+			t.Errorf("wrong error. want to include %q, have=%q", want, err)
+		}
+		if have := len(dummyUI.finishedWithErr); have != 1 {
+			t.Errorf("wrong number of tasks finished with errors. want=1, have=%d", have)
+		}
+	})
+}
+
+func TestNewRemoteExecutor_Workers(t *testing.T) {
+	workers := []string{"worker-1:8080", "https://worker-2/"}
+	x := newRemoteExecutor(newRemoteExecutorOpts{Workers: workers})
+
+	if want := []string{"http://worker-1:8080", "https://worker-2"}; !reflect.DeepEqual(x.opts.Workers, want) {
+		t.Errorf("wrong workers. want=%v, have=%v", want, x.opts.Workers)
+	}
+	if want := []string{"worker-1:8080", "https://worker-2/"}; !reflect.DeepEqual(workers, want) {
+		t.Errorf("the given workers were modified: %v", workers)
+	}
+}
+
+func TestRemoteTask(t *testing.T) {
+	// Everything that's part of the cache key of a task has to make it to
+	// the workers, otherwise they'd execute something else than what's
+	// cached.
+	task := &Task{
+		Repository:         testRepo1,
+		Path:               "docs",
+		OnlyFetchWorkspace: true,
+		Paths:              []string{"docs/**", "go.mod"},
+		Steps:              []batcheslib.Step{{Run: "echo hello", Container: "alpine:13"}},
+		Artifacts:          [][]string{{"report.sarif"}},
+		OutputSchemas: []map[string]interface{}{{
+			"count": map[string]interface{}{"type": "integer"},
+		}},
+	}
+
+	data, err := json.Marshal(newRemoteTask(task))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt remoteTask
+	if err := json.Unmarshal(data, &rt); err != nil {
+		t.Fatal(err)
+	}
+
+	have := rt.task()
+	if have.Repository.Name != task.Repository.Name || have.Path != task.Path || have.OnlyFetchWorkspace != task.OnlyFetchWorkspace {
+		t.Errorf("wrong workspace. want=%s:%s, have=%s:%s", task.Repository.Name, task.Path, have.Repository.Name, have.Path)
+	}
+	if diff := cmp.Diff(task.Paths, have.Paths); diff != "" {
+		t.Errorf("wrong paths (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(task.Artifacts, have.Artifacts); diff != "" {
+		t.Errorf("wrong artifacts (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(task.OutputSchemas, have.OutputSchemas); diff != "" {
+		t.Errorf("wrong output schemas (-want +got):\n%s", diff)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/batches/template"
 
 	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/batches/secrets"
 	"github.com/sourcegraph/src-cli/internal/batches/util"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 
@@ -33,12 +34,21 @@ type executionOpts struct {
 	ensureImage imageEnsurer
 
 	task *Task
+	// secrets are the values of the secrets of the batch spec.
+	secrets secrets.Values
 
 	tempDir string
 
 	logger log.TaskLogger
 
 	ui StepsExecutionUI
+
+	// debugShell, if set, is started in the container of a step that exits
+	// with a non-zero code, before its workspace is cleaned up.
+	debugShell DebugShell
+	// debugStep is the step (1-based) debugShell is started for instead of
+	// executing it. See Coordinator.Debug.
+	debugStep int
 }
 
 func runSteps(ctx context.Context, opts *executionOpts) (result execution.Result, stepResults []execution.AfterStepResult, err error) {
@@ -51,13 +61,19 @@ func runSteps(ctx context.Context, opts *executionOpts) (result execution.Result
 	defer opts.task.Archive.Close()
 
 	opts.ui.WorkspaceInitializationStarted()
-	workspace, err := opts.wc.Create(ctx, opts.task.Repository, opts.task.Steps, opts.task.Archive)
+	workspace, err := opts.wc.Create(ctx, opts.task.Repository, opts.task.Steps, opts.task.Paths, opts.task.Archive)
 	if err != nil {
 		return execution.Result{}, nil, errors.Wrap(err, "creating workspace")
 	}
 	defer workspace.Close(ctx)
 	opts.ui.WorkspaceInitializationFinished()
 
+	artifactsDir, cleanup, err := prepareArtifacts(ctx, opts, workspace)
+	if err != nil {
+		return execution.Result{}, nil, errors.Wrap(err, "preparing artifacts")
+	}
+	defer cleanup()
+
 	var (
 		execResult = execution.Result{
 			Diff:         "",
@@ -133,14 +149,21 @@ func runSteps(ctx context.Context, opts *executionOpts) (result execution.Result
 			continue
 		}
 
-		// We need to grab the digest for the exact image we're using.
-		img, err := opts.ensureImage(ctx, step.Container)
-		if err != nil {
-			return execResult, nil, err
+		// We need to grab the digest for the exact image we're using. Native
+		// workspaces don't use images at all.
+		var digest string
+		if _, native := nativeCreator(opts.wc); !native {
+			img, err := opts.ensureImage(ctx, step.Container)
+			if err != nil {
+				return execResult, nil, err
+			}
+			digest, err = img.Digest(ctx)
+			if err != nil {
+				return execResult, nil, err
+			}
 		}
-		digest, err := img.Digest(ctx)
-		if err != nil {
-			return execResult, nil, err
+		if variant := opts.task.StepVariant(i); variant != "" {
+			opts.logger.StepLogf(i+1, "matrix: %s", variant)
 		}
 		stdoutBuffer, stderrBuffer, err := executeSingleStep(ctx, opts, workspace, i, step, digest, &stepContext)
 		defer func() {
@@ -157,6 +180,10 @@ func runSteps(ctx context.Context, opts *executionOpts) (result execution.Result
 			return execResult, nil, err
 		}
 
+		if err := collectArtifacts(ctx, opts, workspace, i, artifactsDir, execResult.Outputs); err != nil {
+			return execResult, nil, errors.Wrap(err, "collecting artifacts of step")
+		}
+
 		changes, err := workspace.Changes(ctx)
 		if err != nil {
 			return execResult, nil, errors.Wrap(err, "getting changed files in step")
@@ -170,6 +197,10 @@ func runSteps(ctx context.Context, opts *executionOpts) (result execution.Result
 		if err := setOutputs(step.Outputs, execResult.Outputs, &stepContext); err != nil {
 			return execResult, nil, errors.Wrap(err, "setting step outputs")
 		}
+		if err := validateOutputs(opts.task.stepOutputSchemas(i), execResult.Outputs); err != nil {
+			opts.logger.StepLogf(i+1, "invalid outputs: %s", err)
+			return execResult, nil, errors.Wrapf(err, "step %d", i+1)
+		}
 
 		// Get the current diff and store that away as the per-step result.
 		stepDiff, err := workspace.Diff(ctx)
@@ -216,6 +247,10 @@ func executeSingleStep(
 	imageDigest string,
 	stepContext *template.StepContext,
 ) (bytes.Buffer, bytes.Buffer, error) {
+	if creator, ok := nativeCreator(opts.wc); ok {
+		return executeSingleStepOnHost(ctx, opts, creator, workspace, i, step, stepContext)
+	}
+
 	// ----------
 	// PREPARATION
 	// ----------
@@ -251,6 +286,19 @@ func executeSingleStep(
 	}
 	defer cleanup()
 
+	// Create the files of the step's secrets.
+	secretFiles, cleanup, err := createSecretFiles(opts.tempDir, opts.task.stepSecrets(i), opts.secrets)
+	if err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+	secretNames, secretValues, err := secretEnv(opts.task.stepSecrets(i), opts.secrets)
+	if err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+
 	// Resolve step.Env given the current environment.
 	stepEnv, err := step.Env.Resolve(os.Environ())
 	if err != nil {
@@ -284,31 +332,84 @@ func executeSingleStep(
 		scriptWorkDir = workDir + "/" + opts.task.Path
 	}
 
-	args := append([]string{
-		"run",
-		"--rm",
-		"--init",
-		"--cidfile", cidFile,
+	// The options of the container are shared with the debug shell, which is
+	// started in the same kind of container.
+	containerOpts := append([]string{
 		"--workdir", scriptWorkDir,
 		"--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", runScriptFile, containerTemp),
 	}, workspaceOpts...)
 
 	for target, source := range filesToMount {
-		args = append(args, "--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", source.Name(), target))
+		containerOpts = append(containerOpts, "--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", source.Name(), target))
+	}
+	for target, source := range secretFiles {
+		containerOpts = append(containerOpts, "--mount", fmt.Sprintf("type=bind,source=%s,target=%s,ro", source.Name(), target))
 	}
 
 	for k, v := range env {
-		args = append(args, "-e", k+"="+v)
+		containerOpts = append(containerOpts, "-e", k+"="+v)
+	}
+	// Docker takes the values of the secrets from its own environment.
+	for _, name := range secretNames {
+		containerOpts = append(containerOpts, "-e", name)
 	}
+	containerOpts = append(containerOpts, "--entrypoint", shell)
 
-	args = append(args, "--entrypoint", shell)
+	args := append([]string{"run", "--rm", "--init", "--cidfile", cidFile}, containerOpts...)
 
 	cmd := exec.CommandContext(ctx, "docker", args...)
 	cmd.Args = append(cmd.Args, "--", imageDigest, containerTemp)
 	if dir := workspace.WorkDir(); dir != nil {
 		cmd.Dir = *dir
 	}
+	if len(secretValues) > 0 {
+		cmd.Env = append(os.Environ(), secretValues...)
+	}
+
+	opts.logger.StepLogf(i+1, "run: %q, container: %q", step.Run, step.Container)
+	opts.logger.StepLogf(i+1, "full command: %q", strings.Join(cmd.Args, " "))
+
+	debugCmd := func() *exec.Cmd {
+		debugArgs := append([]string{"run", "--rm", "--interactive", "--tty"}, containerOpts...)
+		// The shell isn't bound to the context, so that the timeout of the
+		// step doesn't end it.
+		debugCmd := exec.Command("docker", append(debugArgs, imageDigest)...)
+		debugCmd.Dir, debugCmd.Env = cmd.Dir, cmd.Env
+		return debugCmd
+	}
+
+	if opts.debugStep == i+1 {
+		if err := startDebugShell(opts, i, containerTemp, debugCmd()); err != nil {
+			return bytes.Buffer{}, bytes.Buffer{}, err
+		}
+		return bytes.Buffer{}, bytes.Buffer{}, errStepDebugged
+	}
 
+	stdout, stderr, err := runStepCommand(ctx, opts, cmd, i, step, runScript, env, containerTemp, "Docker container")
+	sfe := &stepFailedErr{}
+	if opts.debugShell != nil && errors.As(err, sfe) && sfe.ExitCode > 0 {
+		// The deferred cleanups and the one of the workspace only happen
+		// once the shell exits.
+		if err := startDebugShell(opts, i, containerTemp, debugCmd()); err != nil {
+			opts.logger.StepLogf(i+1, "debug shell failed: %s", err)
+		}
+	}
+	return stdout, stderr, err
+}
+
+// runStepCommand runs the given command of the step, pipes its output into the
+// UI and the log and returns the output.
+func runStepCommand(
+	ctx context.Context,
+	opts *executionOpts,
+	cmd *exec.Cmd,
+	i int,
+	step batcheslib.Step,
+	runScript string,
+	env map[string]string,
+	tmpFilename string,
+	what string,
+) (bytes.Buffer, bytes.Buffer, error) {
 	writerCtx, writerCancel := context.WithCancel(ctx)
 	defer writerCancel()
 	outputWriter := opts.ui.StepOutputWriter(writerCtx, opts.task, i+1)
@@ -316,9 +417,10 @@ func executeSingleStep(
 		outputWriter.Close()
 	}()
 
+	// The values of secrets are masked in everything the output ends up in.
 	var stdoutBuffer, stderrBuffer bytes.Buffer
-	stdout := io.MultiWriter(&stdoutBuffer, outputWriter.StdoutWriter(), opts.logger.PrefixWriter("stdout"))
-	stderr := io.MultiWriter(&stderrBuffer, outputWriter.StderrWriter(), opts.logger.PrefixWriter("stderr"))
+	stdout := secrets.NewMaskingWriter(io.MultiWriter(&stdoutBuffer, outputWriter.StdoutWriter(), opts.logger.StepWriter(i+1, log.StreamStdout)), opts.secrets)
+	stderr := secrets.NewMaskingWriter(io.MultiWriter(&stderrBuffer, outputWriter.StderrWriter(), opts.logger.StepWriter(i+1, log.StreamStderr)), opts.secrets)
 
 	// Setup readers that pipe the output into the given buffers
 	wg, err := process.PipeOutput(ctx, cmd, stdout, stderr)
@@ -336,36 +438,37 @@ func executeSingleStep(
 			Err:         wrappedErr,
 			ExitCode:    exitCode,
 			Args:        cmd.Args,
+			Step:        i + 1,
 			Run:         runScript,
+			Env:         env,
 			Container:   step.Container,
-			TmpFilename: containerTemp,
+			TmpFilename: tmpFilename,
 			Stdout:      strings.TrimSpace(stdoutBuffer.String()),
 			Stderr:      strings.TrimSpace(stderrBuffer.String()),
 		}
 	}
 
-	opts.logger.Logf("[Step %d] run: %q, container: %q", i+1, step.Run, step.Container)
-	opts.logger.Logf("[Step %d] full command: %q", i+1, strings.Join(cmd.Args, " "))
-
 	// Start the command
 	t0 := time.Now()
 	if err := cmd.Start(); err != nil {
-		opts.logger.Logf("[Step %d] error starting Docker container: %+v", i+1, err)
+		opts.logger.StepLogf(i+1, "error starting %s: %+v", what, err)
 		return stdoutBuffer, stderrBuffer, newStepFailedErr(err)
 	}
 
 	// Wait for the readers, because the pipes used by PipeOutput under the
 	// hood are closed when the command exits
 	wg.Wait()
+	secrets.Flush(stdout)
+	secrets.Flush(stderr)
 	// Now wait for the command
 	err = cmd.Wait()
 	elapsed := time.Since(t0).Round(time.Millisecond)
 	if err != nil {
-		opts.logger.Logf("[Step %d] took %s; error running Docker container: %+v", i+1, elapsed, err)
+		opts.logger.StepLogf(i+1, "took %s; error running %s: %+v", elapsed, what, err)
 		return stdoutBuffer, stderrBuffer, newStepFailedErr(err)
 	}
 
-	opts.logger.Logf("[Step %d] complete in %s", i+1, elapsed)
+	opts.logger.StepLogf(i+1, "complete in %s", elapsed)
 	return stdoutBuffer, stderrBuffer, nil
 }
 
@@ -385,13 +488,13 @@ func setOutputs(stepOutputs batcheslib.Outputs, global map[string]interface{}, s
 			// JSON when we cache the results.
 			// See https://github.com/go-yaml/yaml/issues/139 for context
 			if err := yamlv3.NewDecoder(&value).Decode(&out); err != nil {
-				return err
+				return errors.Wrapf(err, "parsing output %q as YAML", name)
 			}
 			global[name] = out
 		case "json":
 			var out interface{}
 			if err := json.NewDecoder(&value).Decode(&out); err != nil {
-				return err
+				return errors.Wrapf(err, "parsing output %q as JSON", name)
 			}
 			global[name] = out
 		default:
@@ -564,7 +667,10 @@ func createCidFile(ctx context.Context, tempDir string, repoSlug string) (string
 }
 
 type stepFailedErr struct {
+	// Step is the 1-based index of the step.
+	Step      int
 	Run       string
+	Env       map[string]string
 	Container string
 
 	TmpFilename string
@@ -621,6 +727,34 @@ func (e stepFailedErr) Error() string {
 	return out.String()
 }
 
+// FailedStep is the step a task failed in.
+type FailedStep struct {
+	// Step is the 1-based index of the step.
+	Step int
+	// Run is the rendered run script of the step.
+	Run       string
+	Env       map[string]string
+	Container string
+	// ExitCode of the command, or -1 if a non-command error occured.
+	ExitCode int
+}
+
+// FailedStepOf returns the step that the given error of a task was caused by.
+// It returns false if the task didn't fail in a step, or failed on a worker.
+func FailedStepOf(err error) (FailedStep, bool) {
+	var sfe stepFailedErr
+	if !errors.As(err, &sfe) {
+		return FailedStep{}, false
+	}
+	return FailedStep{
+		Step:      sfe.Step,
+		Run:       sfe.Run,
+		Env:       sfe.Env,
+		Container: sfe.Container,
+		ExitCode:  sfe.ExitCode,
+	}, true
+}
+
 func (e stepFailedErr) SingleLineError() string {
 	out := e.Err.Error()
 	if len(e.Stderr) > 0 {
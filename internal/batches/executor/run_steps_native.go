@@ -0,0 +1,269 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
+
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+)
+
+// executeSingleStepOnHost is the counterpart of executeSingleStep for native
+// workspaces: instead of starting a container, it runs the step's run script
+// with the shell of the host, in the directory of the workspace.
+func executeSingleStepOnHost(
+	ctx context.Context,
+	opts *executionOpts,
+	creator workspace.NativeCreator,
+	ws workspace.Workspace,
+	i int,
+	step batcheslib.Step,
+	stepContext *template.StepContext,
+) (bytes.Buffer, bytes.Buffer, error) {
+	// ----------
+	// PREPARATION
+	// ----------
+	opts.ui.StepPreparingStart(i + 1)
+
+	dir := ws.WorkDir()
+	if dir == nil {
+		err := errors.New("native workspace has no working directory")
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+
+	runScriptFile, runScript, cleanup, err := createRunScriptFile(ctx, opts.tempDir, step.Run, stepContext)
+	if err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+
+	if err := creator.CheckScript(runScript); err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+
+	// Parse and render the step.Files.
+	filesToMount, cleanup, err := createFilesToMount(opts.tempDir, step, stepContext)
+	if err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+
+	secretFiles, cleanup, err := createSecretFiles(opts.tempDir, opts.task.stepSecrets(i), opts.secrets)
+	if err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+	for target, f := range secretFiles {
+		filesToMount[target] = f
+	}
+	_, secretValues, err := secretEnv(opts.task.stepSecrets(i), opts.secrets)
+	if err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+
+	// Without a container, there's nothing to mount the files into. Putting
+	// them at their target paths on the host would clobber other files and
+	// collide with the ones of other tasks, so they are put under a directory
+	// of the step instead, which it gets as stepFilesDirEnv.
+	filesDir, placedFiles, cleanup, err := placeFilesInDir(opts.tempDir, filesToMount)
+	if err != nil {
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+
+	// Resolve step.Env given the environment the step gets on the host, which
+	// doesn't have the credentials src itself might have been given.
+	baseEnv := hostBaseEnv(os.Environ())
+	stepEnv, err := step.Env.Resolve(baseEnv)
+	if err != nil {
+		err = errors.Wrap(err, "resolving step environment")
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	// Render the step.Env variables as templates.
+	env, err := template.RenderStepMap(stepEnv, stepContext)
+	if err != nil {
+		err = errors.Wrap(err, "parsing step environment")
+		opts.ui.StepPreparingFailed(i+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+
+	opts.ui.StepPreparingSuccess(i + 1)
+
+	// ----------
+	// EXECUTION
+	// ----------
+	opts.ui.StepStarted(i+1, runScript, env)
+
+	// Where should we execute the steps.run script?
+	scriptWorkDir := *dir
+	if opts.task.Path != "" {
+		scriptWorkDir = filepath.Join(*dir, filepath.FromSlash(opts.task.Path))
+	}
+
+	cmd := exec.CommandContext(ctx, hostShell(), runScriptFile)
+	cmd.Dir = scriptWorkDir
+	// Like in a container, the step only gets its own environment and its
+	// secrets, on top of what's needed to run programs on the host.
+	cmd.Env = baseEnv
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Env = append(cmd.Env, secretValues...)
+	if filesDir != "" {
+		cmd.Env = append(cmd.Env, stepFilesDirEnv+"="+filesDir)
+	}
+
+	opts.logger.StepLogf(i+1, "run: %q, natively in %q", step.Run, scriptWorkDir)
+	for _, f := range placedFiles {
+		opts.logger.StepLogf(i+1, "created file %q in $%s", f, stepFilesDirEnv)
+	}
+	opts.logger.StepLogf(i+1, "full command: %q", strings.Join(cmd.Args, " "))
+
+	return runStepCommand(ctx, opts, cmd, i, step, runScript, env, runScriptFile, "run script")
+}
+
+// nativeCreator returns the given Creator as a NativeCreator, if it is one.
+func nativeCreator(wc workspace.Creator) (workspace.NativeCreator, bool) {
+	nc, ok := wc.(workspace.NativeCreator)
+	return nc, ok
+}
+
+// hostShell returns the shell that run scripts are executed with on the host.
+// Like in containers, bash is preferred over sh.
+func hostShell() string {
+	if _, err := exec.LookPath("bash"); err == nil {
+		return "bash"
+	}
+	return "sh"
+}
+
+// hostBaseEnvVars are the variables of the environment of src that steps
+// executed on the host get, since programs need them to work. Everything else,
+// like SRC_ACCESS_TOKEN or the credentials of a CI system, isn't passed on.
+var hostBaseEnvVars = map[string]struct{}{
+	"PATH":    {},
+	"HOME":    {},
+	"USER":    {},
+	"LOGNAME": {},
+	"SHELL":   {},
+	"TMPDIR":  {},
+	"LANG":    {},
+	"LC_ALL":  {},
+	"TZ":      {},
+	"TERM":    {},
+	// Windows
+	"SYSTEMROOT":   {},
+	"SYSTEMDRIVE":  {},
+	"WINDIR":       {},
+	"COMSPEC":      {},
+	"PATHEXT":      {},
+	"TEMP":         {},
+	"TMP":          {},
+	"USERPROFILE":  {},
+	"APPDATA":      {},
+	"LOCALAPPDATA": {},
+}
+
+// hostBaseEnv returns the "NAME=value" pairs of the given environment whose
+// names are in hostBaseEnvVars.
+func hostBaseEnv(environ []string) []string {
+	var env []string
+	for _, kv := range environ {
+		name := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			name = kv[:i]
+		}
+		if _, ok := hostBaseEnvVars[strings.ToUpper(name)]; ok {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// stepFilesDirEnv is the environment variable that holds the directory the
+// files of a step executed on the host are put in, at their target paths: the
+// file "/tmp/config.json" is at "$SRC_STEP_FILES_DIR/tmp/config.json".
+const stepFilesDirEnv = "SRC_STEP_FILES_DIR"
+
+// placeFilesInDir copies the rendered step files into a new directory in
+// tempDir, at their target paths, which have to be absolute, just like in a
+// container. The files can only be read by the current user, since they
+// include the secret files.
+//
+// It returns the directory, or "" if there are no files, the target paths of
+// the files and a function that removes them again.
+func placeFilesInDir(tempDir string, files map[string]*os.File) (string, []string, func(), error) {
+	if len(files) == 0 {
+		return "", nil, func() {}, nil
+	}
+
+	// MkdirTemp creates the directory with 0700.
+	dir, err := os.MkdirTemp(tempDir, "files-")
+	if err != nil {
+		return "", nil, func() {}, errors.Wrap(err, "creating directory for files")
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	var placed []string
+	for target, source := range files {
+		if !filepath.IsAbs(target) {
+			return "", nil, cleanup, errors.Newf("file target %q is not an absolute path", target)
+		}
+		dst := stepFilePath(dir, target)
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return "", nil, cleanup, errors.Wrapf(err, "creating directory for file %q", target)
+		}
+		if err := copyFile(source.Name(), dst, 0600); err != nil {
+			return "", nil, cleanup, errors.Wrapf(err, "writing file %q", target)
+		}
+		placed = append(placed, target)
+	}
+
+	return dir, placed, cleanup, nil
+}
+
+// stepFilePath returns the path of the file with the given absolute target
+// path in the directory of the files of a step. Cleaning the target first
+// keeps it in dir, even if it has "..".
+func stepFilePath(dir, target string) string {
+	target = filepath.Clean(target)
+	return filepath.Join(dir, strings.TrimPrefix(target, filepath.VolumeName(target)))
+}
+
+// copyFile copies the file src to the new file dst, which gets the given
+// mode.
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHostBaseEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin:/bin",
+		"HOME=/home/mary",
+		"SRC_ACCESS_TOKEN=secret",
+		"GITHUB_TOKEN=secret",
+		"LANG=en_US.UTF-8",
+		"SystemRoot=C:\\Windows",
+		"EMPTY",
+	}
+
+	want := []string{
+		"PATH=/usr/bin:/bin",
+		"HOME=/home/mary",
+		"LANG=en_US.UTF-8",
+		"SystemRoot=C:\\Windows",
+	}
+	if diff := cmp.Diff(want, hostBaseEnv(environ)); diff != "" {
+		t.Errorf("wrong environment (-want +have):\n%s", diff)
+	}
+}
+
+func TestPlaceFilesInDir(t *testing.T) {
+	tempDir := t.TempDir()
+
+	newFile := func(t *testing.T, content string) *os.File {
+		t.Helper()
+		f, err := os.CreateTemp(tempDir, "rendered-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	// Targets outside of the directory, like on the host, stay in it.
+	target := filepath.FromSlash("/tmp/config.json")
+	escaping := filepath.FromSlash("/../../secret.txt")
+	if runtime.GOOS == "windows" {
+		target, escaping = `C:\tmp\config.json`, `C:\..\..\secret.txt`
+	}
+	files := map[string]*os.File{
+		target:   newFile(t, "{}"),
+		escaping: newFile(t, "hunter2"),
+	}
+
+	// Two tasks with the same files don't collide.
+	for i := 0; i < 2; i++ {
+		dir, placed, cleanup, err := placeFilesInDir(tempDir, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup()
+
+		if len(placed) != len(files) {
+			t.Errorf("wrong number of placed files. want=%d, have=%d", len(files), len(placed))
+		}
+		for target, want := range map[string]string{target: "{}", escaping: "hunter2"} {
+			name := stepFilePath(dir, target)
+			if !strings.HasPrefix(name, dir) {
+				t.Errorf("file %s is outside of the directory: %s", target, name)
+			}
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != want {
+				t.Errorf("wrong content of %s. want=%q, have=%q", target, want, content)
+			}
+			if runtime.GOOS != "windows" {
+				info, err := os.Stat(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if mode := info.Mode().Perm(); mode != 0600 {
+					t.Errorf("wrong mode of %s: %o", target, mode)
+				}
+			}
+		}
+
+		cleanup()
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("directory not removed: %v", err)
+		}
+	}
+
+	if _, _, _, err := placeFilesInDir(tempDir, map[string]*os.File{"relative.txt": newFile(t, "")}); err == nil {
+		t.Error("unexpectedly no error for a relative target")
+	}
+}
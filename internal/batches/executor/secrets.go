@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/secrets"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+// stepSecrets returns the secrets of the given step.
+func (t *Task) stepSecrets(step int) []specext.StepSecret {
+	if step >= len(t.Secrets) {
+		return nil
+	}
+	return t.Secrets[step]
+}
+
+// secretEnv returns the environment variables that hold the given secrets as
+// "NAME=value" pairs, together with their names, which are all that's passed
+// to `docker run`, so that the values don't show up in its arguments.
+func secretEnv(refs []specext.StepSecret, values secrets.Values) (names, env []string, err error) {
+	for _, ref := range refs {
+		if ref.Env == "" {
+			continue
+		}
+		value, ok := values[ref.Name]
+		if !ok {
+			return nil, nil, errors.Newf("secret %q is not resolved", ref.Name)
+		}
+		names = append(names, ref.Env)
+		env = append(env, ref.Env+"="+value)
+	}
+	return names, env, nil
+}
+
+// createSecretFiles creates temporary files with the values of the given
+// secrets that are to be mounted into the container that executes the step,
+// by their target path. The files are in a directory that only the current
+// user can access.
+func createSecretFiles(tempDir string, refs []specext.StepSecret, values secrets.Values) (map[string]*os.File, func(), error) {
+	files := map[string]*os.File{}
+	dir := ""
+	cleanup := func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}
+
+	for _, ref := range refs {
+		if ref.File == "" {
+			continue
+		}
+		value, ok := values[ref.Name]
+		if !ok {
+			return nil, cleanup, errors.Newf("secret %q is not resolved", ref.Name)
+		}
+
+		if dir == "" {
+			var err error
+			if dir, err = os.MkdirTemp(tempDir, "secrets-"); err != nil {
+				return nil, cleanup, errors.Wrap(err, "creating directory for secrets")
+			}
+		}
+
+		f, err := os.CreateTemp(dir, "")
+		if err != nil {
+			return nil, cleanup, errors.Wrap(err, "creating secret file")
+		}
+		_, err = f.WriteString(value)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, cleanup, errors.Wrap(err, "writing secret file")
+		}
+		// The container might not run as the current user.
+		if err := os.Chmod(f.Name(), 0644); err != nil {
+			return nil, cleanup, errors.Wrap(err, "setting permissions on secret file")
+		}
+		files[ref.File] = f
+	}
+
+	return files, cleanup, nil
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/batches/secrets"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+func TestStepSecrets(t *testing.T) {
+	refs := []specext.StepSecret{
+		{Name: "token", Env: "GITHUB_TOKEN"},
+		{Name: "npmrc", File: "/root/.npmrc"},
+	}
+	values := secrets.Values{"token": "s3cr3t", "npmrc": "registry=https://example.com"}
+
+	names, env, err := secretEnv(refs, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"GITHUB_TOKEN"}, names); diff != "" {
+		t.Errorf("wrong names (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"GITHUB_TOKEN=s3cr3t"}, env); diff != "" {
+		t.Errorf("wrong env (-want +have):\n%s", diff)
+	}
+
+	files, cleanup, err := createSecretFiles(t.TempDir(), refs, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if len(files) != 1 || files["/root/.npmrc"] == nil {
+		t.Fatalf("wrong files: %v", files)
+	}
+	content, err := os.ReadFile(files["/root/.npmrc"].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != values["npmrc"] {
+		t.Errorf("wrong content: %q", content)
+	}
+
+	if _, _, err := secretEnv(refs, secrets.Values{}); err == nil {
+		t.Error("no error for unresolved secret")
+	}
+}
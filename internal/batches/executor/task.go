@@ -1,13 +1,20 @@
 package executor
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
 	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
 	"github.com/sourcegraph/sourcegraph/lib/batches/execution/cache"
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
 	"github.com/sourcegraph/sourcegraph/lib/batches/template"
 
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/log"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
 )
 
 type Task struct {
@@ -21,8 +28,28 @@ type Task struct {
 	// see RepoFetcher).
 	// If Path is "" then this setting has no effect.
 	OnlyFetchWorkspace bool
+	// Paths are glob patterns that restrict the files in the workspace to the
+	// ones matching them. If there are none, the workspace has all files.
+	Paths []string
 
 	Steps []batcheslib.Step
+	// StepVariants are the matrix variants of the steps, like "go=1.17", by
+	// their index. Steps without a matrix have an empty variant. They are nil
+	// if no step has a matrix.
+	StepVariants []string
+	// Artifacts are the paths of the artifacts of the steps, by their index,
+	// relative to Path. They are nil if no step has any.
+	Artifacts [][]string
+	// Secrets are the secrets of the steps, by their index. They are nil if
+	// no step has any. The values are given to the executor.
+	Secrets [][]specext.StepSecret
+	// OutputSchemas are the JSON schemas of the outputs of the steps, by
+	// their index and the name of the output. They are nil if no step has
+	// any.
+	OutputSchemas []map[string]interface{}
+	// ArtifactsDir is the directory the artifacts are stored in. If it's
+	// empty, they are discarded after the steps were executed.
+	ArtifactsDir string `json:"-"`
 
 	// TODO(mrnugget): this should just be a single BatchSpec field instead, if
 	// we can make it work with caching
@@ -34,6 +61,14 @@ type Task struct {
 
 	CachedResultFound bool                      `json:"-"`
 	CachedResult      execution.AfterStepResult `json:"-"`
+
+	// LogFile is the path of the log file of the execution. It's removed
+	// after the execution, unless it failed, logs are kept or it's in the
+	// directory of a run.
+	LogFile string `json:"-"`
+	// ChangedFiles are the files changed by the steps, once the task was
+	// executed or its result was found in the cache.
+	ChangedFiles *git.Changes `json:"-"`
 }
 
 func (t *Task) ArchivePathToFetch() string {
@@ -43,31 +78,116 @@ func (t *Task) ArchivePathToFetch() string {
 	return ""
 }
 
-func (t *Task) cacheKey(globalEnv []string) *cache.ExecutionKeyWithGlobalEnv {
-	return &cache.ExecutionKeyWithGlobalEnv{
-		GlobalEnv: globalEnv,
-		ExecutionKey: &cache.ExecutionKey{
-			Repository: batcheslib.Repository{
-				ID:          t.Repository.ID,
-				Name:        t.Repository.Name,
-				BaseRef:     t.Repository.BaseRef(),
-				BaseRev:     t.Repository.Rev(),
-				FileMatches: t.Repository.SortedFileMatches(),
+// StepVariant returns the matrix variant of the given step, or an empty
+// string if it has none.
+func (t *Task) StepVariant(step int) string {
+	if step < 0 || step >= len(t.StepVariants) {
+		return ""
+	}
+	return t.StepVariants[step]
+}
+
+// logTask returns the task as it's identified in its log.
+func (t *Task) logTask() log.Task {
+	return log.Task{Repository: t.Repository.Name, Rev: t.Repository.Rev(), Path: t.Path}
+}
+
+func (t *Task) cacheKey(globalEnv []string) *taskCacheKey {
+	return &taskCacheKey{
+		ExecutionKeyWithGlobalEnv: &cache.ExecutionKeyWithGlobalEnv{
+			GlobalEnv: globalEnv,
+			ExecutionKey: &cache.ExecutionKey{
+				Repository: batcheslib.Repository{
+					ID:          t.Repository.ID,
+					Name:        t.Repository.Name,
+					BaseRef:     t.Repository.BaseRef(),
+					BaseRev:     t.Repository.Rev(),
+					FileMatches: t.Repository.SortedFileMatches(),
+				},
+				Path:                  t.Path,
+				OnlyFetchWorkspace:    t.OnlyFetchWorkspace,
+				Steps:                 t.Steps,
+				BatchChangeAttributes: t.BatchChangeAttributes,
 			},
-			Path:                  t.Path,
-			OnlyFetchWorkspace:    t.OnlyFetchWorkspace,
-			Steps:                 t.Steps,
-			BatchChangeAttributes: t.BatchChangeAttributes,
+		},
+		keyExtensions: keyExtensions{
+			Paths:         t.Paths,
+			Artifacts:     t.Artifacts,
+			Secrets:       t.Secrets,
+			OutputSchemas: t.OutputSchemas,
 		},
 	}
 }
 
-func cacheKeyForStep(key *cache.ExecutionKeyWithGlobalEnv, stepIndex int) *cache.StepsCacheKeyWithGlobalEnv {
-	return &cache.StepsCacheKeyWithGlobalEnv{
-		StepsCacheKey: &cache.StepsCacheKey{
-			ExecutionKey: key.ExecutionKey,
-			StepIndex:    stepIndex,
+func cacheKeyForStep(key *taskCacheKey, stepIndex int) *stepCacheKey {
+	return &stepCacheKey{
+		StepsCacheKeyWithGlobalEnv: &cache.StepsCacheKeyWithGlobalEnv{
+			StepsCacheKey: &cache.StepsCacheKey{
+				ExecutionKey: key.ExecutionKey,
+				StepIndex:    stepIndex,
+			},
+			GlobalEnv: key.GlobalEnv,
 		},
-		GlobalEnv: key.GlobalEnv,
+		keyExtensions: key.keyExtensions,
+	}
+}
+
+// taskCacheKey extends the cache key of a Task with the settings that only
+// src-cli knows about. They are only part of the key if they are used, so
+// that the keys of batch specs that don't use them stay the same.
+type taskCacheKey struct {
+	*cache.ExecutionKeyWithGlobalEnv
+	keyExtensions
+}
+
+func (key *taskCacheKey) Key() (string, error) {
+	return key.extend(key.ExecutionKeyWithGlobalEnv)
+}
+
+// stepCacheKey is the taskCacheKey of the results of a single step.
+type stepCacheKey struct {
+	*cache.StepsCacheKeyWithGlobalEnv
+	keyExtensions
+}
+
+func (key *stepCacheKey) Key() (string, error) {
+	return key.extend(key.StepsCacheKeyWithGlobalEnv)
+}
+
+// keyExtensions are the settings of a Task that are part of its cache keys in
+// addition to the ones the keys of the lib have.
+type keyExtensions struct {
+	// Paths restrict the files in the workspace, so the results differ.
+	Paths []string `json:",omitempty"`
+	// Artifacts aren't part of the diff.
+	Artifacts [][]string `json:",omitempty"`
+	// Secrets change the environment of the steps. Only where they are put
+	// is part of the keys, not their values.
+	Secrets [][]specext.StepSecret `json:",omitempty"`
+	// OutputSchemas decide whether the steps fail.
+	OutputSchemas []map[string]interface{} `json:",omitempty"`
+	// Native is whether the steps are executed on the host instead of in
+	// their containers, where the same run script uses other programs.
+	Native bool `json:",omitempty"`
+}
+
+func (ext keyExtensions) empty() bool {
+	return len(ext.Paths) == 0 && len(ext.Artifacts) == 0 && len(ext.Secrets) == 0 && len(ext.OutputSchemas) == 0 && !ext.Native
+}
+
+func (ext keyExtensions) extend(key cache.Keyer) (string, error) {
+	k, err := key.Key()
+	if err != nil || (ext.empty()) {
+		return k, err
+	}
+
+	raw, err := json.Marshal(struct {
+		Key string
+		keyExtensions
+	}{k, ext})
+	if err != nil {
+		return "", err
 	}
+	h := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(h[:16]), nil
 }
@@ -0,0 +1,418 @@
+package executor
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/log"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/batches/util"
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+)
+
+// The HTTP API between a coordinator and its workers consists of two
+// endpoints:
+//
+// GET workerInfoPath returns the workerInfo of the worker.
+//
+// POST workerTasksPath takes a remoteTask, executes it and streams the
+// progress back as newline-delimited JSON encoded workerEvents. The last event
+// is always either a workerEventResult or a workerEventError.
+const (
+	workerInfoPath  = "/v1/info"
+	workerTasksPath = "/v1/tasks"
+)
+
+type workerInfo struct {
+	Parallelism int `json:"parallelism"`
+}
+
+// remoteTask is the wire format of a Task that's sent to a worker.
+type remoteTask struct {
+	Repository            *graphql.Repository             `json:"repository"`
+	Path                  string                          `json:"path"`
+	OnlyFetchWorkspace    bool                            `json:"onlyFetchWorkspace"`
+	Paths                 []string                        `json:"paths,omitempty"`
+	Steps                 []batcheslib.Step               `json:"steps"`
+	Artifacts             [][]string                      `json:"artifacts,omitempty"`
+	OutputSchemas         []map[string]interface{}        `json:"outputSchemas,omitempty"`
+	BatchChangeAttributes *template.BatchChangeAttributes `json:"batchChangeAttributes"`
+
+	CachedResultFound bool                      `json:"cachedResultFound"`
+	CachedResult      execution.AfterStepResult `json:"cachedResult"`
+}
+
+func newRemoteTask(task *Task) *remoteTask {
+	return &remoteTask{
+		Repository:            task.Repository,
+		Path:                  task.Path,
+		OnlyFetchWorkspace:    task.OnlyFetchWorkspace,
+		Paths:                 task.Paths,
+		Steps:                 task.Steps,
+		Artifacts:             task.Artifacts,
+		OutputSchemas:         task.OutputSchemas,
+		BatchChangeAttributes: task.BatchChangeAttributes,
+		CachedResultFound:     task.CachedResultFound,
+		CachedResult:          task.CachedResult,
+	}
+}
+
+func (rt *remoteTask) task() *Task {
+	return &Task{
+		Repository:            rt.Repository,
+		Path:                  rt.Path,
+		OnlyFetchWorkspace:    rt.OnlyFetchWorkspace,
+		Paths:                 rt.Paths,
+		Steps:                 rt.Steps,
+		Artifacts:             rt.Artifacts,
+		OutputSchemas:         rt.OutputSchemas,
+		BatchChangeAttributes: rt.BatchChangeAttributes,
+		CachedResultFound:     rt.CachedResultFound,
+		CachedResult:          rt.CachedResult,
+	}
+}
+
+type workerEventType string
+
+const (
+	workerEventArchiveDownloadStarted          workerEventType = "ARCHIVE_DOWNLOAD_STARTED"
+	workerEventArchiveDownloadFinished         workerEventType = "ARCHIVE_DOWNLOAD_FINISHED"
+	workerEventWorkspaceInitializationStarted  workerEventType = "WORKSPACE_INITIALIZATION_STARTED"
+	workerEventWorkspaceInitializationFinished workerEventType = "WORKSPACE_INITIALIZATION_FINISHED"
+	workerEventSkippingStepsUpto               workerEventType = "SKIPPING_STEPS_UPTO"
+	workerEventStepSkipped                     workerEventType = "STEP_SKIPPED"
+	workerEventStepPreparingStart              workerEventType = "STEP_PREPARING_START"
+	workerEventStepPreparingSuccess            workerEventType = "STEP_PREPARING_SUCCESS"
+	workerEventStepPreparingFailed             workerEventType = "STEP_PREPARING_FAILED"
+	workerEventStepStarted                     workerEventType = "STEP_STARTED"
+	workerEventStepStdout                      workerEventType = "STEP_STDOUT"
+	workerEventStepStderr                      workerEventType = "STEP_STDERR"
+	workerEventCalculatingDiffStarted          workerEventType = "CALCULATING_DIFF_STARTED"
+	workerEventCalculatingDiffFinished         workerEventType = "CALCULATING_DIFF_FINISHED"
+	workerEventStepFinished                    workerEventType = "STEP_FINISHED"
+	workerEventStepFailed                      workerEventType = "STEP_FAILED"
+	workerEventLog                             workerEventType = "LOG"
+	workerEventResult                          workerEventType = "RESULT"
+	workerEventError                           workerEventType = "ERROR"
+)
+
+// workerEvent is a single line in the response stream of a worker. Which
+// fields are set depends on the Type.
+type workerEvent struct {
+	Type workerEventType `json:"type"`
+
+	Step      int                    `json:"step,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Status    string                 `json:"status,omitempty"`
+	ExitCode  int                    `json:"exitCode,omitempty"`
+	RunScript string                 `json:"runScript,omitempty"`
+	Env       map[string]string      `json:"env,omitempty"`
+	Diff      string                 `json:"diff,omitempty"`
+	Changes   *git.Changes           `json:"changes,omitempty"`
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+
+	Result      *execution.Result           `json:"result,omitempty"`
+	StepResults []execution.AfterStepResult `json:"stepResults,omitempty"`
+}
+
+type NewWorkerOpts struct {
+	// Dependencies
+	RepoArchiveRegistry repozip.ArchiveRegistry
+	EnsureImage         imageEnsurer
+	Logger              log.LogManager
+	// PrepareCreator returns the workspace.Creator that is used to execute
+	// the given steps.
+	PrepareCreator func(ctx context.Context, steps []batcheslib.Step) (workspace.Creator, error)
+
+	// Config
+	Parallelism int
+	Timeout     time.Duration
+	TempDir     string
+	// Token, if set, has to be sent by coordinators as a bearer token.
+	Token string
+	// Logf, if set, is called to report which tasks are being executed.
+	Logf func(format string, args ...interface{})
+}
+
+// Worker is an http.Handler that executes Tasks on behalf of a coordinator
+// running on another machine. See NewCoordinatorOpts.Workers.
+type Worker struct {
+	opts NewWorkerOpts
+	sem  chan struct{}
+}
+
+var _ http.Handler = &Worker{}
+
+func NewWorker(opts NewWorkerOpts) *Worker {
+	if opts.Parallelism < 1 {
+		opts.Parallelism = 1
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+
+	return &Worker{
+		opts: opts,
+		sem:  make(chan struct{}, opts.Parallelism),
+	}
+}
+
+func (wk *Worker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if wk.opts.Token != "" && !wk.validToken(r) {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == workerInfoPath && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(workerInfo{Parallelism: wk.opts.Parallelism})
+
+	case r.URL.Path == workerTasksPath && r.Method == http.MethodPost:
+		wk.serveTask(w, r)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// validToken returns whether the request has the token of the worker as a
+// bearer token. The header is compared in constant time, so that the token
+// can't be guessed from how long the comparison takes.
+func (wk *Worker) validToken(r *http.Request) bool {
+	want := []byte("Bearer " + wk.opts.Token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) == 1
+}
+
+func (wk *Worker) serveTask(w http.ResponseWriter, r *http.Request) {
+	var rt remoteTask
+	if err := json.NewDecoder(r.Body).Decode(&rt); err != nil {
+		http.Error(w, fmt.Sprintf("decoding task: %s", err), http.StatusBadRequest)
+		return
+	}
+	if rt.Repository == nil {
+		http.Error(w, "task has no repository", http.StatusBadRequest)
+		return
+	}
+	task := rt.task()
+
+	// We only execute as many tasks at the same time as we're configured to.
+	// Coordinators shouldn't send more than that, but if multiple coordinators
+	// use the same worker, the requests have to wait.
+	select {
+	case wk.sem <- struct{}{}:
+		defer func() { <-wk.sem }()
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	events := newWorkerEventWriter(w)
+
+	slug := util.SlugForPathInRepo(task.Repository.Name, task.Repository.Rev(), task.Path)
+	wk.opts.Logf("Executing %s", slug)
+
+	result, stepResults, err := wk.execute(r.Context(), task, slug, events)
+	if err != nil {
+		wk.opts.Logf("Executing %s failed: %s", slug, err)
+
+		status := err.Error()
+		if sle, ok := err.(singleLineErr); ok {
+			status = sle.SingleLineError()
+		}
+		events.write(workerEvent{Type: workerEventError, Error: err.Error(), Status: status})
+		return
+	}
+
+	wk.opts.Logf("Executing %s succeeded", slug)
+	events.write(workerEvent{Type: workerEventResult, Result: &result, StepResults: stepResults})
+}
+
+func (wk *Worker) execute(ctx context.Context, task *Task, slug string, events *workerEventWriter) (execution.Result, []execution.AfterStepResult, error) {
+	taskLog, err := wk.opts.Logger.AddTask(task.logTask())
+	if err != nil {
+		return execution.Result{}, nil, errors.Wrap(err, "creating log file")
+	}
+	defer taskLog.Close()
+
+	creator, err := wk.opts.PrepareCreator(ctx, task.Steps)
+	if err != nil {
+		taskLog.MarkErrored()
+		return execution.Result{}, nil, errors.Wrap(err, "preparing workspace")
+	}
+
+	opts := &newExecutorOpts{
+		Creator:             creator,
+		RepoArchiveRegistry: wk.opts.RepoArchiveRegistry,
+		EnsureImage:         wk.opts.EnsureImage,
+		Logger:              wk.opts.Logger,
+		Timeout:             wk.opts.Timeout,
+		TempDir:             wk.opts.TempDir,
+	}
+
+	logger := &streamingTaskLogger{TaskLogger: taskLog, events: events}
+	result, stepResults, err := runTask(ctx, opts, task, logger, &streamingStepsExecUI{events: events})
+	if err != nil {
+		taskLog.MarkErrored()
+	}
+	return result, stepResults, err
+}
+
+// workerEventWriter writes workerEvents to a response and flushes them right
+// away, so that coordinators can show the progress.
+type workerEventWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	w   http.ResponseWriter
+}
+
+func newWorkerEventWriter(w http.ResponseWriter) *workerEventWriter {
+	return &workerEventWriter{enc: json.NewEncoder(w), w: w}
+}
+
+func (ew *workerEventWriter) write(e workerEvent) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+
+	// If the coordinator went away, the request context is cancelled and the
+	// execution stops, so we can ignore the error here.
+	_ = ew.enc.Encode(e)
+	if f, ok := ew.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// streamingTaskLogger is a log.TaskLogger that also sends every line of the
+// log to the coordinator.
+type streamingTaskLogger struct {
+	log.TaskLogger
+	events *workerEventWriter
+}
+
+func (l *streamingTaskLogger) Log(s string) {
+	l.TaskLogger.Log(s)
+	l.events.write(workerEvent{Type: workerEventLog, Message: s})
+}
+
+func (l *streamingTaskLogger) Logf(format string, a ...interface{}) {
+	l.Log(fmt.Sprintf(format, a...))
+}
+
+func (l *streamingTaskLogger) StepLogf(step int, format string, a ...interface{}) {
+	l.TaskLogger.StepLogf(step, format, a...)
+	l.events.write(workerEvent{Type: workerEventLog, Step: step, Message: fmt.Sprintf(format, a...)})
+}
+
+// streamingStepsExecUI is a StepsExecutionUI that sends everything to the
+// coordinator, where it's replayed by remoteExecutor.
+type streamingStepsExecUI struct {
+	events *workerEventWriter
+}
+
+var _ StepsExecutionUI = &streamingStepsExecUI{}
+
+func (ui *streamingStepsExecUI) ArchiveDownloadStarted() {
+	ui.events.write(workerEvent{Type: workerEventArchiveDownloadStarted})
+}
+
+func (ui *streamingStepsExecUI) ArchiveDownloadFinished(err error) {
+	ui.events.write(workerEvent{Type: workerEventArchiveDownloadFinished, Error: errorString(err)})
+}
+
+func (ui *streamingStepsExecUI) WorkspaceInitializationStarted() {
+	ui.events.write(workerEvent{Type: workerEventWorkspaceInitializationStarted})
+}
+
+func (ui *streamingStepsExecUI) WorkspaceInitializationFinished() {
+	ui.events.write(workerEvent{Type: workerEventWorkspaceInitializationFinished})
+}
+
+func (ui *streamingStepsExecUI) SkippingStepsUpto(step int) {
+	ui.events.write(workerEvent{Type: workerEventSkippingStepsUpto, Step: step})
+}
+
+func (ui *streamingStepsExecUI) StepSkipped(step int) {
+	ui.events.write(workerEvent{Type: workerEventStepSkipped, Step: step})
+}
+
+func (ui *streamingStepsExecUI) StepPreparingStart(step int) {
+	ui.events.write(workerEvent{Type: workerEventStepPreparingStart, Step: step})
+}
+
+func (ui *streamingStepsExecUI) StepPreparingSuccess(step int) {
+	ui.events.write(workerEvent{Type: workerEventStepPreparingSuccess, Step: step})
+}
+
+func (ui *streamingStepsExecUI) StepPreparingFailed(step int, err error) {
+	ui.events.write(workerEvent{Type: workerEventStepPreparingFailed, Step: step, Error: errorString(err)})
+}
+
+func (ui *streamingStepsExecUI) StepStarted(step int, runScript string, env map[string]string) {
+	ui.events.write(workerEvent{Type: workerEventStepStarted, Step: step, RunScript: runScript, Env: env})
+}
+
+func (ui *streamingStepsExecUI) StepOutputWriter(ctx context.Context, task *Task, step int) StepOutputWriter {
+	return &streamingStepOutputWriter{
+		stdout: &streamingWriter{events: ui.events, typ: workerEventStepStdout, step: step},
+		stderr: &streamingWriter{events: ui.events, typ: workerEventStepStderr, step: step},
+	}
+}
+
+func (ui *streamingStepsExecUI) CalculatingDiffStarted() {
+	ui.events.write(workerEvent{Type: workerEventCalculatingDiffStarted})
+}
+
+func (ui *streamingStepsExecUI) CalculatingDiffFinished() {
+	ui.events.write(workerEvent{Type: workerEventCalculatingDiffFinished})
+}
+
+func (ui *streamingStepsExecUI) StepFinished(step int, diff string, changes *git.Changes, outputs map[string]interface{}) {
+	ui.events.write(workerEvent{Type: workerEventStepFinished, Step: step, Diff: diff, Changes: changes, Outputs: outputs})
+}
+
+func (ui *streamingStepsExecUI) StepFailed(step int, err error, exitCode int) {
+	ui.events.write(workerEvent{Type: workerEventStepFailed, Step: step, Error: errorString(err), ExitCode: exitCode})
+}
+
+type streamingStepOutputWriter struct {
+	stdout, stderr io.Writer
+}
+
+func (w *streamingStepOutputWriter) StdoutWriter() io.Writer { return w.stdout }
+func (w *streamingStepOutputWriter) StderrWriter() io.Writer { return w.stderr }
+func (w *streamingStepOutputWriter) Close() error            { return nil }
+
+type streamingWriter struct {
+	events *workerEventWriter
+	typ    workerEventType
+	step   int
+}
+
+func (w *streamingWriter) Write(p []byte) (int, error) {
+	w.events.write(workerEvent{Type: w.typ, Step: w.step, Message: string(p)})
+	return len(p), nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return strings.TrimSpace(err.Error())
+}
@@ -0,0 +1,92 @@
+package graphql
+
+import "strings"
+
+// ChangesetApplyPreview is what applying a batch spec does to one changeset.
+// Previews of changesets in repositories that the user can't see have no
+// details about the changeset.
+type ChangesetApplyPreview struct {
+	Typename   string   `json:"__typename"`
+	Operations []string `json:"operations"`
+	Targets    struct {
+		Typename      string `json:"__typename"`
+		ChangesetSpec *struct {
+			ID ChangesetSpecID `json:"id"`
+		} `json:"changesetSpec,omitempty"`
+		Changeset *Changeset `json:"changeset,omitempty"`
+	} `json:"targets"`
+}
+
+// The actions of ChangesetApplyPreview.Action.
+const (
+	PreviewActionCreate    = "create"
+	PreviewActionImport    = "import"
+	PreviewActionPush      = "push"
+	PreviewActionUpdate    = "update"
+	PreviewActionClose     = "close"
+	PreviewActionReopen    = "reopen"
+	PreviewActionDetach    = "detach"
+	PreviewActionArchive   = "archive"
+	PreviewActionUnchanged = "unchanged"
+)
+
+// PreviewActions are the actions of ChangesetApplyPreview.Action in the order
+// in which they are summarized.
+var PreviewActions = []string{
+	PreviewActionCreate,
+	PreviewActionImport,
+	PreviewActionPush,
+	PreviewActionUpdate,
+	PreviewActionClose,
+	PreviewActionReopen,
+	PreviewActionDetach,
+	PreviewActionArchive,
+	PreviewActionUnchanged,
+}
+
+// ChangesetSpecID returns the ID of the changeset spec that the changeset is
+// created or updated from, or "" if it's detached.
+func (p *ChangesetApplyPreview) ChangesetSpecID() ChangesetSpecID {
+	if p.Targets.ChangesetSpec == nil {
+		return ""
+	}
+	return p.Targets.ChangesetSpec.ID
+}
+
+// Action returns the most significant thing that happens to the changeset,
+// which is one of PreviewActions. Changesets that don't exist yet are
+// created or imported, and existing ones are closed, reopened, have new
+// commits pushed or are updated in that order of precedence.
+func (p *ChangesetApplyPreview) Action() string {
+	switch {
+	case strings.HasSuffix(p.Targets.Typename, "TargetsDetach"):
+		if p.has("ARCHIVE") {
+			return PreviewActionArchive
+		}
+		return PreviewActionDetach
+	case strings.HasSuffix(p.Targets.Typename, "TargetsAttach"):
+		if p.has("IMPORT") {
+			return PreviewActionImport
+		}
+		return PreviewActionCreate
+	case p.has("CLOSE"):
+		return PreviewActionClose
+	case p.has("REOPEN"):
+		return PreviewActionReopen
+	case p.has("PUSH"):
+		return PreviewActionPush
+	case len(p.Operations) > 0:
+		return PreviewActionUpdate
+	default:
+		return PreviewActionUnchanged
+	}
+}
+
+func (p *ChangesetApplyPreview) has(operation string) bool {
+	for _, op := range p.Operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,46 @@
 package graphql
 
+import "time"
+
+type BatchChangeID string
+
 type BatchChange struct {
-	URL string
+	ID              BatchChangeID   `json:"id"`
+	Name            string          `json:"name"`
+	Description     string          `json:"description"`
+	State           string          `json:"state"`
+	URL             string          `json:"url"`
+	Namespace       Namespace       `json:"namespace"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+	ClosedAt        *time.Time      `json:"closedAt"`
+	ChangesetsStats ChangesetsStats `json:"changesetsStats"`
+}
+
+type Namespace struct {
+	NamespaceName string `json:"namespaceName"`
+	URL           string `json:"url"`
+}
+
+// ChangesetsStats are the number of changesets of a batch change by state.
+type ChangesetsStats struct {
+	Total       int `json:"total"`
+	Unpublished int `json:"unpublished"`
+	Draft       int `json:"draft"`
+	Open        int `json:"open"`
+	Merged      int `json:"merged"`
+	Closed      int `json:"closed"`
+	Deleted     int `json:"deleted"`
+}
+
+// ListBatchChangesOpts are the filters of ListBatchChanges.
+type ListBatchChangesOpts struct {
+	// Namespace is the ID of the user or organization whose batch changes are
+	// listed. All batch changes are listed if it's empty.
+	Namespace string
+	// State is OPEN or CLOSED. Batch changes in any state are listed if it's
+	// empty.
+	State string
+	// First is the maximum number of batch changes. -1 lists all of them.
+	First int
 }
@@ -2,6 +2,10 @@ package graphql
 
 import (
 	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
 )
 
 type batchesBackend struct {
@@ -10,17 +14,39 @@ type batchesBackend struct {
 
 var _ Operations = &batchesBackend{}
 
+const batchChangeFieldsFragment = `
+fragment batchChangeFields on BatchChange {
+    id
+    name
+    description
+    state
+    url
+    namespace {
+        namespaceName
+        url
+    }
+    createdAt
+    updatedAt
+    closedAt
+    changesetsStats {
+        total
+        unpublished
+        draft
+        open
+        merged
+        closed
+        deleted
+    }
+}
+`
+
 const applyBatchChangeMutation = `
 mutation ApplyBatchChange($batchSpec: ID!) {
 	applyBatchChange(batchSpec: $batchSpec) {
 		...batchChangeFields
 	}
 }
-
-fragment batchChangeFields on BatchChange {
-    url
-}
-`
+` + batchChangeFieldsFragment
 
 func (bb *batchesBackend) ApplyBatchChange(ctx context.Context, batchSpecID BatchSpecID) (*BatchChange, error) {
 	var result struct {
@@ -64,3 +90,476 @@ func (bb *batchesBackend) CreateBatchSpec(ctx context.Context, namespace, spec s
 	}
 	return &result.CreateBatchSpec, nil
 }
+
+const listBatchChangesQuery = `
+query BatchChanges($first: Int, $state: BatchChangeState) {
+    batchChanges(first: $first, state: $state) {
+        nodes {
+            ...batchChangeFields
+        }
+    }
+}
+` + batchChangeFieldsFragment
+
+const listNamespaceBatchChangesQuery = `
+query NamespaceBatchChanges($namespace: ID!, $first: Int, $state: BatchChangeState) {
+    node(id: $namespace) {
+        ... on User {
+            batchChanges(first: $first, state: $state) {
+                nodes {
+                    ...batchChangeFields
+                }
+            }
+        }
+        ... on Org {
+            batchChanges(first: $first, state: $state) {
+                nodes {
+                    ...batchChangeFields
+                }
+            }
+        }
+    }
+}
+` + batchChangeFieldsFragment
+
+func (bb *batchesBackend) ListBatchChanges(ctx context.Context, opts ListBatchChangesOpts) ([]*BatchChange, error) {
+	vars := map[string]interface{}{
+		"first": api.NullInt(opts.First),
+		"state": api.NullString(opts.State),
+	}
+
+	type connection struct {
+		Nodes []*BatchChange
+	}
+	if opts.Namespace == "" {
+		var result struct {
+			BatchChanges connection
+		}
+		if ok, err := bb.newRequest(listBatchChangesQuery, vars).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+		return result.BatchChanges.Nodes, nil
+	}
+
+	vars["namespace"] = opts.Namespace
+	var result struct {
+		Node *struct {
+			BatchChanges connection
+		}
+	}
+	if ok, err := bb.newRequest(listNamespaceBatchChangesQuery, vars).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	if result.Node == nil {
+		return nil, errors.Newf("namespace %q not found", opts.Namespace)
+	}
+	return result.Node.BatchChanges.Nodes, nil
+}
+
+const getBatchChangeQuery = `
+query BatchChange($namespace: ID!, $name: String!) {
+    batchChange(namespace: $namespace, name: $name) {
+        ...batchChangeFields
+    }
+}
+` + batchChangeFieldsFragment
+
+func (bb *batchesBackend) GetBatchChange(ctx context.Context, namespace, name string) (*BatchChange, error) {
+	var result struct {
+		BatchChange *BatchChange
+	}
+	if ok, err := bb.newRequest(getBatchChangeQuery, map[string]interface{}{
+		"namespace": namespace,
+		"name":      name,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	return result.BatchChange, nil
+}
+
+const closeBatchChangeMutation = `
+mutation CloseBatchChange($batchChange: ID!, $closeChangesets: Boolean!) {
+    closeBatchChange(batchChange: $batchChange, closeChangesets: $closeChangesets) {
+        ...batchChangeFields
+    }
+}
+` + batchChangeFieldsFragment
+
+func (bb *batchesBackend) CloseBatchChange(ctx context.Context, id BatchChangeID, closeChangesets bool) (*BatchChange, error) {
+	var result struct {
+		BatchChange *BatchChange `json:"closeBatchChange"`
+	}
+	if ok, err := bb.newRequest(closeBatchChangeMutation, map[string]interface{}{
+		"batchChange":     id,
+		"closeChangesets": closeChangesets,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	return result.BatchChange, nil
+}
+
+const deleteBatchChangeMutation = `
+mutation DeleteBatchChange($batchChange: ID!) {
+    deleteBatchChange(batchChange: $batchChange) {
+        alwaysNil
+    }
+}
+`
+
+func (bb *batchesBackend) DeleteBatchChange(ctx context.Context, id BatchChangeID) error {
+	var result struct {
+		DeleteBatchChange struct {
+			AlwaysNil *string
+		}
+	}
+	if ok, err := bb.newRequest(deleteBatchChangeMutation, map[string]interface{}{
+		"batchChange": id,
+	}).Do(ctx, &result); err != nil || !ok {
+		return err
+	}
+	return nil
+}
+
+// changesetsPageSize is the number of changesets ListChangesets requests at a
+// time.
+const changesetsPageSize = 100
+
+const listChangesetsQuery = `
+query BatchChangeChangesets(
+    $batchChange: ID!,
+    $first: Int!,
+    $after: String,
+    $state: ChangesetState,
+    $reviewState: ChangesetReviewState,
+    $checkState: ChangesetCheckState
+) {
+    node(id: $batchChange) {
+        ... on BatchChange {
+            changesets(
+                first: $first,
+                after: $after,
+                state: $state,
+                reviewState: $reviewState,
+                checkState: $checkState
+            ) {
+                nodes {
+                    __typename
+                    ... on HiddenExternalChangeset {
+                        id
+                        state
+                    }
+                    ... on ExternalChangeset {
+                        id
+                        state
+                        reviewState
+                        checkState
+                        title
+                        externalID
+                        externalURL {
+                            url
+                        }
+                        repository {
+                            name
+                        }
+                        error
+                    }
+                }
+                pageInfo {
+                    hasNextPage
+                    endCursor
+                }
+            }
+        }
+    }
+}
+`
+
+func (bb *batchesBackend) ListChangesets(ctx context.Context, opts ListChangesetsOpts) ([]*Changeset, error) {
+	var (
+		changesets []*Changeset
+		after      *string
+	)
+	for {
+		var result struct {
+			Node *struct {
+				Changesets struct {
+					Nodes    []*Changeset
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   *string
+					}
+				}
+			}
+		}
+		if ok, err := bb.newRequest(listChangesetsQuery, map[string]interface{}{
+			"batchChange": opts.BatchChange,
+			"first":       changesetsPageSize,
+			"after":       after,
+			"state":       api.NullString(opts.State),
+			"reviewState": api.NullString(opts.ReviewState),
+			"checkState":  api.NullString(opts.CheckState),
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+		if result.Node == nil {
+			return nil, errors.Newf("batch change %q not found", opts.BatchChange)
+		}
+
+		page := result.Node.Changesets
+		changesets = append(changesets, page.Nodes...)
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == nil {
+			return changesets, nil
+		}
+		after = page.PageInfo.EndCursor
+	}
+}
+
+const bulkOperationFieldsFragment = `
+fragment bulkOperationFields on BulkOperation {
+    id
+    type
+    state
+    progress
+    changesetCount
+    errors {
+        changeset {
+            id
+        }
+        error
+    }
+    createdAt
+    finishedAt
+}
+`
+
+const mergeChangesetsMutation = `
+mutation MergeChangesets($batchChange: ID!, $changesets: [ID!]!, $squash: Boolean!) {
+    mergeChangesets(batchChange: $batchChange, changesets: $changesets, squash: $squash) {
+        ...bulkOperationFields
+    }
+}
+` + bulkOperationFieldsFragment
+
+func (bb *batchesBackend) MergeChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID, squash bool) (*BulkOperation, error) {
+	return bb.bulkOperation(ctx, mergeChangesetsMutation, "mergeChangesets", map[string]interface{}{
+		"batchChange": batchChange,
+		"changesets":  changesets,
+		"squash":      squash,
+	})
+}
+
+const closeChangesetsMutation = `
+mutation CloseChangesets($batchChange: ID!, $changesets: [ID!]!) {
+    closeChangesets(batchChange: $batchChange, changesets: $changesets) {
+        ...bulkOperationFields
+    }
+}
+` + bulkOperationFieldsFragment
+
+func (bb *batchesBackend) CloseChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error) {
+	return bb.bulkOperation(ctx, closeChangesetsMutation, "closeChangesets", map[string]interface{}{
+		"batchChange": batchChange,
+		"changesets":  changesets,
+	})
+}
+
+const createChangesetCommentsMutation = `
+mutation CreateChangesetComments($batchChange: ID!, $changesets: [ID!]!, $body: String!) {
+    createChangesetComments(batchChange: $batchChange, changesets: $changesets, body: $body) {
+        ...bulkOperationFields
+    }
+}
+` + bulkOperationFieldsFragment
+
+func (bb *batchesBackend) CreateChangesetComments(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID, body string) (*BulkOperation, error) {
+	return bb.bulkOperation(ctx, createChangesetCommentsMutation, "createChangesetComments", map[string]interface{}{
+		"batchChange": batchChange,
+		"changesets":  changesets,
+		"body":        body,
+	})
+}
+
+const reenqueueChangesetsMutation = `
+mutation ReenqueueChangesets($batchChange: ID!, $changesets: [ID!]!) {
+    reenqueueChangesets(batchChange: $batchChange, changesets: $changesets) {
+        ...bulkOperationFields
+    }
+}
+` + bulkOperationFieldsFragment
+
+func (bb *batchesBackend) ReenqueueChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error) {
+	return bb.bulkOperation(ctx, reenqueueChangesetsMutation, "reenqueueChangesets", map[string]interface{}{
+		"batchChange": batchChange,
+		"changesets":  changesets,
+	})
+}
+
+const detachChangesetsMutation = `
+mutation DetachChangesets($batchChange: ID!, $changesets: [ID!]!) {
+    detachChangesets(batchChange: $batchChange, changesets: $changesets) {
+        ...bulkOperationFields
+    }
+}
+` + bulkOperationFieldsFragment
+
+func (bb *batchesBackend) DetachChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error) {
+	return bb.bulkOperation(ctx, detachChangesetsMutation, "detachChangesets", map[string]interface{}{
+		"batchChange": batchChange,
+		"changesets":  changesets,
+	})
+}
+
+// bulkOperation executes one of the mutations that create a bulk operation,
+// which returns it as field.
+func (bb *batchesBackend) bulkOperation(ctx context.Context, mutation, field string, vars map[string]interface{}) (*BulkOperation, error) {
+	var result map[string]*BulkOperation
+	if ok, err := bb.newRequest(mutation, vars).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	return result[field], nil
+}
+
+const getBulkOperationQuery = `
+query BulkOperation($bulkOperation: ID!) {
+    node(id: $bulkOperation) {
+        ... on BulkOperation {
+            ...bulkOperationFields
+        }
+    }
+}
+` + bulkOperationFieldsFragment
+
+func (bb *batchesBackend) GetBulkOperation(ctx context.Context, id BulkOperationID) (*BulkOperation, error) {
+	var result struct {
+		Node *BulkOperation
+	}
+	if ok, err := bb.newRequest(getBulkOperationQuery, map[string]interface{}{
+		"bulkOperation": id,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	return result.Node, nil
+}
+
+// applyPreviewPageSize is the number of changesets ApplyPreview requests at a
+// time.
+const applyPreviewPageSize = 100
+
+const applyPreviewQuery = `
+query ApplyPreview($batchSpec: ID!, $first: Int!, $after: String) {
+    node(id: $batchSpec) {
+        ... on BatchSpec {
+            applyPreview(first: $first, after: $after) {
+                nodes {
+                    __typename
+                    ... on VisibleChangesetApplyPreview {
+                        operations
+                        targets {
+                            __typename
+                            ... on VisibleApplyPreviewTargetsAttach {
+                                changesetSpec {
+                                    id
+                                }
+                            }
+                            ... on VisibleApplyPreviewTargetsUpdate {
+                                changesetSpec {
+                                    id
+                                }
+                                changeset {
+                                    ...previewChangesetFields
+                                }
+                            }
+                            ... on VisibleApplyPreviewTargetsDetach {
+                                changeset {
+                                    ...previewChangesetFields
+                                }
+                            }
+                        }
+                    }
+                    ... on HiddenChangesetApplyPreview {
+                        operations
+                        targets {
+                            __typename
+                            ... on HiddenApplyPreviewTargetsAttach {
+                                changesetSpec {
+                                    id
+                                }
+                            }
+                            ... on HiddenApplyPreviewTargetsUpdate {
+                                changesetSpec {
+                                    id
+                                }
+                                changeset {
+                                    id
+                                    state
+                                }
+                            }
+                            ... on HiddenApplyPreviewTargetsDetach {
+                                changeset {
+                                    id
+                                    state
+                                }
+                            }
+                        }
+                    }
+                }
+                pageInfo {
+                    hasNextPage
+                    endCursor
+                }
+            }
+        }
+    }
+}
+
+fragment previewChangesetFields on ExternalChangeset {
+    __typename
+    id
+    state
+    title
+    externalID
+    externalURL {
+        url
+    }
+    repository {
+        name
+    }
+}
+`
+
+func (bb *batchesBackend) ApplyPreview(ctx context.Context, batchSpecID BatchSpecID) ([]*ChangesetApplyPreview, error) {
+	var (
+		previews []*ChangesetApplyPreview
+		after    *string
+	)
+	for {
+		var result struct {
+			Node *struct {
+				ApplyPreview struct {
+					Nodes    []*ChangesetApplyPreview
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   *string
+					}
+				}
+			}
+		}
+		if ok, err := bb.newRequest(applyPreviewQuery, map[string]interface{}{
+			"batchSpec": batchSpecID,
+			"first":     applyPreviewPageSize,
+			"after":     after,
+		}).Do(ctx, &result); err != nil || !ok {
+			return nil, err
+		}
+		if result.Node == nil {
+			return nil, errors.Newf("batch spec %q not found", batchSpecID)
+		}
+
+		page := result.Node.ApplyPreview
+		previews = append(previews, page.Nodes...)
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == nil {
+			return previews, nil
+		}
+		after = page.PageInfo.EndCursor
+	}
+}
@@ -1,6 +1,10 @@
 package graphql
 
-import "context"
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+)
 
 type campaignsBackend struct {
 	commonBackend
@@ -8,6 +12,10 @@ type campaignsBackend struct {
 
 var _ Operations = &campaignsBackend{}
 
+// ErrCampaigns is returned by the operations that src-cli only supports on
+// instances that have batch changes rather than campaigns.
+var ErrCampaigns = errors.New("this requires Sourcegraph 3.26 or later")
+
 const applyCampaignMutation = `
 mutation ApplyCampaign($campaignSpec: ID!) {
 	applyCampaign(campaignSpec: $campaignSpec) {
@@ -62,3 +70,51 @@ func (cb *campaignsBackend) CreateBatchSpec(ctx context.Context, namespace, spec
 	}
 	return &result.CreateCampaignSpec, nil
 }
+
+func (cb *campaignsBackend) ListBatchChanges(ctx context.Context, opts ListBatchChangesOpts) ([]*BatchChange, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) GetBatchChange(ctx context.Context, namespace, name string) (*BatchChange, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) CloseBatchChange(ctx context.Context, id BatchChangeID, closeChangesets bool) (*BatchChange, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) DeleteBatchChange(ctx context.Context, id BatchChangeID) error {
+	return ErrCampaigns
+}
+
+func (cb *campaignsBackend) ListChangesets(ctx context.Context, opts ListChangesetsOpts) ([]*Changeset, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) MergeChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID, squash bool) (*BulkOperation, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) CloseChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) CreateChangesetComments(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID, body string) (*BulkOperation, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) ReenqueueChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) DetachChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) GetBulkOperation(ctx context.Context, id BulkOperationID) (*BulkOperation, error) {
+	return nil, ErrCampaigns
+}
+
+func (cb *campaignsBackend) ApplyPreview(ctx context.Context, batchSpecID BatchSpecID) ([]*ChangesetApplyPreview, error) {
+	return nil, ErrCampaigns
+}
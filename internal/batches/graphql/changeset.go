@@ -0,0 +1,105 @@
+package graphql
+
+import "time"
+
+type ChangesetID string
+
+// Changeset is a changeset of a batch change. Changesets in repositories that
+// the user can't see only have an ID and a state.
+type Changeset struct {
+	Typename    string      `json:"__typename"`
+	ID          ChangesetID `json:"id"`
+	State       string      `json:"state"`
+	ReviewState string      `json:"reviewState,omitempty"`
+	CheckState  string      `json:"checkState,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	ExternalID  string      `json:"externalID,omitempty"`
+	ExternalURL *struct {
+		URL string `json:"url"`
+	} `json:"externalURL,omitempty"`
+	Repository *struct {
+		Name string `json:"name"`
+	} `json:"repository,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Hidden returns whether the changeset is in a repository that the user can't
+// see.
+func (c *Changeset) Hidden() bool {
+	return c.Typename == "HiddenExternalChangeset"
+}
+
+// RepositoryName returns the name of the repository of the changeset, or ""
+// if it's hidden.
+func (c *Changeset) RepositoryName() string {
+	if c.Repository == nil {
+		return ""
+	}
+	return c.Repository.Name
+}
+
+// ListChangesetsOpts are the filters of ListChangesets. The filters that are
+// empty aren't applied.
+type ListChangesetsOpts struct {
+	BatchChange BatchChangeID
+	State       string
+	ReviewState string
+	CheckState  string
+}
+
+type BulkOperationID string
+
+// The states of a BulkOperation.
+const (
+	BulkOperationProcessing = "PROCESSING"
+	BulkOperationFailed     = "FAILED"
+	BulkOperationCompleted  = "COMPLETED"
+)
+
+// BulkOperation is an operation that the instance applies to many changesets
+// in the background.
+type BulkOperation struct {
+	ID             BulkOperationID      `json:"id"`
+	Type           string               `json:"type"`
+	State          string               `json:"state"`
+	Progress       float64              `json:"progress"`
+	ChangesetCount int                  `json:"changesetCount"`
+	Errors         []*ChangesetJobError `json:"errors"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	FinishedAt     *time.Time           `json:"finishedAt"`
+}
+
+// ChangesetJobError is the error of a BulkOperation for one changeset.
+type ChangesetJobError struct {
+	Changeset struct {
+		ID ChangesetID `json:"id"`
+	} `json:"changeset"`
+	Error *string `json:"error"`
+}
+
+// ChangesetErrors returns the errors of the bulk operation by changeset.
+// Changesets without an error have been processed successfully, once the bulk
+// operation is no longer processing.
+func (op *BulkOperation) ChangesetErrors() map[ChangesetID]string {
+	errs := make(map[ChangesetID]string, len(op.Errors))
+	for _, e := range op.Errors {
+		msg := "unknown error"
+		if e.Error != nil {
+			msg = *e.Error
+		}
+		errs[e.Changeset.ID] = msg
+	}
+	return errs
+}
+
+// DisplayName returns the name of the changeset in output, like
+// "github.com/sourcegraph/src-cli#123".
+func (c *Changeset) DisplayName() string {
+	if c.Hidden() || c.Repository == nil {
+		return "hidden changeset " + string(c.ID)
+	}
+	if c.ExternalID == "" {
+		return c.Repository.Name
+	}
+	return c.Repository.Name + "#" + c.ExternalID
+}
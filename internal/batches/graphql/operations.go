@@ -14,6 +14,27 @@ import (
 type Operations interface {
 	ApplyBatchChange(ctx context.Context, batchSpecID BatchSpecID) (*BatchChange, error)
 	CreateBatchSpec(ctx context.Context, namespace, spec string, changesetSpecIDs []ChangesetSpecID) (*CreateBatchSpecResponse, error)
+
+	ListBatchChanges(ctx context.Context, opts ListBatchChangesOpts) ([]*BatchChange, error)
+	// GetBatchChange returns the batch change with the given name in the
+	// namespace with the given ID, or nil if there is none.
+	GetBatchChange(ctx context.Context, namespace, name string) (*BatchChange, error)
+	CloseBatchChange(ctx context.Context, id BatchChangeID, closeChangesets bool) (*BatchChange, error)
+	DeleteBatchChange(ctx context.Context, id BatchChangeID) error
+
+	ListChangesets(ctx context.Context, opts ListChangesetsOpts) ([]*Changeset, error)
+	MergeChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID, squash bool) (*BulkOperation, error)
+	CloseChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error)
+	CreateChangesetComments(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID, body string) (*BulkOperation, error)
+	ReenqueueChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error)
+	DetachChangesets(ctx context.Context, batchChange BatchChangeID, changesets []ChangesetID) (*BulkOperation, error)
+	// GetBulkOperation returns the bulk operation with the given ID, or nil if
+	// there is none.
+	GetBulkOperation(ctx context.Context, id BulkOperationID) (*BulkOperation, error)
+
+	// ApplyPreview returns what applying the batch spec does to each
+	// changeset.
+	ApplyPreview(ctx context.Context, batchSpecID BatchSpecID) ([]*ChangesetApplyPreview, error)
 }
 
 type BatchSpecID string
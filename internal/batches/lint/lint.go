@@ -0,0 +1,311 @@
+// Package lint finds mistakes in batch specs that are valid according to the
+// schema, but that won't do what their author intended.
+package lint
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic is a single problem found in a batch spec.
+type Diagnostic struct {
+	// Line and Column are 1-based. They are 0 if the problem can't be tied to
+	// a position in the batch spec.
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	// Rule identifies the check that found the problem.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// Fix suggests how to fix the problem.
+	Fix string `json:"fix,omitempty"`
+}
+
+// Options configure Lint.
+type Options struct {
+	// Repos are the names of the repositories the batch spec applies to. If
+	// nil, the checks that need them are skipped.
+	Repos []string
+}
+
+// Lint checks the given batch spec and returns the problems it found, ordered
+// by their position. It doesn't validate the batch spec against the schema,
+// and it tolerates batch specs that don't match it.
+func Lint(data []byte, opts Options) []Diagnostic {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []Diagnostic{yamlErrorDiagnostic(err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	l := &linter{
+		lines: strings.Split(string(data), "\n"),
+		opts:  opts,
+	}
+
+	root := resolve(doc.Content[0])
+	if root.Kind != yaml.MappingNode {
+		l.add(root, 0, SeverityError, "yaml", "the batch spec is not a YAML object", "")
+		return l.diags
+	}
+
+	l.lintSteps(root)
+	l.lintChangesetTemplate(root)
+	l.lintBranches(root)
+	l.lintWorkspaces(root)
+
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		if l.diags[i].Line != l.diags[j].Line {
+			return l.diags[i].Line < l.diags[j].Line
+		}
+		return l.diags[i].Column < l.diags[j].Column
+	})
+
+	return l.diags
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+func yamlErrorDiagnostic(err error) Diagnostic {
+	d := Diagnostic{
+		Severity: SeverityError,
+		Rule:     "yaml",
+		Message:  strings.TrimPrefix(err.Error(), "yaml: "),
+	}
+	if m := yamlErrorLine.FindStringSubmatch(d.Message); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Column = 1
+	}
+	return d
+}
+
+type linter struct {
+	lines []string
+	opts  Options
+	diags []Diagnostic
+
+	// outputs maps the names of the outputs the steps define to the index of
+	// the first step that defines them.
+	outputs map[string]int
+}
+
+// add adds a diagnostic at the given offset into the value of node.
+func (l *linter) add(node *yaml.Node, offset int, severity Severity, rule, message, fix string) {
+	line, column := l.position(node, offset)
+	l.diags = append(l.diags, Diagnostic{
+		Line:     line,
+		Column:   column,
+		Severity: severity,
+		Rule:     rule,
+		Message:  message,
+		Fix:      fix,
+	})
+}
+
+// position returns the line and column of the given offset into the value of
+// the scalar node. For anything that's not a scalar, or if the offset can't be
+// mapped back to the source, the position of the node itself is returned.
+func (l *linter) position(node *yaml.Node, offset int) (line, column int) {
+	if offset <= 0 || offset > len(node.Value) || node.Kind != yaml.ScalarNode {
+		return node.Line, node.Column
+	}
+
+	before := node.Value[:offset]
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		if strings.Contains(before, "\n") {
+			return node.Line, node.Column
+		}
+		column = node.Column + offset
+		if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			column++
+		}
+		return node.Line, column
+	}
+
+	// The content of block scalars starts on the line after the indicator and
+	// is indented.
+	line = node.Line + 1 + strings.Count(before, "\n")
+	column = offset - strings.LastIndex(before, "\n")
+	if line-1 < len(l.lines) {
+		src := l.lines[line-1]
+		column += len(src) - len(strings.TrimLeft(src, " \t"))
+	}
+	return line, column
+}
+
+func (l *linter) lintSteps(root *yaml.Node) {
+	_, steps := lookup(root, "steps")
+	if steps == nil || steps.Kind != yaml.SequenceNode {
+		return
+	}
+
+	l.outputs = map[string]int{}
+	for i, step := range steps.Content {
+		_, outputs := lookup(resolve(step), "outputs")
+		for _, name := range keys(outputs) {
+			if _, ok := l.outputs[name.Value]; !ok {
+				l.outputs[name.Value] = i
+			}
+		}
+	}
+
+	for i, step := range steps.Content {
+		step = resolve(step)
+		if step.Kind != yaml.MappingNode {
+			continue
+		}
+		sc := scope{step: i}
+		if _, matrix := lookup(step, "matrix"); matrix != nil {
+			sc.matrix = []string{}
+			for _, name := range keys(matrix) {
+				sc.matrix = append(sc.matrix, name.Value)
+			}
+			sort.Strings(sc.matrix)
+		}
+
+		if _, run := lookup(step, "run"); run != nil {
+			l.checkTemplate(run, sc)
+			l.checkWrites(run)
+		}
+
+		if _, container := lookup(step, "container"); container != nil {
+			l.checkImage(container)
+		}
+
+		if _, env := lookup(step, "env"); env != nil {
+			switch env.Kind {
+			case yaml.MappingNode:
+				for _, v := range values(env) {
+					l.checkTemplate(v, sc)
+				}
+			case yaml.SequenceNode:
+				for _, item := range env.Content {
+					item = resolve(item)
+					if item.Kind == yaml.MappingNode {
+						for _, v := range values(item) {
+							l.checkTemplate(v, sc)
+						}
+					}
+				}
+			}
+		}
+
+		if _, files := lookup(step, "files"); files != nil {
+			for _, v := range values(files) {
+				l.checkTemplate(v, sc)
+			}
+		}
+
+		if _, cond := lookup(step, "if"); cond != nil {
+			l.checkCondition(cond, sc)
+		}
+
+		if _, outputs := lookup(step, "outputs"); outputs != nil {
+			for _, output := range values(outputs) {
+				if _, value := lookup(output, "value"); value != nil {
+					l.checkTemplate(value, scope{step: i, outputs: true, matrix: sc.matrix})
+				}
+			}
+		}
+	}
+}
+
+func (l *linter) lintChangesetTemplate(root *yaml.Node) {
+	_, tmpl := lookup(root, "changesetTemplate")
+	if tmpl == nil {
+		return
+	}
+
+	sc := scope{step: -1}
+	for _, field := range []string{"title", "body", "branch"} {
+		if _, v := lookup(tmpl, field); v != nil {
+			l.checkTemplate(v, sc)
+		}
+	}
+
+	_, commit := lookup(tmpl, "commit")
+	if commit == nil {
+		return
+	}
+	if _, message := lookup(commit, "message"); message != nil {
+		l.checkTemplate(message, sc)
+	}
+	if _, author := lookup(commit, "author"); author != nil {
+		for _, field := range []string{"name", "email"} {
+			if _, v := lookup(author, field); v != nil {
+				l.checkTemplate(v, sc)
+			}
+		}
+	}
+}
+
+// resolve follows aliases.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// lookup returns the key and value nodes of the given key in a mapping node,
+// or nils if node is not a mapping or doesn't have the key.
+func lookup(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	node = resolve(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], resolve(node.Content[i+1])
+		}
+	}
+	return nil, nil
+}
+
+// keys returns the key nodes of a mapping node.
+func keys(node *yaml.Node) []*yaml.Node {
+	node = resolve(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var ks []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		ks = append(ks, node.Content[i])
+	}
+	return ks
+}
+
+// values returns the value nodes of a mapping node.
+func values(node *yaml.Node) []*yaml.Node {
+	node = resolve(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var vs []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		vs = append(vs, resolve(node.Content[i+1]))
+	}
+	return vs
+}
+
+// scalar returns the value of node if it's a scalar.
+func scalar(node *yaml.Node) (string, bool) {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return node.Value, true
+}
@@ -0,0 +1,200 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-multierror"
+)
+
+func TestLint(t *testing.T) {
+	tests := map[string]struct {
+		spec  string
+		repos []string
+		want  []Diagnostic
+	}{
+		"no problems": {
+			spec: `
+name: hello-world
+on:
+  - repositoriesMatchingQuery: file:README.md
+steps:
+  - run: echo "${{ repository.name }}" >> README.md
+    container: alpine:3
+    outputs:
+      greeting:
+        value: ${{ step.stdout }}
+  - run: |
+      echo ${{ outputs.greeting }} | tee -a README.md
+      echo done 2>&1 > /dev/null
+    container: alpine@sha256:e2e16842c9b54d985bf1ef9242a313f36b856181f188de21313820e177002501
+    if: ${{ matches repository.name "github.com/sourcegraph/*" }}
+changesetTemplate:
+  title: Hello World
+  body: ${{ join steps.modified_files ", " }}
+  branch: hello-world
+  commit:
+    message: ${{ outputs.greeting }}
+`,
+		},
+		"templates": {
+			spec: `
+steps:
+  - run: echo ${{ outputs.later }} ${{ previous_step.stdout }}
+    container: alpine:3
+  - run: |
+      echo ${{ repository.nmae }}
+      echo ${{ uppercase step.stdout }}
+    container: alpine:3
+    outputs:
+      later:
+        value: ${{ outputs.later }}
+changesetTemplate:
+  title: ${{ outputs.undefined }}
+  body: "${{ previous_step.stdout }}"
+  branch: ${{ if }}
+`,
+			want: []Diagnostic{
+				{Line: 3, Column: 19, Severity: SeverityError, Rule: "output-undefined", Message: `output "later" is defined by step 2, which runs after step 1`, Fix: "define the output in an earlier step, or move the reference to a later step"},
+				{Line: 3, Column: 40, Severity: SeverityWarning, Rule: "template-unavailable", Message: "previous_step is always empty in the first step", Fix: "remove the reference, or move the step after the one it refers to"},
+				{Line: 6, Column: 16, Severity: SeverityError, Rule: "template-unknown-field", Message: `repository has no field "nmae"`, Fix: "use one of the fields name, search_result_paths"},
+				{Line: 7, Column: 16, Severity: SeverityError, Rule: "template-undefined", Message: `"uppercase" is not a template variable or function`, Fix: "use one of the variables repository, batch_change, outputs, steps, previous_step"},
+				{Line: 7, Column: 26, Severity: SeverityError, Rule: "template-unavailable", Message: "step is only available in the outputs of a step, not in steps", Fix: "use previous_step to refer to the result of the previous step, or steps to refer to the results of all steps"},
+				{Line: 13, Column: 14, Severity: SeverityError, Rule: "output-undefined", Message: `output "undefined" is not defined by any step`, Fix: "define it in the outputs of a step, or use one of the outputs later"},
+				{Line: 14, Column: 14, Severity: SeverityError, Rule: "template-unavailable", Message: "previous_step is not available in changesetTemplate", Fix: "use steps or outputs to refer to the results of the steps"},
+				{Line: 15, Column: 11, Severity: SeverityError, Rule: "template-syntax", Message: "invalid template: 1: missing value for if", Fix: "fix the syntax of the template"},
+			},
+		},
+		"matrix": {
+			spec: `
+steps:
+  - run: echo ${{ matrix.go }} ${{ matrix.os }}
+    container: golang:${{ matrix.go }}
+    matrix:
+      go: ["1.17", "1.18"]
+  - run: echo ${{ matrix.go }}
+    container: alpine:3
+`,
+			want: []Diagnostic{
+				{Line: 3, Column: 36, Severity: SeverityError, Rule: "template-unknown-field", Message: `the matrix has no value "os"`, Fix: "use one of the values go, or add it to the matrix"},
+				{Line: 7, Column: 19, Severity: SeverityError, Rule: "template-unavailable", Message: "matrix is only available in steps with a matrix, not in a step without one", Fix: "add a matrix to the step"},
+			},
+		},
+		"conditions": {
+			spec: `
+steps:
+  - run: echo 1
+    container: alpine:3
+    if: false
+  - run: echo 2
+    container: alpine:3
+    if: "yes"
+  - run: echo 3
+    container: alpine:3
+    if: ${{ eq 1 2 }}
+  - run: echo 4
+    container: alpine:3
+    if: ${{ true }}
+`,
+			want: []Diagnostic{
+				{Line: 5, Column: 9, Severity: SeverityWarning, Rule: "condition", Message: "the condition is never true, so step 1 never runs", Fix: "remove the step, or use a condition that depends on the repository or the previous steps"},
+				{Line: 8, Column: 9, Severity: SeverityWarning, Rule: "condition", Message: "the condition is never true, so step 2 never runs", Fix: "remove the step, or use a condition that depends on the repository or the previous steps"},
+				{Line: 11, Column: 9, Severity: SeverityWarning, Rule: "condition", Message: "the condition is never true, so step 3 never runs", Fix: "remove the step, or use a condition that depends on the repository or the previous steps"},
+				{Line: 14, Column: 9, Severity: SeverityInfo, Rule: "condition", Message: "the condition is always true", Fix: "remove the condition"},
+			},
+		},
+		"images and writes": {
+			spec: `
+steps:
+  - run: |
+      echo hello > /etc/motd
+      # echo hello > /etc/issue
+      sed 's/a/b/' README.md | tee ../README.md > /tmp/log
+    container: alpine
+  - run: echo hello >> /work/README.md
+    container: sourcegraph/comby:latest
+  - run: echo hello >> README.md
+    container: localhost:5000/comby:1.0
+`,
+			want: []Diagnostic{
+				{Line: 4, Column: 20, Severity: SeverityWarning, Rule: "write-outside-workspace", Message: "the step writes to /etc/motd, which is outside the workspace, so the changes are not part of the diff", Fix: "write to a path in the workspace, or to /tmp for files that shouldn't be part of the diff"},
+				{Line: 6, Column: 36, Severity: SeverityWarning, Rule: "write-outside-workspace", Message: "the step writes to ../README.md, which is outside the workspace, so the changes are not part of the diff", Fix: "write to a path in the workspace, or to /tmp for files that shouldn't be part of the diff"},
+				{Line: 7, Column: 16, Severity: SeverityWarning, Rule: "unpinned-image", Message: `the image "alpine" has no tag, so the latest version is used and the results can change between executions`, Fix: "pin the image to a version, like alpine:<version>, or to a digest"},
+				{Line: 9, Column: 16, Severity: SeverityWarning, Rule: "unpinned-image", Message: `the image "sourcegraph/comby:latest" uses the tag latest, so the results can change between executions`, Fix: "pin the image to a version, like sourcegraph/comby:<version>, or to a digest"},
+			},
+		},
+		"branches": {
+			spec: `
+workspaces:
+  - rootAtLocationOf: package.json
+    in: github.com/sourcegraph/*
+  - rootAtLocationOf: go.mod
+    in: "[invalid"
+changesetTemplate:
+  branch: hello-world
+transformChanges:
+  group:
+    - directory: client
+      branch: hello-world
+    - directory: a
+      branch: other
+      repository: github.com/sourcegraph/a
+    - directory: b
+      branch: other
+      repository: github.com/sourcegraph/b
+`,
+			repos: []string{"github.com/golang/go"},
+			want: []Diagnostic{
+				{Line: 4, Column: 9, Severity: SeverityWarning, Rule: "workspaces-in", Message: `the glob "github.com/sourcegraph/*" matches none of the 1 repositories the batch spec applies to`, Fix: "fix the glob, or remove the workspace configuration"},
+				{Line: 6, Column: 9, Severity: SeverityError, Rule: "workspaces-in", Message: `invalid glob "[invalid": unexpected end of input`, Fix: "fix the glob"},
+				{Line: 8, Column: 11, Severity: SeverityWarning, Rule: "duplicate-branch", Message: "the branch is the same in every workspace, so the changesets of repositories with multiple workspaces have the same branch", Fix: "add ${{ steps.path }} to the branch"},
+				{Line: 12, Column: 15, Severity: SeverityError, Rule: "duplicate-branch", Message: `the branch "hello-world" is also used by changesetTemplate.branch on line 8`, Fix: "use a different branch for every changeset in a repository"},
+			},
+		},
+		"invalid yaml": {
+			spec: "name: foo\nsteps: [\n",
+			want: []Diagnostic{
+				{Line: 2, Column: 1, Severity: SeverityError, Rule: "yaml", Message: "line 2: did not find expected node content"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			have := Lint([]byte(tt.spec), Options{Repos: tt.repos})
+			if diff := cmp.Diff(tt.want, have); diff != "" {
+				t.Errorf("wrong diagnostics (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSchemaDiagnostics(t *testing.T) {
+	spec := []byte(`name: hello-world
+foo: bar
+steps:
+  - run: echo
+changesetTemplate:
+  title: Hello World
+`)
+
+	var err error
+	err = multierror.Append(err,
+		errors.New("(root): Additional property foo is not allowed"),
+		errors.New("steps.0: container is required"),
+		errors.New("changesetTemplate: branch is required"),
+		errors.New("something else"),
+	)
+
+	have := SchemaDiagnostics(spec, errors.Wrap(err, "parsing batch spec"))
+	want := []Diagnostic{
+		{Line: 2, Column: 1, Severity: SeverityError, Rule: "schema", Message: "(root): Additional property foo is not allowed"},
+		{Line: 4, Column: 5, Severity: SeverityError, Rule: "schema", Message: "steps.0: container is required"},
+		{Line: 5, Column: 1, Severity: SeverityError, Rule: "schema", Message: "changesetTemplate: branch is required"},
+		{Severity: SeverityError, Rule: "schema", Message: "something else"},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong diagnostics (-want +have):\n%s", diff)
+	}
+}
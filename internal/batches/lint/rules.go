@@ -0,0 +1,231 @@
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// checkCondition checks the if: of a step, which is only true if it is, or
+// renders to, "true".
+func (l *linter) checkCondition(node *yaml.Node, sc scope) {
+	if node.Kind != yaml.ScalarNode {
+		return
+	}
+
+	refs, ok := l.checkTemplate(node, sc)
+	if !ok {
+		return
+	}
+	for _, ref := range refs {
+		if !builtinTemplateFuncs[ref.name] {
+			// It depends on the repository or the previous steps.
+			return
+		}
+	}
+
+	value := node.Value
+	if strings.Contains(value, "${{") {
+		var out bytes.Buffer
+		t, err := template.New("").Delims("${{", "}}").Parse(value)
+		if err != nil {
+			return
+		}
+		if err := t.Execute(&out, nil); err != nil {
+			l.add(node, 0, SeverityError, "condition", "the condition fails to evaluate: "+err.Error(), "fix the condition")
+			return
+		}
+		value = out.String()
+	}
+
+	if strings.TrimSpace(value) == "true" {
+		l.add(node, 0, SeverityInfo, "condition",
+			"the condition is always true",
+			"remove the condition")
+		return
+	}
+	l.add(node, 0, SeverityWarning, "condition",
+		fmt.Sprintf("the condition is never true, so step %d never runs", sc.step+1),
+		"remove the step, or use a condition that depends on the repository or the previous steps")
+}
+
+// checkImage checks that the container of a step is pinned to a version.
+func (l *linter) checkImage(node *yaml.Node) {
+	image, ok := scalar(node)
+	if !ok || image == "" || strings.Contains(image, "@") || strings.Contains(image, "${{") {
+		// Images with templates, like the values of a matrix, can't be
+		// checked.
+		return
+	}
+
+	name, tag := image, ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		name, tag = image[:i], image[i+1:]
+	}
+
+	fix := fmt.Sprintf("pin the image to a version, like %s:<version>, or to a digest", name)
+	switch tag {
+	case "":
+		l.add(node, 0, SeverityWarning, "unpinned-image",
+			fmt.Sprintf("the image %q has no tag, so the latest version is used and the results can change between executions", image), fix)
+	case "latest":
+		l.add(node, 0, SeverityWarning, "unpinned-image",
+			fmt.Sprintf("the image %q uses the tag latest, so the results can change between executions", image), fix)
+	}
+}
+
+var (
+	// redirectTarget matches the targets of output redirections, but not of
+	// redirections to file descriptors like 2>&1.
+	redirectTarget = regexp.MustCompile(`(?:^|[^<>&0-9])[0-9&]?>>?\|?[ \t]*([^\s;|&<>()]+)`)
+	teeTarget      = regexp.MustCompile(`(?:^|[\s;|&(])tee[ \t]+(?:-[a-z-]+[ \t]+)*([^\s;|&<>()]+)`)
+)
+
+// workspaceMounts are the directories steps can write to without the changes
+// being lost: the workspace itself and temporary files.
+var workspaceMounts = []string{"/work", "/tmp", "/dev"}
+
+// checkWrites looks for shell redirections and tee invocations in the run of
+// a step that write outside the workspace, because those changes aren't part
+// of the diff. It's a heuristic that doesn't know about cd and variables.
+func (l *linter) checkWrites(node *yaml.Node) {
+	script, ok := scalar(node)
+	if !ok {
+		return
+	}
+
+	offset := 0
+	for _, line := range strings.SplitAfter(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			for _, re := range []*regexp.Regexp{redirectTarget, teeTarget} {
+				for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+					target := strings.Trim(line[m[2]:m[3]], `"'`)
+					if outsideWorkspace(target) {
+						l.add(node, offset+m[2], SeverityWarning, "write-outside-workspace",
+							fmt.Sprintf("the step writes to %s, which is outside the workspace, so the changes are not part of the diff", target),
+							"write to a path in the workspace, or to /tmp for files that shouldn't be part of the diff")
+					}
+				}
+			}
+		}
+		offset += len(line)
+	}
+}
+
+func outsideWorkspace(path string) bool {
+	switch {
+	case strings.Contains(path, "$"):
+		return false
+	case path == ".." || strings.HasPrefix(path, "../"), path == "~" || strings.HasPrefix(path, "~/"):
+		return true
+	case strings.HasPrefix(path, "/"):
+		for _, dir := range workspaceMounts {
+			if path == dir || strings.HasPrefix(path, dir+"/") {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+type branchUse struct {
+	node *yaml.Node
+	// repo is the repository the branch is used in, or empty if it's used in
+	// all of them.
+	repo  string
+	field string
+}
+
+// lintBranches looks for changesets in the same repository that would have the
+// same branch.
+func (l *linter) lintBranches(root *yaml.Node) {
+	var uses []branchUse
+
+	_, tmpl := lookup(root, "changesetTemplate")
+	_, branch := lookup(tmpl, "branch")
+	if b, ok := scalar(branch); ok && !strings.Contains(b, "${{") {
+		uses = append(uses, branchUse{node: branch, field: "changesetTemplate.branch"})
+
+		if _, ws := lookup(root, "workspaces"); ws != nil && len(ws.Content) > 0 {
+			l.add(branch, 0, SeverityWarning, "duplicate-branch",
+				"the branch is the same in every workspace, so the changesets of repositories with multiple workspaces have the same branch",
+				"add ${{ steps.path }} to the branch")
+		}
+	}
+
+	_, transform := lookup(root, "transformChanges")
+	_, groups := lookup(transform, "group")
+	if groups != nil && groups.Kind == yaml.SequenceNode {
+		for i, group := range groups.Content {
+			_, branch := lookup(group, "branch")
+			b, ok := scalar(branch)
+			if !ok || strings.Contains(b, "${{") {
+				continue
+			}
+			_, repo := lookup(group, "repository")
+			r, _ := scalar(repo)
+			uses = append(uses, branchUse{node: branch, repo: r, field: fmt.Sprintf("transformChanges.group[%d].branch", i)})
+		}
+	}
+
+	for j := range uses {
+		for i := 0; i < j; i++ {
+			a, b := uses[i], uses[j]
+			if a.node.Value != b.node.Value {
+				continue
+			}
+			if a.repo != "" && b.repo != "" && a.repo != b.repo {
+				continue
+			}
+			l.add(b.node, 0, SeverityError, "duplicate-branch",
+				fmt.Sprintf("the branch %q is also used by %s on line %d", b.node.Value, a.field, a.node.Line),
+				"use a different branch for every changeset in a repository")
+			break
+		}
+	}
+}
+
+// lintWorkspaces checks that the globs in workspaces.in compile and match the
+// repositories the batch spec applies to.
+func (l *linter) lintWorkspaces(root *yaml.Node) {
+	_, ws := lookup(root, "workspaces")
+	if ws == nil || ws.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for _, conf := range ws.Content {
+		_, in := lookup(conf, "in")
+		pattern, ok := scalar(in)
+		if !ok || pattern == "" {
+			continue
+		}
+
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			l.add(in, 0, SeverityError, "workspaces-in", fmt.Sprintf("invalid glob %q: %s", pattern, err), "fix the glob")
+			continue
+		}
+
+		if l.opts.Repos == nil {
+			continue
+		}
+		matched := false
+		for _, repo := range l.opts.Repos {
+			if g.Match(repo) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			l.add(in, 0, SeverityWarning, "workspaces-in",
+				fmt.Sprintf("the glob %q matches none of the %d repositories the batch spec applies to", pattern, len(l.opts.Repos)),
+				"fix the glob, or remove the workspace configuration")
+		}
+	}
+}
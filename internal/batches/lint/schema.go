@@ -0,0 +1,87 @@
+package lint
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// schemaErrorField matches the field the errors of the schema validation
+	// start with, like "steps.0.container: ...".
+	schemaErrorField   = regexp.MustCompile(`^(\(root\)|[\w-]+(?:\.[\w-]+)*): `)
+	additionalProperty = regexp.MustCompile(`^Additional property (\S+) is not allowed`)
+)
+
+// SchemaDiagnostics turns the error returned when parsing the batch spec in
+// data into diagnostics. The errors of the schema validation are positioned at
+// the fields they are about, if they can be found.
+func SchemaDiagnostics(data []byte, err error) []Diagnostic {
+	errs := []error{err}
+	var multiErr *multierror.Error
+	if errors.As(err, &multiErr) {
+		errs = multiErr.Errors
+	}
+
+	var root *yaml.Node
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) == nil && len(doc.Content) > 0 {
+		root = resolve(doc.Content[0])
+	}
+
+	diags := make([]Diagnostic, 0, len(errs))
+	for _, err := range errs {
+		d := Diagnostic{
+			Severity: SeverityError,
+			Rule:     "schema",
+			Message:  err.Error(),
+		}
+
+		if m := schemaErrorField.FindStringSubmatch(d.Message); m != nil && root != nil {
+			if node := schemaErrorNode(root, m[1], d.Message[len(m[0]):]); node != nil {
+				d.Line, d.Column = node.Line, node.Column
+			}
+		}
+
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// schemaErrorNode returns the node the schema error about field is about.
+func schemaErrorNode(root *yaml.Node, field, description string) *yaml.Node {
+	// The errors are shown at the key of the field, if it has one.
+	node, at := root, root
+	if field != "(root)" {
+		for _, part := range strings.Split(field, ".") {
+			node = resolve(node)
+			switch node.Kind {
+			case yaml.MappingNode:
+				k, v := lookup(node, part)
+				if v == nil {
+					return at
+				}
+				node, at = v, k
+			case yaml.SequenceNode:
+				i, err := strconv.Atoi(part)
+				if err != nil || i >= len(node.Content) {
+					return at
+				}
+				node, at = node.Content[i], node.Content[i]
+			default:
+				return at
+			}
+		}
+	}
+
+	if m := additionalProperty.FindStringSubmatch(description); m != nil {
+		if k, _ := lookup(node, m[1]); k != nil {
+			return k
+		}
+	}
+	return at
+}
@@ -0,0 +1,371 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scope describes where in the batch spec a template is.
+type scope struct {
+	// step is the index of the step the template is in, or -1 if it's in the
+	// changesetTemplate.
+	step int
+	// outputs is true if the template is the value of an output of the step.
+	outputs bool
+	// matrix are the names of the values of the matrix of the step, which is
+	// nil if it has none.
+	matrix []string
+}
+
+func (sc scope) inChangesetTemplate() bool { return sc.step < 0 }
+
+// TemplateVariable describes a variable or function that can be used in the
+// templates of a batch spec.
+type TemplateVariable struct {
+	Name        string
+	Description string
+	// Fields are the fields of the variable, if it's an object with a fixed
+	// set of fields.
+	Fields []TemplateVariable
+}
+
+// withFileFields returns the fields describing changed files, followed by
+// the given fields.
+func withFileFields(fields ...TemplateVariable) []TemplateVariable {
+	return append([]TemplateVariable{
+		{Name: "modified_files", Description: "The files that were modified."},
+		{Name: "added_files", Description: "The files that were added."},
+		{Name: "deleted_files", Description: "The files that were deleted."},
+		{Name: "renamed_files", Description: "The files that were renamed."},
+	}, fields...)
+}
+
+// TemplateVariables are the variables that can be used in templates. Which of
+// them are available depends on where the template is.
+var TemplateVariables = []TemplateVariable{
+	{
+		Name:        "repository",
+		Description: "The repository the batch spec is executed in.",
+		Fields: []TemplateVariable{
+			{Name: "name", Description: "The name of the repository, as it is known to Sourcegraph."},
+			{Name: "search_result_paths", Description: "The paths of the files in the repository that the repositoriesMatchingQuery matched."},
+		},
+	},
+	{
+		Name:        "batch_change",
+		Description: "The batch change.",
+		Fields: []TemplateVariable{
+			{Name: "name", Description: "The name of the batch change."},
+			{Name: "description", Description: "The description of the batch change."},
+		},
+	},
+	{
+		Name:        "outputs",
+		Description: "The outputs set by the steps that have been executed, by name.",
+	},
+	{
+		Name:        "steps",
+		Description: "The changes made by all steps.",
+		Fields: withFileFields(
+			TemplateVariable{Name: "path", Description: "The path of the workspace in the repository."},
+		),
+	},
+	{
+		Name:        "previous_step",
+		Description: "The result of the previous step. Not available in changesetTemplate.",
+		Fields: withFileFields(
+			TemplateVariable{Name: "stdout", Description: "What the previous step wrote to stdout."},
+			TemplateVariable{Name: "stderr", Description: "What the previous step wrote to stderr."},
+		),
+	},
+	{
+		Name:        "step",
+		Description: "The result of the step. Only available in the outputs of a step.",
+		Fields: withFileFields(
+			TemplateVariable{Name: "stdout", Description: "What the step wrote to stdout."},
+			TemplateVariable{Name: "stderr", Description: "What the step wrote to stderr."},
+		),
+	},
+}
+
+// TemplateFuncs are the functions that batch spec templates add to Go's
+// builtin ones.
+var TemplateFuncs = []TemplateVariable{
+	{Name: "join", Description: "`join LIST SEPARATOR` joins the elements of the list with the separator."},
+	{Name: "join_if", Description: "`join_if SEPARATOR ELEMENTS...` joins the non-empty elements with the separator."},
+	{Name: "matches", Description: "`matches TEXT GLOB` reports whether the text matches the glob."},
+	{Name: "replace", Description: "`replace TEXT OLD NEW` replaces every occurrence of OLD in TEXT with NEW."},
+	{Name: "split", Description: "`split TEXT SEPARATOR` splits the text at every occurrence of the separator."},
+}
+
+// AvailableTemplateVariables returns the names of the variables that can be
+// used in a template in changesetTemplate, in the outputs of a step, or in
+// the other fields of a step.
+func AvailableTemplateVariables(inChangesetTemplate, inOutputs bool) []string {
+	switch {
+	case inChangesetTemplate:
+		return []string{"repository", "batch_change", "outputs", "steps"}
+	case inOutputs:
+		return []string{"repository", "batch_change", "outputs", "steps", "previous_step", "step"}
+	default:
+		return []string{"repository", "batch_change", "outputs", "steps", "previous_step"}
+	}
+}
+
+func templateVariable(name string) (TemplateVariable, bool) {
+	for _, v := range TemplateVariables {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return TemplateVariable{}, false
+}
+
+func isTemplateFunc(name string) bool {
+	for _, f := range TemplateFuncs {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinTemplateFuncs are Go's builtin template functions and keywords.
+var builtinTemplateFuncs = map[string]bool{
+	"and": true, "call": true, "eq": true, "ge": true, "gt": true, "html": true,
+	"index": true, "js": true, "le": true, "len": true, "lt": true, "ne": true,
+	"not": true, "or": true, "print": true, "printf": true, "println": true,
+	"slice": true, "urlquery": true,
+	"block": true, "break": true, "continue": true, "define": true, "else": true,
+	"end": true, "false": true, "if": true, "nil": true, "range": true,
+	"template": true, "true": true, "with": true,
+}
+
+// templateRef is a reference to a template variable or function, like
+// outputs.foo or join.
+type templateRef struct {
+	// offset is the offset of the reference in the template.
+	offset int
+	name   string
+	fields []string
+}
+
+var templateAction = regexp.MustCompile(`(?s)\$\{\{(.*?)\}\}`)
+
+// checkTemplate checks the template in the given scalar node and returns the
+// references in it. ok is false if the template can't be parsed.
+func (l *linter) checkTemplate(node *yaml.Node, sc scope) (refs []templateRef, ok bool) {
+	text, isScalar := scalar(node)
+	if !isScalar || !strings.Contains(text, "${{") {
+		return nil, true
+	}
+
+	for _, m := range templateAction.FindAllStringSubmatchIndex(text, -1) {
+		refs = append(refs, scanTemplateRefs(text[m[2]:m[3]], m[2])...)
+	}
+
+	// Every name we found is made available to the parser, so that it only
+	// reports syntax errors: we report unknown names ourselves.
+	funcs := template.FuncMap{}
+	for _, ref := range refs {
+		funcs[ref.name] = func(...interface{}) interface{} { return nil }
+	}
+	if _, err := template.New("").Delims("${{", "}}").Funcs(funcs).Parse(text); err != nil {
+		msg := strings.TrimPrefix(err.Error(), "template: ")
+		msg = strings.TrimPrefix(msg, ":")
+		l.add(node, 0, SeverityError, "template-syntax", "invalid template: "+msg, "fix the syntax of the template")
+		return nil, false
+	}
+
+	for _, ref := range refs {
+		l.checkTemplateRef(node, sc, ref)
+	}
+	return refs, true
+}
+
+func (l *linter) checkTemplateRef(node *yaml.Node, sc scope, ref templateRef) {
+	if isTemplateFunc(ref.name) || builtinTemplateFuncs[ref.name] {
+		return
+	}
+
+	switch ref.name {
+	case "repository", "batch_change", "steps":
+
+	case "previous_step":
+		if sc.inChangesetTemplate() {
+			l.add(node, ref.offset, SeverityError, "template-unavailable",
+				"previous_step is not available in changesetTemplate",
+				"use steps or outputs to refer to the results of the steps")
+			return
+		}
+		if sc.step == 0 && !sc.outputs {
+			l.add(node, ref.offset, SeverityWarning, "template-unavailable",
+				"previous_step is always empty in the first step",
+				"remove the reference, or move the step after the one it refers to")
+			return
+		}
+
+	case "step":
+		if !sc.outputs {
+			where := "in steps"
+			if sc.inChangesetTemplate() {
+				where = "in changesetTemplate"
+			}
+			l.add(node, ref.offset, SeverityError, "template-unavailable",
+				fmt.Sprintf("step is only available in the outputs of a step, not %s", where),
+				"use previous_step to refer to the result of the previous step, or steps to refer to the results of all steps")
+			return
+		}
+
+	case "outputs":
+		if len(ref.fields) > 0 {
+			l.checkOutputRef(node, sc, ref)
+		}
+		return
+
+	case "matrix":
+		l.checkMatrixRef(node, sc, ref)
+		return
+
+	default:
+		l.add(node, ref.offset, SeverityError, "template-undefined",
+			fmt.Sprintf("%q is not a template variable or function", ref.name),
+			"use one of the variables "+strings.Join(AvailableTemplateVariables(sc.inChangesetTemplate(), sc.outputs), ", "))
+		return
+	}
+
+	if len(ref.fields) == 0 {
+		return
+	}
+	v, _ := templateVariable(ref.name)
+	var fields []string
+	for _, f := range v.Fields {
+		if f.Name == ref.fields[0] {
+			return
+		}
+		fields = append(fields, f.Name)
+	}
+	sort.Strings(fields)
+	l.add(node, ref.offset, SeverityError, "template-unknown-field",
+		fmt.Sprintf("%s has no field %q", ref.name, ref.fields[0]),
+		fmt.Sprintf("use one of the fields %s", strings.Join(fields, ", ")))
+}
+
+func (l *linter) checkOutputRef(node *yaml.Node, sc scope, ref templateRef) {
+	name := ref.fields[0]
+	defined, ok := l.outputs[name]
+	if !ok {
+		fix := "define it in the outputs of a step"
+		if len(l.outputs) > 0 {
+			var names []string
+			for n := range l.outputs {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			fix += ", or use one of the outputs " + strings.Join(names, ", ")
+		}
+		l.add(node, ref.offset, SeverityError, "output-undefined",
+			fmt.Sprintf("output %q is not defined by any step", name), fix)
+		return
+	}
+
+	// Steps can use the outputs of the steps before them and their own
+	// outputs in their outputs.
+	if sc.inChangesetTemplate() || defined < sc.step || (defined == sc.step && sc.outputs) {
+		return
+	}
+	l.add(node, ref.offset, SeverityError, "output-undefined",
+		fmt.Sprintf("output %q is defined by step %d, which runs after step %d", name, defined+1, sc.step+1),
+		"define the output in an earlier step, or move the reference to a later step")
+}
+
+func (l *linter) checkMatrixRef(node *yaml.Node, sc scope, ref templateRef) {
+	if sc.matrix == nil {
+		where := "a step without one"
+		if sc.inChangesetTemplate() {
+			where = "changesetTemplate"
+		}
+		l.add(node, ref.offset, SeverityError, "template-unavailable",
+			fmt.Sprintf("matrix is only available in steps with a matrix, not in %s", where),
+			"add a matrix to the step")
+		return
+	}
+	if len(ref.fields) == 0 {
+		return
+	}
+	for _, name := range sc.matrix {
+		if name == ref.fields[0] {
+			return
+		}
+	}
+	l.add(node, ref.offset, SeverityError, "template-unknown-field",
+		fmt.Sprintf("the matrix has no value %q", ref.fields[0]),
+		fmt.Sprintf("use one of the values %s, or add it to the matrix", strings.Join(sc.matrix, ", ")))
+}
+
+// scanTemplateRefs returns the references to variables and functions in the
+// given template action. base is the offset of the action in the template.
+func scanTemplateRefs(action string, base int) []templateRef {
+	var refs []templateRef
+	for i := 0; i < len(action); {
+		c := action[i]
+		switch {
+		case c == '"' || c == '\'':
+			// Skip string and character literals.
+			i++
+			for i < len(action) && action[i] != c {
+				if action[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+
+		case c == '`':
+			i++
+			for i < len(action) && action[i] != '`' {
+				i++
+			}
+			i++
+
+		case c == '$' || c == '.' || isDigit(c):
+			// Template variables, fields of the cursor and numbers aren't
+			// references we can check.
+			i++
+			for i < len(action) && (isIdent(action[i]) || action[i] == '.') {
+				i++
+			}
+
+		case isIdent(c):
+			start := i
+			for i < len(action) && isIdent(action[i]) {
+				i++
+			}
+			ref := templateRef{offset: base + start, name: action[start:i]}
+			for i+1 < len(action) && action[i] == '.' && isIdent(action[i+1]) {
+				i++
+				fieldStart := i
+				for i < len(action) && isIdent(action[i]) {
+					i++
+				}
+				ref.fields = append(ref.fields, action[fieldStart:i])
+			}
+			refs = append(refs, ref)
+
+		default:
+			i++
+		}
+	}
+	return refs
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdent(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,90 @@
+// Package lockfile reads and writes the lockfiles of batch specs, which pin
+// the container images of their steps to registry digests, so that everyone
+// who executes a batch spec runs the same images.
+package lockfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Name is the name of the lockfile next to a batch spec.
+const Name = "batch.lock.json"
+
+// Lockfile pins the container images of the steps of a batch spec.
+type Lockfile struct {
+	// Images maps the images as they are given in the steps of the batch spec
+	// to the references of their registry digests, like
+	// alpine@sha256:xxx.
+	Images map[string]string `json:"images"`
+}
+
+// PathFor returns the path of the lockfile of the batch spec in the given
+// file. The lockfile of a batch spec that's read from stdin is in the current
+// directory.
+func PathFor(specFile string) string {
+	if specFile == "" || specFile == "-" {
+		return Name
+	}
+	return filepath.Join(filepath.Dir(specFile), Name)
+}
+
+// Read reads the lockfile at the given path.
+func Read(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Newf("lockfile %s doesn't exist, run 'src batch lock' to create it", path)
+		}
+		return nil, errors.Wrap(err, "reading lockfile")
+	}
+
+	var l Lockfile
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, errors.Wrapf(err, "parsing lockfile %s", path)
+	}
+	return &l, nil
+}
+
+// Write writes the lockfile to the given path.
+func (l *Lockfile) Write(path string) error {
+	// The keys of maps are sorted, so the file only changes if the images do.
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "writing lockfile")
+	}
+	return nil
+}
+
+// Check returns an error that lists every image that isn't in the lockfile or
+// whose registry digest doesn't match it. digests maps the images to their
+// current registry digests.
+func (l *Lockfile) Check(digests map[string]string) error {
+	var problems []string
+	for image, digest := range digests {
+		locked, ok := l.Images[image]
+		switch {
+		case !ok:
+			problems = append(problems, image+": not in the lockfile")
+		case locked != digest:
+			problems = append(problems, image+": locked to "+locked+", but is "+digest)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return errors.Newf(
+		"container images don't match the lockfile, pull the locked images or run 'src batch lock' to update it:\n\t%s",
+		strings.Join(problems, "\n\t"),
+	)
+}
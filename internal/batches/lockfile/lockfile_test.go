@@ -0,0 +1,76 @@
+package lockfile
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLockfile_ReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Name)
+
+	if _, err := Read(path); err == nil {
+		t.Fatal("no error for missing lockfile")
+	}
+
+	want := &Lockfile{Images: map[string]string{
+		"alpine:3":       "alpine@sha256:aaa",
+		"comby/comby:10": "comby/comby@sha256:bbb",
+	}}
+	if err := want.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	have, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong lockfile (-want +have):\n%s", diff)
+	}
+}
+
+func TestLockfile_Check(t *testing.T) {
+	l := &Lockfile{Images: map[string]string{
+		"alpine:3":       "alpine@sha256:aaa",
+		"comby/comby:10": "comby/comby@sha256:bbb",
+	}}
+
+	if err := l.Check(map[string]string{"alpine:3": "alpine@sha256:aaa"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	err := l.Check(map[string]string{
+		"alpine:3":       "alpine@sha256:ccc",
+		"comby/comby:10": "comby/comby@sha256:bbb",
+		"ubuntu":         "ubuntu@sha256:ddd",
+	})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{
+		"alpine:3: locked to alpine@sha256:aaa, but is alpine@sha256:ccc",
+		"ubuntu: not in the lockfile",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "comby") {
+		t.Errorf("error %q contains matching image", err)
+	}
+}
+
+func TestPathFor(t *testing.T) {
+	for spec, want := range map[string]string{
+		"":                    Name,
+		"-":                   Name,
+		"batch.yaml":          Name,
+		"specs/foo/spec.yaml": filepath.Join("specs", "foo", Name),
+	} {
+		if have := PathFor(spec); have != want {
+			t.Errorf("PathFor(%q) = %q, want %q", spec, have, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Entry is a line of the JSON log of a task.
+type Entry struct {
+	Time time.Time `json:"time"`
+	Task Task      `json:"task"`
+	// Step is the step (1-based) the entry is about, or 0 if it's about the
+	// task as a whole.
+	Step int `json:"step,omitempty"`
+	// Stream is "stdout" or "stderr" for the output of a step, and "log" for
+	// everything else.
+	Stream  string `json:"stream"`
+	Message string `json:"message"`
+}
+
+const (
+	StreamLog    = "log"
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// JSONTaskLogger is a TaskLogger that writes an Entry per line into the log
+// file of the task in the directory of a run. The file is always kept, and
+// entries are appended to it if the task is executed again in the same run.
+type JSONTaskLogger struct {
+	task Task
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+var _ TaskLogger = &JSONTaskLogger{}
+
+func newJSONTaskLogger(task Task, dir string) (*JSONTaskLogger, error) {
+	name := filepath.Join(dir, task.Slug()+".jsonl")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating log file %s", name)
+	}
+
+	return &JSONTaskLogger{task: task, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (tl *JSONTaskLogger) write(step int, stream, message string) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	// Like the text logs, the JSON logs are best effort.
+	_ = tl.enc.Encode(Entry{
+		Time:    time.Now(),
+		Task:    tl.task,
+		Step:    step,
+		Stream:  stream,
+		Message: message,
+	})
+}
+
+func (tl *JSONTaskLogger) Close() error {
+	return tl.f.Close()
+}
+
+func (tl *JSONTaskLogger) Log(s string) {
+	tl.write(0, StreamLog, s)
+}
+
+func (tl *JSONTaskLogger) Logf(format string, a ...interface{}) {
+	tl.write(0, StreamLog, fmt.Sprintf(format, a...))
+}
+
+func (tl *JSONTaskLogger) StepLogf(step int, format string, a ...interface{}) {
+	tl.write(step, StreamLog, fmt.Sprintf(format, a...))
+}
+
+// MarkErrored does nothing, since the JSON logs are always kept.
+func (tl *JSONTaskLogger) MarkErrored() {}
+
+func (tl *JSONTaskLogger) Path() string {
+	return tl.f.Name()
+}
+
+func (tl *JSONTaskLogger) StepWriter(step int, stream string) io.Writer {
+	return &lineWriter{func(line string) {
+		tl.write(step, stream, line)
+	}}
+}
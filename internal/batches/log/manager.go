@@ -4,19 +4,36 @@ import (
 	"sync"
 
 	"github.com/hashicorp/go-multierror"
+
+	"github.com/sourcegraph/src-cli/internal/batches/util"
 )
 
 type LogManager interface {
-	AddTask(string) (TaskLogger, error)
+	AddTask(Task) (TaskLogger, error)
 	Close() error
 	LogFiles() []string
 }
 
+// Task is the task a TaskLogger logs for.
+type Task struct {
+	Repository string `json:"repository"`
+	Rev        string `json:"rev"`
+	Path       string `json:"path,omitempty"`
+}
+
+// Slug returns the slug of the task that its log file is named after.
+func (t Task) Slug() string {
+	return util.SlugForPathInRepo(t.Repository, t.Rev, t.Path)
+}
+
 var _ LogManager = &Manager{}
 
 type Manager struct {
 	dir      string
 	keepLogs bool
+	// json makes the logs of the tasks be written as JSON lines into dir,
+	// which is the directory of a run. See NewRun.
+	json bool
 
 	tasks sync.Map
 }
@@ -25,13 +42,27 @@ func NewManager(dir string, keepLogs bool) *Manager {
 	return &Manager{dir: dir, keepLogs: keepLogs}
 }
 
-func (lm *Manager) AddTask(slug string) (TaskLogger, error) {
-	tl, err := newTaskLogger(slug, lm.keepLogs, lm.dir)
+// NewJSONManager returns a Manager that writes the logs of the tasks as JSON
+// lines into the given directory of a run and always keeps them.
+func NewJSONManager(runDir string) *Manager {
+	return &Manager{dir: runDir, keepLogs: true, json: true}
+}
+
+func (lm *Manager) AddTask(task Task) (TaskLogger, error) {
+	var (
+		tl  TaskLogger
+		err error
+	)
+	if lm.json {
+		tl, err = newJSONTaskLogger(task, lm.dir)
+	} else {
+		tl, err = newTaskLogger(task.Slug(), lm.keepLogs, lm.dir)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	lm.tasks.Store(slug, tl)
+	lm.tasks.Store(task.Slug(), tl)
 	return tl, nil
 }
 
@@ -39,7 +70,7 @@ func (lm *Manager) Close() error {
 	var errs *multierror.Error
 
 	lm.tasks.Range(func(_, v interface{}) bool {
-		logger := v.(*FileTaskLogger)
+		logger := v.(TaskLogger)
 
 		if err := logger.Close(); err != nil {
 			errs = multierror.Append(errs, err)
@@ -48,14 +79,14 @@ func (lm *Manager) Close() error {
 		return true
 	})
 
-	return errs
+	return errs.ErrorOrNil()
 }
 
 func (lm *Manager) LogFiles() []string {
 	var files []string
 
 	lm.tasks.Range(func(_, v interface{}) bool {
-		files = append(files, v.(*FileTaskLogger).Path())
+		files = append(files, v.(TaskLogger).Path())
 		return true
 	})
 
@@ -0,0 +1,159 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// NewRun creates the directory of a new run in logDir, into which the JSON
+// logs of its tasks are written, and returns its ID and path. The IDs start
+// with the time the run started, so that they sort chronologically.
+func NewRun(logDir string) (id, dir string, err error) {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return "", "", errors.Wrapf(err, "creating log directory %s", logDir)
+	}
+	dir, err = os.MkdirTemp(logDir, time.Now().UTC().Format("20060102T150405Z")+"-")
+	if err != nil {
+		return "", "", errors.Wrapf(err, "creating run directory in %s", logDir)
+	}
+	return filepath.Base(dir), dir, nil
+}
+
+// Runs returns the IDs of the runs in logDir, oldest first.
+func Runs(logDir string) ([]string, error) {
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading log directory %s", logDir)
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Query selects the entries of a run. Its zero value selects all entries.
+type Query struct {
+	// Repository is the name of the repository of the tasks.
+	Repository string
+	// Step is the step (1-based) the entries are about.
+	Step int
+}
+
+func (q Query) matches(e Entry) bool {
+	if q.Repository != "" && e.Task.Repository != q.Repository {
+		return false
+	}
+	if q.Step != 0 && e.Step != q.Step {
+		return false
+	}
+	return true
+}
+
+// RunReader reads the entries of the logs of a run that match a query,
+// including the ones written while the run is still executing.
+type RunReader struct {
+	dir   string
+	query Query
+	// offsets are the offsets in the log files up to which they were read.
+	offsets map[string]int64
+}
+
+func NewRunReader(logDir, id string, query Query) (*RunReader, error) {
+	dir := filepath.Join(logDir, id)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, errors.Newf("run %s doesn't exist in %s", id, logDir)
+	}
+	return &RunReader{dir: dir, query: query, offsets: make(map[string]int64)}, nil
+}
+
+// Read returns the entries written since the last call, ordered by time.
+// Lines that are still being written are returned once they're complete.
+func (r *RunReader) Read() ([]Entry, error) {
+	files, err := filepath.Glob(filepath.Join(r.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, name := range files {
+		fileEntries, err := r.readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+func (r *RunReader) readFile(name string) ([]Entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening log file %s", name)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(r.offsets[name], io.SeekStart); err != nil {
+		return nil, errors.Wrapf(err, "seeking in log file %s", name)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading log file %s", name)
+	}
+
+	end := bytes.LastIndexByte(data, '\n')
+	if end == -1 {
+		return nil, nil
+	}
+	r.offsets[name] += int64(end + 1)
+
+	var entries []Entry
+	for _, line := range bytes.Split(data[:end], []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, errors.Wrapf(err, "parsing log file %s", name)
+		}
+		if r.query.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// Follow calls fn with the entries of the run, checking for new ones at the
+// given interval, until ctx is done.
+func (r *RunReader) Follow(ctx context.Context, interval time.Duration, fn func(Entry)) error {
+	for {
+		entries, err := r.Read()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fn(e)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
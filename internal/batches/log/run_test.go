@@ -0,0 +1,143 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	logDir := t.TempDir()
+	id, dir, err := NewRun(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcCLI := Task{Repository: "github.com/sourcegraph/src-cli", Rev: "d34db33f"}
+	docs := Task{Repository: "github.com/sourcegraph/sourcegraph", Rev: "c0ffee", Path: "doc"}
+
+	manager := NewJSONManager(dir)
+	srcCLILog, err := manager.AddTask(srcCLI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docsLog, err := manager.AddTask(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcCLILog.Log("Executing on worker localhost:9091")
+	srcCLILog.StepLogf(1, "run: %q", "echo hello")
+	fmt.Fprint(srcCLILog.StepWriter(1, StreamStdout), "hello\nworld\n")
+	docsLog.StepLogf(2, "complete in %s", time.Second)
+	if err := manager.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := Runs(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{id}, ids); diff != "" {
+		t.Errorf("wrong runs (-want +have):\n%s", diff)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []Entry
+	}{
+		{
+			name: "all",
+			want: []Entry{
+				{Task: srcCLI, Stream: StreamLog, Message: "Executing on worker localhost:9091"},
+				{Task: srcCLI, Step: 1, Stream: StreamLog, Message: `run: "echo hello"`},
+				{Task: srcCLI, Step: 1, Stream: StreamStdout, Message: "hello"},
+				{Task: srcCLI, Step: 1, Stream: StreamStdout, Message: "world"},
+				{Task: docs, Step: 2, Stream: StreamLog, Message: "complete in 1s"},
+			},
+		},
+		{
+			name:  "repository",
+			query: Query{Repository: "github.com/sourcegraph/sourcegraph"},
+			want:  []Entry{{Task: docs, Step: 2, Stream: StreamLog, Message: "complete in 1s"}},
+		},
+		{
+			name:  "step",
+			query: Query{Repository: "github.com/sourcegraph/src-cli", Step: 1},
+			want: []Entry{
+				{Task: srcCLI, Step: 1, Stream: StreamLog, Message: `run: "echo hello"`},
+				{Task: srcCLI, Step: 1, Stream: StreamStdout, Message: "hello"},
+				{Task: srcCLI, Step: 1, Stream: StreamStdout, Message: "world"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRunReader(logDir, id, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			have, err := r.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range have {
+				have[i].Time = time.Time{}
+			}
+			if diff := cmp.Diff(tt.want, have); diff != "" {
+				t.Errorf("wrong entries (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := NewRunReader(logDir, "missing", Query{}); err == nil {
+		t.Error("no error for missing run")
+	}
+}
+
+func TestRunReader_Follow(t *testing.T) {
+	logDir := t.TempDir()
+	id, dir, err := NewRun(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRunReader(logDir, id, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A line that is still being written isn't read yet.
+	name := filepath.Join(dir, "task.jsonl")
+	if err := os.WriteFile(name, []byte(`{"stream":"log","message":"first"}`+"\n"+`{"stream":"log",`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	ctx, cancel := context.WithCancel(context.Background())
+	err = r.Follow(ctx, time.Millisecond, func(e Entry) {
+		messages = append(messages, e.Message)
+		if len(messages) == 1 {
+			f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString(`"message":"second"}` + "\n"); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"first", "second"}, messages); diff != "" {
+		t.Errorf("wrong messages (-want +have):\n%s", diff)
+	}
+}
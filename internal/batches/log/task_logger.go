@@ -14,9 +14,13 @@ type TaskLogger interface {
 	Close() error
 	Log(string)
 	Logf(string, ...interface{})
+	// StepLogf logs a message about the given step (1-based).
+	StepLogf(step int, format string, a ...interface{})
 	MarkErrored()
 	Path() string
-	PrefixWriter(prefix string) io.Writer
+	// StepWriter returns a writer that logs every line written to it as
+	// output of the given step on the stream, which is "stdout" or "stderr".
+	StepWriter(step int, stream string) io.Writer
 }
 
 type FileTaskLogger struct {
@@ -64,6 +68,10 @@ func (tl *FileTaskLogger) Logf(format string, a ...interface{}) {
 	fmt.Fprintf(tl.f, "%s "+format+"\n", append([]interface{}{time.Now().Format(time.RFC3339Nano)}, a...)...)
 }
 
+func (tl *FileTaskLogger) StepLogf(step int, format string, a ...interface{}) {
+	tl.Logf("[Step %d] "+format, append([]interface{}{step}, a...)...)
+}
+
 func (tl *FileTaskLogger) MarkErrored() {
 	tl.errored = true
 }
@@ -72,16 +80,18 @@ func (tl *FileTaskLogger) Path() string {
 	return tl.f.Name()
 }
 
-func (tl *FileTaskLogger) PrefixWriter(prefix string) io.Writer {
-	return &prefixWriter{tl, prefix}
+func (tl *FileTaskLogger) StepWriter(step int, stream string) io.Writer {
+	return &lineWriter{func(line string) {
+		tl.Logf("%s | %s", stream, line)
+	}}
 }
 
-type prefixWriter struct {
-	logger *FileTaskLogger
-	prefix string
+// lineWriter logs every line written to it with logLine.
+type lineWriter struct {
+	logLine func(line string)
 }
 
-func (pw *prefixWriter) Write(p []byte) (int, error) {
+func (lw *lineWriter) Write(p []byte) (int, error) {
 	// Don't split on the final newline in this writer, split
 	// content into separate lines anyways, so lines without \n
 	// at the end wouldn't print properly regardless. This fixes
@@ -95,7 +105,7 @@ func (pw *prefixWriter) Write(p []byte) (int, error) {
 	//
 	t := bytes.TrimSuffix(p, []byte("\n"))
 	for _, line := range bytes.Split(t, []byte("\n")) {
-		pw.logger.Logf("%s | %s", pw.prefix, string(line))
+		lw.logLine(string(line))
 	}
 	return len(p), nil
 }
@@ -0,0 +1,318 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/batches/lint"
+)
+
+var (
+	// keyLine matches the text before the cursor if a key is being typed.
+	keyLine = regexp.MustCompile(`^\s*(?:-\s+)?([\w-]*)$`)
+	// valueLine matches the text before the cursor if a value is being typed.
+	valueLine = regexp.MustCompile(`^(\s*(?:-\s+)?)([\w-]+):\s+(\S*)$`)
+	// keyAt matches the key of a line.
+	keyAt = regexp.MustCompile(`^(\s*(?:-\s+)?)([\w-]+):(?:\s|$)`)
+	// templateRef matches references to template variables and their fields.
+	templateRef = regexp.MustCompile(`[A-Za-z_][\w]*(?:\.[A-Za-z_][\w]*)*\.?`)
+)
+
+// complete returns the completions at the given position: template variables
+// in templates, keys and values everywhere else.
+func complete(text string, pos Position) []CompletionItem {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return []CompletionItem{}
+	}
+	line := lines[pos.Line]
+	before := line[:byteOffset(line, pos.Character)]
+
+	if start := strings.LastIndex(before, "${{"); start >= 0 && !strings.Contains(before[start:], "}}") {
+		return completeTemplate(text, lines, pos.Line, before)
+	}
+
+	if m := keyLine.FindStringSubmatch(before); m != nil {
+		path := yamlPath(lines, pos.Line, len(before)-len(m[1]))
+		var items []CompletionItem
+		for _, p := range properties(schemaAt(path)) {
+			item := CompletionItem{
+				Label:      p.name,
+				Kind:       CompletionItemKindProperty,
+				InsertText: p.name + ": ",
+			}
+			if p.required {
+				item.Detail = "required"
+			}
+			if d := description([]*schemaNode{p.node}); d != "" {
+				item.Documentation = &MarkupContent{Kind: "markdown", Value: d}
+			}
+			items = append(items, item)
+		}
+		return nonNil(items)
+	}
+
+	if m := valueLine.FindStringSubmatch(before); m != nil {
+		path := append(yamlPath(lines, pos.Line, len(m[1])), m[2])
+		return completeValue(schemaAt(path))
+	}
+
+	return []CompletionItem{}
+}
+
+// completeValue returns the enum values and examples of the schemas.
+func completeValue(nodes []*schemaNode) []CompletionItem {
+	seen := map[string]bool{}
+	var items []CompletionItem
+	add := func(v interface{}) {
+		label := fmt.Sprint(v)
+		if seen[label] {
+			return
+		}
+		seen[label] = true
+		items = append(items, CompletionItem{Label: label, Kind: CompletionItemKindValue})
+	}
+
+	for _, n := range nodes {
+		for _, alt := range n.alternatives() {
+			for _, v := range alt.Enum {
+				add(v)
+			}
+			for _, v := range alt.Examples {
+				add(v)
+			}
+			if alt.Type == "boolean" {
+				add(true)
+				add(false)
+			}
+		}
+	}
+	return nonNil(items)
+}
+
+// completeTemplate returns the template variables, their fields and functions
+// that can be used at the end of before.
+func completeTemplate(text string, lines []string, line int, before string) []CompletionItem {
+	inChangesetTemplate, inOutputs := templateScope(lines, line)
+
+	word := ""
+	if m := templateRef.FindAllStringIndex(before, -1); len(m) > 0 && m[len(m)-1][1] == len(before) {
+		word = before[m[len(m)-1][0]:]
+	}
+
+	// Complete the fields of a variable.
+	if i := strings.Index(word, "."); i >= 0 {
+		name := word[:i]
+		if name == "outputs" {
+			var items []CompletionItem
+			for _, o := range documentOutputs(text) {
+				items = append(items, CompletionItem{
+					Label:  o.name,
+					Kind:   CompletionItemKindField,
+					Detail: fmt.Sprintf("set by step %d", o.step+1),
+				})
+			}
+			return nonNil(items)
+		}
+
+		var items []CompletionItem
+		for _, v := range lint.TemplateVariables {
+			if v.Name != name {
+				continue
+			}
+			for _, f := range v.Fields {
+				items = append(items, CompletionItem{
+					Label:         f.Name,
+					Kind:          CompletionItemKindField,
+					Documentation: &MarkupContent{Kind: "markdown", Value: f.Description},
+				})
+			}
+		}
+		return nonNil(items)
+	}
+
+	var items []CompletionItem
+	for _, name := range lint.AvailableTemplateVariables(inChangesetTemplate, inOutputs) {
+		for _, v := range lint.TemplateVariables {
+			if v.Name == name {
+				items = append(items, CompletionItem{
+					Label:         v.Name,
+					Kind:          CompletionItemKindVariable,
+					Documentation: &MarkupContent{Kind: "markdown", Value: v.Description},
+				})
+			}
+		}
+	}
+	for _, f := range lint.TemplateFuncs {
+		items = append(items, CompletionItem{
+			Label:         f.Name,
+			Kind:          CompletionItemKindFunction,
+			Documentation: &MarkupContent{Kind: "markdown", Value: f.Description},
+		})
+	}
+	return items
+}
+
+// templateScope returns where the template in the given line is.
+func templateScope(lines []string, line int) (inChangesetTemplate, inOutputs bool) {
+	path := yamlPath(lines, line, indentation(lines[line]))
+	if m := keyAt.FindStringSubmatch(lines[line]); m != nil {
+		path = append(yamlPath(lines, line, len(m[1])), m[2])
+	}
+
+	if len(path) == 0 {
+		return false, false
+	}
+	inChangesetTemplate = path[0] == "changesetTemplate"
+	for i, key := range path {
+		if key == "outputs" && i > 0 && path[0] == "steps" {
+			inOutputs = true
+		}
+	}
+	return inChangesetTemplate, inOutputs
+}
+
+type documentOutput struct {
+	name string
+	// step is the index of the step that sets the output first.
+	step int
+}
+
+// documentOutputs returns the outputs the steps of the batch spec set.
+func documentOutputs(text string) []documentOutput {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	steps := mappingValue(doc.Content[0], "steps")
+	if steps == nil || steps.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var outputs []documentOutput
+	for i, step := range steps.Content {
+		o := mappingValue(step, "outputs")
+		if o == nil || o.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j < len(o.Content); j += 2 {
+			name := o.Content[j].Value
+			if !seen[name] {
+				seen[name] = true
+				outputs = append(outputs, documentOutput{name: name, step: i})
+			}
+		}
+	}
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].name < outputs[j].name })
+	return outputs
+}
+
+// hover returns the documentation of the key or template variable at the
+// given position, or nil if there is none.
+func hover(text string, pos Position) *Hover {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return nil
+	}
+	line := lines[pos.Line]
+	offset := byteOffset(line, pos.Character)
+
+	// Template variables.
+	for _, action := range templateActionIndex.FindAllStringIndex(line, -1) {
+		if offset < action[0] || offset > action[1] {
+			continue
+		}
+		for _, m := range templateRef.FindAllStringIndex(line[action[0]:action[1]], -1) {
+			start, end := action[0]+m[0], action[0]+m[1]
+			if offset < start || offset > end {
+				continue
+			}
+			if doc := templateDoc(text, line[start:end], offset-start); doc != "" {
+				return &Hover{
+					Contents: MarkupContent{Kind: "markdown", Value: doc},
+					Range:    byteRange(line, pos.Line, start, end),
+				}
+			}
+		}
+		return nil
+	}
+
+	// Keys.
+	m := keyAt.FindStringSubmatchIndex(line)
+	if m == nil || offset < m[4] || offset > m[5] {
+		return nil
+	}
+	key := line[m[4]:m[5]]
+	nodes := schemaAt(append(yamlPath(lines, pos.Line, m[3]), key))
+	doc := description(nodes)
+	if doc == "" {
+		return nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: fmt.Sprintf("**%s**\n\n%s", key, doc)},
+		Range:    byteRange(line, pos.Line, m[4], m[5]),
+	}
+}
+
+var templateActionIndex = regexp.MustCompile(`\$\{\{.*?(?:\}\}|$)`)
+
+// templateDoc returns the documentation of the variable or field at offset in
+// the reference ref, like repository.name.
+func templateDoc(text, ref string, offset int) string {
+	parts := strings.Split(strings.TrimSuffix(ref, "."), ".")
+	// The index of the part the offset is in.
+	i := strings.Count(ref[:offset], ".")
+	if i >= len(parts) {
+		i = len(parts) - 1
+	}
+
+	for _, f := range lint.TemplateFuncs {
+		if f.Name == parts[0] {
+			return fmt.Sprintf("**%s**\n\n%s", f.Name, f.Description)
+		}
+	}
+
+	for _, v := range lint.TemplateVariables {
+		if v.Name != parts[0] {
+			continue
+		}
+		if i == 0 {
+			return fmt.Sprintf("**%s**\n\n%s", v.Name, v.Description)
+		}
+		if v.Name == "outputs" {
+			for _, o := range documentOutputs(text) {
+				if o.name == parts[1] {
+					return fmt.Sprintf("**outputs.%s**\n\nSet by step %d.", o.name, o.step+1)
+				}
+			}
+			return ""
+		}
+		for _, f := range v.Fields {
+			if f.Name == parts[1] {
+				return fmt.Sprintf("**%s.%s**\n\n%s", v.Name, f.Name, f.Description)
+			}
+		}
+	}
+	return ""
+}
+
+func byteRange(line string, lineNum, start, end int) *Range {
+	return &Range{
+		Start: Position{Line: lineNum, Character: utf16Len(line[:start])},
+		End:   Position{Line: lineNum, Character: utf16Len(line[:end])},
+	}
+}
+
+// nonNil makes sure that empty completions are encoded as an empty list
+// rather than null.
+func nonNil(items []CompletionItem) []CompletionItem {
+	if items == nil {
+		return []CompletionItem{}
+	}
+	return items
+}
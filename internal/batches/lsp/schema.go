@@ -0,0 +1,212 @@
+package lsp
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/schema"
+)
+
+// schemaNode is the part of a JSON schema the server uses for completion and
+// hover docs.
+type schemaNode struct {
+	Title                string                 `json:"title"`
+	Description          string                 `json:"description"`
+	Type                 interface{}            `json:"type"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+	OneOf                []*schemaNode          `json:"oneOf"`
+	AnyOf                []*schemaNode          `json:"anyOf"`
+	Enum                 []interface{}          `json:"enum"`
+	Examples             []interface{}          `json:"examples"`
+	Required             []string               `json:"required"`
+}
+
+var batchSpecSchema = mustParseSchema(schema.BatchSpecJSON)
+
+func mustParseSchema(data string) *schemaNode {
+	var n schemaNode
+	if err := json.Unmarshal([]byte(data), &n); err != nil {
+		panic("invalid batch spec schema: " + err.Error())
+	}
+	return &n
+}
+
+// alternatives returns the node and all the schemas it can be according to
+// oneOf and anyOf.
+func (n *schemaNode) alternatives() []*schemaNode {
+	alts := []*schemaNode{n}
+	for _, alt := range append(n.OneOf, n.AnyOf...) {
+		alts = append(alts, alt.alternatives()...)
+	}
+	return alts
+}
+
+// child returns the schemas of the given key of an object, or of the items of
+// an array if key is itemsKey.
+func (n *schemaNode) child(key string) []*schemaNode {
+	var children []*schemaNode
+	for _, alt := range n.alternatives() {
+		if key == itemsKey {
+			if alt.Items != nil {
+				children = append(children, alt.Items)
+			}
+			continue
+		}
+
+		if p, ok := alt.Properties[key]; ok {
+			children = append(children, p)
+		} else if len(alt.AdditionalProperties) > 0 && alt.AdditionalProperties[0] == '{' {
+			var additional schemaNode
+			if err := json.Unmarshal(alt.AdditionalProperties, &additional); err == nil {
+				children = append(children, &additional)
+			}
+		}
+	}
+	return children
+}
+
+// schemaAt returns the schemas of the value at the given path.
+func schemaAt(path []string) []*schemaNode {
+	nodes := []*schemaNode{batchSpecSchema}
+	for _, key := range path {
+		var next []*schemaNode
+		for _, n := range nodes {
+			next = append(next, n.child(key)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// schemaProperty is a key an object can have.
+type schemaProperty struct {
+	name     string
+	node     *schemaNode
+	required bool
+}
+
+// properties returns the keys the objects described by nodes can have.
+func properties(nodes []*schemaNode) []schemaProperty {
+	seen := map[string]bool{}
+	var props []schemaProperty
+	for _, n := range nodes {
+		for _, alt := range n.alternatives() {
+			for name, p := range alt.Properties {
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+
+				required := false
+				for _, r := range alt.Required {
+					required = required || r == name
+				}
+				props = append(props, schemaProperty{name: name, node: p, required: required})
+			}
+		}
+	}
+	sort.Slice(props, func(i, j int) bool { return props[i].name < props[j].name })
+	return props
+}
+
+// description returns the first description of the nodes.
+func description(nodes []*schemaNode) string {
+	for _, n := range nodes {
+		for _, alt := range n.alternatives() {
+			if alt.Description != "" {
+				return alt.Description
+			}
+		}
+	}
+	return ""
+}
+
+// itemsKey is the path element for the items of a sequence.
+const itemsKey = "[]"
+
+// yamlPath returns the path of keys to the mapping the key starting at col in
+// the given line belongs to. It works on the indentation of the lines, so
+// that it works while the document is being edited and isn't valid YAML.
+func yamlPath(lines []string, line, col int) []string {
+	text := lines[line]
+	if col < len(text) {
+		text = text[:col]
+	}
+
+	var path []string
+	cur := indentation(text)
+	inSeq := false
+	if rest := text[cur:]; strings.HasPrefix(rest, "-") {
+		// A new item of a sequence.
+		path = append(path, itemsKey)
+		inSeq = true
+	}
+
+	for l := line - 1; l >= 0 && (cur > 0 || inSeq); l-- {
+		text := lines[l]
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		ind := indentation(text)
+		rest := text[ind:]
+
+		if strings.HasPrefix(rest, "- ") || rest == "-" {
+			if inSeq {
+				if ind < cur {
+					// A sequence in a sequence.
+					path = append(path, itemsKey)
+					cur = ind
+				}
+				continue
+			}
+
+			keyCol := ind + 1 + indentation(rest[1:])
+			if keyCol > cur {
+				continue
+			}
+			if keyCol < cur {
+				// The key whose value we're in starts the item.
+				if key, ok := yamlKey(rest[keyCol-ind:]); ok {
+					path = append(path, key)
+				}
+			}
+			path = append(path, itemsKey)
+			cur = ind
+			inSeq = true
+			continue
+		}
+
+		if ind < cur || (inSeq && ind == cur) {
+			key, ok := yamlKey(rest)
+			if !ok {
+				continue
+			}
+			path = append(path, key)
+			cur = ind
+			inSeq = false
+		}
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+func indentation(text string) int {
+	return len(text) - len(strings.TrimLeft(text, " "))
+}
+
+// yamlKey returns the key of a "key: value" line.
+func yamlKey(text string) (string, bool) {
+	i := strings.Index(text, ":")
+	if i <= 0 || (i+1 < len(text) && text[i+1] != ' ') {
+		return "", false
+	}
+	return strings.Trim(text[:i], `"'`), true
+}
@@ -0,0 +1,363 @@
+// Package lsp implements a language server for batch specs that editors can
+// talk to over stdio.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/lint"
+)
+
+// Options configure the Server.
+type Options struct {
+	// ParseBatchSpec parses and validates a batch spec against the schema.
+	ParseBatchSpec func(data []byte) (*batcheslib.BatchSpec, error)
+	// ResolveRepositoriesOn returns the names of the repositories an entry in
+	// "on" matches. It's the only thing that needs Sourcegraph. If it's nil,
+	// no code lenses are offered.
+	ResolveRepositoriesOn func(ctx context.Context, on *batcheslib.OnQueryOrRepository) ([]string, error)
+}
+
+// Server is a language server for batch specs. It handles the requests of a
+// single client, one after the other.
+type Server struct {
+	opts Options
+	conn *conn
+
+	// docs are the texts of the open documents by URI.
+	docs     map[string]string
+	shutdown bool
+}
+
+func NewServer(opts Options) *Server {
+	return &Server{opts: opts, docs: map[string]string{}}
+}
+
+// Serve handles the messages read from r and writes the responses to w,
+// until the client asks the server to exit or r is closed.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			var respErr *responseError
+			if errors.As(err, &respErr) {
+				if err := s.conn.reply(nil, nil, respErr); err != nil {
+					return err
+				}
+				continue
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit requested without shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(ctx, msg)
+		if msg.ID == nil {
+			// Notifications don't get a response.
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		capabilities := ServerCapabilities{
+			TextDocumentSync:   1,
+			CompletionProvider: &CompletionOptions{TriggerCharacters: []string{".", " "}},
+			HoverProvider:      true,
+		}
+		if s.opts.ResolveRepositoriesOn != nil {
+			capabilities.CodeLensProvider = &CodeLensOptions{ResolveProvider: true}
+		}
+		return InitializeResult{
+			Capabilities: capabilities,
+			ServerInfo:   ServerInfo{Name: "src batch lsp"},
+		}, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		s.docs[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return CompletionList{Items: complete(s.docs[params.TextDocument.URI], params.Position)}, nil
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return hover(s.docs[params.TextDocument.URI], params.Position), nil
+
+	case "textDocument/codeLens":
+		var params CodeLensParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.codeLenses(params.TextDocument.URI), nil
+
+	case "codeLens/resolve":
+		var lens CodeLens
+		if err := unmarshalParams(msg, &lens); err != nil {
+			return nil, err
+		}
+		return s.resolveCodeLens(ctx, lens), nil
+	}
+
+	if msg.ID == nil {
+		// Unknown notifications, like $/cancelRequest, can be ignored.
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not supported", msg.Method)}
+}
+
+func unmarshalParams(msg *message, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) publishDiagnostics(uri string) error {
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: s.diagnose(s.docs[uri]),
+	})
+}
+
+// diagnose validates the batch spec against the schema and lints it.
+func (s *Server) diagnose(text string) []Diagnostic {
+	data := []byte(text)
+	lintDiags := lint.Lint(data, lint.Options{})
+
+	var diags []lint.Diagnostic
+	if _, err := s.opts.ParseBatchSpec(data); err != nil {
+		invalidYAML := false
+		for _, d := range lintDiags {
+			invalidYAML = invalidYAML || d.Rule == "yaml"
+		}
+		// If the YAML is invalid, the schema errors only repeat that.
+		if !invalidYAML {
+			diags = lint.SchemaDiagnostics(data, err)
+		}
+	}
+	diags = append(diags, lintDiags...)
+
+	lines := strings.Split(text, "\n")
+	result := make([]Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		severity := DiagnosticSeverityInformation
+		switch d.Severity {
+		case lint.SeverityError:
+			severity = DiagnosticSeverityError
+		case lint.SeverityWarning:
+			severity = DiagnosticSeverityWarning
+		}
+
+		message := d.Message
+		if d.Fix != "" {
+			message += " (fix: " + d.Fix + ")"
+		}
+
+		result = append(result, Diagnostic{
+			Range:    lineRange(lines, d.Line-1, d.Column-1),
+			Severity: severity,
+			Code:     d.Rule,
+			Source:   "src batch",
+			Message:  message,
+		})
+	}
+	return result
+}
+
+// lineRange returns the range from the given byte column to the end of the
+// line. Diagnostics without a position are shown on the first line.
+func lineRange(lines []string, line, col int) Range {
+	if line < 0 || line >= len(lines) {
+		line, col = 0, 0
+	}
+	text := lines[line]
+	if col < 0 || col > len(text) {
+		col = 0
+	}
+	return Range{
+		Start: Position{Line: line, Character: utf16Len(text[:col])},
+		End:   Position{Line: line, Character: utf16Len(text)},
+	}
+}
+
+// codeLensData is the data of the code lenses of the entries in "on", which
+// are resolved lazily, because resolving them needs Sourcegraph.
+type codeLensData struct {
+	URI   string `json:"uri"`
+	Index int    `json:"index"`
+}
+
+func (s *Server) codeLenses(uri string) []CodeLens {
+	lenses := []CodeLens{}
+	if s.opts.ResolveRepositoriesOn == nil {
+		return lenses
+	}
+
+	for i, item := range onEntries(s.docs[uri]) {
+		data, _ := json.Marshal(codeLensData{URI: uri, Index: i})
+		pos := Position{Line: item.Line - 1, Character: item.Column - 1}
+		lenses = append(lenses, CodeLens{Range: Range{Start: pos, End: pos}, Data: data})
+	}
+	return lenses
+}
+
+func (s *Server) resolveCodeLens(ctx context.Context, lens CodeLens) CodeLens {
+	lens.Command = &Command{Title: s.resolveRepositories(ctx, lens.Data)}
+	return lens
+}
+
+// resolveRepositories returns the title of the code lens with the given data:
+// the repositories the entry in "on" resolves to.
+func (s *Server) resolveRepositories(ctx context.Context, rawData json.RawMessage) string {
+	var data codeLensData
+	if err := json.Unmarshal(rawData, &data); err != nil {
+		return "Invalid code lens"
+	}
+
+	entries := onEntries(s.docs[data.URI])
+	if data.Index >= len(entries) {
+		return "The document changed, the repositories are resolved again"
+	}
+
+	var on batcheslib.OnQueryOrRepository
+	if err := entries[data.Index].Decode(&on); err != nil {
+		return "Invalid entry: " + err.Error()
+	}
+
+	repos, err := s.opts.ResolveRepositoriesOn(ctx, &on)
+	if err != nil {
+		return "Couldn't resolve repositories: " + err.Error()
+	}
+
+	const shown = 3
+	switch len(repos) {
+	case 0:
+		return "No repositories"
+	case 1:
+		return "1 repository: " + repos[0]
+	}
+	title := fmt.Sprintf("%d repositories: ", len(repos))
+	if len(repos) > shown {
+		return title + strings.Join(repos[:shown], ", ") + ", …"
+	}
+	return title + strings.Join(repos, ", ")
+}
+
+// onEntries returns the nodes of the entries in "on".
+func onEntries(text string) []*yaml.Node {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	on := mappingValue(doc.Content[0], "on")
+	if on == nil || on.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return on.Content
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// utf16Len returns the length of text in UTF-16 code units, which is what
+// positions in LSP are counted in.
+func utf16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// byteOffset returns the byte offset of the UTF-16 based character in text.
+func byteOffset(text string, character int) int {
+	n := 0
+	for i, r := range text {
+		if n >= character {
+			return i
+		}
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return len(text)
+}
@@ -0,0 +1,261 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+)
+
+const testSpec = `name: hello-world
+on:
+  - repositoriesMatchingQuery: file:README.md
+  - repository: github.com/sourcegraph/src-cli
+steps:
+  - run: echo ${{ repository.name }} >> README.md
+    container: alpine:3
+    outputs:
+      greeting:
+        value: ${{ step.stdout }}
+  - run: echo ${{ outputs. }}
+    container: alpine:3
+    # More steps to come.
+changesetTemplate:
+  title: ${{ outputs.nope }}
+`
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+
+	srv := NewServer(Options{
+		ParseBatchSpec: func(data []byte) (*batcheslib.BatchSpec, error) {
+			return nil, errors.New("changesetTemplate: branch is required")
+		},
+		ResolveRepositoriesOn: func(_ context.Context, on *batcheslib.OnQueryOrRepository) ([]string, error) {
+			if on.Repository != "" {
+				return nil, errors.New("offline")
+			}
+			return []string{"github.com/a/a", "github.com/b/b", "github.com/c/c", "github.com/d/d"}, nil
+		},
+	})
+
+	c := newTestClient(t, ctx, srv)
+	const uri = "file:///batch.spec.yaml"
+
+	var init InitializeResult
+	c.call("initialize", map[string]interface{}{}, &init)
+	if init.Capabilities.CodeLensProvider == nil || !init.Capabilities.HoverProvider {
+		t.Fatalf("wrong capabilities: %+v", init.Capabilities)
+	}
+
+	t.Run("diagnostics", func(t *testing.T) {
+		c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, LanguageID: "yaml", Text: testSpec},
+		})
+
+		var params PublishDiagnosticsParams
+		c.readNotification("textDocument/publishDiagnostics", &params)
+
+		have := map[string]Range{}
+		for _, d := range params.Diagnostics {
+			have[d.Code] = d.Range
+		}
+		want := map[string]Range{
+			"schema":           {Start: Position{Line: 13, Character: 0}, End: Position{Line: 13, Character: 18}},
+			"output-undefined": {Start: Position{Line: 14, Character: 13}, End: Position{Line: 14, Character: 28}},
+			"template-syntax":  {Start: Position{Line: 10, Character: 9}, End: Position{Line: 10, Character: 29}},
+		}
+		if diff := cmp.Diff(want, have); diff != "" {
+			t.Errorf("wrong diagnostics (-want +have):\n%s", diff)
+		}
+	})
+
+	labels := func(items []CompletionItem) []string {
+		var labels []string
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	completionTests := map[string]struct {
+		pos  Position
+		want []string
+	}{
+		"top-level keys": {
+			pos:  Position{Line: 13, Character: 0},
+			want: []string{"changesetTemplate", "description", "importChangesets", "name", "on", "steps", "transformChanges", "workspaces"},
+		},
+		"step keys": {
+			pos:  Position{Line: 12, Character: 4},
+			want: []string{"container", "env", "files", "if", "outputs", "run"},
+		},
+		"outputs": {
+			pos:  Position{Line: 10, Character: 26},
+			want: []string{"greeting"},
+		},
+		"fields": {
+			pos:  Position{Line: 5, Character: 29},
+			want: []string{"name", "search_result_paths"},
+		},
+		"variables": {
+			pos:  Position{Line: 14, Character: 12},
+			want: []string{"repository", "batch_change", "outputs", "steps", "join", "join_if", "matches", "replace", "split"},
+		},
+	}
+	for name, tt := range completionTests {
+		t.Run("completion "+name, func(t *testing.T) {
+			var list CompletionList
+			c.call("textDocument/completion", TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+				Position:     tt.pos,
+			}, &list)
+			if diff := cmp.Diff(tt.want, labels(list.Items)); diff != "" {
+				t.Errorf("wrong completions (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("hover", func(t *testing.T) {
+		var h Hover
+		c.call("textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 6, Character: 6},
+		}, &h)
+		if !strings.HasPrefix(h.Contents.Value, "**container**\n\nThe Docker image") {
+			t.Errorf("wrong hover: %q", h.Contents.Value)
+		}
+
+		c.call("textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 5, Character: 30},
+		}, &h)
+		if !strings.HasPrefix(h.Contents.Value, "**repository.name**") {
+			t.Errorf("wrong hover: %q", h.Contents.Value)
+		}
+	})
+
+	t.Run("code lenses", func(t *testing.T) {
+		var lenses []CodeLens
+		c.call("textDocument/codeLens", CodeLensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &lenses)
+		if len(lenses) != 2 {
+			t.Fatalf("wrong number of code lenses: %d", len(lenses))
+		}
+
+		var titles []string
+		for _, lens := range lenses {
+			var resolved CodeLens
+			c.call("codeLens/resolve", lens, &resolved)
+			titles = append(titles, resolved.Command.Title)
+		}
+		want := []string{
+			"4 repositories: github.com/a/a, github.com/b/b, github.com/c/c, …",
+			"Couldn't resolve repositories: offline",
+		}
+		if diff := cmp.Diff(want, titles); diff != "" {
+			t.Errorf("wrong titles (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		msg := c.request("textDocument/formatting", map[string]interface{}{})
+		if msg.Error == nil || msg.Error.Code != codeMethodNotFound {
+			t.Errorf("wrong error: %+v", msg.Error)
+		}
+	})
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("server failed: %s", err)
+	}
+}
+
+type testClient struct {
+	t    *testing.T
+	conn *conn
+	done chan error
+	id   int
+}
+
+func newTestClient(t *testing.T, ctx context.Context, srv *Server) *testClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &testClient{t: t, conn: newConn(clientIn, clientOut), done: make(chan error, 1)}
+	go func() {
+		c.done <- srv.Serve(ctx, serverIn, serverOut)
+		serverOut.Close()
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+func (c *testClient) request(method string, params interface{}) *message {
+	c.t.Helper()
+
+	c.id++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.t, c.id))))
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: mustMarshal(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if msg.ID == nil || string(*msg.ID) != string(id) {
+		c.t.Fatalf("expected response to %s, got %+v", method, msg)
+	}
+	return msg
+}
+
+func (c *testClient) call(method string, params, result interface{}) {
+	c.t.Helper()
+
+	msg := c.request(method, params)
+	if msg.Error != nil {
+		c.t.Fatalf("%s failed: %s", method, msg.Error.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) readNotification(method string, params interface{}) {
+	c.t.Helper()
+
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if msg.Method != method {
+		c.t.Fatalf("expected %s notification, got %+v", method, msg)
+	}
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
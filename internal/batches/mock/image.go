@@ -7,11 +7,13 @@ import (
 )
 
 type Image struct {
-	RawDigest string
-	DigestErr error
-	EnsureErr error
-	UidGid    docker.UIDGID
-	UidGidErr error
+	RawDigest     string
+	DigestErr     error
+	EnsureErr     error
+	RawRepoDigest string
+	RepoDigestErr error
+	UidGid        docker.UIDGID
+	UidGidErr     error
 }
 
 var _ docker.Image = &Image{}
@@ -24,6 +26,10 @@ func (image *Image) Ensure(ctx context.Context) error {
 	return image.EnsureErr
 }
 
+func (image *Image) RepoDigest(ctx context.Context) (string, error) {
+	return image.RawRepoDigest, image.RepoDigestErr
+}
+
 func (image *Image) UIDGID(ctx context.Context) (docker.UIDGID, error) {
 	return image.UidGid, image.UidGidErr
 }
@@ -11,18 +11,19 @@ var _ log.TaskLogger = TaskNoOpLogger{}
 
 type TaskNoOpLogger struct{}
 
-func (tl TaskNoOpLogger) Close() error                         { return nil }
-func (tl TaskNoOpLogger) Log(string)                           {}
-func (tl TaskNoOpLogger) Logf(string, ...interface{})          {}
-func (tl TaskNoOpLogger) MarkErrored()                         {}
-func (tl TaskNoOpLogger) Path() string                         { return "" }
-func (tl TaskNoOpLogger) PrefixWriter(prefix string) io.Writer { return &bytes.Buffer{} }
+func (tl TaskNoOpLogger) Close() error                                 { return nil }
+func (tl TaskNoOpLogger) Log(string)                                   {}
+func (tl TaskNoOpLogger) Logf(string, ...interface{})                  {}
+func (tl TaskNoOpLogger) StepLogf(int, string, ...interface{})         {}
+func (tl TaskNoOpLogger) MarkErrored()                                 {}
+func (tl TaskNoOpLogger) Path() string                                 { return "" }
+func (tl TaskNoOpLogger) StepWriter(step int, stream string) io.Writer { return &bytes.Buffer{} }
 
 var _ log.LogManager = LogNoOpManager{}
 
 type LogNoOpManager struct{}
 
-func (lm LogNoOpManager) AddTask(string) (log.TaskLogger, error) {
+func (lm LogNoOpManager) AddTask(log.Task) (log.TaskLogger, error) {
 	return TaskNoOpLogger{}, nil
 }
 
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// The JUnit XML format, as understood by most CI systems.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as JUnit XML, with a test case for every task.
+// Failed tasks are failures and tasks that weren't executed are skipped. The
+// steps of a task are listed in its output.
+func WriteJUnit(w io.Writer, report *Report) error {
+	suite := junitTestSuite{Name: report.Name}
+	var total float64
+	for _, task := range report.Tasks {
+		tc := junitTestCase{
+			Name:      taskName(task),
+			ClassName: report.Name,
+			Time:      seconds(task.DurationSeconds),
+			SystemOut: taskOutput(task),
+		}
+		switch task.Status {
+		case TaskFailed:
+			tc.Failure = &junitFailure{Message: task.Error, Body: task.Error}
+			if task.LogFile != "" {
+				tc.Failure.Body += "\n\nSee " + task.LogFile + " for details."
+			}
+			suite.Failures++
+		case TaskNotExecuted:
+			tc.Skipped = &junitSkipped{Message: "not executed"}
+			suite.Skipped++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+		suite.Tests++
+		total += task.DurationSeconds
+	}
+	suite.Time = seconds(total)
+
+	suites := junitTestSuites{
+		Name:     report.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func taskName(task *TaskReport) string {
+	if task.Path == "" {
+		return task.Repository
+	}
+	return path.Join(task.Repository, task.Path)
+}
+
+// taskOutput describes the cache status, changed files, steps and log file of
+// a task, one per line.
+func taskOutput(task *TaskReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cache: %s\n", task.Cache)
+	fmt.Fprintf(&b, "changed files: %d\n", task.ChangedFiles)
+	for _, step := range task.Steps {
+		fmt.Fprintf(&b, "step %d", step.Step)
+		if step.Variant != "" {
+			fmt.Fprintf(&b, " (%s)", step.Variant)
+		}
+		fmt.Fprintf(&b, ": %s", step.Status)
+		if step.Status == StepSucceeded || step.Status == StepFailed {
+			fmt.Fprintf(&b, " in %ss", seconds(step.DurationSeconds))
+		}
+		if step.ExitCode != nil {
+			fmt.Fprintf(&b, ", exit code %d", *step.ExitCode)
+		}
+		b.WriteString("\n")
+	}
+	if task.LogFile != "" {
+		fmt.Fprintf(&b, "log: %s\n", task.LogFile)
+	}
+	return b.String()
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
@@ -0,0 +1,309 @@
+// Package report records the execution of the tasks of a batch spec, so that
+// the result of every task can be written to a file that CI systems can
+// render and archive.
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+// Report is the result of executing the tasks of a batch spec.
+type Report struct {
+	// Name is the name of the batch spec.
+	Name  string        `json:"name"`
+	Tasks []*TaskReport `json:"tasks"`
+}
+
+// TaskStatus is the outcome of a task.
+type TaskStatus string
+
+const (
+	TaskSucceeded TaskStatus = "succeeded"
+	TaskFailed    TaskStatus = "failed"
+	// TaskNotExecuted is the status of tasks that weren't executed, because
+	// the execution stopped before.
+	TaskNotExecuted TaskStatus = "not-executed"
+)
+
+// CacheStatus is whether the result of a task was found in the cache.
+type CacheStatus string
+
+const (
+	CacheHit CacheStatus = "hit"
+	// CachePartial is the status of tasks for which the results of some of
+	// their steps were found in the cache.
+	CachePartial CacheStatus = "partial"
+	CacheMiss    CacheStatus = "miss"
+)
+
+// StepStatus is the outcome of a step.
+type StepStatus string
+
+const (
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	// StepSkipped is the status of steps whose if condition was false.
+	StepSkipped     StepStatus = "skipped"
+	StepCached      StepStatus = "cached"
+	StepNotExecuted StepStatus = "not-executed"
+)
+
+// TaskReport is the result of a task, which executes the steps in a
+// repository, or a path in it.
+type TaskReport struct {
+	Repository string      `json:"repository"`
+	Path       string      `json:"path"`
+	Status     TaskStatus  `json:"status"`
+	Cache      CacheStatus `json:"cache"`
+	// DurationSeconds is zero if the task wasn't executed.
+	DurationSeconds float64       `json:"durationSeconds"`
+	Steps           []*StepReport `json:"steps"`
+	// ChangedFiles is the number of files changed by the steps.
+	ChangedFiles int    `json:"changedFiles"`
+	Error        string `json:"error,omitempty"`
+	// LogFile is the path of the log file of the task, if it was kept.
+	LogFile string `json:"logFile,omitempty"`
+}
+
+// StepReport is the result of a step in a task.
+type StepReport struct {
+	// Step is the 1-based index of the step in the batch spec.
+	Step int `json:"step"`
+	// Variant is the matrix variant of the step, like "go=1.17".
+	Variant         string     `json:"variant,omitempty"`
+	Status          StepStatus `json:"status"`
+	DurationSeconds float64    `json:"durationSeconds"`
+	// ExitCode is nil if the step didn't run. It's -1 if the step failed
+	// without an exit code, like when it timed out.
+	ExitCode *int `json:"exitCode,omitempty"`
+}
+
+// WriteJSON writes the report as indented JSON.
+func WriteJSON(w io.Writer, report *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// Recorder records the execution of tasks. Its UI method wraps the UI of the
+// execution, so that the events of the tasks and their steps are recorded
+// while they're shown.
+type Recorder struct {
+	name     string
+	keepLogs bool
+
+	mu      sync.Mutex
+	tasks   []*executor.Task
+	reports map[*executor.Task]*TaskReport
+	started map[*executor.Task]time.Time
+	steps   map[*executor.Task]map[int]time.Time
+}
+
+// NewRecorder returns a Recorder for the given tasks of the batch spec with
+// the given name. The uncached tasks are the ones that are executed, the
+// results of the others were found in the cache. keepLogs is whether the log
+// files of the tasks that succeeded are kept.
+func NewRecorder(name string, tasks, uncached []*executor.Task, keepLogs bool) *Recorder {
+	r := &Recorder{
+		name:     name,
+		keepLogs: keepLogs,
+		tasks:    tasks,
+		reports:  make(map[*executor.Task]*TaskReport, len(tasks)),
+		started:  make(map[*executor.Task]time.Time),
+		steps:    make(map[*executor.Task]map[int]time.Time),
+	}
+
+	isUncached := make(map[*executor.Task]bool, len(uncached))
+	for _, task := range uncached {
+		isUncached[task] = true
+	}
+
+	for _, task := range tasks {
+		report := &TaskReport{
+			Repository: task.Repository.Name,
+			Path:       task.Path,
+			Status:     TaskNotExecuted,
+			Cache:      CacheMiss,
+			Steps:      make([]*StepReport, len(task.Steps)),
+		}
+		for i := range task.Steps {
+			report.Steps[i] = &StepReport{Step: i + 1, Variant: task.StepVariant(i), Status: StepNotExecuted}
+		}
+
+		if !isUncached[task] {
+			report.Status = TaskSucceeded
+			report.Cache = CacheHit
+			for _, step := range report.Steps {
+				step.Status = StepCached
+			}
+		} else if task.CachedResultFound {
+			report.Cache = CachePartial
+			for i := 0; i <= task.CachedResult.StepIndex && i < len(report.Steps); i++ {
+				report.Steps[i].Status = StepCached
+			}
+		}
+
+		r.reports[task] = report
+	}
+
+	return r
+}
+
+// UI returns a TaskExecutionUI that records the events it receives and passes
+// them on to the given UI.
+func (r *Recorder) UI(ui executor.TaskExecutionUI) executor.TaskExecutionUI {
+	return &recordingUI{TaskExecutionUI: ui, r: r}
+}
+
+// Report returns the report of the tasks. It should be called once the
+// execution is done.
+func (r *Recorder) Report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Name: r.name, Tasks: make([]*TaskReport, 0, len(r.tasks))}
+	for _, task := range r.tasks {
+		taskReport := r.reports[task]
+		taskReport.ChangedFiles = countChanges(task.ChangedFiles)
+		if taskReport.Status == TaskSucceeded && r.keepLogs && taskReport.LogFile == "" {
+			taskReport.LogFile = task.LogFile
+		}
+		report.Tasks = append(report.Tasks, taskReport)
+	}
+	return report
+}
+
+func (r *Recorder) taskStarted(task *executor.Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.started[task] = time.Now()
+}
+
+func (r *Recorder) taskFinished(task *executor.Task, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, ok := r.reports[task]
+	if !ok {
+		return
+	}
+	if started, ok := r.started[task]; ok {
+		report.DurationSeconds = time.Since(started).Seconds()
+	}
+
+	if err == nil {
+		report.Status = TaskSucceeded
+		return
+	}
+
+	report.Status = TaskFailed
+	report.Error = err.Error()
+	var taskErr executor.TaskExecutionErr
+	if errors.As(err, &taskErr) {
+		report.Error = taskErr.Err.Error()
+		report.LogFile = taskErr.Logfile
+	}
+}
+
+func (r *Recorder) stepStarted(task *executor.Task, step int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.steps[task] == nil {
+		r.steps[task] = make(map[int]time.Time)
+	}
+	// Preparing the step counts towards its duration, so the step is only
+	// started once.
+	if _, ok := r.steps[task][step]; !ok {
+		r.steps[task][step] = time.Now()
+	}
+}
+
+func (r *Recorder) stepDone(task *executor.Task, step int, status StepStatus, exitCode *int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, ok := r.reports[task]
+	if !ok || step < 1 || step > len(report.Steps) {
+		return
+	}
+
+	stepReport := report.Steps[step-1]
+	stepReport.Status = status
+	stepReport.ExitCode = exitCode
+	if started, ok := r.steps[task][step]; ok {
+		stepReport.DurationSeconds = time.Since(started).Seconds()
+	}
+}
+
+type recordingUI struct {
+	executor.TaskExecutionUI
+	r *Recorder
+}
+
+func (ui *recordingUI) TaskStarted(task *executor.Task) {
+	ui.r.taskStarted(task)
+	ui.TaskExecutionUI.TaskStarted(task)
+}
+
+func (ui *recordingUI) TaskFinished(task *executor.Task, err error) {
+	ui.r.taskFinished(task, err)
+	ui.TaskExecutionUI.TaskFinished(task, err)
+}
+
+func (ui *recordingUI) StepsExecutionUI(task *executor.Task) executor.StepsExecutionUI {
+	return &recordingStepsUI{StepsExecutionUI: ui.TaskExecutionUI.StepsExecutionUI(task), r: ui.r, task: task}
+}
+
+type recordingStepsUI struct {
+	executor.StepsExecutionUI
+	r    *Recorder
+	task *executor.Task
+}
+
+func (ui *recordingStepsUI) StepSkipped(step int) {
+	ui.r.stepDone(ui.task, step, StepSkipped, nil)
+	ui.StepsExecutionUI.StepSkipped(step)
+}
+
+func (ui *recordingStepsUI) StepPreparingStart(step int) {
+	ui.r.stepStarted(ui.task, step)
+	ui.StepsExecutionUI.StepPreparingStart(step)
+}
+
+func (ui *recordingStepsUI) StepPreparingFailed(step int, err error) {
+	ui.r.stepDone(ui.task, step, StepFailed, nil)
+	ui.StepsExecutionUI.StepPreparingFailed(step, err)
+}
+
+func (ui *recordingStepsUI) StepStarted(step int, runScript string, env map[string]string) {
+	ui.r.stepStarted(ui.task, step)
+	ui.StepsExecutionUI.StepStarted(step, runScript, env)
+}
+
+func (ui *recordingStepsUI) StepFinished(step int, diff string, changes *git.Changes, outputs map[string]interface{}) {
+	exitCode := 0
+	ui.r.stepDone(ui.task, step, StepSucceeded, &exitCode)
+	ui.StepsExecutionUI.StepFinished(step, diff, changes, outputs)
+}
+
+func (ui *recordingStepsUI) StepFailed(step int, err error, exitCode int) {
+	ui.r.stepDone(ui.task, step, StepFailed, &exitCode)
+	ui.StepsExecutionUI.StepFailed(step, err, exitCode)
+}
+
+func countChanges(changes *git.Changes) int {
+	if changes == nil {
+		return 0
+	}
+	return len(changes.Modified) + len(changes.Added) + len(changes.Deleted) + len(changes.Renamed)
+}
@@ -0,0 +1,181 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestRecorder(t *testing.T) {
+	newTask := func(repo string, steps int) *executor.Task {
+		return &executor.Task{
+			Repository: &graphql.Repository{Name: repo},
+			Steps:      make([]batcheslib.Step, steps),
+		}
+	}
+	cached := newTask("github.com/sourcegraph/cached", 1)
+	cached.ChangedFiles = &git.Changes{Modified: []string{"README.md"}}
+	succeeded := newTask("github.com/sourcegraph/succeeded", 2)
+	succeeded.Path = "docs"
+	succeeded.LogFile = "/tmp/succeeded.log"
+	succeeded.ChangedFiles = &git.Changes{Modified: []string{"a.md"}, Added: []string{"b.md"}}
+	failed := newTask("github.com/sourcegraph/failed", 2)
+	failed.CachedResultFound = true
+	notExecuted := newTask("github.com/sourcegraph/not-executed", 1)
+
+	tasks := []*executor.Task{cached, succeeded, failed, notExecuted}
+	r := NewRecorder("hello-world", tasks, tasks[1:], true)
+
+	ui := r.UI(noopTaskExecutionUI{})
+	ui.TaskStarted(succeeded)
+	steps := ui.StepsExecutionUI(succeeded)
+	steps.StepPreparingStart(1)
+	steps.StepStarted(1, "", nil)
+	steps.StepFinished(1, "", nil, nil)
+	steps.StepSkipped(2)
+	ui.TaskFinished(succeeded, nil)
+
+	ui.TaskStarted(failed)
+	steps = ui.StepsExecutionUI(failed)
+	steps.StepStarted(2, "", nil)
+	steps.StepFailed(2, errors.New("exit status 1"), 1)
+	ui.TaskFinished(failed, executor.TaskExecutionErr{
+		Err:        errors.New("step 2 failed"),
+		Logfile:    "/tmp/failed.log",
+		Repository: failed.Repository.Name,
+	})
+
+	report := r.Report()
+	for _, task := range report.Tasks {
+		task.DurationSeconds = 0
+		for _, step := range task.Steps {
+			step.DurationSeconds = 0
+		}
+	}
+
+	zero, one := 0, 1
+	want := &Report{
+		Name: "hello-world",
+		Tasks: []*TaskReport{
+			{
+				Repository:   "github.com/sourcegraph/cached",
+				Status:       TaskSucceeded,
+				Cache:        CacheHit,
+				Steps:        []*StepReport{{Step: 1, Status: StepCached}},
+				ChangedFiles: 1,
+			},
+			{
+				Repository: "github.com/sourcegraph/succeeded",
+				Path:       "docs",
+				Status:     TaskSucceeded,
+				Cache:      CacheMiss,
+				Steps: []*StepReport{
+					{Step: 1, Status: StepSucceeded, ExitCode: &zero},
+					{Step: 2, Status: StepSkipped},
+				},
+				ChangedFiles: 2,
+				LogFile:      "/tmp/succeeded.log",
+			},
+			{
+				Repository: "github.com/sourcegraph/failed",
+				Status:     TaskFailed,
+				Cache:      CachePartial,
+				Steps: []*StepReport{
+					{Step: 1, Status: StepCached},
+					{Step: 2, Status: StepFailed, ExitCode: &one},
+				},
+				Error:   "step 2 failed",
+				LogFile: "/tmp/failed.log",
+			},
+			{
+				Repository: "github.com/sourcegraph/not-executed",
+				Status:     TaskNotExecuted,
+				Cache:      CacheMiss,
+				Steps:      []*StepReport{{Step: 1, Status: StepNotExecuted}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("wrong report (-want +have):\n%s", diff)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	exitCode := 1
+	report := &Report{
+		Name: "hello-world",
+		Tasks: []*TaskReport{
+			{
+				Repository:      "github.com/sourcegraph/succeeded",
+				Path:            "docs",
+				Status:          TaskSucceeded,
+				Cache:           CacheHit,
+				DurationSeconds: 1.5,
+				Steps:           []*StepReport{{Step: 1, Status: StepCached}},
+				ChangedFiles:    2,
+			},
+			{
+				Repository:      "github.com/sourcegraph/failed",
+				Status:          TaskFailed,
+				Cache:           CacheMiss,
+				DurationSeconds: 2,
+				Steps: []*StepReport{
+					{Step: 1, Variant: "go=1.17", Status: StepFailed, DurationSeconds: 1.25, ExitCode: &exitCode},
+				},
+				Error:   "step 1 failed",
+				LogFile: "/tmp/failed.log",
+			},
+			{
+				Repository: "github.com/sourcegraph/not-executed",
+				Status:     TaskNotExecuted,
+				Cache:      CacheMiss,
+				Steps:      []*StepReport{{Step: 1, Status: StepNotExecuted}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="hello-world" tests="3" failures="1" skipped="1" time="3.500">
+  <testsuite name="hello-world" tests="3" failures="1" skipped="1" time="3.500">
+    <testcase name="github.com/sourcegraph/succeeded/docs" classname="hello-world" time="1.500">
+      <system-out>cache: hit&#xA;changed files: 2&#xA;step 1: cached&#xA;</system-out>
+    </testcase>
+    <testcase name="github.com/sourcegraph/failed" classname="hello-world" time="2.000">
+      <failure message="step 1 failed">step 1 failed&#xA;&#xA;See /tmp/failed.log for details.</failure>
+      <system-out>cache: miss&#xA;changed files: 0&#xA;step 1 (go=1.17): failed in 1.250s, exit code 1&#xA;log: /tmp/failed.log&#xA;</system-out>
+    </testcase>
+    <testcase name="github.com/sourcegraph/not-executed" classname="hello-world" time="0.000">
+      <skipped message="not executed"></skipped>
+      <system-out>cache: miss&#xA;changed files: 0&#xA;step 1: not-executed&#xA;</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("wrong JUnit XML (-want +have):\n%s", diff)
+	}
+}
+
+type noopTaskExecutionUI struct{}
+
+func (noopTaskExecutionUI) Start([]*executor.Task)                                              {}
+func (noopTaskExecutionUI) Success()                                                            {}
+func (noopTaskExecutionUI) Failed(error)                                                        {}
+func (noopTaskExecutionUI) TaskStarted(*executor.Task)                                          {}
+func (noopTaskExecutionUI) TaskFinished(*executor.Task, error)                                  {}
+func (noopTaskExecutionUI) TaskChangesetSpecsBuilt(*executor.Task, []*batcheslib.ChangesetSpec) {}
+func (noopTaskExecutionUI) StepsExecutionUI(*executor.Task) executor.StepsExecutionUI {
+	return executor.NoopStepsExecUI{}
+}
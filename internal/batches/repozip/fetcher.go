@@ -73,6 +73,10 @@ type archiveRegistry struct {
 	dir        string
 	deleteZips bool
 
+	// clones are the local clones archives are created from instead of
+	// downloading them. See NewLocalArchiveRegistry.
+	clones *LocalClones
+
 	zipsMu sync.Mutex
 	zips   map[string]*repoArchive
 }
@@ -118,6 +122,9 @@ func (rf *archiveRegistry) zipFor(repo RepoRevision, workspacePath string) *repo
 			deleteOnClose: rf.deleteZips,
 			pathInRepo:    workspacePath,
 		}
+		if dir, ok := rf.clones.Lookup(repo.RepoName); ok {
+			zip.localClone = dir
+		}
 
 		if workspacePath != "" {
 			// We're doing another loop here to catch all
@@ -174,6 +181,9 @@ type repoArchive struct {
 	pathInRepo string
 
 	client HTTPClient
+	// localClone is the path of a local clone of the repository. If it's set,
+	// the archive and files are created from it rather than downloaded.
+	localClone string
 
 	// zipPath is the path of the downloaded ZIP archive on the local filesystem.
 	zipPath string
@@ -262,6 +272,12 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 		}
 	}()
 
+	if rz.localClone != "" {
+		if err := verifyLocalClone(ctx, rz.localClone, rz.repo); err != nil {
+			return err
+		}
+	}
+
 	exists, err := fileExists(rz.zipPath)
 	if err != nil {
 		return err
@@ -276,7 +292,7 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 			return err
 		}
 
-		ok, err := fetchRepositoryFile(ctx, rz.client, rz.repo, rz.pathInRepo, rz.zipPath)
+		ok, err := rz.fetchFile(ctx, rz.pathInRepo, rz.zipPath)
 		if err != nil {
 			return errors.Wrap(err, "fetching ZIP archive")
 		}
@@ -296,7 +312,7 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 			continue
 		}
 
-		ok, err := fetchRepositoryFile(ctx, rz.client, rz.repo, addFile.filename, addFile.localPath)
+		ok, err := rz.fetchFile(ctx, addFile.filename, addFile.localPath)
 		if err != nil {
 			return errors.Wrapf(err, "fetching %s for repository archive", addFile.filename)
 		}
@@ -308,6 +324,15 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 	return nil
 }
 
+// fetchFile fetches the given pathInRepo from the local clone, if there is
+// one, or from Sourcegraph and writes it to dest.
+func (rz *repoArchive) fetchFile(ctx context.Context, pathInRepo, dest string) (bool, error) {
+	if rz.localClone != "" {
+		return archiveLocalFile(ctx, rz.localClone, rz.repo, pathInRepo, dest)
+	}
+	return fetchRepositoryFile(ctx, rz.client, rz.repo, pathInRepo, dest)
+}
+
 // fetchRepositoryInFile fetches the given `pathInRepo` using the Sourcegraph's
 // raw endpoint and writes it to `dest`.
 // If `pathInRepo` is empty and `dest` ends in `.zip` a ZIP archive of the
@@ -0,0 +1,218 @@
+package repozip
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// WorktreeArchive is implemented by the Archives of NewGitArchiveRegistry,
+// which are git repositories instead of ZIP archives. Workspaces are created
+// from them as worktrees, which also contain the history of the repository,
+// instead of by unzipping Path.
+type WorktreeArchive interface {
+	Archive
+
+	// Commit returns the commit that the workspaces are created at.
+	Commit() string
+
+	// AddWorktree checks out the commit in dir, which has to be empty, as a
+	// worktree of the bare repository at Path.
+	AddWorktree(ctx context.Context, dir string) error
+
+	// RemoveWorktree removes the worktree in dir again.
+	RemoveWorktree(ctx context.Context, dir string) error
+}
+
+// NewGitArchiveRegistry returns an ArchiveRegistry that keeps a bare mirror of
+// every repository in dir. Only the objects that are missing in a mirror are
+// fetched, over the git endpoint of the Sourcegraph instance, so that
+// executing a batch spec on new commits doesn't download the whole repository
+// again.
+//
+// The mirrors are kept when the Archives are closed.
+func NewGitArchiveRegistry(client HTTPClient, dir string) ArchiveRegistry {
+	// The worktrees refer to the mirrors by their absolute paths.
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return &gitArchiveRegistry{client: client, dir: filepath.Join(dir, "mirrors")}
+}
+
+type gitArchiveRegistry struct {
+	client HTTPClient
+	dir    string
+
+	mirrorsMu sync.Mutex
+	mirrors   map[string]*mirror
+}
+
+// mirror is the bare mirror of a repository.
+type mirror struct {
+	// mu serializes the git commands that change the mirror.
+	mu   sync.Mutex
+	path string
+}
+
+func (r *gitArchiveRegistry) Checkout(repo RepoRevision, _ string) Archive {
+	r.mirrorsMu.Lock()
+	defer r.mirrorsMu.Unlock()
+
+	if r.mirrors == nil {
+		r.mirrors = make(map[string]*mirror)
+	}
+	m, ok := r.mirrors[repo.RepoName]
+	if !ok {
+		m = &mirror{path: filepath.Join(r.dir, mirrorName(repo.RepoName))}
+		r.mirrors[repo.RepoName] = m
+	}
+
+	// The workspaces always contain the whole repository, so there's nothing
+	// to be gained from the path.
+	return &gitArchive{client: r.client, mirror: m, repo: repo}
+}
+
+// mirrorName returns the name of the directory of the mirror of the
+// repository. The mirror is shared by all commits, so it's named after the
+// repository only.
+//
+// Replacing the slashes makes different repositories end up with the same
+// name, like "github.com/a/b-c" and "github.com/a-b/c", so a hash of the
+// repository name makes it unique again.
+func mirrorName(repoName string) string {
+	sum := sha256.Sum256([]byte(repoName))
+	return strings.ReplaceAll(repoName, "/", "-") + "-" + hex.EncodeToString(sum[:4]) + ".git"
+}
+
+var _ WorktreeArchive = &gitArchive{}
+
+type gitArchive struct {
+	client HTTPClient
+	mirror *mirror
+	repo   RepoRevision
+}
+
+func (a *gitArchive) Ensure(ctx context.Context) error {
+	a.mirror.mu.Lock()
+	defer a.mirror.mu.Unlock()
+
+	exists, err := fileExists(a.mirror.path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := a.initMirror(ctx); err != nil {
+			os.RemoveAll(a.mirror.path)
+			return errors.Wrap(err, "creating mirror")
+		}
+	}
+
+	if _, err := mirrorGit(ctx, a.mirror.path, nil, "cat-file", "-e", a.repo.Commit+"^{commit}"); err == nil {
+		// Nothing new to fetch.
+		return nil
+	}
+
+	req, err := a.client.NewHTTPRequest(ctx, "GET", repositoryGitEndpoint(a.repo), nil)
+	if err != nil {
+		return err
+	}
+	// The headers, such as the one with the access token, are passed on to
+	// git in its environment rather than its arguments, so that they don't
+	// show up in the list of processes.
+	var keys []string
+	for key := range req.Header {
+		if key != "User-Agent" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	env := []string{fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(keys))}
+	for i, key := range keys {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=http.extraHeader", i),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s: %s", i, key, req.Header.Get(key)),
+		)
+	}
+
+	if _, err := mirrorGit(ctx, a.mirror.path, env, "fetch", "--quiet", "--no-tags", req.URL.String(), a.repo.Commit); err != nil {
+		return errors.Wrap(err, "fetching commit into mirror")
+	}
+
+	// Without a ref, the fetched objects would be removed by the next gc.
+	_, err = mirrorGit(ctx, a.mirror.path, nil, "update-ref", "refs/batch-changes/"+a.repo.Commit, a.repo.Commit)
+	return err
+}
+
+func (a *gitArchive) initMirror(ctx context.Context) error {
+	if err := os.MkdirAll(a.mirror.path, 0700); err != nil {
+		return err
+	}
+	if _, err := mirrorGit(ctx, a.mirror.path, nil, "init", "--quiet", "--bare"); err != nil {
+		return err
+	}
+	// The volume workspaces fetch the commit from the mirror.
+	_, err := mirrorGit(ctx, a.mirror.path, nil, "config", "uploadpack.allowAnySHA1InWant", "true")
+	return err
+}
+
+// Close doesn't remove anything: the mirror is reused the next time.
+func (a *gitArchive) Close() error { return nil }
+
+func (a *gitArchive) Path() string { return a.mirror.path }
+
+func (a *gitArchive) Commit() string { return a.repo.Commit }
+
+// AdditionalFilePaths returns no files, since the worktrees contain all files
+// of the repository.
+func (a *gitArchive) AdditionalFilePaths() map[string]string { return map[string]string{} }
+
+func (a *gitArchive) AddWorktree(ctx context.Context, dir string) error {
+	a.mirror.mu.Lock()
+	defer a.mirror.mu.Unlock()
+
+	_, err := mirrorGit(ctx, a.mirror.path, nil, "worktree", "add", "--quiet", "--detach", dir, a.repo.Commit)
+	return err
+}
+
+func (a *gitArchive) RemoveWorktree(ctx context.Context, dir string) error {
+	a.mirror.mu.Lock()
+	defer a.mirror.mu.Unlock()
+
+	if _, err := mirrorGit(ctx, a.mirror.path, nil, "worktree", "remove", "--force", dir); err != nil {
+		// If the worktree can't be removed by git, we remove it ourselves and
+		// let git forget about it.
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		_, err = mirrorGit(ctx, a.mirror.path, nil, "worktree", "prune")
+		return err
+	}
+	return nil
+}
+
+func repositoryGitEndpoint(repo RepoRevision) string {
+	return path.Join(".api", "git", repo.RepoName)
+}
+
+// mirrorGit runs git in the mirror in dir, with env added to the environment.
+func mirrorGit(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Env = append(cmd.Env, env...)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "'git %s' failed: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
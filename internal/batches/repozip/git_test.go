@@ -0,0 +1,142 @@
+package repozip
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func TestGitArchiveRegistry(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not found")
+	}
+
+	const repoName = "github.com/sourcegraph/src-cli"
+
+	// The repository is served by git http-backend at the git endpoint.
+	root := t.TempDir()
+	upstream := filepath.Join(root, ".api", "git", filepath.FromSlash(repoName))
+	if err := os.MkdirAll(upstream, 0700); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, upstream, "init", "--quiet")
+	runGit(t, upstream, "config", "uploadpack.allowAnySHA1InWant", "true")
+
+	commit := func(name, content string) string {
+		if err := os.WriteFile(filepath.Join(upstream, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, upstream, "add", name)
+		runGit(t, upstream, "commit", "--quiet", "-m", "add "+name)
+		return strings.TrimSpace(runGit(t, upstream, "rev-parse", "HEAD"))
+	}
+
+	var requests int
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		requests++
+		backend.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	var clientBuffer bytes.Buffer
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, AccessToken: "secret", Out: &clientBuffer})
+	registry := NewGitArchiveRegistry(client, t.TempDir())
+	ctx := context.Background()
+
+	checkout := func(t *testing.T, rev string) (WorktreeArchive, string) {
+		t.Helper()
+
+		archive := registry.Checkout(RepoRevision{RepoName: repoName, Commit: rev}, "").(WorktreeArchive)
+		if err := archive.Ensure(ctx); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		dir := filepath.Join(t.TempDir(), "workspace")
+		if err := archive.AddWorktree(ctx, dir); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return archive, dir
+	}
+
+	first := commit("README.md", "# Welcome\n")
+
+	t.Run("first commit", func(t *testing.T) {
+		archive, dir := checkout(t, first)
+		defer archive.Close()
+
+		content, err := os.ReadFile(filepath.Join(dir, "README.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "# Welcome\n" {
+			t.Errorf("wrong content: %q", content)
+		}
+
+		if err := archive.RemoveWorktree(ctx, dir); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("worktree not removed: %v", err)
+		}
+	})
+
+	second := commit("CHANGELOG.md", "# Changelog\n")
+
+	t.Run("new commit", func(t *testing.T) {
+		requests = 0
+		archive, dir := checkout(t, second)
+		defer archive.RemoveWorktree(ctx, dir)
+
+		if requests == 0 {
+			t.Error("new commit wasn't fetched")
+		}
+
+		// The workspace has the history of the repository.
+		if have := strings.TrimSpace(runGit(t, dir, "rev-list", "--count", "HEAD")); have != "2" {
+			t.Errorf("wrong number of commits: %s", have)
+		}
+	})
+
+	t.Run("known commit", func(t *testing.T) {
+		requests = 0
+		archive, dir := checkout(t, first)
+		defer archive.RemoveWorktree(ctx, dir)
+
+		if requests != 0 {
+			t.Errorf("known commit was fetched again with %d requests", requests)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "CHANGELOG.md")); !os.IsNotExist(err) {
+			t.Errorf("file of later commit in worktree: %v", err)
+		}
+	})
+}
+
+func TestMirrorName(t *testing.T) {
+	// Both would be "github.com-a-b-c.git" if only the slashes were
+	// replaced.
+	a, b := mirrorName("github.com/a/b-c"), mirrorName("github.com/a-b/c")
+	if a == b {
+		t.Errorf("repositories have the same mirror %q", a)
+	}
+	if !strings.HasPrefix(a, "github.com-a-b-c-") || !strings.HasSuffix(a, ".git") {
+		t.Errorf("mirror isn't named after the repository: %q", a)
+	}
+}
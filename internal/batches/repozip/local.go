@@ -0,0 +1,157 @@
+package repozip
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// NewLocalArchiveRegistry returns an ArchiveRegistry that creates the archives
+// of the repositories that have a local clone with `git archive`, instead of
+// downloading them from Sourcegraph. The archives of all other repositories
+// are downloaded using client.
+//
+// The HEAD of a local clone has to be the commit the archive is for, so that
+// the workspaces are the same as the ones Sourcegraph would create.
+func NewLocalArchiveRegistry(client HTTPClient, dir string, deleteZips bool, clones *LocalClones) ArchiveRegistry {
+	return &archiveRegistry{client: client, dir: dir, deleteZips: deleteZips, clones: clones}
+}
+
+// LocalClones finds the local clones of repositories.
+type LocalClones struct {
+	// Dir is a directory that contains clones at the names of the
+	// repositories, like Dir/github.com/sourcegraph/src-cli.
+	Dir string
+	// Paths maps the names of repositories to the paths of their clones. They
+	// take precedence over the clones in Dir.
+	Paths map[string]string
+}
+
+// Lookup returns the path of the local clone of the repository with the given
+// name, if there is one.
+func (lc *LocalClones) Lookup(repoName string) (string, bool) {
+	if lc == nil {
+		return "", false
+	}
+
+	if dir, ok := lc.Paths[repoName]; ok {
+		return dir, true
+	}
+
+	if lc.Dir != "" {
+		dir := filepath.Join(lc.Dir, filepath.FromSlash(repoName))
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// ReadLocalClonesFile reads a file that maps the names of repositories to the
+// paths of their local clones:
+//
+//	github.com/sourcegraph/sourcegraph: ~/src/sourcegraph
+//	github.com/sourcegraph/src-cli: ../src-cli
+//
+// Relative paths are relative to the directory of the file.
+func ReadLocalClonesFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths map[string]string
+	if err := yaml.Unmarshal(data, &paths); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", path)
+	}
+
+	home, _ := os.UserHomeDir()
+	for name, dir := range paths {
+		switch {
+		case dir == "~" || strings.HasPrefix(dir, "~/"):
+			if home == "" {
+				return nil, errors.Newf("the clone of %s is in the home directory, but it can't be determined", name)
+			}
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		case !filepath.IsAbs(dir):
+			dir = filepath.Join(filepath.Dir(path), dir)
+		}
+		paths[name] = dir
+	}
+	return paths, nil
+}
+
+// verifyLocalClone returns an error if the HEAD of the clone in dir isn't the
+// commit of repo.
+func verifyLocalClone(ctx context.Context, dir string, repo RepoRevision) error {
+	out, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return errors.Wrapf(err, "determining HEAD of local clone of %s in %s", repo.RepoName, dir)
+	}
+
+	if head := strings.TrimSpace(string(out)); head != repo.Commit {
+		return errors.Newf(
+			"HEAD of local clone of %s in %s is %s, but the batch spec is executed on %s: check out %s or remove the clone from the local repositories",
+			repo.RepoName, dir, head, repo.Commit, repo.Commit,
+		)
+	}
+	return nil
+}
+
+// archiveLocalFile writes pathInRepo at the commit of repo in the clone in dir
+// to dest. Like fetchRepositoryFile, a ZIP archive of the whole repository is
+// written if pathInRepo is empty and dest ends in `.zip`, and of the directory
+// pathInRepo if it's not empty.
+func archiveLocalFile(ctx context.Context, dir string, repo RepoRevision, pathInRepo, dest string) (bool, error) {
+	// The commands run in dir.
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return false, err
+	}
+
+	if strings.HasSuffix(dest, ".zip") {
+		args := []string{"archive", "--format=zip", "--output", dest, repo.Commit}
+		if pathInRepo != "" {
+			args = append(args, "--", pathInRepo)
+		}
+		if _, err := git(ctx, dir, args...); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	object := repo.Commit + ":" + pathInRepo
+	if _, err := git(ctx, dir, "cat-file", "-e", object); err != nil {
+		// Like the raw endpoint, a file that doesn't exist isn't an error.
+		return false, nil
+	}
+
+	content, err := git(ctx, dir, "cat-file", "blob", object)
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(dest, content, 0600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "'git %s' failed: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
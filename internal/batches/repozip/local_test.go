@@ -0,0 +1,179 @@
+package repozip
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocalArchiveRegistry(t *testing.T) {
+	clone := t.TempDir()
+	files := map[string]string{
+		"README.md":               "# Welcome to the README\n",
+		".gitignore":              "node_modules\n",
+		"examples/.gitattributes": "*.go diff=golang\n",
+		"examples/project/go.mod": "module project\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(clone, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, clone, "init", "--quiet")
+	runGit(t, clone, "add", ".")
+	runGit(t, clone, "commit", "--quiet", "-m", "initial commit")
+	head := strings.TrimSpace(runGit(t, clone, "rev-parse", "HEAD"))
+
+	const repoName = "github.com/sourcegraph/src-cli"
+	registry := NewLocalArchiveRegistry(nil, t.TempDir(), true, &LocalClones{
+		Paths: map[string]string{repoName: clone},
+	})
+	repo := RepoRevision{RepoName: repoName, Commit: head}
+
+	t.Run("repository", func(t *testing.T) {
+		archive := registry.Checkout(repo, "")
+		if err := archive.Ensure(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := []string{".gitignore", "README.md", "examples/", "examples/.gitattributes", "examples/project/", "examples/project/go.mod"}
+		if diff := cmp.Diff(want, zipFiles(t, archive.Path())); diff != "" {
+			t.Errorf("wrong files in archive (-want +have):\n%s", diff)
+		}
+
+		if err := archive.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(archive.Path()); !os.IsNotExist(err) {
+			t.Errorf("archive not deleted: %v", err)
+		}
+	})
+
+	t.Run("path in repository", func(t *testing.T) {
+		archive := registry.Checkout(repo, "examples/project")
+		if err := archive.Ensure(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer archive.Close()
+
+		want := []string{"examples/", "examples/project/", "examples/project/go.mod"}
+		if diff := cmp.Diff(want, zipFiles(t, archive.Path())); diff != "" {
+			t.Errorf("wrong files in archive (-want +have):\n%s", diff)
+		}
+
+		additional := map[string]string{}
+		for name, p := range archive.AdditionalFilePaths() {
+			content, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			additional[name] = string(content)
+		}
+		wantAdditional := map[string]string{
+			".gitignore":              files[".gitignore"],
+			"examples/.gitattributes": files["examples/.gitattributes"],
+		}
+		if diff := cmp.Diff(wantAdditional, additional); diff != "" {
+			t.Errorf("wrong additional files (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("HEAD mismatch", func(t *testing.T) {
+		archive := registry.Checkout(RepoRevision{RepoName: repoName, Commit: "d34db33f"}, "")
+		err := archive.Ensure(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "HEAD of local clone") {
+			t.Fatalf("wrong error: %v", err)
+		}
+	})
+}
+
+func TestLocalClones_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "github.com", "sourcegraph", "src-cli"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	clones := &LocalClones{
+		Dir:   dir,
+		Paths: map[string]string{"github.com/sourcegraph/sourcegraph": "/src/sourcegraph"},
+	}
+	tests := map[string]struct {
+		wantDir string
+		wantOk  bool
+	}{
+		"github.com/sourcegraph/sourcegraph": {"/src/sourcegraph", true},
+		"github.com/sourcegraph/src-cli":     {filepath.Join(dir, "github.com", "sourcegraph", "src-cli"), true},
+		"github.com/sourcegraph/about":       {"", false},
+	}
+	for name, tt := range tests {
+		haveDir, haveOk := clones.Lookup(name)
+		if haveDir != tt.wantDir || haveOk != tt.wantOk {
+			t.Errorf("Lookup(%q) = %q, %t, want %q, %t", name, haveDir, haveOk, tt.wantDir, tt.wantOk)
+		}
+	}
+}
+
+func TestReadLocalClonesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clones.yaml")
+	content := "github.com/sourcegraph/sourcegraph: /src/sourcegraph\ngithub.com/sourcegraph/src-cli: src-cli\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := ReadLocalClonesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"github.com/sourcegraph/sourcegraph": "/src/sourcegraph",
+		"github.com/sourcegraph/src-cli":     filepath.Join(dir, "src-cli"),
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong paths (-want +have):\n%s", diff)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func zipFiles(t *testing.T, path string) []string {
+	t.Helper()
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
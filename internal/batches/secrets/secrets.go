@@ -0,0 +1,149 @@
+// Package secrets resolves the secrets of batch specs and masks their values in
+// the output of steps.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+// Mask is what the values of secrets are replaced with.
+const Mask = "***"
+
+// Values are the values of secrets, by their name.
+type Values map[string]string
+
+// Resolve reads the values of the given secrets from their sources. Trailing
+// newlines are removed from the values.
+func Resolve(ctx context.Context, sources map[string]specext.SecretSource) (Values, error) {
+	values := make(Values, len(sources))
+	for name, source := range sources {
+		value, err := resolve(ctx, source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolving secret %q", name)
+		}
+		values[name] = strings.TrimRight(value, "\r\n")
+	}
+	return values, nil
+}
+
+func resolve(ctx context.Context, source specext.SecretSource) (string, error) {
+	switch {
+	case source.Env != "":
+		value, ok := os.LookupEnv(source.Env)
+		if !ok {
+			return "", errors.Newf("environment variable %q is not set", source.Env)
+		}
+		return value, nil
+
+	case source.File != "":
+		path := source.File
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			path = filepath.Join(home, path[2:])
+		}
+		value, err := os.ReadFile(path)
+		return string(value), err
+
+	case source.Command != "":
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", source.Command)
+		cmd.Stderr = &stderr
+		value, err := cmd.Output()
+		if err != nil {
+			return "", errors.Wrapf(err, "running command: %s", strings.TrimSpace(stderr.String()))
+		}
+		return string(value), nil
+
+	default:
+		return "", errors.New("no source")
+	}
+}
+
+// Replacer returns a replacer that replaces the values with Mask, longest
+// values first, so that values that contain others are masked entirely.
+func (v Values) Replacer() *strings.Replacer {
+	var values []string
+	for _, value := range v {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	oldnew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldnew = append(oldnew, value, Mask)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// MaskingWriter masks the values of secrets in what is written to it before
+// passing it on. It passes on complete lines only, so that values that are
+// written in parts are masked too. Flush passes on the rest.
+type MaskingWriter struct {
+	w        io.Writer
+	replacer *strings.Replacer
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewMaskingWriter returns a MaskingWriter that writes to w. If there are no
+// values, the returned writer is w itself.
+func NewMaskingWriter(w io.Writer, values Values) io.Writer {
+	if len(values) == 0 {
+		return w
+	}
+	return &MaskingWriter{w: w, replacer: values.Replacer()}
+}
+
+func (mw *MaskingWriter) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.buf = append(mw.buf, p...)
+	if i := bytes.LastIndexByte(mw.buf, '\n'); i >= 0 {
+		lines := mw.buf[:i+1]
+		if _, err := io.WriteString(mw.w, mw.replacer.Replace(string(lines))); err != nil {
+			return 0, err
+		}
+		mw.buf = append(mw.buf[:0], mw.buf[i+1:]...)
+	}
+	return len(p), nil
+}
+
+// Flush passes on what's left of an incomplete last line.
+func (mw *MaskingWriter) Flush() error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	if len(mw.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(mw.w, mw.replacer.Replace(string(mw.buf)))
+	mw.buf = mw.buf[:0]
+	return err
+}
+
+// Flush flushes w if it's a MaskingWriter.
+func Flush(w io.Writer) error {
+	if mw, ok := w.(*MaskingWriter); ok {
+		return mw.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("SRC_TEST_SECRET", "from-env")
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := Resolve(context.Background(), map[string]specext.SecretSource{
+		"env":     {Env: "SRC_TEST_SECRET"},
+		"file":    {File: file},
+		"command": {Command: "echo from-command"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Values{"env": "from-env", "file": "from-file", "command": "from-command"}
+	if diff := cmp.Diff(want, values); diff != "" {
+		t.Errorf("wrong values (-want +have):\n%s", diff)
+	}
+
+	t.Run("missing environment variable", func(t *testing.T) {
+		_, err := Resolve(context.Background(), map[string]specext.SecretSource{"env": {Env: "SRC_TEST_SECRET_MISSING"}})
+		if err == nil {
+			t.Fatal("no error")
+		}
+	})
+
+	t.Run("failing command", func(t *testing.T) {
+		_, err := Resolve(context.Background(), map[string]specext.SecretSource{"command": {Command: "exit 1"}})
+		if err == nil {
+			t.Fatal("no error")
+		}
+	})
+}
+
+func TestMaskingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewMaskingWriter(&buf, Values{"token": "s3cr3t", "longer": "s3cr3t-and-more", "empty": ""})
+
+	// The value is written in parts.
+	for _, s := range []string{"token: s3", "cr3t\nother: s3cr3t-and-more\n", "last: s3cr3t"} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have, want := buf.String(), "token: ***\nother: ***\n"; have != want {
+		t.Errorf("wrong output before flush: %q, want %q", have, want)
+	}
+
+	if err := Flush(w); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := buf.String(), "token: ***\nother: ***\nlast: ***"; have != want {
+		t.Errorf("wrong output: %q, want %q", have, want)
+	}
+}
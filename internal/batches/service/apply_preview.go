@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// ChangesetPreview is what applying a batch spec does to one changeset, with
+// the details that are known locally filled in.
+type ChangesetPreview struct {
+	// Action is one of graphql.PreviewActions.
+	Action string `json:"action"`
+	// Operations are the operations that the instance performs on the
+	// changeset, like PUSH or PUBLISH.
+	Operations []string `json:"operations"`
+	// Repository is empty for changesets in repositories that the user can't
+	// see.
+	Repository string `json:"repository,omitempty"`
+	Title      string `json:"title,omitempty"`
+	ExternalID string `json:"externalID,omitempty"`
+	// Diff is the diff of the changeset spec, for changesets that are created
+	// or that have new commits pushed.
+	Diff string `json:"diff,omitempty"`
+}
+
+// PreviewApply returns what applying the batch spec with the given ID does to
+// each changeset. specs are the changeset specs that were uploaded for it by
+// their ID, and repos are the repositories they belong to.
+func (svc *Service) PreviewApply(ctx context.Context, batchSpecID graphql.BatchSpecID, specs map[graphql.ChangesetSpecID]*batcheslib.ChangesetSpec, repos []*graphql.Repository) ([]*ChangesetPreview, error) {
+	previews, err := svc.newOperations().ApplyPreview(ctx, batchSpecID)
+	if err != nil {
+		return nil, err
+	}
+	return changesetPreviews(previews, specs, repos), nil
+}
+
+func changesetPreviews(previews []*graphql.ChangesetApplyPreview, specs map[graphql.ChangesetSpecID]*batcheslib.ChangesetSpec, repos []*graphql.Repository) []*ChangesetPreview {
+	repoNames := make(map[string]string, len(repos))
+	for _, r := range repos {
+		repoNames[r.ID] = r.Name
+	}
+
+	result := make([]*ChangesetPreview, 0, len(previews))
+	for _, p := range previews {
+		preview := &ChangesetPreview{
+			Action:     p.Action(),
+			Operations: p.Operations,
+		}
+
+		if c := p.Targets.Changeset; c != nil {
+			preview.Repository = c.RepositoryName()
+			preview.Title = c.Title
+			preview.ExternalID = c.ExternalID
+		}
+		if spec, ok := specs[p.ChangesetSpecID()]; ok {
+			if name, ok := repoNames[spec.BaseRepository]; ok {
+				preview.Repository = name
+			}
+			if spec.Title != "" {
+				preview.Title = spec.Title
+			}
+			if spec.ExternalID != "" {
+				preview.ExternalID = spec.ExternalID
+			}
+			if (preview.Action == graphql.PreviewActionCreate || preview.Action == graphql.PreviewActionPush) && len(spec.Commits) > 0 {
+				preview.Diff = spec.Commits[0].Diff
+			}
+		}
+
+		result = append(result, preview)
+	}
+	return result
+}
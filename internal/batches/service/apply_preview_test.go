@@ -0,0 +1,119 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestChangesetPreviews(t *testing.T) {
+	var previews []*graphql.ChangesetApplyPreview
+	if err := json.Unmarshal([]byte(testApplyPreviewNodes), &previews); err != nil {
+		t.Fatal(err)
+	}
+	specs := map[graphql.ChangesetSpecID]*batcheslib.ChangesetSpec{
+		"spec-1": {
+			BaseRepository: "repo-1",
+			Title:          "Add README",
+			Commits:        []batcheslib.GitCommitDescription{{Diff: "+hello\n"}},
+		},
+		"spec-2": {
+			BaseRepository: "repo-2",
+			Title:          "Update README",
+			Commits:        []batcheslib.GitCommitDescription{{Diff: "-hello\n+world\n"}},
+		},
+		"spec-3": {
+			BaseRepository: "repo-3",
+			Title:          "Same as before",
+			Commits:        []batcheslib.GitCommitDescription{{Diff: "+same\n"}},
+		},
+	}
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/sourcegraph/one"},
+		{ID: "repo-2", Name: "github.com/sourcegraph/two"},
+		{ID: "repo-3", Name: "github.com/sourcegraph/three"},
+	}
+
+	want := []*ChangesetPreview{
+		{
+			Action:     graphql.PreviewActionCreate,
+			Operations: []string{"PUSH", "PUBLISH"},
+			Repository: "github.com/sourcegraph/one",
+			Title:      "Add README",
+			Diff:       "+hello\n",
+		},
+		{
+			Action:     graphql.PreviewActionPush,
+			Operations: []string{"PUSH", "UPDATE"},
+			Repository: "github.com/sourcegraph/two",
+			Title:      "Update README",
+			ExternalID: "12",
+			Diff:       "-hello\n+world\n",
+		},
+		{
+			Action:     graphql.PreviewActionUnchanged,
+			Operations: []string{},
+			Repository: "github.com/sourcegraph/three",
+			Title:      "Same as before",
+			ExternalID: "13",
+		},
+		{
+			Action:     graphql.PreviewActionClose,
+			Operations: []string{"CLOSE", "DETACH"},
+			Repository: "github.com/sourcegraph/four",
+			Title:      "Old changeset",
+			ExternalID: "14",
+		},
+		{
+			Action:     graphql.PreviewActionDetach,
+			Operations: []string{"DETACH"},
+		},
+	}
+	if diff := cmp.Diff(want, changesetPreviews(previews, specs, repos)); diff != "" {
+		t.Errorf("wrong previews (-want +have):\n%s", diff)
+	}
+}
+
+const testApplyPreviewNodes = `[
+  {
+    "__typename": "VisibleChangesetApplyPreview",
+    "operations": ["PUSH", "PUBLISH"],
+    "targets": { "__typename": "VisibleApplyPreviewTargetsAttach", "changesetSpec": { "id": "spec-1" } }
+  },
+  {
+    "__typename": "VisibleChangesetApplyPreview",
+    "operations": ["PUSH", "UPDATE"],
+    "targets": {
+      "__typename": "VisibleApplyPreviewTargetsUpdate",
+      "changesetSpec": { "id": "spec-2" },
+      "changeset": { "__typename": "ExternalChangeset", "id": "c-2", "state": "OPEN", "title": "Old title", "externalID": "12", "repository": { "name": "github.com/sourcegraph/two" } }
+    }
+  },
+  {
+    "__typename": "VisibleChangesetApplyPreview",
+    "operations": [],
+    "targets": {
+      "__typename": "VisibleApplyPreviewTargetsUpdate",
+      "changesetSpec": { "id": "spec-3" },
+      "changeset": { "__typename": "ExternalChangeset", "id": "c-3", "state": "OPEN", "title": "Same as before", "externalID": "13", "repository": { "name": "github.com/sourcegraph/three" } }
+    }
+  },
+  {
+    "__typename": "VisibleChangesetApplyPreview",
+    "operations": ["CLOSE", "DETACH"],
+    "targets": {
+      "__typename": "VisibleApplyPreviewTargetsUpdate",
+      "changesetSpec": { "id": "spec-4" },
+      "changeset": { "__typename": "ExternalChangeset", "id": "c-4", "state": "OPEN", "title": "Old changeset", "externalID": "14", "repository": { "name": "github.com/sourcegraph/four" } }
+    }
+  },
+  {
+    "__typename": "HiddenChangesetApplyPreview",
+    "operations": ["DETACH"],
+    "targets": { "__typename": "HiddenApplyPreviewTargetsDetach", "changeset": { "id": "c-5", "state": "CLOSED" } }
+  }
+]`
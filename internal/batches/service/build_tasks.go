@@ -3,15 +3,24 @@ package service
 import (
 	"context"
 
+	"github.com/cockroachdb/errors"
+
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
 	"github.com/sourcegraph/sourcegraph/lib/batches/template"
 
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+	"github.com/sourcegraph/src-cli/internal/batches/util"
 )
 
 // buildTasks returns *executor.Tasks for all the workspaces determined for the given spec.
-func buildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []RepoWorkspace) []*executor.Task {
+func buildTasks(ctx context.Context, spec *batcheslib.BatchSpec, ext *specext.Extensions, workspaces []RepoWorkspace) ([]*executor.Task, error) {
 	tasks := make([]*executor.Task, 0, len(workspaces))
+	paths := ext.WorkspacePaths()
+	artifacts := ext.StepArtifacts()
+	secrets := ext.StepSecrets()
+	outputSchemas := ext.StepOutputSchemas()
+	matrices := ext.StepMatrices()
 
 	for _, ws := range workspaces {
 		task := &executor.Task{
@@ -19,6 +28,7 @@ func buildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []Re
 			Path:               ws.Path,
 			Steps:              ws.Steps,
 			OnlyFetchWorkspace: ws.OnlyFetchWorkspace,
+			Paths:              paths,
 
 			TransformChanges: spec.TransformChanges,
 			Template:         spec.ChangesetTemplate,
@@ -27,8 +37,95 @@ func buildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []Re
 				Description: spec.Description,
 			},
 		}
+
+		if artifacts != nil || secrets != nil || outputSchemas != nil || matrices != nil {
+			// The extensions of the steps are given by their indexes in the
+			// batch spec, but the workspace might not have all of them.
+			indexes, err := workspaceStepIndexes(spec, ws)
+			if err != nil {
+				return nil, err
+			}
+			if matrices != nil {
+				// Every variant of a step is a step of its own, so that it
+				// has its own cache key.
+				task.Steps, indexes, task.StepVariants = expandMatrices(ws.Steps, indexes, matrices)
+			}
+			task.Artifacts = workspaceArtifacts(indexes, artifacts)
+			task.Secrets = workspaceSecrets(indexes, secrets)
+			task.OutputSchemas = workspaceOutputSchemas(indexes, outputSchemas)
+		}
+
 		tasks = append(tasks, task)
 	}
 
-	return tasks
+	return tasks, nil
+}
+
+// workspaceStepIndexes returns the indexes of the steps of the workspace in
+// the batch spec.
+//
+// This already succeeded when the workspaces were determined, but if it
+// doesn't now, the extensions of the steps can't be given to them, and
+// executing the steps without them would silently do something else than the
+// batch spec says.
+func workspaceStepIndexes(spec *batcheslib.BatchSpec, ws RepoWorkspace) ([]int, error) {
+	indexes, err := stepIndexesForRepo(spec, util.NewTemplatingRepo(ws.Repo.Name, ws.Repo.FileMatches))
+	if err != nil {
+		return nil, errors.Wrapf(err, "determining the steps of the workspace in %s", ws.Repo.Name)
+	}
+	if len(indexes) != len(ws.Steps) {
+		return nil, errors.Newf("the workspace in %s has %d steps, but %d steps of the batch spec apply to it", ws.Repo.Name, len(ws.Steps), len(indexes))
+	}
+	return indexes, nil
+}
+
+// workspaceArtifacts returns the artifacts of the steps with the given
+// indexes, or nil if they have none.
+func workspaceArtifacts(indexes []int, artifacts [][]string) [][]string {
+	var found bool
+	wsArtifacts := make([][]string, len(indexes))
+	for i, index := range indexes {
+		if index < len(artifacts) && len(artifacts[index]) > 0 {
+			wsArtifacts[i] = artifacts[index]
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return wsArtifacts
+}
+
+// workspaceSecrets returns the secrets of the steps with the given indexes,
+// or nil if they have none.
+func workspaceSecrets(indexes []int, secrets [][]specext.StepSecret) [][]specext.StepSecret {
+	var found bool
+	wsSecrets := make([][]specext.StepSecret, len(indexes))
+	for i, index := range indexes {
+		if index < len(secrets) && len(secrets[index]) > 0 {
+			wsSecrets[i] = secrets[index]
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return wsSecrets
+}
+
+// workspaceOutputSchemas returns the output schemas of the steps with the
+// given indexes, or nil if they have none.
+func workspaceOutputSchemas(indexes []int, schemas []map[string]interface{}) []map[string]interface{} {
+	var found bool
+	wsSchemas := make([]map[string]interface{}, len(indexes))
+	for i, index := range indexes {
+		if index < len(schemas) && len(schemas[index]) > 0 {
+			wsSchemas[i] = schemas[index]
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return wsSchemas
 }
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+func TestBuildTasks(t *testing.T) {
+	spec := &batcheslib.BatchSpec{
+		Name: "hello-world",
+		Steps: []batcheslib.Step{
+			{Run: "echo first", Container: "alpine:3"},
+			{Run: "echo second > report.txt", Container: "alpine:3"},
+		},
+	}
+	ext := &specext.Extensions{Steps: []specext.StepExtensions{{}, {Artifacts: []string{"report.txt"}}}}
+	repo := &graphql.Repository{Name: "github.com/sourcegraph/src-cli"}
+
+	t.Run("extensions", func(t *testing.T) {
+		tasks, err := buildTasks(context.Background(), spec, ext, []RepoWorkspace{{Repo: repo, Steps: spec.Steps}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 1 {
+			t.Fatalf("wrong number of tasks. want=1, have=%d", len(tasks))
+		}
+		if diff := cmp.Diff([][]string{nil, {"report.txt"}}, tasks[0].Artifacts); diff != "" {
+			t.Errorf("wrong artifacts (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("steps don't match", func(t *testing.T) {
+		// The extensions can't be given to the steps if we don't know which
+		// steps of the batch spec they are.
+		_, err := buildTasks(context.Background(), spec, ext, []RepoWorkspace{{Repo: repo, Steps: spec.Steps[1:]}})
+		if err == nil {
+			t.Fatal("unexpectedly no error")
+		}
+		if want := "has 1 steps, but 2 steps of the batch spec apply to it"; !strings.Contains(err.Error(), want) {
+			t.Errorf("wrong error. want to include %q, have=%q", want, err)
+		}
+	})
+}
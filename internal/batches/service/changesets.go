@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// ListChangesets lists the changesets of a batch change that match the
+// filters. If repo isn't empty, only the changesets in repositories whose
+// names match the glob pattern are listed, which excludes hidden changesets.
+func (svc *Service) ListChangesets(ctx context.Context, opts graphql.ListChangesetsOpts, repo string) ([]*graphql.Changeset, error) {
+	var g glob.Glob
+	if repo != "" {
+		var err error
+		if g, err = glob.Compile(repo); err != nil {
+			return nil, errors.Wrapf(err, "invalid repository pattern %q", repo)
+		}
+	}
+
+	changesets, err := svc.newOperations().ListChangesets(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return changesets, nil
+	}
+
+	matching := changesets[:0]
+	for _, c := range changesets {
+		if !c.Hidden() && g.Match(c.RepositoryName()) {
+			matching = append(matching, c)
+		}
+	}
+	return matching, nil
+}
+
+func (svc *Service) MergeChangesets(ctx context.Context, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID, squash bool) (*graphql.BulkOperation, error) {
+	return svc.newOperations().MergeChangesets(ctx, batchChange, changesets, squash)
+}
+
+func (svc *Service) CloseChangesets(ctx context.Context, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+	return svc.newOperations().CloseChangesets(ctx, batchChange, changesets)
+}
+
+func (svc *Service) CreateChangesetComments(ctx context.Context, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID, body string) (*graphql.BulkOperation, error) {
+	return svc.newOperations().CreateChangesetComments(ctx, batchChange, changesets, body)
+}
+
+func (svc *Service) ReenqueueChangesets(ctx context.Context, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+	return svc.newOperations().ReenqueueChangesets(ctx, batchChange, changesets)
+}
+
+func (svc *Service) DetachChangesets(ctx context.Context, batchChange graphql.BatchChangeID, changesets []graphql.ChangesetID) (*graphql.BulkOperation, error) {
+	return svc.newOperations().DetachChangesets(ctx, batchChange, changesets)
+}
+
+// WaitForBulkOperation polls the bulk operation every interval until it's no
+// longer processing, and returns it in its final state. progress is called
+// with the bulk operation after every poll.
+func (svc *Service) WaitForBulkOperation(ctx context.Context, op *graphql.BulkOperation, interval time.Duration, progress func(*graphql.BulkOperation)) (*graphql.BulkOperation, error) {
+	ops := svc.newOperations()
+	for op.State == graphql.BulkOperationProcessing {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		next, err := ops.GetBulkOperation(ctx, op.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "checking the state of the bulk operation")
+		}
+		if next == nil {
+			return nil, errors.Newf("bulk operation %q not found", op.ID)
+		}
+		op = next
+		if progress != nil {
+			progress(op)
+		}
+	}
+	return op, nil
+}
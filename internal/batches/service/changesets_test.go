@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestService_ListChangesets(t *testing.T) {
+	for name, tt := range map[string]struct {
+		repo string
+		want []graphql.ChangesetID
+	}{
+		"all":       {want: []graphql.ChangesetID{"1", "2", "3", "4"}},
+		"glob":      {repo: "github.com/sourcegraph/*", want: []graphql.ChangesetID{"1", "2"}},
+		"exact":     {repo: "github.com/other/repo", want: []graphql.ChangesetID{"3"}},
+		"not found": {repo: "gitlab.com/*", want: []graphql.ChangesetID{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, done := mockGraphQLClient(testListChangesetsPage1, testListChangesetsPage2)
+			defer done()
+
+			svc := &Service{client: client, features: batches.FeatureFlags{BatchChanges: true}}
+			changesets, err := svc.ListChangesets(context.Background(), graphql.ListChangesetsOpts{BatchChange: "QmF0Y2hDaGFuZ2U6MQ=="}, tt.repo)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			have := []graphql.ChangesetID{}
+			for _, c := range changesets {
+				have = append(have, c.ID)
+			}
+			if diff := cmp.Diff(tt.want, have); diff != "" {
+				t.Errorf("wrong changesets (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+const testListChangesetsPage1 = `{
+  "data": {
+    "node": {
+      "changesets": {
+        "nodes": [
+          { "__typename": "ExternalChangeset", "id": "1", "state": "OPEN", "repository": { "name": "github.com/sourcegraph/src-cli" } },
+          { "__typename": "ExternalChangeset", "id": "2", "state": "FAILED", "repository": { "name": "github.com/sourcegraph/sourcegraph" } }
+        ],
+        "pageInfo": { "hasNextPage": true, "endCursor": "2" }
+      }
+    }
+  }
+}
+`
+
+const testListChangesetsPage2 = `{
+  "data": {
+    "node": {
+      "changesets": {
+        "nodes": [
+          { "__typename": "ExternalChangeset", "id": "3", "state": "MERGED", "repository": { "name": "github.com/other/repo" } },
+          { "__typename": "HiddenExternalChangeset", "id": "4", "state": "OPEN" }
+        ],
+        "pageInfo": { "hasNextPage": false, "endCursor": null }
+      }
+    }
+  }
+}
+`
+
+func TestService_WaitForBulkOperation(t *testing.T) {
+	client, done := mockGraphQLClient(testBulkOperationProcessing, testBulkOperationFailed)
+	defer done()
+
+	svc := &Service{client: client, features: batches.FeatureFlags{BatchChanges: true}}
+	op := &graphql.BulkOperation{ID: "QnVsa09wZXJhdGlvbjox", State: graphql.BulkOperationProcessing}
+
+	var progress []float64
+	op, err := svc.WaitForBulkOperation(context.Background(), op, time.Millisecond, func(op *graphql.BulkOperation) {
+		progress = append(progress, op.Progress)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if op.State != graphql.BulkOperationFailed {
+		t.Errorf("wrong state: %s", op.State)
+	}
+	if diff := cmp.Diff([]float64{0.5, 1}, progress); diff != "" {
+		t.Errorf("wrong progress (-want +have):\n%s", diff)
+	}
+	want := map[graphql.ChangesetID]string{"2": "merge conflict"}
+	if diff := cmp.Diff(want, op.ChangesetErrors()); diff != "" {
+		t.Errorf("wrong errors (-want +have):\n%s", diff)
+	}
+}
+
+const testBulkOperationProcessing = `{
+  "data": {
+    "node": { "id": "QnVsa09wZXJhdGlvbjox", "type": "MERGE", "state": "PROCESSING", "progress": 0.5, "changesetCount": 2, "errors": [] }
+  }
+}
+`
+
+const testBulkOperationFailed = `{
+  "data": {
+    "node": {
+      "id": "QnVsa09wZXJhdGlvbjox",
+      "type": "MERGE",
+      "state": "FAILED",
+      "progress": 1,
+      "changesetCount": 2,
+      "errors": [{ "changeset": { "id": "2" }, "error": "merge conflict" }]
+    }
+  }
+}
+`
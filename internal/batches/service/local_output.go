@@ -0,0 +1,143 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// LocalManifestFile is the name of the manifest that WriteChangesetSpecs
+// writes next to the patches.
+const LocalManifestFile = "manifest.json"
+
+// LocalManifest describes the changesets whose patches were written to a
+// directory by WriteChangesetSpecs.
+type LocalManifest struct {
+	Changesets []LocalChangeset `json:"changesets"`
+}
+
+// LocalChangeset is a changeset spec with the templates of the batch spec
+// rendered, whose diff is stored in Patch, relative to the manifest.
+type LocalChangeset struct {
+	Repository   string `json:"repository"`
+	RepositoryID string `json:"repositoryID"`
+	BaseRef      string `json:"baseRef"`
+	BaseRev      string `json:"baseRev"`
+
+	Branch        string      `json:"branch"`
+	Title         string      `json:"title"`
+	Body          string      `json:"body"`
+	CommitMessage string      `json:"commitMessage"`
+	AuthorName    string      `json:"authorName,omitempty"`
+	AuthorEmail   string      `json:"authorEmail,omitempty"`
+	Published     interface{} `json:"published"`
+
+	Patch string `json:"patch"`
+}
+
+var unsafePatchNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WriteChangesetSpecs writes the diff of every branch changeset spec to a
+// .patch file in dir and a LocalManifestFile that describes them. Specs that
+// import existing changesets have no diff and are skipped.
+func WriteChangesetSpecs(dir string, repos []*graphql.Repository, specs []*batcheslib.ChangesetSpec) (*LocalManifest, error) {
+	repoByID := make(map[string]*graphql.Repository, len(repos))
+	for _, repo := range repos {
+		repoByID[repo.ID] = repo
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "creating output directory")
+	}
+
+	manifest := &LocalManifest{Changesets: []LocalChangeset{}}
+	for _, spec := range specs {
+		if spec.Type() == batcheslib.ChangesetSpecDescriptionTypeExisting {
+			continue
+		}
+
+		repo, ok := repoByID[spec.BaseRepository]
+		if !ok {
+			return nil, errors.Newf("changeset spec for unknown repository %q", spec.BaseRepository)
+		}
+
+		cs := LocalChangeset{
+			Repository:   repo.Name,
+			RepositoryID: repo.ID,
+			BaseRef:      spec.BaseRef,
+			BaseRev:      spec.BaseRev,
+			Branch:       strings.TrimPrefix(spec.HeadRef, "refs/heads/"),
+			Title:        spec.Title,
+			Body:         spec.Body,
+			Published:    spec.Published.Val,
+		}
+
+		var diff strings.Builder
+		for i, commit := range spec.Commits {
+			if i == 0 {
+				cs.CommitMessage = commit.Message
+				cs.AuthorName = commit.AuthorName
+				cs.AuthorEmail = commit.AuthorEmail
+			}
+			diff.WriteString(commit.Diff)
+		}
+
+		cs.Patch = patchFileName(repo.Name, cs.Branch)
+		if err := os.WriteFile(filepath.Join(dir, cs.Patch), []byte(diff.String()), 0644); err != nil {
+			return nil, errors.Wrapf(err, "writing patch for %s", repo.Name)
+		}
+
+		manifest.Changesets = append(manifest.Changesets, cs)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding manifest")
+	}
+	if err := os.WriteFile(filepath.Join(dir, LocalManifestFile), data, 0644); err != nil {
+		return nil, errors.Wrap(err, "writing manifest")
+	}
+
+	return manifest, nil
+}
+
+// patchFileName returns the name of the patch file of the changeset on the
+// given branch in the repository.
+//
+// Branch names are unique per repository, which ValidateChangesetSpecs
+// ensures, but replacing the characters that aren't safe in file names can
+// make different repositories and branches end up with the same name, like
+// "a/b-c" with "d" and "a/b" with "c-d". A hash of the repository and the
+// branch makes the name unique again.
+func patchFileName(repo, branch string) string {
+	sum := sha256.Sum256([]byte(repo + "\x00" + branch))
+	name := unsafePatchNameChars.ReplaceAllString(repo+"-"+branch, "-")
+	return name + "-" + hex.EncodeToString(sum[:4]) + ".patch"
+}
+
+// ReadLocalManifest reads the LocalManifest at the given path, as written by
+// WriteChangesetSpecs, and returns it together with the directory the patches
+// are in.
+func ReadLocalManifest(path string) (*LocalManifest, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading manifest")
+	}
+
+	var manifest LocalManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, "", errors.Wrapf(err, "parsing manifest %s", path)
+	}
+
+	return &manifest, filepath.Dir(path), nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestWriteChangesetSpecs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "github.com/sourcegraph/src-cli"},
+		{ID: "repo-2", Name: "github.com/sourcegraph/sourcegraph"},
+	}
+	specs := []*batcheslib.ChangesetSpec{
+		{
+			BaseRepository: "repo-1",
+			HeadRepository: "repo-1",
+			BaseRef:        "refs/heads/main",
+			BaseRev:        "d34db33f",
+			HeadRef:        "refs/heads/feat/hello-world",
+			Title:          "Hello World",
+			Body:           "Adds a hello world",
+			Commits: []batcheslib.GitCommitDescription{{
+				Message:     "Add hello world",
+				Diff:        "diff --git a/README.md b/README.md\n",
+				AuthorName:  "Mary McButtons",
+				AuthorEmail: "mary@example.com",
+			}},
+			Published: batcheslib.PublishedValue{Val: "draft"},
+		},
+		{
+			BaseRepository: "repo-2",
+			ExternalID:     "123",
+		},
+	}
+
+	have, err := WriteChangesetSpecs(dir, repos, specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &LocalManifest{Changesets: []LocalChangeset{{
+		Repository:    "github.com/sourcegraph/src-cli",
+		RepositoryID:  "repo-1",
+		BaseRef:       "refs/heads/main",
+		BaseRev:       "d34db33f",
+		Branch:        "feat/hello-world",
+		Title:         "Hello World",
+		Body:          "Adds a hello world",
+		CommitMessage: "Add hello world",
+		AuthorName:    "Mary McButtons",
+		AuthorEmail:   "mary@example.com",
+		Published:     "draft",
+		Patch:         "github.com-sourcegraph-src-cli-feat-hello-world-d25cbb45.patch",
+	}}}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong manifest (-want +have):\n%s", diff)
+	}
+
+	patch, err := os.ReadFile(filepath.Join(dir, want.Changesets[0].Patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(patch), specs[0].Commits[0].Diff; have != want {
+		t.Errorf("wrong patch. want=%q, have=%q", want, have)
+	}
+
+	written, patchDir, err := ReadLocalManifest(filepath.Join(dir, LocalManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, written); diff != "" {
+		t.Errorf("wrong manifest written (-want +have):\n%s", diff)
+	}
+	if patchDir != dir {
+		t.Errorf("wrong patch directory. want=%q, have=%q", dir, patchDir)
+	}
+}
+
+func TestWriteChangesetSpecs_UniquePatches(t *testing.T) {
+	dir := t.TempDir()
+
+	// Both end up as "a-b-c-d" once the unsafe characters are replaced.
+	repos := []*graphql.Repository{
+		{ID: "repo-1", Name: "a/b-c"},
+		{ID: "repo-2", Name: "a/b"},
+	}
+	specs := []*batcheslib.ChangesetSpec{
+		{BaseRepository: "repo-1", HeadRef: "refs/heads/d", Commits: []batcheslib.GitCommitDescription{{Diff: "diff 1\n"}}},
+		{BaseRepository: "repo-2", HeadRef: "refs/heads/c-d", Commits: []batcheslib.GitCommitDescription{{Diff: "diff 2\n"}}},
+	}
+
+	manifest, err := WriteChangesetSpecs(dir, repos, specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Changesets[0].Patch == manifest.Changesets[1].Patch {
+		t.Fatalf("changesets have the same patch %q", manifest.Changesets[0].Patch)
+	}
+	for i, cs := range manifest.Changesets {
+		patch, err := os.ReadFile(filepath.Join(dir, cs.Patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := string(patch), specs[i].Commits[0].Diff; have != want {
+			t.Errorf("wrong patch for %s. want=%q, have=%q", cs.Repository, want, have)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"encoding/json"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+// ExpandSteps returns the steps of a batch spec with the steps that have a
+// matrix replaced by one step per variant, for example to know which
+// container images they use.
+func (svc *Service) ExpandSteps(steps []batcheslib.Step, ext *specext.Extensions) []batcheslib.Step {
+	matrices := ext.StepMatrices()
+	if matrices == nil {
+		return steps
+	}
+
+	indexes := make([]int, len(steps))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	expanded, _, _ := expandMatrices(steps, indexes, matrices)
+	return expanded
+}
+
+// expandMatrices replaces the steps that have a matrix with one step per
+// variant. indexes are the indexes of the steps in the batch spec. It returns
+// the expanded steps, their indexes in the batch spec and their variants,
+// which are empty for steps without a matrix.
+func expandMatrices(steps []batcheslib.Step, indexes []int, matrices []specext.Matrix) ([]batcheslib.Step, []int, []string) {
+	var (
+		expanded        []batcheslib.Step
+		expandedIndexes []int
+		variants        []string
+	)
+	for i, step := range steps {
+		var m specext.Matrix
+		if indexes[i] < len(matrices) {
+			m = matrices[indexes[i]]
+		}
+		if len(m) == 0 {
+			expanded = append(expanded, step)
+			expandedIndexes = append(expandedIndexes, indexes[i])
+			variants = append(variants, "")
+			continue
+		}
+
+		for _, v := range m.Variants() {
+			expanded = append(expanded, expandStep(step, v))
+			expandedIndexes = append(expandedIndexes, indexes[i])
+			variants = append(variants, v.String())
+		}
+	}
+	return expanded, expandedIndexes, variants
+}
+
+// expandStep returns the step with the values of the variant in place of the
+// references to them in all of its fields.
+func expandStep(step batcheslib.Step, v specext.Variant) batcheslib.Step {
+	// Going through JSON is the simplest way to get at every string in the
+	// step, including the environment.
+	data, err := json.Marshal(step)
+	if err != nil {
+		// This can't happen for steps that were parsed from a batch spec.
+		return step
+	}
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return step
+	}
+	if data, err = json.Marshal(expandValue(raw, v)); err != nil {
+		return step
+	}
+
+	var expanded batcheslib.Step
+	if err := json.Unmarshal(data, &expanded); err != nil {
+		return step
+	}
+	return expanded
+}
+
+func expandValue(value interface{}, v specext.Variant) interface{} {
+	switch val := value.(type) {
+	case string:
+		return v.Expand(val)
+	case map[string]interface{}:
+		for k, e := range val {
+			val[k] = expandValue(e, v)
+		}
+	case []interface{}:
+		for i, e := range val {
+			val[i] = expandValue(e, v)
+		}
+	}
+	return value
+}
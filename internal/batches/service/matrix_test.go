@@ -0,0 +1,55 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
+)
+
+func TestExpandMatrices(t *testing.T) {
+	steps := []batcheslib.Step{
+		{Run: "echo first", Container: "alpine:3"},
+		{
+			Run:       "go get go@${{ matrix.go }} && echo ${{ repository.name }}",
+			Container: "golang:${{ matrix.go }}",
+			Files:     map[string]string{"/tmp/version": "${{ matrix.go }}"},
+			If:        `${{ ne matrix.go "1.16" }}`,
+		},
+	}
+	matrices := []specext.Matrix{nil, nil, {"go": {"1.17", "1.18"}}}
+
+	// The first step of the batch spec doesn't apply to the workspace.
+	expanded, indexes, variants := expandMatrices(steps, []int{1, 2}, matrices)
+
+	want := []batcheslib.Step{
+		{Run: "echo first", Container: "alpine:3"},
+		{
+			Run:       "go get go@1.17 && echo ${{ repository.name }}",
+			Container: "golang:1.17",
+			Files:     map[string]string{"/tmp/version": "1.17"},
+			If:        `${{ ne "1.17" "1.16" }}`,
+		},
+		{
+			Run:       "go get go@1.18 && echo ${{ repository.name }}",
+			Container: "golang:1.18",
+			Files:     map[string]string{"/tmp/version": "1.18"},
+			If:        `${{ ne "1.18" "1.16" }}`,
+		},
+	}
+	// Steps can't be compared directly because of their environment.
+	wantJSON, _ := json.MarshalIndent(want, "", "  ")
+	haveJSON, _ := json.MarshalIndent(expanded, "", "  ")
+	if diff := cmp.Diff(string(wantJSON), string(haveJSON)); diff != "" {
+		t.Errorf("wrong steps (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{1, 2, 2}, indexes); diff != "" {
+		t.Errorf("wrong indexes (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"", "go=1.17", "go=1.18"}, variants); diff != "" {
+		t.Errorf("wrong variants (-want +have):\n%s", diff)
+	}
+}
@@ -10,9 +10,11 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/cockroachdb/errors"
 	"github.com/hashicorp/go-multierror"
+	"github.com/neelance/parallel"
 
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
 	onlib "github.com/sourcegraph/sourcegraph/lib/batches/on"
@@ -23,6 +25,7 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/batches/specext"
 )
 
 type Service struct {
@@ -91,6 +94,19 @@ func (svc *Service) DetermineFeatureFlags(ctx context.Context) error {
 	return svc.features.SetFromVersion(version)
 }
 
+// AssumeLatestFeatureFlags enables all features, as if the Sourcegraph
+// instance was running the latest version. It's meant for commands that work
+// without a Sourcegraph instance.
+func (svc *Service) AssumeLatestFeatureFlags() {
+	// Development versions support everything, so this can't fail.
+	_ = svc.features.SetFromVersion("dev")
+}
+
+// Features returns the features of the Sourcegraph instance.
+func (svc *Service) Features() batches.FeatureFlags {
+	return svc.features
+}
+
 // TODO(campaigns-deprecation): this shim can be removed in Sourcegraph 4.0.
 func (svc *Service) newOperations() graphql.Operations {
 	return graphql.NewOperations(
@@ -120,6 +136,49 @@ func (svc *Service) CreateBatchSpec(ctx context.Context, namespace, spec string,
 	return result.ID, result.ApplyURL, nil
 }
 
+// ListBatchChanges lists the batch changes in the given namespace, which is
+// the name of a user or organization, or in all namespaces if it's empty.
+func (svc *Service) ListBatchChanges(ctx context.Context, namespace string, opts graphql.ListBatchChangesOpts) ([]*graphql.BatchChange, error) {
+	if namespace != "" {
+		id, err := svc.ResolveNamespace(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		opts.Namespace = id
+	}
+	return svc.newOperations().ListBatchChanges(ctx, opts)
+}
+
+// GetBatchChange returns the batch change with the given name in the given
+// namespace, which is the name of a user or organization. If the namespace is
+// empty, the namespace of the current user is used.
+func (svc *Service) GetBatchChange(ctx context.Context, namespace, name string) (*graphql.BatchChange, error) {
+	id, err := svc.ResolveNamespace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	batchChange, err := svc.newOperations().GetBatchChange(ctx, id, name)
+	if err != nil {
+		return nil, err
+	}
+	if batchChange == nil {
+		if namespace == "" {
+			return nil, errors.Newf("batch change %q not found", name)
+		}
+		return nil, errors.Newf("batch change %q not found in namespace %q", name, namespace)
+	}
+	return batchChange, nil
+}
+
+func (svc *Service) CloseBatchChange(ctx context.Context, id graphql.BatchChangeID, closeChangesets bool) (*graphql.BatchChange, error) {
+	return svc.newOperations().CloseBatchChange(ctx, id, closeChangesets)
+}
+
+func (svc *Service) DeleteBatchChange(ctx context.Context, id graphql.BatchChangeID) error {
+	return svc.newOperations().DeleteBatchChange(ctx, id)
+}
+
 const createChangesetSpecMutation = `
 mutation CreateChangesetSpec($spec: String!) {
     createChangesetSpec(changesetSpec: $spec) {
@@ -153,27 +212,56 @@ func (svc *Service) CreateChangesetSpec(ctx context.Context, spec *batcheslib.Ch
 	return graphql.ChangesetSpecID(result.CreateChangesetSpec.ID), nil
 }
 
+// ensureImagesParallelism is the number of images EnsureDockerImages pulls at
+// the same time.
+const ensureImagesParallelism = 4
+
 // EnsureDockerImages iterates over the steps within the batch spec to ensure the
 // images exist and to determine the exact content digest to be used when running
-// each step, including any required by the service itself.
+// each step, including any required by the service itself. The images are
+// pulled concurrently.
 //
-// Progress information is reported back to the given progress function: perc
-// will be a value between 0.0 and 1.0, inclusive.
+// Progress information is reported back to the given progress function: done
+// is the number of distinct images that are ready out of total.
 func (svc *Service) EnsureDockerImages(ctx context.Context, steps []batcheslib.Step, progress func(done, total int)) (map[string]docker.Image, error) {
-	total := len(steps)
+	var names []string
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		if !seen[step.Container] {
+			seen[step.Container] = true
+			names = append(names, step.Container)
+		}
+	}
+
+	total := len(names)
 	progress(0, total)
 
-	// TODO: this _really_ should be parallelised, since the image cache takes
-	// care to only pull the same image once.
-	images := make(map[string]docker.Image)
-	for i := range steps {
-		img, err := svc.EnsureImage(ctx, steps[i].Container)
-		if err != nil {
-			return nil, err
-		}
-		images[steps[i].Container] = img
+	var (
+		images = make(map[string]docker.Image, total)
+		done   int
+		mu     sync.Mutex
+	)
+	par := parallel.NewRun(ensureImagesParallelism)
+	for _, name := range names {
+		par.Acquire()
+		go func(name string) {
+			defer par.Release()
+
+			img, err := svc.EnsureImage(ctx, name)
+			if err != nil {
+				par.Error(err)
+				return
+			}
 
-		progress(i+1, total)
+			mu.Lock()
+			defer mu.Unlock()
+			images[name] = img
+			done++
+			progress(done, total)
+		}(name)
+	}
+	if err := par.Wait(); err != nil {
+		return nil, err
 	}
 
 	return images, nil
@@ -193,18 +281,36 @@ func (svc *Service) DetermineWorkspaces(ctx context.Context, repos []*graphql.Re
 	return findWorkspaces(ctx, spec, svc, repos)
 }
 
-func (svc *Service) BuildTasks(ctx context.Context, spec *batcheslib.BatchSpec, workspaces []RepoWorkspace) []*executor.Task {
-	return buildTasks(ctx, spec, workspaces)
+// BuildTasks returns the Tasks that execute the batch spec in the workspaces.
+// ext are the src-cli extensions of the batch spec, which can be nil.
+func (svc *Service) BuildTasks(ctx context.Context, spec *batcheslib.BatchSpec, ext *specext.Extensions, workspaces []RepoWorkspace) ([]*executor.Task, error) {
+	return buildTasks(ctx, spec, ext, workspaces)
 }
 
 func (svc *Service) NewCoordinator(opts executor.NewCoordinatorOpts) *executor.Coordinator {
-	opts.RepoArchiveRegistry = repozip.NewArchiveRegistry(svc.client, opts.CacheDir, opts.CleanArchives)
+	switch {
+	case opts.GitMirrors:
+		opts.RepoArchiveRegistry = repozip.NewGitArchiveRegistry(svc.client, opts.CacheDir)
+	case opts.LocalClones != nil:
+		opts.RepoArchiveRegistry = repozip.NewLocalArchiveRegistry(svc.client, opts.CacheDir, opts.CleanArchives, opts.LocalClones)
+	default:
+		opts.RepoArchiveRegistry = repozip.NewArchiveRegistry(svc.client, opts.CacheDir, opts.CleanArchives)
+	}
 	opts.Features = svc.features
 	opts.EnsureImage = svc.EnsureImage
 
 	return executor.NewCoordinator(opts)
 }
 
+// NewWorker returns an executor.Worker that executes the Tasks it receives
+// using the given cacheDir for repository archives.
+func (svc *Service) NewWorker(opts executor.NewWorkerOpts, cacheDir string, cleanArchives bool) *executor.Worker {
+	opts.RepoArchiveRegistry = repozip.NewArchiveRegistry(svc.client, cacheDir, cleanArchives)
+	opts.EnsureImage = svc.EnsureImage
+
+	return executor.NewWorker(opts)
+}
+
 func (svc *Service) CreateImportChangesetSpecs(ctx context.Context, batchSpec *batcheslib.BatchSpec) ([]*batcheslib.ChangesetSpec, error) {
 	return batcheslib.BuildImportChangesetSpecs(ctx, batchSpec.ImportChangesets, func(ctx context.Context, repoNames []string) (_ map[string]string, errs error) {
 		repoNameIDs := map[string]string{}
@@ -287,7 +393,23 @@ func (e *duplicateBranchesErr) Error() string {
 	return out.String()
 }
 
+// ParseBatchSpec parses and validates the batch spec in data. The src-cli
+// extensions in it are validated, but not returned: see
+// ParseBatchSpecWithExtensions.
 func (svc *Service) ParseBatchSpec(data []byte) (*batcheslib.BatchSpec, error) {
+	spec, _, _, err := svc.ParseBatchSpecWithExtensions(data)
+	return spec, err
+}
+
+// ParseBatchSpecWithExtensions parses and validates the batch spec in data and
+// its src-cli extensions. It also returns the batch spec without the
+// extensions, which is what Sourcegraph gets to see.
+func (svc *Service) ParseBatchSpecWithExtensions(data []byte) (*batcheslib.BatchSpec, *specext.Extensions, []byte, error) {
+	data, ext, err := specext.Split(data)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "parsing batch spec")
+	}
+
 	spec, err := batcheslib.ParseBatchSpec(data, batcheslib.ParseBatchSpecOptions{
 		AllowArrayEnvironments: svc.features.AllowArrayEnvironments,
 		AllowTransformChanges:  svc.features.AllowTransformChanges,
@@ -295,9 +417,9 @@ func (svc *Service) ParseBatchSpec(data []byte) (*batcheslib.BatchSpec, error) {
 		AllowFiles:             svc.allowFiles,
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing batch spec")
+		return nil, nil, nil, errors.Wrap(err, "parsing batch spec")
 	}
-	return spec, nil
+	return spec, ext, data, nil
 }
 
 const exampleSpecTmpl = `name: NAME-OF-YOUR-BATCH-CHANGE
@@ -569,3 +569,72 @@ func TestService_ValidateChangesetSpecs(t *testing.T) {
 		})
 	}
 }
+
+func TestService_GetBatchChange(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		client, done := mockGraphQLClient(testResolveNamespaceOrg, testGetBatchChange)
+		defer done()
+
+		svc := &Service{client: client, features: batches.FeatureFlags{BatchChanges: true}}
+		have, err := svc.GetBatchChange(context.Background(), "myorg", "hello-world")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		want := &graphql.BatchChange{
+			ID:        "QmF0Y2hDaGFuZ2U6MQ==",
+			Name:      "hello-world",
+			State:     "OPEN",
+			URL:       "/organizations/myorg/batch-changes/hello-world",
+			Namespace: graphql.Namespace{NamespaceName: "myorg", URL: "/organizations/myorg"},
+			ChangesetsStats: graphql.ChangesetsStats{
+				Total:  3,
+				Open:   2,
+				Merged: 1,
+			},
+		}
+		if diff := cmp.Diff(want, have, cmpopts.IgnoreFields(graphql.BatchChange{}, "CreatedAt", "UpdatedAt")); diff != "" {
+			t.Errorf("wrong batch change (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client, done := mockGraphQLClient(testResolveNamespaceOrg, `{"data": {"batchChange": null}}`)
+		defer done()
+
+		svc := &Service{client: client, features: batches.FeatureFlags{BatchChanges: true}}
+		_, err := svc.GetBatchChange(context.Background(), "myorg", "hello-world")
+		if err == nil {
+			t.Fatal("no error")
+		}
+		if want := `batch change "hello-world" not found in namespace "myorg"`; err.Error() != want {
+			t.Errorf("wrong error: %q, want %q", err, want)
+		}
+	})
+}
+
+const testResolveNamespaceOrg = `{
+  "data": {
+    "user": null,
+    "organization": { "id": "T3JnOjE=" }
+  }
+}
+`
+
+const testGetBatchChange = `{
+  "data": {
+    "batchChange": {
+      "id": "QmF0Y2hDaGFuZ2U6MQ==",
+      "name": "hello-world",
+      "description": "",
+      "state": "OPEN",
+      "url": "/organizations/myorg/batch-changes/hello-world",
+      "namespace": { "namespaceName": "myorg", "url": "/organizations/myorg" },
+      "createdAt": "2021-07-01T10:00:00Z",
+      "updatedAt": "2021-07-02T10:00:00Z",
+      "closedAt": null,
+      "changesetsStats": { "total": 3, "unpublished": 0, "draft": 0, "open": 2, "merged": 1, "closed": 0, "deleted": 0 }
+    }
+  }
+}
+`
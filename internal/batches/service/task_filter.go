@@ -0,0 +1,112 @@
+package service
+
+import (
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+// TaskFilter narrows the tasks of a batch spec down to the ones of a
+// repository or a workspace, so that only they are executed. It doesn't
+// change the tasks themselves, so their cache keys stay the same.
+type TaskFilter struct {
+	// Repository is the name of the repository. Any repository matches if
+	// it's empty.
+	Repository string
+	// Rev is the branch or (the prefix of) the commit the repository is at.
+	// Any revision matches if it's empty.
+	Rev string
+	// Path is the path of the workspace in the repository, with "." being the
+	// root of the repository. Any workspace matches if it's empty.
+	Path string
+}
+
+// NewTaskFilter returns the TaskFilter for a repository given as NAME[@REV]
+// and the path of a workspace.
+func NewTaskFilter(repo, workspacePath string) TaskFilter {
+	f := TaskFilter{Repository: repo}
+	if i := strings.LastIndex(repo, "@"); i != -1 {
+		f.Repository, f.Rev = repo[:i], repo[i+1:]
+	}
+	if workspacePath != "" {
+		f.Path = cleanWorkspacePath(workspacePath)
+	}
+	return f
+}
+
+// Empty returns whether the filter matches all tasks.
+func (f TaskFilter) Empty() bool {
+	return f.Repository == "" && f.Rev == "" && f.Path == ""
+}
+
+// Filter returns the tasks that match the filter. It fails if none do.
+func (f TaskFilter) Filter(tasks []*executor.Task) ([]*executor.Task, error) {
+	if f.Empty() {
+		return tasks, nil
+	}
+
+	var filtered []*executor.Task
+	for _, task := range tasks {
+		if f.matches(task) {
+			filtered = append(filtered, task)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, errors.Newf("no workspace of the batch spec matches %s", f)
+	}
+	return filtered, nil
+}
+
+func (f TaskFilter) matches(task *executor.Task) bool {
+	if f.Repository != "" && task.Repository.Name != f.Repository {
+		return false
+	}
+	if f.Rev != "" && !matchesRev(task, f.Rev) {
+		return false
+	}
+	if f.Path != "" && cleanWorkspacePath(task.Path) != f.Path {
+		return false
+	}
+	return true
+}
+
+func (f TaskFilter) String() string {
+	var parts []string
+	if f.Repository != "" || f.Rev != "" {
+		repo := f.Repository
+		if f.Rev != "" {
+			repo += "@" + f.Rev
+		}
+		parts = append(parts, "repository "+repo)
+	}
+	if f.Path != "" {
+		parts = append(parts, "path "+f.Path)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// minCommitPrefix is the minimum length of the prefix of a commit that a
+// revision is matched against, so that short branch names don't match.
+const minCommitPrefix = 7
+
+// matchesRev returns whether the repository of the task is at the given
+// branch or commit.
+func matchesRev(task *executor.Task, rev string) bool {
+	commit := task.Repository.Rev()
+	if commit == rev || (len(rev) >= minCommitPrefix && strings.HasPrefix(commit, rev)) {
+		return true
+	}
+	baseRef := task.Repository.BaseRef()
+	return baseRef == rev || baseRef == "refs/heads/"+rev
+}
+
+func cleanWorkspacePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "."
+	}
+	return path.Clean(p)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestTaskFilter_Filter(t *testing.T) {
+	repo := func(name, branch, commit string) *graphql.Repository {
+		return &graphql.Repository{
+			Name:          name,
+			DefaultBranch: &graphql.Branch{Name: branch, Target: graphql.Target{OID: commit}},
+		}
+	}
+	var (
+		srcCLI      = repo("github.com/sourcegraph/src-cli", "main", "d34db33fd34db33f")
+		srcCLIDev   = &graphql.Repository{Name: "github.com/sourcegraph/src-cli", Branch: graphql.Branch{Name: "dev", Target: graphql.Target{OID: "f00b4rf00b4r"}}}
+		sourcegraph = repo("github.com/sourcegraph/sourcegraph", "main", "c0ffeec0ffee")
+
+		srcCLIRoot    = &executor.Task{Repository: srcCLI}
+		srcCLIDevRoot = &executor.Task{Repository: srcCLIDev}
+		sgClient      = &executor.Task{Repository: sourcegraph, Path: "client"}
+		sgDocs        = &executor.Task{Repository: sourcegraph, Path: "doc/admin"}
+	)
+	tasks := []*executor.Task{srcCLIRoot, srcCLIDevRoot, sgClient, sgDocs}
+
+	tests := []struct {
+		name          string
+		repo          string
+		workspacePath string
+		want          []*executor.Task
+		wantErr       string
+	}{
+		{name: "no filter", want: tasks},
+		{name: "repository", repo: "github.com/sourcegraph/src-cli", want: []*executor.Task{srcCLIRoot, srcCLIDevRoot}},
+		{name: "repository at branch", repo: "github.com/sourcegraph/src-cli@dev", want: []*executor.Task{srcCLIDevRoot}},
+		{name: "repository at ref", repo: "github.com/sourcegraph/src-cli@refs/heads/main", want: []*executor.Task{srcCLIRoot}},
+		{name: "repository at commit", repo: "github.com/sourcegraph/src-cli@d34db33fd34db33f", want: []*executor.Task{srcCLIRoot}},
+		{name: "repository at commit prefix", repo: "github.com/sourcegraph/src-cli@f00b4rf", want: []*executor.Task{srcCLIDevRoot}},
+		{name: "commit prefix too short", repo: "github.com/sourcegraph/src-cli@d34", wantErr: "no workspace of the batch spec matches repository github.com/sourcegraph/src-cli@d34"},
+		{name: "workspace", repo: "github.com/sourcegraph/sourcegraph", workspacePath: "/doc/admin/", want: []*executor.Task{sgDocs}},
+		{name: "root workspace", workspacePath: ".", want: []*executor.Task{srcCLIRoot, srcCLIDevRoot}},
+		{name: "workspace in any repository", workspacePath: "client", want: []*executor.Task{sgClient}},
+		{name: "no match", repo: "github.com/sourcegraph/sourcegraph", workspacePath: "enterprise", wantErr: "no workspace of the batch spec matches repository github.com/sourcegraph/sourcegraph and path enterprise"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := NewTaskFilter(tt.repo, tt.workspacePath).Filter(tasks)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("wrong error: want %q, have %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, have); diff != "" {
+				t.Errorf("wrong tasks (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
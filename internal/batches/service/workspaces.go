@@ -170,11 +170,26 @@ func findWorkspaces(
 
 // stepsForRepo calculates the steps required to run on the given repo.
 func stepsForRepo(spec *batcheslib.BatchSpec, repo template.Repository) ([]batcheslib.Step, error) {
-	taskSteps := []batcheslib.Step{}
-	for _, step := range spec.Steps {
+	indexes, err := stepIndexesForRepo(spec, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	taskSteps := make([]batcheslib.Step, 0, len(indexes))
+	for _, i := range indexes {
+		taskSteps = append(taskSteps, spec.Steps[i])
+	}
+	return taskSteps, nil
+}
+
+// stepIndexesForRepo returns the indexes of the steps in the batch spec that
+// are required to run on the given repo.
+func stepIndexesForRepo(spec *batcheslib.BatchSpec, repo template.Repository) ([]int, error) {
+	indexes := []int{}
+	for i, step := range spec.Steps {
 		// If no if condition is given, just go ahead and add the step to the list.
 		if step.IfCondition() == "" {
-			taskSteps = append(taskSteps, step)
+			indexes = append(indexes, i)
 			continue
 		}
 
@@ -194,10 +209,10 @@ func stepsForRepo(spec *batcheslib.BatchSpec, repo template.Repository) ([]batch
 		// If we could evaluate the condition statically and the resulting
 		// boolean is false, we don't add that step.
 		if !static {
-			taskSteps = append(taskSteps, step)
+			indexes = append(indexes, i)
 		} else if boolVal {
-			taskSteps = append(taskSteps, step)
+			indexes = append(indexes, i)
 		}
 	}
-	return taskSteps, nil
+	return indexes, nil
 }
@@ -0,0 +1,154 @@
+package specext
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+)
+
+// maxMatrixVariants is the maximum number of variants of a step.
+const maxMatrixVariants = 256
+
+// Matrix are the values of the variables of a step that is executed once for
+// every combination of them. The values are available in the templates of
+// the step as matrix.NAME.
+type Matrix map[string][]string
+
+// Variant is one combination of the values of a Matrix.
+type Variant map[string]string
+
+var (
+	matrixName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// templateAction matches the actions in templates, like ${{ matrix.go }}.
+	templateAction = regexp.MustCompile(`(?s)\$\{\{(.*?)\}\}`)
+	// matrixRef matches the references to matrix values in an action. The
+	// first group is what precedes the reference, so that fields named matrix
+	// aren't mistaken for it.
+	matrixRef = regexp.MustCompile(`(^|[^\w.$])matrix\.([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// validate returns the errors in the matrix. where is the path of the matrix
+// in the batch spec.
+func (m Matrix) validate(where string) error {
+	var errs *multierror.Error
+	for name, values := range m {
+		if !matrixName.MatchString(name) {
+			errs = multierror.Append(errs, errors.Newf("%s: invalid name %q, must only contain letters, digits and underscores", where, name))
+		}
+		if len(values) == 0 {
+			errs = multierror.Append(errs, errors.Newf("%s: %s has no values", where, name))
+		}
+	}
+	if n := m.size(); n > maxMatrixVariants {
+		errs = multierror.Append(errs, errors.Newf("%s: %d variants, but at most %d are allowed", where, n, maxMatrixVariants))
+	}
+	return errs.ErrorOrNil()
+}
+
+func (m Matrix) size() int {
+	n := 1
+	for _, values := range m {
+		n *= len(values)
+		if n > maxMatrixVariants {
+			// That's enough to know, and it can't overflow.
+			return n
+		}
+	}
+	return n
+}
+
+// Variants returns all combinations of the values of the matrix. The values
+// of the variable whose name comes first alphabetically change slowest.
+func (m Matrix) Variants() []Variant {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variants := []Variant{{}}
+	for _, name := range names {
+		next := make([]Variant, 0, len(variants)*len(m[name]))
+		for _, v := range variants {
+			for _, value := range m[name] {
+				variant := Variant{name: value}
+				for k, val := range v {
+					variant[k] = val
+				}
+				next = append(next, variant)
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+// String returns the values of the variant, like "go=1.17, module=api".
+func (v Variant) String() string {
+	pairs := make([]string, 0, len(v))
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// Expand replaces the references to the values of the variant in the
+// template actions in s with the values. Actions that consist of nothing but
+// such a reference, like ${{ matrix.go }}, are replaced with the value, so
+// that they can be used where no templates are rendered, like in the
+// container of a step. References to values the variant doesn't have are left
+// in place.
+func (v Variant) Expand(s string) string {
+	if !strings.Contains(s, "matrix.") {
+		return s
+	}
+
+	return templateAction.ReplaceAllStringFunc(s, func(action string) string {
+		inner := action[len("${{") : len(action)-len("}}")]
+		replaced := false
+		inner = replaceMatrixRefs(inner, func(name string) (string, bool) {
+			value, ok := v[name]
+			if ok {
+				replaced = true
+			}
+			return strconv.Quote(value), ok
+		})
+		if !replaced {
+			return action
+		}
+		if value, err := strconv.Unquote(strings.TrimSpace(inner)); err == nil {
+			return value
+		}
+		return "${{" + inner + "}}"
+	})
+}
+
+// matrixRefs returns the names of the matrix values that the template
+// actions in s reference.
+func matrixRefs(s string) []string {
+	var names []string
+	for _, m := range templateAction.FindAllStringSubmatch(s, -1) {
+		replaceMatrixRefs(m[1], func(name string) (string, bool) {
+			names = append(names, name)
+			return "", false
+		})
+	}
+	return names
+}
+
+// replaceMatrixRefs replaces the references to matrix values in the given
+// action with what replace returns for their name, if it returns true.
+func replaceMatrixRefs(action string, replace func(name string) (string, bool)) string {
+	return matrixRef.ReplaceAllStringFunc(action, func(ref string) string {
+		m := matrixRef.FindStringSubmatch(ref)
+		if value, ok := replace(m[2]); ok {
+			return m[1] + value
+		}
+		return ref
+	})
+}
@@ -0,0 +1,92 @@
+package specext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatrix_Variants(t *testing.T) {
+	m := Matrix{"module": {"api", "web"}, "go": {"1.17", "1.18"}}
+
+	want := []Variant{
+		{"go": "1.17", "module": "api"},
+		{"go": "1.17", "module": "web"},
+		{"go": "1.18", "module": "api"},
+		{"go": "1.18", "module": "web"},
+	}
+	if diff := cmp.Diff(want, m.Variants()); diff != "" {
+		t.Errorf("wrong variants (-want +have):\n%s", diff)
+	}
+
+	if have, want := want[1].String(), "go=1.17, module=web"; have != want {
+		t.Errorf("wrong string: %q, want %q", have, want)
+	}
+}
+
+func TestVariant_Expand(t *testing.T) {
+	v := Variant{"go": "1.17", "module": `say "hi"`}
+
+	for in, want := range map[string]string{
+		"no templates":                                  "no templates",
+		"golang:${{ matrix.go }}":                       "golang:1.17",
+		"${{matrix.module}}":                            `say "hi"`,
+		`${{ if eq matrix.go "1.17" }}yes${{ end }}`:    `${{ if eq "1.17" "1.17" }}yes${{ end }}`,
+		"${{ join (split matrix.module \" \") \"-\" }}": `${{ join (split "say \"hi\"" " ") "-" }}`,
+		"${{ outputs.matrix.go }}":                      "${{ outputs.matrix.go }}",
+		"${{ matrix.undefined }}":                       "${{ matrix.undefined }}",
+		"${{ repository.name }}":                        "${{ repository.name }}",
+	} {
+		if have := v.Expand(in); have != want {
+			t.Errorf("Expand(%q) = %q, want %q", in, have, want)
+		}
+	}
+}
+
+func TestSplit_Matrix(t *testing.T) {
+	spec, ext, err := Split([]byte(`name: hello
+steps:
+  - run: go get go@${{ matrix.go }}
+    container: golang:${{ matrix.go }}
+    matrix:
+      go: ["1.17", "1.18"]
+  - run: echo
+    container: alpine:3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(spec), "matrix:") {
+		t.Errorf("matrix not removed:\n%s", spec)
+	}
+	want := []Matrix{{"go": {"1.17", "1.18"}}, nil}
+	if diff := cmp.Diff(want, ext.StepMatrices()); diff != "" {
+		t.Errorf("wrong matrices (-want +have):\n%s", diff)
+	}
+
+	_, _, err = Split([]byte(`name: hello
+steps:
+  - run: echo ${{ matrix.module }}
+    container: alpine:3
+    matrix:
+      go: ["1.17"]
+      "invalid-name": [a]
+      empty: []
+  - run: echo ${{ matrix.go }}
+    container: alpine:3
+`))
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{
+		"steps.0: matrix.module is used",
+		`invalid name "invalid-name"`,
+		"empty has no values",
+		"steps.1: matrix.go is used",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't contain %q: %s", want, err)
+		}
+	}
+}
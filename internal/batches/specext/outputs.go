@@ -0,0 +1,58 @@
+package specext
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// maxValueLen is the length after which the values in the errors of
+// ValidateOutput are cut off.
+const maxValueLen = 80
+
+// CompileOutputSchema compiles the JSON schema of an output.
+func CompileOutputSchema(schema interface{}) (*gojsonschema.Schema, error) {
+	s, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid JSON schema")
+	}
+	return s, nil
+}
+
+// ValidateOutput validates the value of an output against its JSON schema. The
+// returned error lists every part of the value that doesn't match, together
+// with the offending value.
+func ValidateOutput(schema, value interface{}) error {
+	s, err := CompileOutputSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.Validate(gojsonschema.NewGoLoader(value))
+	if err != nil {
+		return errors.Wrap(err, "validating output")
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	problems := make([]string, 0, len(result.Errors()))
+	for _, e := range result.Errors() {
+		problems = append(problems, fmt.Sprintf("%s: %s (value: %s)", e.Field(), e.Description(), formatValue(e.Value())))
+	}
+	return errors.Newf("value doesn't match the schema:\n\t%s", strings.Join(problems, "\n\t"))
+}
+
+func formatValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "?"
+	}
+	if len(data) > maxValueLen {
+		return string(data[:maxValueLen]) + "..."
+	}
+	return string(data)
+}
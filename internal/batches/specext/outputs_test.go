@@ -0,0 +1,48 @@
+package specext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateOutput(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"findings"},
+		"properties": map[string]interface{}{
+			"findings": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"severity": map[string]interface{}{"enum": []interface{}{"low", "high"}},
+					},
+				},
+			},
+		},
+	}
+
+	valid := map[string]interface{}{
+		"findings": []interface{}{map[string]interface{}{"severity": "low"}},
+	}
+	if err := ValidateOutput(schema, valid); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	invalid := map[string]interface{}{
+		"findings": []interface{}{map[string]interface{}{"severity": "medium"}},
+	}
+	err := ValidateOutput(schema, invalid)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{"findings.0.severity", `(value: "medium")`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't contain %q: %s", want, err)
+		}
+	}
+
+	if err := ValidateOutput(schema, "not an object"); err == nil {
+		t.Error("no error for string")
+	}
+}
@@ -0,0 +1,445 @@
+// Package specext implements the settings that src-cli supports in batch specs
+// on top of the batch spec schema of Sourcegraph. They are removed from batch
+// specs before the batch specs are validated against the schema and sent to
+// Sourcegraph, so that neither needs to know about them.
+package specext
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions are the settings of a batch spec that are specific to src-cli.
+type Extensions struct {
+	// Paths are glob patterns that restrict the files in the workspaces to
+	// the ones whose paths, relative to the root of the repository, match
+	// one of them.
+	Paths []string `yaml:"paths"`
+
+	// Secrets are the secrets that steps can use, by their name.
+	Secrets map[string]SecretSource `yaml:"secrets"`
+
+	// Steps are the extensions of the steps, by their index.
+	Steps []StepExtensions `yaml:"-"`
+}
+
+// StepExtensions are the settings of a step that are specific to src-cli.
+type StepExtensions struct {
+	// Paths are the paths the step needs in the workspace, in addition to the
+	// ones of the batch spec.
+	Paths []string `yaml:"paths"`
+
+	// Artifacts are the paths of the files or directories, relative to the
+	// directory the step is executed in, that the step produces and that are
+	// copied out of the workspace after it finishes. They aren't part of the
+	// diff.
+	Artifacts []string `yaml:"artifacts"`
+
+	// Secrets are the secrets of the batch spec that the step gets.
+	Secrets []StepSecret `yaml:"secrets"`
+
+	// Matrix makes the step execute once for every combination of its
+	// values.
+	Matrix Matrix `yaml:"matrix"`
+
+	// OutputSchemas are the JSON schemas that the outputs of the step are
+	// validated against after it finishes, by the name of the output. They
+	// are given as "schema" in the outputs.
+	OutputSchemas map[string]interface{} `yaml:"-"`
+}
+
+// SecretSource is where the value of a secret comes from. Exactly one of its
+// fields is set.
+type SecretSource struct {
+	// Env is the environment variable of src-cli that holds the value.
+	Env string `yaml:"env"`
+	// File is the path of the file that holds the value.
+	File string `yaml:"file"`
+	// Command is the shell command that prints the value.
+	Command string `yaml:"command"`
+}
+
+// StepSecret is a secret that a step gets, either as an environment variable
+// or as a file. Its value is never part of cache keys, logs or outputs.
+type StepSecret struct {
+	// Name is the name of the secret in the batch spec.
+	Name string `yaml:"name" json:"name"`
+	// Env is the environment variable the value is put in. It defaults to
+	// Name, unless File is set.
+	Env string `yaml:"env" json:"env,omitempty"`
+	// File is the absolute path of the file in the container that holds the
+	// value.
+	File string `yaml:"file" json:"file,omitempty"`
+}
+
+// UnmarshalYAML allows a StepSecret to be given by its name alone.
+func (s *StepSecret) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&s.Name)
+	}
+
+	type plain StepSecret
+	return node.Decode((*plain)(s))
+}
+
+// ArtifactsOutput is the name of the output that holds the paths of the
+// artifacts that the previous steps produced.
+const ArtifactsOutput = "artifacts"
+
+// specKeys, stepKeys and outputKeys are the keys of the extensions in batch
+// specs, steps and their outputs.
+var (
+	specKeys   = []string{"paths", "secrets"}
+	stepKeys   = []string{"paths", "artifacts", "secrets", "matrix"}
+	outputKeys = []string{"schema"}
+)
+
+// Split removes the extensions from the batch spec in data. It returns the
+// batch spec without them, which is data itself if there are none, and the
+// extensions.
+//
+// If data isn't valid YAML, it's returned unchanged, so that the error is
+// reported when the batch spec is parsed.
+func Split(data []byte) ([]byte, *Extensions, error) {
+	ext := &Extensions{}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return data, ext, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return data, ext, nil
+	}
+
+	var errs *multierror.Error
+	removed := false
+	// conflicts is whether a step has an output with the name of the one
+	// that holds the artifacts.
+	conflicts := false
+
+	if n := removeKeys(root, specKeys); n != nil {
+		removed = true
+		if err := n.Decode(ext); err != nil {
+			errs = multierror.Append(errs, errors.Wrap(err, "src-cli extensions"))
+		}
+	}
+
+	if steps := mappingValue(root, "steps"); steps != nil && steps.Kind == yaml.SequenceNode {
+		ext.Steps = make([]StepExtensions, len(steps.Content))
+		for i, step := range steps.Content {
+			if step.Kind != yaml.MappingNode {
+				continue
+			}
+			if n := removeKeys(step, stepKeys); n != nil {
+				removed = true
+				if err := n.Decode(&ext.Steps[i]); err != nil {
+					errs = multierror.Append(errs, errors.Wrapf(err, "src-cli extensions of step %d", i+1))
+				}
+			}
+			for _, name := range scalarMatrixRefs(step) {
+				if _, ok := ext.Steps[i].Matrix[name]; !ok {
+					errs = multierror.Append(errs, errors.Newf("steps.%d: matrix.%s is used, but not defined in the matrix of the step", i, name))
+				}
+			}
+			outputs := mappingValue(step, "outputs")
+			if outputs == nil || outputs.Kind != yaml.MappingNode {
+				continue
+			}
+			if mappingValue(outputs, ArtifactsOutput) != nil {
+				conflicts = true
+			}
+			for j := 0; j+1 < len(outputs.Content); j += 2 {
+				name, output := outputs.Content[j].Value, outputs.Content[j+1]
+				if output.Kind != yaml.MappingNode {
+					continue
+				}
+				n := removeKeys(output, outputKeys)
+				if n == nil {
+					continue
+				}
+				removed = true
+				var o struct {
+					Schema interface{} `yaml:"schema"`
+				}
+				if err := n.Decode(&o); err != nil {
+					errs = multierror.Append(errs, errors.Wrapf(err, "src-cli extensions of output %q of step %d", name, i+1))
+					continue
+				}
+				if ext.Steps[i].OutputSchemas == nil {
+					ext.Steps[i].OutputSchemas = map[string]interface{}{}
+				}
+				ext.Steps[i].OutputSchemas[name] = o.Schema
+			}
+		}
+	}
+
+	if err := ext.validate(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if conflicts && ext.StepArtifacts() != nil {
+		errs = multierror.Append(errs, errors.Newf("the output %q is reserved for the artifacts of steps", ArtifactsOutput))
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return data, nil, err
+	}
+
+	if !removed {
+		return data, ext, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return data, nil, errors.Wrap(err, "encoding batch spec")
+	}
+	if err := enc.Close(); err != nil {
+		return data, nil, errors.Wrap(err, "encoding batch spec")
+	}
+	return buf.Bytes(), ext, nil
+}
+
+func (ext *Extensions) validate() error {
+	var errs *multierror.Error
+	check := func(where string, patterns []string) {
+		for _, p := range patterns {
+			if _, err := glob.Compile(p, '/'); err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "%s: invalid glob pattern %q", where, p))
+			}
+		}
+	}
+
+	check("paths", ext.Paths)
+	for i, step := range ext.Steps {
+		check(fmt.Sprintf("steps.%d.paths", i), step.Paths)
+		for _, a := range step.Artifacts {
+			if !validArtifact(a) {
+				errs = multierror.Append(errs, errors.Newf("steps.%d.artifacts: invalid path %q, must be relative and within the workspace", i, a))
+			}
+		}
+		for _, secret := range step.Secrets {
+			if _, ok := ext.Secrets[secret.Name]; !ok {
+				errs = multierror.Append(errs, errors.Newf("steps.%d.secrets: undefined secret %q", i, secret.Name))
+			}
+			if secret.Env != "" && secret.File != "" {
+				errs = multierror.Append(errs, errors.Newf("steps.%d.secrets: secret %q can't have both env and file", i, secret.Name))
+			}
+			if secret.File != "" && !path.IsAbs(secret.File) {
+				errs = multierror.Append(errs, errors.Newf("steps.%d.secrets: file %q of secret %q is not an absolute path", i, secret.File, secret.Name))
+			}
+		}
+		if err := step.Matrix.validate(fmt.Sprintf("steps.%d.matrix", i)); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		for name, schema := range step.OutputSchemas {
+			if _, err := CompileOutputSchema(schema); err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "steps.%d.outputs.%s.schema", i, name))
+			}
+		}
+	}
+	for name, source := range ext.Secrets {
+		set := 0
+		for _, field := range []string{source.Env, source.File, source.Command} {
+			if field != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			errs = multierror.Append(errs, errors.Newf("secrets.%s: exactly one of env, file and command must be set", name))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func validArtifact(p string) bool {
+	clean := path.Clean(p)
+	return p != "" && !path.IsAbs(clean) && clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// StepArtifacts returns the paths of the artifacts of the steps, by their
+// index, or nil if no step has any.
+func (ext *Extensions) StepArtifacts() [][]string {
+	if ext == nil {
+		return nil
+	}
+
+	var found bool
+	artifacts := make([][]string, len(ext.Steps))
+	for i, step := range ext.Steps {
+		for _, a := range step.Artifacts {
+			artifacts[i] = append(artifacts[i], path.Clean(a))
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return artifacts
+}
+
+// StepSecrets returns the secrets of the steps, by their index, or nil if no
+// step has any. The secrets that are given by name alone are put in the
+// environment variable with their name.
+func (ext *Extensions) StepSecrets() [][]StepSecret {
+	if ext == nil {
+		return nil
+	}
+
+	var found bool
+	secrets := make([][]StepSecret, len(ext.Steps))
+	for i, step := range ext.Steps {
+		for _, secret := range step.Secrets {
+			if secret.Env == "" && secret.File == "" {
+				secret.Env = secret.Name
+			}
+			secrets[i] = append(secrets[i], secret)
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return secrets
+}
+
+// StepOutputSchemas returns the schemas of the outputs of the steps, by their
+// index, or nil if no step has any.
+func (ext *Extensions) StepOutputSchemas() []map[string]interface{} {
+	if ext == nil {
+		return nil
+	}
+
+	var found bool
+	schemas := make([]map[string]interface{}, len(ext.Steps))
+	for i, step := range ext.Steps {
+		if len(step.OutputSchemas) > 0 {
+			schemas[i] = step.OutputSchemas
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return schemas
+}
+
+// StepMatrices returns the matrices of the steps, by their index, or nil if
+// no step has one.
+func (ext *Extensions) StepMatrices() []Matrix {
+	if ext == nil {
+		return nil
+	}
+
+	var found bool
+	matrices := make([]Matrix, len(ext.Steps))
+	for i, step := range ext.Steps {
+		if len(step.Matrix) > 0 {
+			matrices[i] = step.Matrix
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return matrices
+}
+
+// WorkspacePaths returns the glob patterns of the files that the workspaces
+// need to contain, or nil if they need all files.
+//
+// The paths of the steps only restrict the workspaces if the batch spec has
+// paths or if every step has them, because a step without them might need any
+// file.
+func (ext *Extensions) WorkspacePaths() []string {
+	if ext == nil {
+		return nil
+	}
+
+	restricted := len(ext.Paths) > 0
+	if !restricted && len(ext.Steps) > 0 {
+		restricted = true
+		for _, step := range ext.Steps {
+			restricted = restricted && len(step.Paths) > 0
+		}
+	}
+	if !restricted {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var paths []string
+	add := func(patterns []string) {
+		for _, p := range patterns {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	add(ext.Paths)
+	for _, step := range ext.Steps {
+		add(step.Paths)
+	}
+	return paths
+}
+
+// removeKeys removes the given keys from the mapping node and returns a
+// mapping node with them, or nil if it has none of them.
+func removeKeys(node *yaml.Node, keys []string) *yaml.Node {
+	var removed *yaml.Node
+	content := node.Content[:0:0]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		if !contains(keys, k.Value) {
+			content = append(content, k, v)
+			continue
+		}
+		if removed == nil {
+			removed = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		removed.Content = append(removed.Content, k, v)
+	}
+	if removed != nil {
+		node.Content = content
+	}
+	return removed
+}
+
+// scalarMatrixRefs returns the names of the matrix values that the templates
+// in the scalars of the given node reference.
+func scalarMatrixRefs(node *yaml.Node) []string {
+	if node.Kind == yaml.ScalarNode {
+		return matrixRefs(node.Value)
+	}
+	var names []string
+	for _, n := range node.Content {
+		names = append(names, scalarMatrixRefs(n)...)
+	}
+	return names
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
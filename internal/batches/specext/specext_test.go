@@ -0,0 +1,246 @@
+package specext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplit(t *testing.T) {
+	t.Run("no extensions", func(t *testing.T) {
+		data := []byte("name: hello\n# A comment that stays.\nsteps:\n  - run: echo\n    container: alpine:3\n")
+		spec, ext, err := Split(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(spec) != string(data) {
+			t.Errorf("batch spec changed:\n%s", spec)
+		}
+		if have := ext.WorkspacePaths(); have != nil {
+			t.Errorf("unexpected paths: %v", have)
+		}
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		data := []byte(`name: hello
+paths:
+  - "**/go.mod"
+steps:
+  - run: echo
+    container: alpine:3
+    paths: [go.mod]
+  - run: echo
+    container: alpine:3
+`)
+		spec, ext, err := Split(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `name: hello
+steps:
+  - run: echo
+    container: alpine:3
+  - run: echo
+    container: alpine:3
+`
+		if diff := cmp.Diff(want, string(spec)); diff != "" {
+			t.Errorf("wrong batch spec (-want +have):\n%s", diff)
+		}
+
+		wantExt := &Extensions{
+			Paths: []string{"**/go.mod"},
+			Steps: []StepExtensions{{Paths: []string{"go.mod"}}, {}},
+		}
+		if diff := cmp.Diff(wantExt, ext); diff != "" {
+			t.Errorf("wrong extensions (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, _, err := Split([]byte("name: hello\nsteps:\n  - run: echo\n    container: alpine:3\n    paths: ['[a-']\n"))
+		if err == nil || !strings.Contains(err.Error(), "steps.0.paths") {
+			t.Fatalf("wrong error: %v", err)
+		}
+	})
+
+	t.Run("artifacts", func(t *testing.T) {
+		spec, ext, err := Split([]byte("name: hello\nsteps:\n  - run: echo\n    container: alpine:3\n    artifacts: [report.sarif, ./out/]\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(spec), "artifacts") {
+			t.Errorf("artifacts not removed from batch spec:\n%s", spec)
+		}
+		if diff := cmp.Diff([][]string{{"report.sarif", "out"}}, ext.StepArtifacts()); diff != "" {
+			t.Errorf("wrong artifacts (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid artifact", func(t *testing.T) {
+		for _, artifact := range []string{"/etc/passwd", "../report.sarif", "."} {
+			_, _, err := Split([]byte("name: hello\nsteps:\n  - run: echo\n    container: alpine:3\n    artifacts: ['" + artifact + "']\n"))
+			if err == nil || !strings.Contains(err.Error(), "steps.0.artifacts") {
+				t.Errorf("wrong error for %q: %v", artifact, err)
+			}
+		}
+	})
+
+	t.Run("reserved output", func(t *testing.T) {
+		_, _, err := Split([]byte(`name: hello
+steps:
+  - run: echo
+    container: alpine:3
+    artifacts: [report.sarif]
+  - run: echo
+    container: alpine:3
+    outputs:
+      artifacts:
+        value: hello
+`))
+		if err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Fatalf("wrong error: %v", err)
+		}
+	})
+
+	t.Run("secrets", func(t *testing.T) {
+		spec, ext, err := Split([]byte(`name: hello
+secrets:
+  token:
+    env: GITHUB_TOKEN
+  npmrc:
+    file: ~/.npmrc
+steps:
+  - run: echo
+    container: alpine:3
+    secrets:
+      - token
+      - name: npmrc
+        file: /root/.npmrc
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(spec), "secrets") {
+			t.Errorf("secrets not removed from batch spec:\n%s", spec)
+		}
+
+		want := [][]StepSecret{{{Name: "token", Env: "token"}, {Name: "npmrc", File: "/root/.npmrc"}}}
+		if diff := cmp.Diff(want, ext.StepSecrets()); diff != "" {
+			t.Errorf("wrong secrets (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid secrets", func(t *testing.T) {
+		_, _, err := Split([]byte(`name: hello
+secrets:
+  token:
+    env: GITHUB_TOKEN
+    command: gh auth token
+steps:
+  - run: echo
+    container: alpine:3
+    secrets: [undefined, {name: token, file: relative}]
+`))
+		if err == nil {
+			t.Fatal("no error")
+		}
+		for _, want := range []string{"secrets.token: exactly one", "undefined secret", "not an absolute path"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error doesn't contain %q: %s", want, err)
+			}
+		}
+	})
+
+	t.Run("output schemas", func(t *testing.T) {
+		spec, ext, err := Split([]byte(`name: hello
+steps:
+  - run: echo
+    container: alpine:3
+  - run: echo
+    container: alpine:3
+    outputs:
+      report:
+        value: ${{ step.stdout }}
+        format: json
+        schema:
+          type: object
+          required: [findings]
+      plain:
+        value: hello
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(spec), "schema") {
+			t.Errorf("schema not removed:\n%s", spec)
+		}
+		want := []map[string]interface{}{
+			nil,
+			{"report": map[string]interface{}{"type": "object", "required": []interface{}{"findings"}}},
+		}
+		if diff := cmp.Diff(want, ext.StepOutputSchemas()); diff != "" {
+			t.Errorf("wrong output schemas (-want +have):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid output schema", func(t *testing.T) {
+		_, _, err := Split([]byte(`name: hello
+steps:
+  - run: echo
+    container: alpine:3
+    outputs:
+      report:
+        value: ${{ step.stdout }}
+        schema:
+          type: nothing
+`))
+		if err == nil || !strings.Contains(err.Error(), "steps.0.outputs.report.schema") {
+			t.Fatalf("wrong error: %v", err)
+		}
+	})
+
+	t.Run("invalid YAML", func(t *testing.T) {
+		data := []byte("name: [hello\n")
+		spec, _, err := Split(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(spec) != string(data) {
+			t.Errorf("batch spec changed:\n%s", spec)
+		}
+	})
+}
+
+func TestExtensions_WorkspacePaths(t *testing.T) {
+	tests := map[string]struct {
+		ext  *Extensions
+		want []string
+	}{
+		"none": {
+			ext:  &Extensions{Steps: []StepExtensions{{}, {}}},
+			want: nil,
+		},
+		"batch spec": {
+			ext:  &Extensions{Paths: []string{"go.mod"}, Steps: []StepExtensions{{}, {Paths: []string{"go.sum", "go.mod"}}}},
+			want: []string{"go.mod", "go.sum"},
+		},
+		"all steps": {
+			ext:  &Extensions{Steps: []StepExtensions{{Paths: []string{"go.mod"}}, {Paths: []string{"go.sum"}}}},
+			want: []string{"go.mod", "go.sum"},
+		},
+		"not all steps": {
+			ext:  &Extensions{Steps: []StepExtensions{{Paths: []string{"go.mod"}}, {}}},
+			want: nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.ext.WorkspacePaths()); diff != "" {
+				t.Errorf("wrong paths (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,9 +1,15 @@
 package ui
 
 import (
+	"context"
+	"os/exec"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
 	"github.com/sourcegraph/src-cli/internal/batches"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 )
 
@@ -33,20 +39,47 @@ type ExecUI interface {
 
 	ExecutingTasks(verbose bool, parallelism int) executor.TaskExecutionUI
 	ExecutingTasksSkippingErrors(err error)
+	// TriageFailures lets the user look into the tasks that failed with err
+	// and execute them again. It returns the changeset specs of the tasks
+	// that succeeded when executed again and the errors that remain.
+	TriageFailures(ctx context.Context, err error, rerun RerunFunc) ([]*batcheslib.ChangesetSpec, error)
+	// DebugShell runs the debug shell of a step with the terminal attached to
+	// it. See executor.DebugShell.
+	DebugShell(task *executor.Task, step int, script string, cmd *exec.Cmd) error
 
 	LogFilesKept(files []string)
+	// WritingLogs is called when the logs of the tasks are written to the
+	// directory of the run with the given ID in logDir.
+	WritingLogs(logDir, runID string)
+	// ServingDashboard is called when the dashboard of the execution is
+	// served at the given URL.
+	ServingDashboard(url string)
 
 	NoChangesetSpecs()
 	UploadingChangesetSpecs(num int)
 	UploadingChangesetSpecsProgress(done, total int)
 	UploadingChangesetSpecsSuccess(ids []graphql.ChangesetSpecID)
 
+	WritingChangesetSpecs(num int)
+	WritingChangesetSpecsSuccess(dir string, num int)
+
 	CreatingBatchSpec()
 	CreatingBatchSpecSuccess(previewURL string)
 	CreatingBatchSpecError(err error) error
 
+	PreviewingApply()
+	PreviewingApplySuccess(previews []*service.ChangesetPreview, showDiffs bool)
+	// PreviewingApplyFailed reports that the changes couldn't be previewed.
+	// It's only a warning: the batch spec can still be applied.
+	PreviewingApplyFailed(err error)
+
 	PreviewBatchSpec(previewURL string)
 
+	// ConfirmApply asks whether to apply the batch spec and returns whether
+	// the user agreed.
+	ConfirmApply(previewURL string) (bool, error)
+	ApplyingBatchSpecCancelled(previewURL string)
+
 	ApplyingBatchSpec()
 	ApplyingBatchSpecSuccess(batchChangeURL string)
 
@@ -6,14 +6,18 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
 	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/dineshappavoo/basex"
 
 	"github.com/sourcegraph/src-cli/internal/batches"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 
 	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
@@ -62,6 +66,8 @@ func (ui *JSONLines) DeterminingWorkspaceCreatorTypeSuccess(wt workspace.Creator
 		t = "VOLUME"
 	case workspace.CreatorTypeBind:
 		t = "BIND"
+	case workspace.CreatorTypeNative:
+		t = "NATIVE"
 	}
 	logOperationSuccess(batcheslib.LogEventOperationDeterminingWorkspaceType, &batcheslib.DeterminingWorkspaceTypeMetadata{Type: t})
 }
@@ -105,6 +111,38 @@ func (ui *JSONLines) ExecutingTasksSkippingErrors(err error) {
 	})
 }
 
+func (ui *JSONLines) TriageFailures(ctx context.Context, err error, rerun RerunFunc) ([]*batcheslib.ChangesetSpec, error) {
+	// There's nobody to triage the failures with.
+	return nil, err
+}
+
+func (ui *JSONLines) DebugShell(task *executor.Task, step int, script string, cmd *exec.Cmd) error {
+	return errors.New("can't start a debug shell with -text-only")
+}
+
+// The operations and metadata of writing the logs of a run and serving the
+// dashboard are not part of batcheslib, since they only exist in src-cli.
+const logEventOperationWritingLogs batcheslib.LogEventOperation = "WRITING_LOGS"
+
+type writingLogsMetadata struct {
+	RunID string `json:"runID"`
+	Dir   string `json:"dir"`
+}
+
+func (ui *JSONLines) WritingLogs(logDir, runID string) {
+	logOperationSuccess(logEventOperationWritingLogs, &writingLogsMetadata{RunID: runID, Dir: filepath.Join(logDir, runID)})
+}
+
+const logEventOperationServingDashboard batcheslib.LogEventOperation = "SERVING_DASHBOARD"
+
+type servingDashboardMetadata struct {
+	URL string `json:"url"`
+}
+
+func (ui *JSONLines) ServingDashboard(url string) {
+	logOperationSuccess(logEventOperationServingDashboard, &servingDashboardMetadata{URL: url})
+}
+
 func (ui *JSONLines) LogFilesKept(files []string) {
 	for _, path := range files {
 		logOperationSuccess(batcheslib.LogEventOperationLogFileKept, &batcheslib.LogFileKeptMetadata{Path: path})
@@ -141,6 +179,23 @@ func (ui *JSONLines) UploadingChangesetSpecsSuccess(ids []graphql.ChangesetSpecI
 	})
 }
 
+// The operation and metadata of writing changeset specs to disk are not part
+// of batcheslib, since they are only used by `src batch run`.
+const logEventOperationWritingChangesetSpecs batcheslib.LogEventOperation = "WRITING_CHANGESET_SPECS"
+
+type writingChangesetSpecsMetadata struct {
+	Total int    `json:"total"`
+	Dir   string `json:"dir,omitempty"`
+}
+
+func (ui *JSONLines) WritingChangesetSpecs(num int) {
+	logOperationStart(logEventOperationWritingChangesetSpecs, &writingChangesetSpecsMetadata{Total: num})
+}
+
+func (ui *JSONLines) WritingChangesetSpecsSuccess(dir string, num int) {
+	logOperationSuccess(logEventOperationWritingChangesetSpecs, &writingChangesetSpecsMetadata{Total: num, Dir: dir})
+}
+
 func (ui *JSONLines) CreatingBatchSpec() {
 	logOperationStart(batcheslib.LogEventOperationCreatingBatchSpec, &batcheslib.CreatingBatchSpecMetadata{})
 }
@@ -156,6 +211,44 @@ func (ui *JSONLines) CreatingBatchSpecError(err error) error {
 	return err
 }
 
+// The operation and metadata of previewing the changes to changesets are not
+// part of batcheslib either.
+const logEventOperationPreviewingApply batcheslib.LogEventOperation = "PREVIEWING_APPLY"
+
+type previewingApplyMetadata struct {
+	Changesets []*service.ChangesetPreview `json:"changesets,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+func (ui *JSONLines) PreviewingApply() {
+	logOperationStart(logEventOperationPreviewingApply, &previewingApplyMetadata{})
+}
+
+func (ui *JSONLines) PreviewingApplySuccess(previews []*service.ChangesetPreview, showDiffs bool) {
+	if !showDiffs {
+		withoutDiffs := make([]*service.ChangesetPreview, 0, len(previews))
+		for _, p := range previews {
+			p := *p
+			p.Diff = ""
+			withoutDiffs = append(withoutDiffs, &p)
+		}
+		previews = withoutDiffs
+	}
+	logOperationSuccess(logEventOperationPreviewingApply, &previewingApplyMetadata{Changesets: previews})
+}
+
+func (ui *JSONLines) PreviewingApplyFailed(err error) {
+	logOperationFailure(logEventOperationPreviewingApply, &previewingApplyMetadata{Error: err.Error()})
+}
+
+func (ui *JSONLines) ConfirmApply(batchSpecURL string) (bool, error) {
+	return false, errors.New("can't ask for confirmation with -text-only: pass -yes to apply the batch spec without confirmation")
+}
+
+func (ui *JSONLines) ApplyingBatchSpecCancelled(batchSpecURL string) {
+	// ConfirmApply never agrees, so there's nothing to cancel.
+}
+
 func (ui *JSONLines) PreviewBatchSpec(batchSpecURL string) {
 	// Covered by CreatingBatchSpecSuccess.
 }
@@ -276,11 +369,41 @@ func (ui *taskExecutionJSONLines) StepsExecutionUI(task *executor.Task) executor
 		panic("unknown task started")
 	}
 
-	return &stepsExecutionJSONLines{linesTask: &lt}
+	return &stepsExecutionJSONLines{linesTask: &lt, task: task}
 }
 
 type stepsExecutionJSONLines struct {
 	linesTask *batcheslib.JSONLinesTask
+	task      *executor.Task
+}
+
+// withVariant adds the matrix variant of the given step to the metadata of an
+// event about it, if the step has one.
+func (ui *stepsExecutionJSONLines) withVariant(step int, metadata interface{}) interface{} {
+	variant := ui.task.StepVariant(step - 1)
+	if variant == "" {
+		return metadata
+	}
+	return &variantMetadata{metadata: metadata, variant: variant}
+}
+
+// variantMetadata is the metadata of an event with a "variant" field added.
+type variantMetadata struct {
+	metadata interface{}
+	variant  string
+}
+
+func (m *variantMetadata) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(m.metadata)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["variant"] = m.variant
+	return json.Marshal(fields)
 }
 
 const stepFlushDuration = 500 * time.Millisecond
@@ -308,32 +431,32 @@ func (ui *stepsExecutionJSONLines) SkippingStepsUpto(startStep int) {
 }
 
 func (ui *stepsExecutionJSONLines) StepSkipped(step int) {
-	logOperationProgress(batcheslib.LogEventOperationTaskStepSkipped, &batcheslib.TaskStepSkippedMetadata{TaskID: ui.linesTask.ID, Step: step})
+	logOperationProgress(batcheslib.LogEventOperationTaskStepSkipped, ui.withVariant(step, &batcheslib.TaskStepSkippedMetadata{TaskID: ui.linesTask.ID, Step: step}))
 }
 
 func (ui *stepsExecutionJSONLines) StepPreparingStart(step int) {
-	logOperationStart(batcheslib.LogEventOperationTaskPreparingStep, &batcheslib.TaskPreparingStepMetadata{TaskID: ui.linesTask.ID, Step: step})
+	logOperationStart(batcheslib.LogEventOperationTaskPreparingStep, ui.withVariant(step, &batcheslib.TaskPreparingStepMetadata{TaskID: ui.linesTask.ID, Step: step}))
 }
 func (ui *stepsExecutionJSONLines) StepPreparingSuccess(step int) {
-	logOperationSuccess(batcheslib.LogEventOperationTaskPreparingStep, &batcheslib.TaskPreparingStepMetadata{TaskID: ui.linesTask.ID, Step: step})
+	logOperationSuccess(batcheslib.LogEventOperationTaskPreparingStep, ui.withVariant(step, &batcheslib.TaskPreparingStepMetadata{TaskID: ui.linesTask.ID, Step: step}))
 }
 func (ui *stepsExecutionJSONLines) StepPreparingFailed(step int, err error) {
-	logOperationFailure(batcheslib.LogEventOperationTaskPreparingStep, &batcheslib.TaskPreparingStepMetadata{TaskID: ui.linesTask.ID, Step: step, Error: err.Error()})
+	logOperationFailure(batcheslib.LogEventOperationTaskPreparingStep, ui.withVariant(step, &batcheslib.TaskPreparingStepMetadata{TaskID: ui.linesTask.ID, Step: step, Error: err.Error()}))
 }
 
 func (ui *stepsExecutionJSONLines) StepStarted(step int, runScript string, env map[string]string) {
-	logOperationStart(batcheslib.LogEventOperationTaskStep, &batcheslib.TaskStepMetadata{TaskID: ui.linesTask.ID, Step: step, Env: env})
+	logOperationStart(batcheslib.LogEventOperationTaskStep, ui.withVariant(step, &batcheslib.TaskStepMetadata{TaskID: ui.linesTask.ID, Step: step, Env: env}))
 }
 
 func (ui *stepsExecutionJSONLines) StepOutputWriter(ctx context.Context, task *executor.Task, step int) executor.StepOutputWriter {
 	sink := func(data string) {
 		logOperationProgress(
 			batcheslib.LogEventOperationTaskStep,
-			&batcheslib.TaskStepMetadata{
+			ui.withVariant(step, &batcheslib.TaskStepMetadata{
 				TaskID: ui.linesTask.ID,
 				Step:   step,
 				Out:    data,
-			},
+			}),
 		)
 	}
 	return NewIntervalProcessWriter(ctx, stepFlushDuration, sink)
@@ -342,24 +465,24 @@ func (ui *stepsExecutionJSONLines) StepOutputWriter(ctx context.Context, task *e
 func (ui *stepsExecutionJSONLines) StepFinished(step int, diff string, changes *git.Changes, outputs map[string]interface{}) {
 	logOperationSuccess(
 		batcheslib.LogEventOperationTaskStep,
-		&batcheslib.TaskStepMetadata{
+		ui.withVariant(step, &batcheslib.TaskStepMetadata{
 			TaskID:  ui.linesTask.ID,
 			Step:    step,
 			Diff:    diff,
 			Outputs: outputs,
-		},
+		}),
 	)
 }
 
 func (ui *stepsExecutionJSONLines) StepFailed(step int, err error, exitCode int) {
 	logOperationFailure(
 		batcheslib.LogEventOperationTaskStep,
-		&batcheslib.TaskStepMetadata{
+		ui.withVariant(step, &batcheslib.TaskStepMetadata{
 			TaskID:   ui.linesTask.ID,
 			Step:     step,
 			Error:    err.Error(),
 			ExitCode: exitCode,
-		},
+		}),
 	)
 }
 
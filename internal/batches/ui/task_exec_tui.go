@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -90,6 +91,9 @@ type taskExecTUI struct {
 	out *output.Output
 
 	verbose bool
+	// streamOutput makes the output of the steps be written above the
+	// progress while they're executed.
+	streamOutput bool
 
 	progress      output.ProgressWithStatusBars
 	numStatusBars int
@@ -147,10 +151,17 @@ func (ui *taskExecTUI) Start(tasks []*executor.Task) {
 }
 
 func (ui *taskExecTUI) Success() {
-	ui.progress.Complete()
+	// The progress is already closed if the failed tasks were executed again.
+	if ui.progress != nil {
+		ui.progress.Complete()
+	}
 }
 func (ui *taskExecTUI) Failed(err error) {
-	// noop right now
+	// The progress stays as it is, with the failed tasks.
+	if ui.progress != nil {
+		ui.progress.Close()
+		ui.progress = nil
+	}
 }
 
 func (ui *taskExecTUI) useFreeStatusBar(ts *taskStatus) (bar int, found bool) {
@@ -238,13 +249,24 @@ func (ui *taskExecTUI) StepsExecutionUI(task *executor.Task) executor.StepsExecu
 		return executor.NoopStepsExecUI{}
 	}
 
-	return &stepsExecTUI{
+	stepsUI := &stepsExecTUI{
 		task: task,
 		updateStatusBar: func(message string) {
 			ts.currentlyExecuting = message
 			ui.progress.StatusBarUpdatef(bar, ts.String())
 		},
 	}
+	if ui.streamOutput {
+		stepsUI.writeOutput = func(step int, data string) {
+			for _, line := range strings.Split(strings.TrimRight(data, "\n"), "\n") {
+				if line == "stdout: " || line == "stderr: " {
+					continue
+				}
+				ui.progress.WriteLine(output.Linef("", output.StyleReset, "%s[%s step %s]%s %s", output.StyleSuggestion, ts.displayName, stepsUI.stepLabel(step), output.StyleReset, line))
+			}
+		}
+	}
+	return stepsUI
 }
 
 func (ui *taskExecTUI) TaskFinished(task *executor.Task, err error) {
@@ -432,6 +454,18 @@ func diffStatDiagram(stat diff.Stat) string {
 type stepsExecTUI struct {
 	task            *executor.Task
 	updateStatusBar func(string)
+	// writeOutput writes the output of a step, prefixed with "stdout: " or
+	// "stderr: ". The output isn't shown if it's nil.
+	writeOutput func(step int, data string)
+}
+
+// stepLabel returns the number of the given step, followed by its matrix
+// variant if it has one.
+func (ui stepsExecTUI) stepLabel(step int) string {
+	if variant := ui.task.StepVariant(step - 1); variant != "" {
+		return fmt.Sprintf("%d (%s)", step, variant)
+	}
+	return strconv.Itoa(step)
 }
 
 func (ui stepsExecTUI) ArchiveDownloadStarted() {
@@ -452,10 +486,10 @@ func (ui stepsExecTUI) SkippingStepsUpto(startStep int) {
 }
 
 func (ui stepsExecTUI) StepSkipped(step int) {
-	ui.updateStatusBar(fmt.Sprintf("Skipping step %d", step))
+	ui.updateStatusBar(fmt.Sprintf("Skipping step %s", ui.stepLabel(step)))
 }
 func (ui stepsExecTUI) StepPreparingStart(step int) {
-	ui.updateStatusBar(fmt.Sprintf("Preparing step %d", step))
+	ui.updateStatusBar(fmt.Sprintf("Preparing step %s", ui.stepLabel(step)))
 }
 func (ui stepsExecTUI) StepPreparingSuccess(step int) {
 	// noop right now
@@ -464,11 +498,19 @@ func (ui stepsExecTUI) StepPreparingFailed(step int, err error) {
 	// noop right now
 }
 func (ui stepsExecTUI) StepStarted(step int, runScript string, _ map[string]string) {
+	if variant := ui.task.StepVariant(step - 1); variant != "" {
+		runScript = "[" + variant + "] " + runScript
+	}
 	ui.updateStatusBar(runScript)
 }
 
 func (ui stepsExecTUI) StepOutputWriter(ctx context.Context, task *executor.Task, step int) executor.StepOutputWriter {
-	return executor.NoopStepOutputWriter{}
+	if ui.writeOutput == nil {
+		return executor.NoopStepOutputWriter{}
+	}
+	return NewIntervalProcessWriter(ctx, stepFlushDuration, func(data string) {
+		ui.writeOutput(step, data)
+	})
 }
 
 func (ui stepsExecTUI) CalculatingDiffStarted() {
@@ -0,0 +1,230 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+// RerunFunc executes a task again and returns the changeset specs built from
+// its result.
+type RerunFunc func(ctx context.Context, task *executor.Task) ([]*batcheslib.ChangesetSpec, error)
+
+func (ui *TUI) TriageFailures(ctx context.Context, err error, rerun RerunFunc) ([]*batcheslib.ChangesetSpec, error) {
+	t := &triage{
+		out:    ui.Out,
+		in:     bufio.NewReader(os.Stdin),
+		prompt: os.Stderr,
+		rerun:  rerun,
+	}
+	return t.run(ctx, err)
+}
+
+// triage goes through the tasks that failed with the user, who can look into
+// their logs and failed steps and execute them again.
+type triage struct {
+	out *output.Output
+	in  *bufio.Reader
+	// prompt is where the prompts are written to. They're written without a
+	// newline, so they don't go through out.
+	prompt io.Writer
+	rerun  RerunFunc
+}
+
+func (t *triage) run(ctx context.Context, err error) ([]*batcheslib.ChangesetSpec, error) {
+	var (
+		failed []executor.TaskExecutionErr
+		other  []error
+	)
+	for _, e := range flattenErrs(err) {
+		if taskErr, ok := e.(executor.TaskExecutionErr); ok && taskErr.Task != nil {
+			failed = append(failed, taskErr)
+		} else {
+			other = append(other, e)
+		}
+	}
+	if len(failed) == 0 {
+		return nil, err
+	}
+
+	var specs []*batcheslib.ChangesetSpec
+	for len(failed) > 0 {
+		t.out.Write("")
+		block := t.out.Block(output.Linef(output.EmojiFailure, output.StyleWarning, "%d task%s failed:", len(failed), plural(len(failed))))
+		for i, f := range failed {
+			block.Writef("%d. %s%s%s: %s", i+1, output.StyleBold, triageTaskName(f.Task), output.StyleReset, f.StatusText())
+		}
+		block.Close()
+
+		answer, err := t.ask(fmt.Sprintf("Inspect a task [1-%d], or press Enter to continue: ", len(failed)))
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" {
+			break
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > len(failed) {
+			t.out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "%q isn't a task.", answer))
+			continue
+		}
+
+		taskSpecs, remaining, fixed, err := t.inspect(ctx, failed[n-1])
+		if err != nil {
+			return nil, err
+		}
+		if fixed {
+			specs = append(specs, taskSpecs...)
+			failed = append(failed[:n-1], failed[n:]...)
+		} else {
+			failed[n-1] = remaining
+		}
+	}
+
+	var errs *multierror.Error
+	for _, e := range other {
+		errs = multierror.Append(errs, e)
+	}
+	for _, f := range failed {
+		errs = multierror.Append(errs, f)
+	}
+	return specs, errs.ErrorOrNil()
+}
+
+// inspect lets the user look into a task that failed and execute it again. It
+// returns the changeset specs of the task and true if it succeeded when
+// executed again, or the error it still fails with.
+func (t *triage) inspect(ctx context.Context, failure executor.TaskExecutionErr) ([]*batcheslib.ChangesetSpec, executor.TaskExecutionErr, bool, error) {
+	for {
+		t.out.Write("")
+		t.out.WriteLine(output.Linef(output.EmojiFailure, output.StyleWarning, "%s%s%s: %s", output.StyleBold, triageTaskName(failure.Task), output.StyleReset, failure.StatusText()))
+		t.out.WriteLine(output.Line("", output.StyleSuggestion, "[l] show the log  [s] show the failed step  [r] execute the task again  [b] back"))
+
+		answer, err := t.ask("> ")
+		if err != nil {
+			return nil, failure, false, err
+		}
+		switch answer {
+		case "l":
+			t.showLog(failure)
+
+		case "s":
+			t.showStep(failure)
+
+		case "r":
+			specs, err := t.rerun(ctx, failure.Task)
+			if err == nil {
+				t.out.WriteLine(output.Linef(output.EmojiSuccess, output.StyleSuccess, "%s succeeded.", triageTaskName(failure.Task)))
+				return specs, failure, true, nil
+			}
+			if errors.Is(err, context.Canceled) {
+				return nil, failure, false, err
+			}
+			failure = rerunFailure(failure, err)
+
+		case "b", "":
+			return nil, failure, false, nil
+
+		default:
+			t.out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "%q isn't an option.", answer))
+		}
+	}
+}
+
+func (t *triage) showLog(failure executor.TaskExecutionErr) {
+	if failure.Logfile == "" {
+		t.out.WriteLine(output.Line(output.EmojiWarning, output.StyleWarning, "The task has no log file."))
+		return
+	}
+	content, err := os.ReadFile(failure.Logfile)
+	if err != nil {
+		t.out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "Reading the log file: %s", err))
+		return
+	}
+
+	t.out.Write("")
+	t.out.WriteLine(output.Linef("", output.StyleBold, "%s:", failure.Logfile))
+	t.out.Write(strings.TrimRight(string(content), "\n"))
+}
+
+func (t *triage) showStep(failure executor.TaskExecutionErr) {
+	step, ok := executor.FailedStepOf(failure.Err)
+	if !ok {
+		t.out.WriteLine(output.Line(output.EmojiWarning, output.StyleWarning, "The task didn't fail in a step that ran here. The log has the details."))
+		return
+	}
+
+	t.out.Write("")
+	block := t.out.Block(output.Linef("", output.StyleBold, "Step %d in %s:", step.Step, step.Container))
+	defer block.Close()
+
+	block.Write("run:")
+	for _, line := range strings.Split(strings.TrimRight(step.Run, "\n"), "\n") {
+		block.Write("  " + line)
+	}
+
+	if len(step.Env) > 0 {
+		keys := make([]string, 0, len(step.Env))
+		for k := range step.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		block.Write("env:")
+		for _, k := range keys {
+			block.Writef("  %s=%s", k, step.Env[k])
+		}
+	}
+
+	if step.ExitCode != -1 {
+		block.Writef("exit code: %d", step.ExitCode)
+	}
+}
+
+// ask writes the prompt and returns the trimmed answer. The answer is empty
+// once the input ends.
+func (t *triage) ask(prompt string) (string, error) {
+	fmt.Fprint(t.prompt, prompt)
+	answer, err := t.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", errors.Wrap(err, "reading answer")
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// rerunFailure returns the error of the task that failed again with err.
+func rerunFailure(previous executor.TaskExecutionErr, err error) executor.TaskExecutionErr {
+	for _, e := range flattenErrs(err) {
+		if taskErr, ok := e.(executor.TaskExecutionErr); ok {
+			return taskErr
+		}
+	}
+	previous.Err = err
+	return previous
+}
+
+func triageTaskName(task *executor.Task) string {
+	if task.Path != "" {
+		return task.Repository.Name + ":" + task.Path
+	}
+	return task.Repository.Name
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-multierror"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func TestTriage(t *testing.T) {
+	fixed := &executor.Task{Repository: &graphql.Repository{Name: "github.com/sourcegraph/fixed"}}
+	broken := &executor.Task{Repository: &graphql.Repository{Name: "github.com/sourcegraph/broken"}, Path: "docs"}
+	otherErr := errors.New("importing changesets failed")
+
+	var errs *multierror.Error
+	errs = multierror.Append(errs, executor.TaskExecutionErr{Err: errors.New("flaky"), Repository: fixed.Repository.Name, Task: fixed})
+	errs = multierror.Append(errs, executor.TaskExecutionErr{Err: errors.New("broken"), Repository: broken.Repository.Name, Task: broken})
+	errs = multierror.Append(errs, otherErr)
+
+	// Execute the first task again, which succeeds, then select a task that
+	// doesn't exist, execute the second task again, which fails again, and go
+	// back and continue.
+	input := strings.Join([]string{"1", "r", "3", "1", "r", "b", ""}, "\n")
+
+	var (
+		prompts bytes.Buffer
+		reruns  []*executor.Task
+	)
+	spec := &batcheslib.ChangesetSpec{BaseRepository: "fixed"}
+	tr := &triage{
+		out:    output.NewOutput(&bytes.Buffer{}, output.OutputOpts{}),
+		in:     bufio.NewReader(strings.NewReader(input)),
+		prompt: &prompts,
+		rerun: func(ctx context.Context, task *executor.Task) ([]*batcheslib.ChangesetSpec, error) {
+			reruns = append(reruns, task)
+			if task == fixed {
+				return []*batcheslib.ChangesetSpec{spec}, nil
+			}
+			return nil, executor.TaskExecutionErr{Err: errors.New("still broken"), Repository: task.Repository.Name, Task: task}
+		},
+	}
+
+	specs, err := tr.run(context.Background(), errs)
+
+	if diff := cmp.Diff([]*executor.Task{fixed, broken}, reruns); diff != "" {
+		t.Errorf("wrong tasks executed again (-want +have):\n%s", diff)
+	}
+	if len(specs) != 1 || specs[0] != spec {
+		t.Errorf("wrong specs: %+v", specs)
+	}
+
+	var remaining *multierror.Error
+	if !errors.As(err, &remaining) {
+		t.Fatalf("error isn't a multierror: %v", err)
+	}
+	if len(remaining.Errors) != 2 {
+		t.Fatalf("wrong number of remaining errors: %v", remaining.Errors)
+	}
+	if remaining.Errors[0] != otherErr {
+		t.Errorf("wrong first error: %v", remaining.Errors[0])
+	}
+	taskErr, ok := remaining.Errors[1].(executor.TaskExecutionErr)
+	if !ok || taskErr.Task != broken || taskErr.Err.Error() != "still broken" {
+		t.Errorf("wrong remaining task error: %v", remaining.Errors[1])
+	}
+
+	wantPrompts := "Inspect a task [1-2], or press Enter to continue: > " +
+		"Inspect a task [1-1], or press Enter to continue: " +
+		"Inspect a task [1-1], or press Enter to continue: > > " +
+		"Inspect a task [1-1], or press Enter to continue: "
+	if diff := cmp.Diff(wantPrompts, prompts.String()); diff != "" {
+		t.Errorf("wrong prompts (-want +have):\n%s", diff)
+	}
+}
+
+func TestTriage_NoTaskErrors(t *testing.T) {
+	want := errors.New("resolving repositories failed")
+	tr := &triage{
+		out:    output.NewOutput(&bytes.Buffer{}, output.OutputOpts{}),
+		in:     bufio.NewReader(strings.NewReader("")),
+		prompt: &bytes.Buffer{},
+		rerun: func(ctx context.Context, task *executor.Task) ([]*batcheslib.ChangesetSpec, error) {
+			t.Fatal("no task should be executed again")
+			return nil, nil
+		},
+	}
+
+	specs, err := tr.run(context.Background(), want)
+	if err != want {
+		t.Errorf("wrong error: %v", err)
+	}
+	if len(specs) != 0 {
+		t.Errorf("unexpected specs: %+v", specs)
+	}
+}
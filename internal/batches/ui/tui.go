@@ -1,12 +1,18 @@
 package ui
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/cockroachdb/errors"
 	"github.com/hashicorp/go-multierror"
+	"github.com/mattn/go-isatty"
 	"github.com/neelance/parallel"
 	"github.com/sourcegraph/sourcegraph/lib/output"
 
@@ -14,6 +20,7 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches"
 	"github.com/sourcegraph/src-cli/internal/batches/executor"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
 	"github.com/sourcegraph/src-cli/internal/batches/workspace"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
 )
@@ -28,6 +35,9 @@ var _ ExecUI = &TUI{}
 
 type TUI struct {
 	Out *output.Output
+	// StreamOutput makes the output of the steps be shown while they're
+	// executed.
+	StreamOutput bool
 
 	pending  output.Pending
 	progress output.Progress
@@ -72,6 +82,7 @@ func (ui *TUI) PreparingContainerImages() {
 }
 
 func (ui *TUI) PreparingContainerImagesProgress(done, total int) {
+	ui.progress.SetLabelAndRecalc(0, fmt.Sprintf("Preparing container images (%d/%d)", done, total))
 	ui.progress.SetValue(0, float64(done)/float64(total))
 }
 
@@ -89,9 +100,18 @@ func (ui *TUI) DeterminingWorkspaceCreatorTypeSuccess(wt workspace.CreatorType)
 		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: bind"))
 	case workspace.CreatorTypeVolume:
 		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: volume"))
+	case workspace.CreatorTypeNative:
+		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: native"))
 	}
 
 	batchCompletePending(ui.pending, "Set workspace type")
+
+	if wt == workspace.CreatorTypeNative {
+		block := ui.Out.Block(output.Line(output.EmojiWarning, output.StyleWarning, "Steps will be executed natively on this machine"))
+		block.Write("They are not isolated in containers: a step can read and modify any file that your user can access,")
+		block.Write("and the \"container\" of each step is ignored. Only the commands allowed by -native-allow-commands can be run.")
+		block.Close()
+	}
 }
 
 func (ui *TUI) ResolvingRepositories() {
@@ -146,6 +166,7 @@ func (ui *TUI) CheckingCacheSuccess(cachedSpecsFound int, uncachedTasks int) {
 
 func (ui *TUI) ExecutingTasks(verbose bool, parallelism int) executor.TaskExecutionUI {
 	ui.progressPrinter = newTaskExecTUI(ui.Out, verbose, parallelism)
+	ui.progressPrinter.streamOutput = ui.StreamOutput
 	return ui.progressPrinter
 }
 
@@ -163,6 +184,17 @@ func (ui *TUI) LogFilesKept(files []string) {
 	}
 }
 
+func (ui *TUI) WritingLogs(logDir, runID string) {
+	block := ui.Out.Block(output.Linef("", batchSuccessColor, "Writing the logs of run %s to %s. To follow them, run:", runID, filepath.Join(logDir, runID)))
+	defer block.Close()
+
+	block.Writef("src batch logs -log-dir %s -follow %s", logDir, runID)
+}
+
+func (ui *TUI) ServingDashboard(url string) {
+	ui.Out.WriteLine(output.Linef("", batchSuccessColor, "Serving the state of the execution at %s", url))
+}
+
 func (ui *TUI) NoChangesetSpecs() {
 	ui.Out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, `No changeset specs created`))
 }
@@ -188,6 +220,26 @@ func (ui *TUI) UploadingChangesetSpecsSuccess(ids []graphql.ChangesetSpecID) {
 	ui.progress.Complete()
 }
 
+func (ui *TUI) WritingChangesetSpecs(num int) {
+	ui.pending = batchCreatePending(ui.Out, "Writing changeset specs")
+}
+
+func (ui *TUI) WritingChangesetSpecsSuccess(dir string, num int) {
+	batchCompletePending(ui.pending, "Writing changeset specs")
+
+	ui.Out.Write("")
+	var label string
+	if num == 1 {
+		label = "Wrote the patch of 1 changeset and a manifest to:"
+	} else {
+		label = fmt.Sprintf("Wrote the patches of %d changesets and a manifest to:", num)
+	}
+	block := ui.Out.Block(output.Line(batchSuccessEmoji, batchSuccessColor, label))
+	defer block.Close()
+
+	block.Writef("%s", dir)
+}
+
 func (ui *TUI) CreatingBatchSpec() {
 	ui.pending = batchCreatePending(ui.Out, "Creating batch spec on Sourcegraph")
 }
@@ -200,6 +252,104 @@ func (ui *TUI) CreatingBatchSpecError(err error) error {
 	return prettyPrintBatchUnlicensedError(ui.Out, err)
 }
 
+func (ui *TUI) PreviewingApply() {
+	ui.pending = batchCreatePending(ui.Out, "Previewing changes to changesets")
+}
+
+func (ui *TUI) PreviewingApplyFailed(err error) {
+	ui.pending.Complete(output.Line(output.EmojiWarning, output.StyleWarning, "Previewing changes to changesets failed"))
+
+	ui.Out.Write("")
+	block := ui.Out.Block(output.Line(output.EmojiWarning, output.StyleWarning, "The changes to changesets can't be shown:"))
+	defer block.Close()
+
+	block.Writef("%s", err)
+}
+
+func (ui *TUI) PreviewingApplySuccess(previews []*service.ChangesetPreview, showDiffs bool) {
+	batchCompletePending(ui.pending, "Previewing changes to changesets")
+
+	ui.Out.Write("")
+	if len(previews) == 0 {
+		ui.Out.WriteLine(output.Line(output.EmojiInfo, output.StyleReset, "Applying the batch spec won't change any changesets."))
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, p := range previews {
+		counts[p.Action]++
+	}
+	var summary []string
+	for _, action := range graphql.PreviewActions {
+		if n := counts[action]; n > 0 {
+			summary = append(summary, fmt.Sprintf("%s %d", action, n))
+		}
+	}
+	var plural string
+	if len(previews) != 1 {
+		plural = "s"
+	}
+	block := ui.Out.Block(output.Linef(output.EmojiInfo, output.StyleBold, "Applying the batch spec affects %d changeset%s: %s", len(previews), plural, strings.Join(summary, ", ")))
+	defer block.Close()
+
+	maxAction := 0
+	for _, action := range graphql.PreviewActions {
+		if len(action) > maxAction {
+			maxAction = len(action)
+		}
+	}
+	for _, p := range previews {
+		name := p.Repository
+		if name == "" {
+			name = "(hidden repository)"
+		} else if p.ExternalID != "" {
+			name += "#" + p.ExternalID
+		}
+		line := fmt.Sprintf("%-*s  %s", maxAction, p.Action, name)
+		if p.Title != "" {
+			line += fmt.Sprintf(" %q", p.Title)
+		}
+		if len(p.Operations) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.ToLower(strings.Join(p.Operations, ", ")))
+		}
+		block.WriteLine(output.Line("", previewActionStyle(p.Action), line))
+
+		if showDiffs && p.Diff != "" {
+			for _, l := range strings.Split(strings.TrimSuffix(p.Diff, "\n"), "\n") {
+				block.Writef("    %s%s%s", diffLineStyle(l), l, output.StyleReset)
+			}
+		}
+	}
+}
+
+func previewActionStyle(action string) output.Style {
+	switch action {
+	case graphql.PreviewActionCreate, graphql.PreviewActionImport:
+		return output.StyleSuccess
+	case graphql.PreviewActionClose, graphql.PreviewActionDetach, graphql.PreviewActionArchive:
+		return output.StyleWarning
+	case graphql.PreviewActionUnchanged:
+		return output.StyleReset
+	default:
+		return output.StylePending
+	}
+}
+
+func diffLineStyle(line string) output.Style {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return output.StyleBold
+	case strings.HasPrefix(line, "+"):
+		return output.StyleLinesAdded
+	case strings.HasPrefix(line, "-"):
+		return output.StyleLinesDeleted
+	case strings.HasPrefix(line, "@@"):
+		return output.StyleSearchLink
+	default:
+		return output.StyleReset
+	}
+}
+
 func (ui *TUI) PreviewBatchSpec(batchSpecURL string) {
 	ui.Out.Write("")
 	block := ui.Out.Block(output.Line(batchSuccessEmoji, batchSuccessColor, "To preview or apply the batch spec, go to:"))
@@ -209,6 +359,49 @@ func (ui *TUI) PreviewBatchSpec(batchSpecURL string) {
 
 }
 
+func (ui *TUI) ConfirmApply(batchSpecURL string) (bool, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return false, errors.New("can't ask for confirmation, because stdin isn't a terminal: pass -yes to apply the batch spec without confirmation")
+	}
+
+	ui.Out.Write("")
+	ui.Out.Writef("The batch spec can also be previewed at %s", batchSpecURL)
+	// The prompt is written without a newline, so it doesn't go through Out.
+	fmt.Fprint(os.Stderr, "Apply the batch spec? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, errors.Wrap(err, "reading confirmation")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (ui *TUI) DebugShell(task *executor.Task, step int, script string, cmd *exec.Cmd) error {
+	ui.Out.Write("")
+	block := ui.Out.Block(output.Linef(output.EmojiWarning, output.StyleWarning, "Starting a shell in the container of step %d in %s%s%s.", step, output.StyleBold, triageTaskName(task), output.StyleReset))
+	block.Writef("The run script of the step is at %s. The workspace is cleaned up once the shell exits.", script)
+	block.Close()
+
+	// Nothing else is written to the terminal while the shell runs.
+	ui.Out.Lock()
+	defer ui.Out.Unlock()
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func (ui *TUI) ApplyingBatchSpecCancelled(batchSpecURL string) {
+	ui.Out.Write("")
+	block := ui.Out.Block(output.Line(output.EmojiWarning, output.StyleWarning, "The batch spec wasn't applied. To preview or apply it later, go to:"))
+	defer block.Close()
+
+	block.Writef("%s", batchSpecURL)
+}
+
 func (ui *TUI) ApplyingBatchSpec() {
 	ui.pending = batchCreatePending(ui.Out, "Applying batch spec")
 }
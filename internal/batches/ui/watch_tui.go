@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/output"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/watch"
+)
+
+// maxFailingStatusBars is the maximum number of failing changesets that the
+// WatchTUI shows at once.
+const maxFailingStatusBars = 5
+
+// WatchTUI shows the changesets of a batch change while they are watched: the
+// share of them that reached a state as progress bars, the number of
+// changesets in each state and the failing changesets as status bars, and the
+// transitions between polls as lines above them.
+type WatchTUI struct {
+	Out *output.Output
+
+	progress      output.ProgressWithStatusBars
+	numStatusBars int
+}
+
+// Update shows the changesets of the latest poll and the transitions since the
+// previous one.
+func (ui *WatchTUI) Update(changesets []*graphql.Changeset, transitions []watch.Transition) {
+	failing := watch.Failing(changesets)
+	numFailing := len(failing)
+	if numFailing > maxFailingStatusBars {
+		numFailing = maxFailingStatusBars
+	}
+
+	// The number of status bars can't be changed, so the progress is recreated
+	// when the number of failing changesets that are shown changes.
+	if ui.progress != nil && ui.numStatusBars != 1+numFailing {
+		ui.progress.Destroy()
+		ui.progress = nil
+	}
+	if ui.progress == nil {
+		ui.numStatusBars = 1 + numFailing
+		statusBars := make([]*output.StatusBar, 0, ui.numStatusBars)
+		for i := 0; i < ui.numStatusBars; i++ {
+			statusBars = append(statusBars, output.NewStatusBar())
+		}
+		bars := make([]output.ProgressBar, len(watchProgressBars))
+		for i, b := range watchProgressBars {
+			bars[i] = output.ProgressBar{Label: b.label, Max: 1.0}
+		}
+		ui.progress = ui.Out.ProgressWithStatusBars(bars, statusBars, nil)
+	}
+
+	now := time.Now().Format("15:04:05")
+	for _, t := range transitions {
+		ui.progress.WriteLine(transitionLine(now, t))
+	}
+
+	for i, b := range watchProgressBars {
+		done, total := b.count(changesets)
+		ui.progress.SetLabelAndRecalc(i, fmt.Sprintf("%s (%d/%d)", b.label, done, total))
+		value := 0.0
+		if total > 0 {
+			value = float64(done) / float64(total)
+		}
+		ui.progress.SetValue(i, value)
+	}
+
+	counts := watch.FormatCounts(watch.CountStates(changesets))
+	if counts == "" {
+		counts = "no changesets"
+	}
+	ui.progress.StatusBarResetf(0, "Changesets", "%s", counts)
+
+	for i, c := range failing[:numFailing] {
+		reason := "checks failed"
+		if c.State == "FAILED" {
+			reason = firstLine(c.Error)
+		}
+		if i == numFailing-1 && len(failing) > numFailing {
+			reason += fmt.Sprintf(" (and %d more failing)", len(failing)-numFailing)
+		}
+		ui.progress.StatusBarResetf(1+i, c.DisplayName(), "%s", reason)
+		ui.progress.StatusBarFailf(1+i, "%s", reason)
+	}
+}
+
+// Done stops updating the dashboard, which stays visible. It can be called
+// more than once.
+func (ui *WatchTUI) Done() {
+	if ui.progress != nil {
+		ui.progress.Close()
+		ui.progress = nil
+	}
+}
+
+var watchProgressBars = []struct {
+	label string
+	count func([]*graphql.Changeset) (done, total int)
+}{
+	{"Published", func(changesets []*graphql.Changeset) (int, int) {
+		return countChangesets(changesets, watch.Published), len(changesets)
+	}},
+	{"Checks passed", func(changesets []*graphql.Changeset) (int, int) {
+		return countChangesets(changesets, func(c *graphql.Changeset) bool { return c.CheckState == "PASSED" }),
+			countChangesets(changesets, func(c *graphql.Changeset) bool { return c.CheckState != "" })
+	}},
+	{"Approved", func(changesets []*graphql.Changeset) (int, int) {
+		return countChangesets(changesets, func(c *graphql.Changeset) bool { return c.ReviewState == "APPROVED" }),
+			countChangesets(changesets, func(c *graphql.Changeset) bool { return c.ReviewState != "" })
+	}},
+	{"Merged", func(changesets []*graphql.Changeset) (int, int) {
+		return countChangesets(changesets, func(c *graphql.Changeset) bool { return c.State == "MERGED" }), len(changesets)
+	}},
+}
+
+func countChangesets(changesets []*graphql.Changeset, match func(*graphql.Changeset) bool) int {
+	n := 0
+	for _, c := range changesets {
+		if match(c) {
+			n++
+		}
+	}
+	return n
+}
+
+func transitionLine(now string, t watch.Transition) output.FancyLine {
+	to := strings.ToLower(strings.ReplaceAll(t.To, "_", " "))
+	if t.From == "" {
+		return output.Linef("", output.StylePending, "%s %s: %s %s", now, t.Changeset.DisplayName(), t.Field, to)
+	}
+
+	style := output.StyleReset
+	switch t.To {
+	case "FAILED", "CHANGES_REQUESTED":
+		style = output.StyleWarning
+	case "MERGED", "PASSED", "APPROVED":
+		style = output.StyleSuccess
+	}
+	from := strings.ToLower(strings.ReplaceAll(t.From, "_", " "))
+	return output.Linef("", style, "%s %s: %s %s → %s", now, t.Changeset.DisplayName(), t.Field, from, to)
+}
+
+func firstLine(s string) string {
+	if s == "" {
+		return "failed"
+	}
+	return strings.SplitN(s, "\n", 2)[0]
+}
@@ -0,0 +1,158 @@
+// Package watch follows the changesets of a batch change over time: what
+// changed between two polls, which changesets are failing and whether a
+// condition that a user waits for is met.
+package watch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+// Transition is a change of a changeset between two polls.
+type Transition struct {
+	Changeset *graphql.Changeset
+	// Field is what changed: "state", "review" or "checks".
+	Field string
+	// From is empty for changesets that are new.
+	From string
+	To   string
+}
+
+// Diff returns the transitions between the changesets of two polls, in the
+// order of the changesets of the second poll. Changesets that are new are
+// reported as a transition of their state from "".
+func Diff(prev, next []*graphql.Changeset) []Transition {
+	previous := make(map[graphql.ChangesetID]*graphql.Changeset, len(prev))
+	for _, c := range prev {
+		previous[c.ID] = c
+	}
+
+	var transitions []Transition
+	for _, c := range next {
+		p, ok := previous[c.ID]
+		if !ok {
+			transitions = append(transitions, Transition{Changeset: c, Field: "state", To: c.State})
+			continue
+		}
+		for _, f := range []struct {
+			name     string
+			from, to string
+		}{
+			{"state", p.State, c.State},
+			{"review", p.ReviewState, c.ReviewState},
+			{"checks", p.CheckState, c.CheckState},
+		} {
+			if f.from != f.to {
+				transitions = append(transitions, Transition{Changeset: c, Field: f.name, From: f.from, To: f.to})
+			}
+		}
+	}
+	return transitions
+}
+
+// CountStates returns the number of changesets in each state.
+func CountStates(changesets []*graphql.Changeset) map[string]int {
+	counts := make(map[string]int)
+	for _, c := range changesets {
+		counts[c.State]++
+	}
+	return counts
+}
+
+// FormatCounts returns the counts of CountStates as text, like
+// "2 merged, 3 open", sorted by state.
+func FormatCounts(counts map[string]int) string {
+	states := make([]string, 0, len(counts))
+	for state := range counts {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	parts := make([]string, 0, len(states))
+	for _, state := range states {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[state], strings.ToLower(state)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Failing returns the changesets that failed to be published or whose checks
+// failed.
+func Failing(changesets []*graphql.Changeset) []*graphql.Changeset {
+	var failing []*graphql.Changeset
+	for _, c := range changesets {
+		if c.State == "FAILED" || c.CheckState == "FAILED" {
+			failing = append(failing, c)
+		}
+	}
+	return failing
+}
+
+// Published returns whether the changeset has been published to its code host.
+func Published(c *graphql.Changeset) bool {
+	switch c.State {
+	case "UNPUBLISHED", "SCHEDULED", "PROCESSING", "RETRYING", "FAILED":
+		return false
+	default:
+		return true
+	}
+}
+
+// Condition is a state of all changesets of a batch change that can be waited
+// for.
+type Condition string
+
+const (
+	AllPublished Condition = "all-published"
+	AllMerged    Condition = "all-merged"
+)
+
+// Conditions are all conditions, for usage messages.
+var Conditions = []Condition{AllPublished, AllMerged}
+
+// ParseCondition returns the condition with the given name.
+func ParseCondition(name string) (Condition, error) {
+	for _, c := range Conditions {
+		if string(c) == name {
+			return c, nil
+		}
+	}
+	return "", errors.Newf("unknown condition %q", name)
+}
+
+// ErrUnreachable is wrapped by the errors of Check for conditions that can no
+// longer be met.
+var ErrUnreachable = errors.New("the condition can no longer be met")
+
+// Check returns whether the condition is met by the changesets. It returns an
+// error wrapping ErrUnreachable if a changeset is in a state from which the
+// condition can't be met without intervention, like a changeset that failed
+// to be published.
+func (c Condition) Check(changesets []*graphql.Changeset) (bool, error) {
+	met := true
+	for _, cs := range changesets {
+		switch c {
+		case AllPublished:
+			if cs.State == "FAILED" {
+				return false, errors.Wrapf(ErrUnreachable, "%s failed to be published", cs.DisplayName())
+			}
+			if !Published(cs) {
+				met = false
+			}
+
+		case AllMerged:
+			switch cs.State {
+			case "MERGED":
+			case "FAILED", "CLOSED", "DELETED":
+				return false, errors.Wrapf(ErrUnreachable, "%s is %s", cs.DisplayName(), strings.ToLower(cs.State))
+			default:
+				met = false
+			}
+		}
+	}
+	return met, nil
+}
@@ -0,0 +1,99 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+)
+
+func changeset(id, state, review, checks string) *graphql.Changeset {
+	return &graphql.Changeset{
+		Typename:    "ExternalChangeset",
+		ID:          graphql.ChangesetID(id),
+		State:       state,
+		ReviewState: review,
+		CheckState:  checks,
+		Repository: &struct {
+			Name string `json:"name"`
+		}{Name: "github.com/sourcegraph/repo-" + id},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	prev := []*graphql.Changeset{
+		changeset("1", "PROCESSING", "", ""),
+		changeset("2", "OPEN", "PENDING", "PENDING"),
+		changeset("3", "OPEN", "APPROVED", "PASSED"),
+	}
+	next := []*graphql.Changeset{
+		changeset("1", "OPEN", "PENDING", ""),
+		changeset("2", "OPEN", "PENDING", "FAILED"),
+		changeset("3", "OPEN", "APPROVED", "PASSED"),
+		changeset("4", "SCHEDULED", "", ""),
+	}
+
+	type transition struct {
+		ID              graphql.ChangesetID
+		Field, From, To string
+	}
+	var have []transition
+	for _, tr := range Diff(prev, next) {
+		have = append(have, transition{tr.Changeset.ID, tr.Field, tr.From, tr.To})
+	}
+	want := []transition{
+		{"1", "state", "PROCESSING", "OPEN"},
+		{"1", "review", "", "PENDING"},
+		{"2", "checks", "PENDING", "FAILED"},
+		{"4", "state", "", "SCHEDULED"},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong transitions (-want +have):\n%s", diff)
+	}
+}
+
+func TestFormatCounts(t *testing.T) {
+	counts := CountStates([]*graphql.Changeset{
+		changeset("1", "OPEN", "", ""),
+		changeset("2", "MERGED", "", ""),
+		changeset("3", "OPEN", "", ""),
+	})
+	// Sorted by state, not by count.
+	if have, want := FormatCounts(counts), "1 merged, 2 open"; have != want {
+		t.Errorf("wrong counts: %q, want %q", have, want)
+	}
+}
+
+func TestCondition_Check(t *testing.T) {
+	for name, tt := range map[string]struct {
+		condition   Condition
+		states      []string
+		met         bool
+		unreachable bool
+	}{
+		"published":           {AllPublished, []string{"OPEN", "DRAFT", "MERGED"}, true, false},
+		"not yet published":   {AllPublished, []string{"OPEN", "PROCESSING"}, false, false},
+		"failed to publish":   {AllPublished, []string{"OPEN", "FAILED"}, false, true},
+		"no changesets":       {AllPublished, nil, true, false},
+		"merged":              {AllMerged, []string{"MERGED", "MERGED"}, true, false},
+		"not yet merged":      {AllMerged, []string{"MERGED", "OPEN"}, false, false},
+		"closed before merge": {AllMerged, []string{"OPEN", "CLOSED"}, false, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var changesets []*graphql.Changeset
+			for i, state := range tt.states {
+				changesets = append(changesets, changeset(string(rune('1'+i)), state, "", ""))
+			}
+
+			met, err := tt.condition.Check(changesets)
+			if met != tt.met {
+				t.Errorf("wrong met: %t", met)
+			}
+			if unreachable := errors.Is(err, ErrUnreachable); unreachable != tt.unreachable {
+				t.Errorf("wrong error: %v", err)
+			}
+		})
+	}
+}
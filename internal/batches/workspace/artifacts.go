@@ -0,0 +1,16 @@
+package workspace
+
+import "strings"
+
+// excludePatterns returns the lines of a gitignore file that exclude exactly
+// the given paths, relative to the root of the repository.
+func excludePatterns(paths []string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+
+	var b strings.Builder
+	for _, p := range paths {
+		// The leading slash anchors the pattern at the root.
+		b.WriteString("/" + escape.Replace(p) + "\n")
+	}
+	return b.String()
+}
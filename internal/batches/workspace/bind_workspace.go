@@ -28,8 +28,21 @@ var _ Creator = &dockerBindWorkspaceCreator{}
 
 func (wc *dockerBindWorkspaceCreator) Type() CreatorType { return CreatorTypeBind }
 
-func (wc *dockerBindWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, archive repozip.Archive) (Workspace, error) {
-	w, err := wc.unzipToWorkspace(ctx, repo, archive.Path())
+func (wc *dockerBindWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, paths []string, archive repozip.Archive) (Workspace, error) {
+	if worktree, ok := archive.(repozip.WorktreeArchive); ok {
+		if len(paths) > 0 {
+			return nil, errWorktreePaths
+		}
+		w, err := wc.addWorktree(ctx, repo, worktree)
+		return w, errors.Wrap(err, "adding worktree")
+	}
+
+	match, err := newPathMatcher(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := wc.unzipToWorkspace(ctx, repo, archive.Path(), match)
 	if err != nil {
 		return nil, errors.Wrap(err, "unzipping the repository")
 	}
@@ -57,9 +70,9 @@ func (*dockerBindWorkspaceCreator) prepareGitRepo(ctx context.Context, w *docker
 	return nil
 }
 
-func (wc *dockerBindWorkspaceCreator) unzipToWorkspace(ctx context.Context, repo *graphql.Repository, zip string) (*dockerBindWorkspace, error) {
+func (wc *dockerBindWorkspaceCreator) unzipToWorkspace(ctx context.Context, repo *graphql.Repository, zip string, match pathMatcher) (*dockerBindWorkspace, error) {
 	prefix := "workspace-" + util.SlugForRepo(repo.Name, repo.Rev())
-	workspace, err := unzipToTempDir(ctx, zip, wc.Dir, prefix)
+	workspace, err := unzipToTempDir(ctx, zip, wc.Dir, prefix, match)
 	if err != nil {
 		return nil, errors.Wrap(err, "unzipping the ZIP archive")
 	}
@@ -67,6 +80,28 @@ func (wc *dockerBindWorkspaceCreator) unzipToWorkspace(ctx context.Context, repo
 	return &dockerBindWorkspace{tempDir: wc.Dir, dir: workspace}, nil
 }
 
+// addWorktree creates the workspace as a worktree of the repository. It
+// already is a git repository, with the commit checked out.
+func (wc *dockerBindWorkspaceCreator) addWorktree(ctx context.Context, repo *graphql.Repository, archive repozip.WorktreeArchive) (*dockerBindWorkspace, error) {
+	prefix := "workspace-" + util.SlugForRepo(repo.Name, repo.Rev())
+	dir, err := os.MkdirTemp(wc.Dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	if err := archive.AddWorktree(ctx, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &dockerBindWorkspace{tempDir: wc.Dir, dir: dir, worktree: archive}, nil
+}
+
 func (wc *dockerBindWorkspaceCreator) copyToWorkspace(ctx context.Context, w *dockerBindWorkspace, files map[string]string) error {
 	for name, src := range files {
 		srcStat, err := os.Stat(src)
@@ -109,25 +144,43 @@ type dockerBindWorkspace struct {
 	tempDir string
 
 	dir string
+	// worktree is the repository the workspace is a worktree of, if it is
+	// one.
+	worktree repozip.WorktreeArchive
+	// excludesFile is the file with the patterns of the artifacts that are
+	// excluded from the diff, if there are any.
+	excludesFile string
 }
 
 var _ Workspace = &dockerBindWorkspace{}
 
 func (w *dockerBindWorkspace) Close(ctx context.Context) error {
+	if w.worktree != nil {
+		return w.worktree.RemoveWorktree(ctx, w.dir)
+	}
 	return os.RemoveAll(w.dir)
 }
 
 func (w *dockerBindWorkspace) DockerRunOpts(ctx context.Context, target string) ([]string, error) {
-	return []string{
+	opts := []string{
 		"--mount",
 		fmt.Sprintf("type=bind,source=%s,target=%s", w.dir, target),
-	}, nil
+	}
+	if w.worktree != nil {
+		// The .git file of a worktree points to the repository by its path,
+		// which has to be the same in the container for git to work there.
+		opts = append(opts,
+			"--mount",
+			fmt.Sprintf("type=bind,source=%s,target=%s", w.worktree.Path(), w.worktree.Path()),
+		)
+	}
+	return opts, nil
 }
 
 func (w *dockerBindWorkspace) WorkDir() *string { return &w.dir }
 
 func (w *dockerBindWorkspace) Changes(ctx context.Context) (*git.Changes, error) {
-	if _, err := runGitCmd(ctx, w.dir, "add", "--all"); err != nil {
+	if _, err := w.gitAdd(ctx); err != nil {
 		return nil, errors.Wrap(err, "git add failed")
 	}
 
@@ -158,31 +211,86 @@ func (w *dockerBindWorkspace) Diff(ctx context.Context) ([]byte, error) {
 
 func (w *dockerBindWorkspace) ApplyDiff(ctx context.Context, diff []byte) error {
 	// Write the diff to a temp file so we can pass it to `git apply`
-	tmp, err := os.CreateTemp(w.tempDir, "bind-workspace-test-*")
+	diffFile, cleanup, err := writeDiffFile(w.tempDir, "bind-workspace-test-*", diff)
 	if err != nil {
-		return errors.Wrap(err, "saving cached diff to temporary file")
-	}
-	defer os.Remove(tmp.Name())
-
-	if _, err := tmp.Write(diff); err != nil {
-		return errors.Wrap(err, "writing to temporary file")
-	}
-
-	if err := tmp.Close(); err != nil {
-		return errors.Wrap(err, "closing temporary file")
+		return err
 	}
+	defer cleanup()
 
 	// Apply diff
-	if _, err = runGitCmd(ctx, w.dir, "apply", "-p0", tmp.Name()); err != nil {
+	if _, err = runGitCmd(ctx, w.dir, "apply", "-p0", diffFile); err != nil {
 		return errors.Wrap(err, "applying cached diff")
 	}
 
 	// Add all files to index
-	_, err = runGitCmd(ctx, w.dir, "add", "--all")
+	_, err = w.gitAdd(ctx)
 	return err
 }
 
-func unzipToTempDir(ctx context.Context, zipFile, tempDir, tempFilePrefix string) (string, error) {
+func (w *dockerBindWorkspace) ExcludeArtifacts(ctx context.Context, paths []string) error {
+	// The excludes are kept in the git directory, which isn't shared with
+	// other worktrees of the same repository, unlike .git/info/exclude.
+	gitDir, err := runGitCmd(ctx, w.dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return err
+	}
+
+	excludesFile := filepath.Join(strings.TrimSpace(string(gitDir)), "src-batch-artifacts")
+	if err := os.WriteFile(excludesFile, []byte(excludePatterns(paths)), 0644); err != nil {
+		return errors.Wrap(err, "writing excludes file")
+	}
+	w.excludesFile = excludesFile
+	return nil
+}
+
+func (w *dockerBindWorkspace) CopyArtifacts(ctx context.Context, paths []string, dest string) ([]string, error) {
+	var copied []string
+	for _, p := range paths {
+		src := filepath.Join(w.dir, filepath.FromSlash(p))
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := util.CopyTree(src, filepath.Join(dest, filepath.FromSlash(p))); err != nil {
+			return copied, errors.Wrapf(err, "copying artifact %q", p)
+		}
+		copied = append(copied, p)
+	}
+	return copied, nil
+}
+
+// gitAdd adds all files in the workspace to the index, except for the
+// excluded artifacts.
+func (w *dockerBindWorkspace) gitAdd(ctx context.Context) ([]byte, error) {
+	if w.excludesFile != "" {
+		return runGitCmd(ctx, w.dir, "-c", "core.excludesFile="+w.excludesFile, "add", "--all")
+	}
+	return runGitCmd(ctx, w.dir, "add", "--all")
+}
+
+// writeDiffFile writes the given diff to a temporary file, so that it can be
+// passed to `git apply`. The returned function removes the file again.
+func writeDiffFile(tempDir, pattern string, diff []byte) (string, func(), error) {
+	tmp, err := os.CreateTemp(tempDir, pattern)
+	if err != nil {
+		return "", func() {}, errors.Wrap(err, "saving diff to temporary file")
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	if _, err := tmp.Write(diff); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", func() {}, errors.Wrap(err, "writing to temporary file")
+	}
+
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", func() {}, errors.Wrap(err, "closing temporary file")
+	}
+
+	return tmp.Name(), cleanup, nil
+}
+
+func unzipToTempDir(ctx context.Context, zipFile, tempDir, tempFilePrefix string, match pathMatcher) (string, error) {
 	volumeDir, err := os.MkdirTemp(tempDir, tempFilePrefix)
 	if err != nil {
 		return "", err
@@ -192,10 +300,12 @@ func unzipToTempDir(ctx context.Context, zipFile, tempDir, tempFilePrefix string
 		return "", err
 	}
 
-	return volumeDir, unzip(ctx, zipFile, volumeDir)
+	return volumeDir, unzip(ctx, zipFile, volumeDir, match)
 }
 
-func unzip(ctx context.Context, zipFile, dest string) error {
+// unzip extracts the files in zipFile to dest. If match isn't nil, only the
+// files it matches are extracted.
+func unzip(ctx context.Context, zipFile, dest string, match pathMatcher) error {
 	r, err := zip.OpenReader(zipFile)
 	if err != nil {
 		return err
@@ -211,6 +321,11 @@ func unzip(ctx context.Context, zipFile, dest string) error {
 		default:
 		}
 
+		if match != nil && (f.FileInfo().IsDir() || !match(f.Name)) {
+			// The directories of the files that match are created for them.
+			continue
+		}
+
 		fpath := filepath.Join(dest, f.Name)
 
 		// Check for ZipSlip. More Info: https://snyk.io/research/zip-slip-vulnerability#go
@@ -89,7 +89,7 @@ func TestDockerBindWorkspaceCreator_Create(t *testing.T) {
 
 		archive := &fakeRepoArchive{mockPath: archivePath}
 		creator := &dockerBindWorkspaceCreator{Dir: testTempDir}
-		workspace, err := creator.Create(context.Background(), repo, nil, archive)
+		workspace, err := creator.Create(context.Background(), repo, nil, nil, archive)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -119,7 +119,7 @@ func TestDockerBindWorkspaceCreator_Create(t *testing.T) {
 		badArchive := &fakeRepoArchive{mockPath: badZipFile}
 
 		creator := &dockerBindWorkspaceCreator{Dir: testTempDir}
-		if _, err := creator.Create(context.Background(), repo, nil, badArchive); err == nil {
+		if _, err := creator.Create(context.Background(), repo, nil, nil, badArchive); err == nil {
 			t.Error("unexpected nil error")
 		}
 	})
@@ -133,7 +133,7 @@ func TestDockerBindWorkspaceCreator_Create(t *testing.T) {
 		}
 
 		creator := &dockerBindWorkspaceCreator{Dir: testTempDir}
-		workspace, err := creator.Create(context.Background(), repo, nil, archive)
+		workspace, err := creator.Create(context.Background(), repo, nil, nil, archive)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -156,6 +156,73 @@ func TestDockerBindWorkspaceCreator_Create(t *testing.T) {
 	})
 }
 
+func TestDockerBindWorkspaceCreator_Worktree(t *testing.T) {
+	ctx := context.Background()
+
+	// A repository with a single commit, of which the worktree is created.
+	repoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("# Welcome to the README\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "README.md"},
+		{"commit", "--quiet", "-m", "initial commit"},
+	} {
+		if _, err := runGitCmd(ctx, repoDir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := runGitCmd(ctx, repoDir, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := &fakeWorktreeArchive{repo: filepath.Join(repoDir, ".git"), commit: strings.TrimSpace(string(out))}
+
+	creator := &dockerBindWorkspaceCreator{Dir: workspaceTmpDir(t)}
+
+	// Worktrees have all files, so they can't be restricted to paths.
+	if _, err := creator.Create(ctx, repo, nil, []string{"*.md"}, archive); err != errWorktreePaths {
+		t.Fatalf("wrong error for paths. want=%q, have=%v", errWorktreePaths, err)
+	}
+
+	w, err := creator.Create(ctx, repo, nil, nil, archive)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	dir := *w.WorkDir()
+
+	opts, err := w.DockerRunOpts(ctx, "/work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOpts := []string{
+		"--mount", "type=bind,source=" + dir + ",target=/work",
+		"--mount", "type=bind,source=" + archive.repo + ",target=" + archive.repo,
+	}
+	if diff := cmp.Diff(wantOpts, opts); diff != "" {
+		t.Errorf("wrong docker run opts (-want +have):\n%s", diff)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Changed\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := w.Changes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"README.md"}, changes.Modified); diff != "" {
+		t.Errorf("wrong changes (-want +have):\n%s", diff)
+	}
+
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("workspace not removed: %v", err)
+	}
+}
+
 func TestDockerBindWorkspace_ApplyDiff(t *testing.T) {
 	// Create a zip file for all the other tests to use.
 	fakeFilesTmpDir := workspaceTmpDir(t)
@@ -191,7 +258,7 @@ index 0000000..7bb2542
 
 		archive := &fakeRepoArchive{mockPath: archivePath}
 		creator := &dockerBindWorkspaceCreator{Dir: testTempDir}
-		workspace, err := creator.Create(context.Background(), repo, nil, archive)
+		workspace, err := creator.Create(context.Background(), repo, nil, nil, archive)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -218,7 +285,7 @@ index 0000000..7bb2542
 
 		archive := &fakeRepoArchive{mockPath: archivePath}
 		creator := &dockerBindWorkspaceCreator{Dir: testTempDir}
-		workspace, err := creator.Create(context.Background(), repo, nil, archive)
+		workspace, err := creator.Create(context.Background(), repo, nil, nil, archive)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -230,6 +297,64 @@ index 0000000..7bb2542
 	})
 }
 
+func TestDockerBindWorkspace_Artifacts(t *testing.T) {
+	ctx := context.Background()
+	dir := workspaceTmpDir(t)
+	archive := &fakeRepoArchive{mockPath: zipUpFiles(t, dir, map[string]string{"README.md": "# Welcome\n"})}
+
+	creator := &dockerBindWorkspaceCreator{Dir: dir}
+	w, err := creator.Create(ctx, repo, nil, nil, archive)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer w.Close(ctx)
+
+	if err := w.ExcludeArtifacts(ctx, []string{"report.sarif", "out"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The step produces the artifacts and changes a file.
+	workDir := *w.WorkDir()
+	for name, content := range map[string]string{
+		"README.md":     "# Hello\n",
+		"report.sarif":  "{}\n",
+		"out/index.txt": "index\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(workDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := w.Changes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"README.md"}, changes.Modified); diff != "" || len(changes.Added) != 0 {
+		t.Errorf("artifacts in changes: %+v", changes)
+	}
+
+	dest := filepath.Join(t.TempDir(), "artifacts")
+	copied, err := w.CopyArtifacts(ctx, []string{"report.sarif", "out", "missing.json"}, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"report.sarif", "out"}, copied); diff != "" {
+		t.Errorf("wrong artifacts copied (-want +have):\n%s", diff)
+	}
+	for name, want := range map[string]string{"report.sarif": "{}\n", "out/index.txt": "index\n"} {
+		have, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(have) != want {
+			t.Errorf("wrong content of %s: %q", name, have)
+		}
+	}
+}
+
 func TestMkdirAll(t *testing.T) {
 	// TestEnsureAll does most of the heavy lifting here; we're just testing the
 	// MkdirAll scenarios here around whether the directory exists.
@@ -411,3 +536,25 @@ func (f *fakeRepoArchive) AdditionalFilePaths() map[string]string {
 	}
 	return map[string]string{}
 }
+
+var _ repozip.WorktreeArchive = &fakeWorktreeArchive{}
+
+type fakeWorktreeArchive struct {
+	fakeRepoArchive
+
+	repo   string
+	commit string
+}
+
+func (f *fakeWorktreeArchive) Path() string   { return f.repo }
+func (f *fakeWorktreeArchive) Commit() string { return f.commit }
+
+func (f *fakeWorktreeArchive) AddWorktree(ctx context.Context, dir string) error {
+	_, err := runGitCmd(ctx, f.repo, "worktree", "add", "--quiet", "--detach", dir, f.commit)
+	return err
+}
+
+func (f *fakeWorktreeArchive) RemoveWorktree(ctx context.Context, dir string) error {
+	_, err := runGitCmd(ctx, f.repo, "worktree", "remove", "--force", dir)
+	return err
+}
@@ -0,0 +1,164 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+)
+
+// LocalCommit describes the commit that ApplyToLocalClone creates.
+type LocalCommit struct {
+	// Branch is the branch that's created for the commit.
+	Branch string
+	// BaseRev is the revision the diff was created against. If it exists in
+	// the clone, the branch is created from it, otherwise from HEAD.
+	BaseRev string
+
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+}
+
+// ApplyConflictError is returned by ApplyToLocalClone if the diff doesn't
+// apply cleanly to the local clone.
+type ApplyConflictError struct {
+	Branch string
+	Output string
+}
+
+func (e *ApplyConflictError) Error() string {
+	return fmt.Sprintf("the diff does not apply cleanly on branch %q:\n%s", e.Branch, strings.TrimSpace(e.Output))
+}
+
+// ApplyToLocalClone applies the given diff to the git repository in dir, on
+// the new branch commit.Branch, and commits it. The working tree of the
+// repository has to be clean.
+//
+// Contrary to the workspaces, it uses the git configuration of the user, so
+// that the committer is the user.
+//
+// If the diff doesn't apply, an *ApplyConflictError is returned and the
+// repository is left as it was: the previously checked out branch is checked
+// out again and the new branch is deleted. If that fails too, the returned
+// error says so.
+func ApplyToLocalClone(ctx context.Context, dir, tempDir string, diff []byte, commit LocalCommit) (err error) {
+	if commit.Branch == "" {
+		return errors.New("no branch given")
+	}
+
+	status, err := runLocalGitCmd(ctx, dir, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(status))) != 0 {
+		return errors.Newf("the working tree of %s has uncommitted changes, commit or stash them first", dir)
+	}
+
+	if _, err := runLocalGitCmd(ctx, dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+commit.Branch); err == nil {
+		return errors.Newf("the branch %q already exists in %s", commit.Branch, dir)
+	}
+
+	// We need to be able to go back to what's checked out now.
+	previous, err := runLocalGitCmd(ctx, dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		// Detached HEAD.
+		if previous, err = runLocalGitCmd(ctx, dir, "rev-parse", "HEAD"); err != nil {
+			return err
+		}
+	}
+
+	startPoint := "HEAD"
+	if commit.BaseRev != "" {
+		if _, err := runLocalGitCmd(ctx, dir, "cat-file", "-e", commit.BaseRev+"^{commit}"); err == nil {
+			startPoint = commit.BaseRev
+		}
+	}
+
+	diffFile, cleanup, err := writeDiffFile(tempDir, "local-clone-diff-*", diff)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if _, err := runLocalGitCmd(ctx, dir, "checkout", "--quiet", "-b", commit.Branch, startPoint); err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Whatever went wrong, we don't want to leave a half-applied tree
+		// behind.
+		if rollbackErr := rollbackLocalClone(dir, strings.TrimSpace(string(previous)), commit.Branch); rollbackErr != nil {
+			err = errors.Wrapf(err, "restoring %s failed, the branch %q may still be checked out: %s", dir, commit.Branch, rollbackErr)
+		}
+	}()
+
+	// The diffs produced by steps don't have a/ and b/ prefixes.
+	if out, err := runLocalGitCmdOutput(ctx, dir, "apply", "--check", "-p0", diffFile); err != nil {
+		return &ApplyConflictError{Branch: commit.Branch, Output: string(out)}
+	}
+
+	if _, err := runLocalGitCmd(ctx, dir, "apply", "--index", "-p0", diffFile); err != nil {
+		return errors.Wrap(err, "applying diff")
+	}
+
+	args := []string{"commit", "--quiet", "-m", commit.Message}
+	if commit.AuthorName != "" || commit.AuthorEmail != "" {
+		args = append(args, "--author", fmt.Sprintf("%s <%s>", commit.AuthorName, commit.AuthorEmail))
+	}
+	if _, err := runLocalGitCmd(ctx, dir, args...); err != nil {
+		return errors.Wrap(err, "committing diff")
+	}
+
+	return nil
+}
+
+// localCloneRollbackTimeout is how long rolling back a failed
+// ApplyToLocalClone may take.
+const localCloneRollbackTimeout = 30 * time.Second
+
+// rollbackLocalClone checks out previous again and deletes branch, with
+// everything that was applied to it.
+//
+// It doesn't use the context of ApplyToLocalClone, since that's usually why
+// it failed when the user interrupted it, and the rollback has to happen
+// anyway.
+func rollbackLocalClone(dir, previous, branch string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), localCloneRollbackTimeout)
+	defer cancel()
+
+	var errs *multierror.Error
+	if _, err := runLocalGitCmd(ctx, dir, "reset", "--quiet", "--hard"); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if _, err := runLocalGitCmd(ctx, dir, "checkout", "--quiet", previous); err != nil {
+		// There's no point in deleting the branch that's still checked out.
+		return multierror.Append(errs, err)
+	}
+	if _, err := runLocalGitCmd(ctx, dir, "branch", "--quiet", "-D", branch); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	return errs.ErrorOrNil()
+}
+
+// runLocalGitCmd is like runGitCmd, but it uses the environment and thus the
+// git configuration of the user.
+func runLocalGitCmd(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	out, err := runLocalGitCmdOutput(ctx, dir, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "'git %s' failed: %s", strings.Join(args, " "), out)
+	}
+	return out, nil
+}
+
+func runLocalGitCmdOutput(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,198 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyToLocalClone(t *testing.T) {
+	ctx := context.Background()
+
+	// The commits are created with the configuration of the user, so we need
+	// to make sure there is one.
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_COMMITTER_NAME", "Committer")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+	t.Setenv("GIT_AUTHOR_NAME", "Committer")
+	t.Setenv("GIT_AUTHOR_EMAIL", "committer@example.com")
+
+	git := func(t *testing.T, dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	newClone := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		git(t, dir, "init", "--quiet")
+		git(t, dir, "checkout", "--quiet", "-b", "main")
+		if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Welcome to the README\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "add", "README.md")
+		git(t, dir, "commit", "--quiet", "-m", "Initial commit")
+		return dir
+	}
+
+	diff := []byte(`diff --git README.md README.md
+index 02a19af..a84667f 100644
+--- README.md
++++ README.md
+@@ -1 +1,3 @@
+ # Welcome to the README
++
++This is a new line
+diff --git new-file.txt new-file.txt
+new file mode 100644
+index 0000000..7bb2542
+--- /dev/null
++++ new-file.txt
+@@ -0,0 +1 @@
++check this out. this is a new file.
+`)
+
+	commit := LocalCommit{
+		Branch:      "batch/hello-world",
+		Message:     "Add a new line",
+		AuthorName:  "Mary McButtons",
+		AuthorEmail: "mary@example.com",
+	}
+
+	t.Run("success", func(t *testing.T) {
+		dir := newClone(t)
+
+		if err := ApplyToLocalClone(ctx, dir, t.TempDir(), diff, commit); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if have, want := git(t, dir, "rev-parse", "--abbrev-ref", "HEAD"), commit.Branch; have != want {
+			t.Errorf("wrong branch checked out. want=%q, have=%q", want, have)
+		}
+		if have, want := git(t, dir, "log", "-1", "--format=%s|%an|%ae"), "Add a new line|Mary McButtons|mary@example.com"; have != want {
+			t.Errorf("wrong commit. want=%q, have=%q", want, have)
+		}
+		if have := git(t, dir, "status", "--porcelain"); have != "" {
+			t.Errorf("working tree not clean:\n%s", have)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, "new-file.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := string(content), "check this out. this is a new file.\n"; have != want {
+			t.Errorf("wrong content. want=%q, have=%q", want, have)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		dir := newClone(t)
+		if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Something else entirely\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "commit", "--quiet", "-am", "Change README")
+		head := git(t, dir, "rev-parse", "HEAD")
+
+		err := ApplyToLocalClone(ctx, dir, t.TempDir(), diff, commit)
+		if _, ok := err.(*ApplyConflictError); !ok {
+			t.Fatalf("wrong error. want=*ApplyConflictError, have=%T: %v", err, err)
+		}
+
+		// Nothing should have changed.
+		if have, want := git(t, dir, "rev-parse", "--abbrev-ref", "HEAD"), "main"; have != want {
+			t.Errorf("wrong branch checked out. want=%q, have=%q", want, have)
+		}
+		if have := git(t, dir, "rev-parse", "HEAD"); have != head {
+			t.Errorf("HEAD changed. want=%q, have=%q", head, have)
+		}
+		if have := git(t, dir, "branch", "--list", commit.Branch); have != "" {
+			t.Errorf("branch wasn't deleted: %q", have)
+		}
+		if have := git(t, dir, "status", "--porcelain"); have != "" {
+			t.Errorf("working tree not clean:\n%s", have)
+		}
+	})
+
+	t.Run("uncommitted changes", func(t *testing.T) {
+		dir := newClone(t)
+		if err := os.WriteFile(filepath.Join(dir, "dirty.txt"), []byte("dirty\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := ApplyToLocalClone(ctx, dir, t.TempDir(), diff, commit)
+		if err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+			t.Fatalf("wrong error: %v", err)
+		}
+	})
+
+	t.Run("branch exists", func(t *testing.T) {
+		dir := newClone(t)
+		git(t, dir, "branch", commit.Branch)
+
+		err := ApplyToLocalClone(ctx, dir, t.TempDir(), diff, commit)
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("wrong error: %v", err)
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		dir := newClone(t)
+
+		// The hook makes the commit fail and cancels the context, like the
+		// user interrupting src while the commit is created.
+		marker := filepath.Join(t.TempDir(), "cancelled")
+		hook := "#!/bin/sh\ntouch " + marker + "\nexit 1\n"
+		if err := os.WriteFile(filepath.Join(dir, ".git", "hooks", "pre-commit"), []byte(hook), 0755); err != nil {
+			t.Fatal(err)
+		}
+		ctx := &cancelledAfterFile{Context: ctx, path: marker}
+
+		if err := ApplyToLocalClone(ctx, dir, t.TempDir(), diff, commit); err == nil {
+			t.Fatal("unexpectedly no error")
+		} else if strings.Contains(err.Error(), "restoring") {
+			t.Fatalf("rolling back failed: %s", err)
+		}
+
+		if have, want := git(t, dir, "rev-parse", "--abbrev-ref", "HEAD"), "main"; have != want {
+			t.Errorf("wrong branch checked out. want=%q, have=%q", want, have)
+		}
+		if have := git(t, dir, "branch", "--list", commit.Branch); have != "" {
+			t.Errorf("branch wasn't deleted: %q", have)
+		}
+		if have := git(t, dir, "status", "--porcelain"); have != "" {
+			t.Errorf("working tree not clean:\n%s", have)
+		}
+	})
+}
+
+// cancelledAfterFile is a context that's cancelled once the file at path
+// exists.
+type cancelledAfterFile struct {
+	context.Context
+	path string
+}
+
+func (c *cancelledAfterFile) Done() <-chan struct{} {
+	if _, err := os.Stat(c.path); err == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return c.Context.Done()
+}
+
+func (c *cancelledAfterFile) Err() error {
+	if _, err := os.Stat(c.path); err == nil {
+		return context.Canceled
+	}
+	return c.Context.Err()
+}
@@ -0,0 +1,406 @@
+package workspace
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+)
+
+// NativeCreator is a Creator for workspaces in which the steps are executed
+// directly on the host, without a container around them.
+type NativeCreator interface {
+	Creator
+
+	// CheckScript returns an error if the given rendered run script invokes a
+	// command that isn't allowed to run on the host.
+	CheckScript(script string) error
+}
+
+// NewNativeCreator returns a NativeCreator that creates its workspaces in
+// cacheDir and only allows the given commands, plus a small set of harmless
+// shell builtins, to be run by steps.
+func NewNativeCreator(cacheDir string, allowedCommands []string) NativeCreator {
+	allowed := make(map[string]struct{}, len(allowedCommands)+len(nativeBuiltins))
+	for _, c := range nativeBuiltins {
+		allowed[c] = struct{}{}
+	}
+	for _, c := range allowedCommands {
+		if c = strings.TrimSpace(c); c != "" {
+			allowed[c] = struct{}{}
+		}
+	}
+
+	return &nativeWorkspaceCreator{
+		bind:    &dockerBindWorkspaceCreator{Dir: cacheDir},
+		allowed: allowed,
+	}
+}
+
+// nativeBuiltins are the shell builtins that steps are always allowed to use,
+// since they can't be used to run other programs.
+var nativeBuiltins = []string{
+	":", "[", "[[", "cd", "echo", "exit", "export", "false", "local", "printf",
+	"pwd", "read", "set", "shift", "test", "true", "unset",
+}
+
+// nativeWorkspaceCreator creates the same workspaces as the bind creator: the
+// repository is unzipped into a directory on the host, which is then used as
+// the working directory of the steps.
+type nativeWorkspaceCreator struct {
+	bind    *dockerBindWorkspaceCreator
+	allowed map[string]struct{}
+}
+
+var _ NativeCreator = &nativeWorkspaceCreator{}
+
+func (wc *nativeWorkspaceCreator) Type() CreatorType { return CreatorTypeNative }
+
+func (wc *nativeWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, paths []string, archive repozip.Archive) (Workspace, error) {
+	return wc.bind.Create(ctx, repo, steps, paths, archive)
+}
+
+func (wc *nativeWorkspaceCreator) CheckScript(script string) error {
+	commands, err := scriptCommands(script)
+	if err != nil {
+		return errors.Wrap(err, "parsing run script")
+	}
+
+	var denied []string
+	seen := make(map[string]struct{})
+	for _, c := range commands {
+		if _, ok := wc.allowed[c]; ok {
+			continue
+		}
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		denied = append(denied, c)
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+
+	sort.Strings(denied)
+	return errors.Newf(
+		"the run script uses commands that are not allowed in native workspace mode: %s (allow them with -native-allow-commands)",
+		strings.Join(denied, ", "),
+	)
+}
+
+// scriptCommands returns the names of the commands that the given shell script
+// invokes, including the ones in command substitutions.
+//
+// This is a best-effort parser that understands enough of the POSIX shell
+// grammar to find the first word of every simple command: quoting, comments,
+// command separators, pipes, subshells and the common compound commands. It's
+// not a sandbox: an allowed command such as sh, eval or xargs can itself be
+// used to run arbitrary programs.
+func scriptCommands(script string) ([]string, error) {
+	p := &scriptParser{src: []rune(script)}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.commands, nil
+}
+
+type scriptParser struct {
+	src []rune
+	pos int
+
+	// words is the list of words in the current simple command.
+	words []string
+	// word is the word that's currently being read.
+	word strings.Builder
+	// inWord is true if word has been started, even if it's still empty,
+	// which is the case for "".
+	inWord bool
+	// depth is the number of currently open subshells.
+	depth int
+
+	commands []string
+}
+
+func (p *scriptParser) parse() error {
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '\\':
+			p.pos++
+			if p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.addRune(p.src[p.pos])
+			}
+			p.pos++
+
+		case r == '\'':
+			end := p.indexFrom(p.pos+1, '\'')
+			if end < 0 {
+				return errors.New("unterminated single quote")
+			}
+			p.addString(string(p.src[p.pos+1 : end]))
+			p.pos = end + 1
+
+		case r == '"':
+			if err := p.readDoubleQuoted(); err != nil {
+				return err
+			}
+
+		case r == '`':
+			end := p.indexFrom(p.pos+1, '`')
+			if end < 0 {
+				return errors.New("unterminated backtick")
+			}
+			if err := p.substitution(string(p.src[p.pos+1 : end])); err != nil {
+				return err
+			}
+			p.inWord = true
+			p.pos = end + 1
+
+		case r == '$' && p.peek(1) == '(':
+			if p.peek(2) == '(' {
+				// Arithmetic expansion doesn't run any commands.
+				end, err := p.matchingParen(p.pos + 1)
+				if err != nil {
+					return err
+				}
+				p.pos = end + 1
+			} else {
+				end, err := p.matchingParen(p.pos + 1)
+				if err != nil {
+					return err
+				}
+				if err := p.substitution(string(p.src[p.pos+2 : end])); err != nil {
+					return err
+				}
+				p.pos = end + 1
+			}
+			p.inWord = true
+
+		case r == '#' && !p.inWord:
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+
+		case r == ' ' || r == '\t':
+			p.endWord()
+			p.pos++
+
+		case r == '\n' || r == ';' || r == '|':
+			p.endCommand()
+			p.pos++
+
+		case r == '&':
+			// `>&2` and `&>` are redirections, not separators.
+			if p.pos > 0 && (p.src[p.pos-1] == '>' || p.src[p.pos-1] == '<') || p.peek(1) == '>' {
+				p.addRune(r)
+			} else {
+				p.endCommand()
+			}
+			p.pos++
+
+		case r == '(':
+			p.endCommand()
+			p.depth++
+			p.pos++
+
+		case r == ')':
+			if p.depth == 0 {
+				// A closing paren without an opening one is a pattern in a
+				// case statement.
+				p.endWord()
+				p.words = nil
+			} else {
+				p.depth--
+				p.endCommand()
+			}
+			p.pos++
+
+		default:
+			p.addRune(r)
+			p.pos++
+		}
+	}
+	p.endCommand()
+
+	return nil
+}
+
+func (p *scriptParser) readDoubleQuoted() error {
+	p.inWord = true
+	p.pos++
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '"':
+			p.pos++
+			return nil
+
+		case r == '\\':
+			p.pos++
+			if p.pos < len(p.src) {
+				p.addRune(p.src[p.pos])
+			}
+			p.pos++
+
+		case r == '`':
+			end := p.indexFrom(p.pos+1, '`')
+			if end < 0 {
+				return errors.New("unterminated backtick")
+			}
+			if err := p.substitution(string(p.src[p.pos+1 : end])); err != nil {
+				return err
+			}
+			p.pos = end + 1
+
+		case r == '$' && p.peek(1) == '(':
+			end, err := p.matchingParen(p.pos + 1)
+			if err != nil {
+				return err
+			}
+			if p.peek(2) != '(' {
+				if err := p.substitution(string(p.src[p.pos+2 : end])); err != nil {
+					return err
+				}
+			}
+			p.pos = end + 1
+
+		default:
+			p.addRune(r)
+			p.pos++
+		}
+	}
+
+	return errors.New("unterminated double quote")
+}
+
+func (p *scriptParser) substitution(script string) error {
+	commands, err := scriptCommands(script)
+	if err != nil {
+		return err
+	}
+	p.commands = append(p.commands, commands...)
+	return nil
+}
+
+// matchingParen returns the index of the paren that closes the one at open.
+func (p *scriptParser) matchingParen(open int) (int, error) {
+	depth := 0
+	for i := open; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("unterminated command substitution")
+}
+
+func (p *scriptParser) indexFrom(start int, r rune) int {
+	for i := start; i < len(p.src); i++ {
+		if p.src[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *scriptParser) peek(offset int) rune {
+	if p.pos+offset < len(p.src) {
+		return p.src[p.pos+offset]
+	}
+	return 0
+}
+
+func (p *scriptParser) addRune(r rune) {
+	p.word.WriteRune(r)
+	p.inWord = true
+}
+
+func (p *scriptParser) addString(s string) {
+	p.word.WriteString(s)
+	p.inWord = true
+}
+
+func (p *scriptParser) endWord() {
+	if p.inWord {
+		p.words = append(p.words, p.word.String())
+	}
+	p.word.Reset()
+	p.inWord = false
+}
+
+func (p *scriptParser) endCommand() {
+	p.endWord()
+	if c := commandName(p.words); c != "" {
+		p.commands = append(p.commands, c)
+	}
+	p.words = nil
+}
+
+// commandName returns the name of the command invoked by the given simple
+// command, or an empty string if it doesn't invoke one.
+func commandName(words []string) string {
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch w {
+		case "if", "then", "else", "elif", "do", "while", "until", "!", "time", "{", "}":
+			// Reserved words that are followed by a command.
+			continue
+		case "fi", "done", "esac", "in", ";;":
+			return ""
+		case "for", "case", "select", "function":
+			// The rest of these don't run a command.
+			return ""
+		}
+
+		if isAssignment(w) {
+			continue
+		}
+		if strings.HasSuffix(w, "()") {
+			// Function definition.
+			return ""
+		}
+		if isRedirection(w) {
+			// A redirection operator on its own is followed by its target.
+			if strings.HasSuffix(w, "<") || strings.HasSuffix(w, ">") {
+				i++
+			}
+			continue
+		}
+
+		return w
+	}
+
+	return ""
+}
+
+func isRedirection(word string) bool {
+	w := strings.TrimLeft(word, "0123456789&")
+	return strings.HasPrefix(w, "<") || strings.HasPrefix(w, ">")
+}
+
+func isAssignment(word string) bool {
+	i := strings.IndexByte(word, '=')
+	if i <= 0 {
+		return false
+	}
+	for j, r := range word[:i] {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (j > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
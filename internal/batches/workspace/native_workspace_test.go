@@ -0,0 +1,99 @@
+package workspace
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScriptCommands(t *testing.T) {
+	for name, tc := range map[string]struct {
+		script string
+		want   []string
+	}{
+		"single command": {
+			script: `sed -i 's/foo/bar/' main.go`,
+			want:   []string{"sed"},
+		},
+		"separators": {
+			script: "echo hi; comby -in-place 'a' 'b' .go && gofmt -w . || exit 1\ngrep -r foo | wc -l",
+			want:   []string{"echo", "comby", "gofmt", "exit", "grep", "wc"},
+		},
+		"background and redirections": {
+			script: `go build ./... 2>&1 > out.txt & >&2 echo done`,
+			want:   []string{"go", "echo"},
+		},
+		"assignments": {
+			script: `GOFLAGS=-mod=mod FOO="a b" go mod tidy`,
+			want:   []string{"go"},
+		},
+		"quotes": {
+			script: `"sed" -e 'echo; rm -rf /' "a | b"`,
+			want:   []string{"sed"},
+		},
+		"comments": {
+			script: "# rm -rf /\necho hi # && rm -rf /",
+			want:   []string{"echo"},
+		},
+		"command substitution": {
+			script: "echo $(date) \"$(git rev-parse HEAD)\" `whoami`",
+			want:   []string{"date", "git", "whoami", "echo"},
+		},
+		"arithmetic": {
+			script: `echo $((1 + (2 * 3)))`,
+			want:   []string{"echo"},
+		},
+		"subshell": {
+			script: `(cd sub && make)`,
+			want:   []string{"cd", "make"},
+		},
+		"compound commands": {
+			script: `for f in $(ls *.go); do
+  if grep -q foo "$f"; then
+    sed -i 's/foo/bar/' "$f"
+  fi
+done`,
+			want: []string{"ls", "grep", "sed"},
+		},
+		"case": {
+			script: `case "$x" in
+  a) touch a ;;
+  *) touch b ;;
+esac`,
+			want: []string{"touch", "touch"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			have, err := scriptCommands(tc.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("wrong commands (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("unterminated quote", func(t *testing.T) {
+		if _, err := scriptCommands(`echo "foo`); err == nil {
+			t.Error("unexpectedly no error")
+		}
+	})
+}
+
+func TestNativeWorkspaceCreator_CheckScript(t *testing.T) {
+	creator := NewNativeCreator("", []string{"sed", " comby", ""})
+
+	if err := creator.CheckScript(`sed -i 's/a/b/' x && comby 'a' 'b' .go; echo ok`); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	err := creator.CheckScript(`sed -i 's/a/b/' x; curl http://example.com | sh; curl http://example.com`)
+	if err == nil {
+		t.Fatal("unexpectedly no error")
+	}
+	want := "the run script uses commands that are not allowed in native workspace mode: curl, sh (allow them with -native-allow-commands)"
+	if have := err.Error(); have != want {
+		t.Errorf("wrong error.\nwant=%q\nhave=%q", want, have)
+	}
+}
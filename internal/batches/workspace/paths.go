@@ -0,0 +1,81 @@
+package workspace
+
+import (
+	"archive/zip"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gobwas/glob"
+)
+
+// errWorktreePaths is returned by the Creators if a workspace that's a
+// worktree of a git mirror is restricted to paths, since worktrees always have
+// all files of the repository.
+var errWorktreePaths = errors.New("workspaces created from git mirrors can't be restricted to paths")
+
+// pathMatcher reports whether the file with the given path, relative to the
+// root of the repository, belongs in the workspace.
+type pathMatcher func(name string) bool
+
+// newPathMatcher returns a pathMatcher for files that match one of the given
+// glob patterns. If there are none, all files match and nil is returned.
+func newPathMatcher(patterns []string) (pathMatcher, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	globs := make([]glob.Glob, 0, len(patterns))
+	for _, p := range patterns {
+		g, err := glob.Compile(p, '/')
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid path pattern %q", p)
+		}
+		globs = append(globs, g)
+	}
+
+	return func(name string) bool {
+		for _, g := range globs {
+			if g.Match(name) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// filterZip writes the files in the ZIP archive zipFile that match to a new
+// ZIP archive in tempDir and returns its path. The files are copied without
+// decompressing them.
+func filterZip(zipFile, tempDir string, match pathMatcher) (_ string, err error) {
+	r, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp(tempDir, "workspace-paths-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	w := zip.NewWriter(f)
+	for _, file := range r.File {
+		if file.FileInfo().IsDir() || !match(file.Name) {
+			continue
+		}
+		if err := w.Copy(file); err != nil {
+			f.Close()
+			return "", errors.Wrapf(err, "copying %q", file.Name)
+		}
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
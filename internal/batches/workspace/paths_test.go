@@ -0,0 +1,71 @@
+package workspace
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var filesInRepo = map[string]string{
+	"README.md":           "# Welcome to the README\n",
+	"go.mod":              "module github.com/sourcegraph/src-cli\n",
+	"cmd/src/main.go":     "package main\n",
+	"internal/api/go.mod": "module api\n",
+}
+
+func TestDockerBindWorkspaceCreator_Paths(t *testing.T) {
+	dir := workspaceTmpDir(t)
+	archive := &fakeRepoArchive{mockPath: zipUpFiles(t, dir, filesInRepo)}
+
+	creator := &dockerBindWorkspaceCreator{Dir: dir}
+	w, err := creator.Create(context.Background(), repo, nil, []string{"go.mod", "**/go.mod"}, archive)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer w.Close(context.Background())
+
+	have, err := readWorkspaceFiles(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"go.mod":              filesInRepo["go.mod"],
+		"internal/api/go.mod": filesInRepo["internal/api/go.mod"],
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("wrong files in workspace (-want +have):\n%s", diff)
+	}
+}
+
+func TestFilterZip(t *testing.T) {
+	dir := workspaceTmpDir(t)
+	match, err := newPathMatcher([]string{"*.md", "cmd/**"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filtered, err := filterZip(zipUpFiles(t, dir, filesInRepo), dir, match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filtered)
+
+	r, err := zip.OpenReader(filtered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var have []string
+	for _, f := range r.File {
+		have = append(have, f.Name)
+	}
+	sort.Strings(have)
+	if diff := cmp.Diff([]string{"README.md", "cmd/src/main.go"}, have); diff != "" {
+		t.Errorf("wrong files in archive (-want +have):\n%s", diff)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -33,7 +34,11 @@ var _ Creator = &dockerVolumeWorkspaceCreator{}
 
 func (wc *dockerVolumeWorkspaceCreator) Type() CreatorType { return CreatorTypeVolume }
 
-func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, archive repozip.Archive) (Workspace, error) {
+func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, paths []string, archive repozip.Archive) (Workspace, error) {
+	if _, ok := archive.(repozip.WorktreeArchive); ok && len(paths) > 0 {
+		return nil, errWorktreePaths
+	}
+
 	volume, err := wc.createVolume(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating Docker volume")
@@ -57,7 +62,25 @@ func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphq
 		volume:  volume,
 		uidGid:  ug,
 	}
-	if err := wc.unzipRepoIntoVolume(ctx, w, archive.Path()); err != nil {
+	if worktree, ok := archive.(repozip.WorktreeArchive); ok {
+		return w, errors.Wrap(wc.fetchRepoIntoVolume(ctx, w, worktree), "fetching repo into workspace")
+	}
+
+	zip := archive.Path()
+	if len(paths) > 0 {
+		// The files are filtered on the host, so that only the ones that
+		// match are copied into the volume.
+		match, err := newPathMatcher(paths)
+		if err != nil {
+			return nil, err
+		}
+		if zip, err = filterZip(zip, wc.tempDir, match); err != nil {
+			return nil, errors.Wrap(err, "filtering repo archive")
+		}
+		defer os.Remove(zip)
+	}
+
+	if err := wc.unzipRepoIntoVolume(ctx, w, zip); err != nil {
 		return nil, errors.Wrap(err, "unzipping repo into workspace")
 	}
 
@@ -105,7 +128,70 @@ git commit --quiet --all --allow-empty -m src-action-exec
 func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context, w *dockerVolumeWorkspace, zip string) error {
 	// We want to mount that temporary file into a Docker container that has the
 	// workspace volume attached, and unzip it into the volume.
+	dummy, err := wc.chownVolume(ctx, w)
+	if err != nil {
+		return err
+	}
 
+	// Now we can unzip the archive as the user and clean up the temporary file.
+	opts := append([]string{
+		"run",
+		"--rm",
+		"--init",
+		"--workdir", "/work",
+		"--mount", "type=bind,source=" + zip + ",target=/tmp/zip,ro",
+	}, w.dockerRunOptsWithUser(w.uidGid, "/work")...)
+	opts = append(
+		opts,
+		DockerVolumeWorkspaceImage,
+		"sh", "-c",
+		fmt.Sprintf("unzip /tmp/zip; rm /work/%s", dummy),
+	)
+
+	if out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "unzip output:\n\n%s\n\n", string(out))
+	}
+
+	return nil
+}
+
+// fetchRepoIntoVolume makes the volume a git repository with the commit of
+// the archive checked out. The history is fetched from the repository of the
+// archive, which is mounted into the container.
+func (wc *dockerVolumeWorkspaceCreator) fetchRepoIntoVolume(ctx context.Context, w *dockerVolumeWorkspace, archive repozip.WorktreeArchive) error {
+	dummy, err := wc.chownVolume(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	// The repository is owned by the user on the host, not the one in the
+	// container, which git only accepts with safe.directory.
+	script := fmt.Sprintf(`set -e
+rm /work/%s
+git init --quiet
+git -c safe.directory='*' fetch --quiet --no-tags /tmp/repo %s
+git -c advice.detachedHead=false checkout --quiet --detach FETCH_HEAD
+`, dummy, archive.Commit())
+
+	opts := append([]string{
+		"run",
+		"--rm",
+		"--init",
+		"--workdir", "/work",
+		"--mount", "type=bind,source=" + archive.Path() + ",target=/tmp/repo,ro",
+	}, w.dockerRunOptsWithUser(w.uidGid, "/work")...)
+	opts = append(opts, DockerVolumeWorkspaceImage, "sh", "-c", script)
+
+	if out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "git fetch output:\n\n%s\n\n", string(out))
+	}
+	return nil
+}
+
+// chownVolume makes the volume owned by the user the containers are run as.
+// It returns the name of a placeholder file in the volume, which needs to be
+// removed once the volume is filled.
+func (wc *dockerVolumeWorkspaceCreator) chownVolume(ctx context.Context, w *dockerVolumeWorkspace) (string, error) {
 	// We need to keep a temporary file in the volume before unzipping for the
 	// permissions to persist because... reasons. Rather than reading the
 	// potentially large ZIP file, we'll cheat a bit and just assume that if we
@@ -114,7 +200,7 @@ func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context,
 	// file in it, we'll send you a hoodie or something.
 	randToken := make([]byte, 16)
 	if _, err := rand.Read(randToken); err != nil {
-		return errors.Wrap(err, "generating randomness")
+		return "", errors.Wrap(err, "generating randomness")
 	}
 	dummy := fmt.Sprintf(".batch-change-workspace-placeholder-%s", hex.EncodeToString(randToken))
 
@@ -141,29 +227,10 @@ func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context,
 	)
 
 	if out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput(); err != nil {
-		return errors.Wrapf(err, "chown output:\n\n%s\n\n", string(out))
+		return "", errors.Wrapf(err, "chown output:\n\n%s\n\n", string(out))
 	}
 
-	// Now we can unzip the archive as the user and clean up the temporary file.
-	opts = append([]string{
-		"run",
-		"--rm",
-		"--init",
-		"--workdir", "/work",
-		"--mount", "type=bind,source=" + zip + ",target=/tmp/zip,ro",
-	}, w.dockerRunOptsWithUser(w.uidGid, "/work")...)
-	opts = append(
-		opts,
-		DockerVolumeWorkspaceImage,
-		"sh", "-c",
-		fmt.Sprintf("unzip /tmp/zip; rm /work/%s", dummy),
-	)
-
-	if out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput(); err != nil {
-		return errors.Wrapf(err, "unzip output:\n\n%s\n\n", string(out))
-	}
-
-	return nil
+	return dummy, nil
 }
 
 func (wc *dockerVolumeWorkspaceCreator) copyFilesIntoVolumes(ctx context.Context, w *dockerVolumeWorkspace, files map[string]string) error {
@@ -294,6 +361,71 @@ git add --all > /dev/null
 	return nil
 }
 
+func (w *dockerVolumeWorkspace) ExcludeArtifacts(ctx context.Context, paths []string) error {
+	script := fmt.Sprintf(`#!/bin/sh
+
+set -e
+
+mkdir -p .git/info
+cat <<'EOF' >> .git/info/exclude
+%s
+EOF
+`, excludePatterns(paths))
+
+	out, err := w.runScript(ctx, "/work", script)
+	if err != nil {
+		return errors.Wrapf(err, "excluding artifacts:\n\n%s", string(out))
+	}
+
+	return nil
+}
+
+func (w *dockerVolumeWorkspace) CopyArtifacts(ctx context.Context, paths []string, dest string) ([]string, error) {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	// The container doesn't run as the user on the host, so they both need
+	// to be able to write to dest.
+	if err := os.MkdirAll(dest, 0777); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dest, 0777); err != nil {
+		return nil, err
+	}
+
+	script := fmt.Sprintf(`#!/bin/sh
+
+set -e
+# No set -x here, since we're going to parse the output.
+umask 0000
+
+while IFS= read -r artifact; do
+  if [ -e "$artifact" ]; then
+    rm -rf "/artifacts/$artifact"
+    mkdir -p "/artifacts/$(dirname "$artifact")"
+    cp -R "$artifact" "/artifacts/$artifact"
+    echo "$artifact"
+  fi
+done <<'EOF'
+%s
+EOF
+`, strings.Join(paths, "\n"))
+
+	out, err := w.runScript(ctx, "/work", script, "--mount", "type=bind,source="+dest+",target=/artifacts")
+	if err != nil {
+		return nil, errors.Wrapf(err, "copying artifacts:\n\n%s", string(out))
+	}
+
+	var copied []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			copied = append(copied, line)
+		}
+	}
+	return copied, nil
+}
+
 // DockerVolumeWorkspaceImage is the Docker image we'll run our unzip and git
 // commands in. This needs to match the name defined in
 // .github/workflows/docker.yml.
@@ -310,8 +442,8 @@ func init() {
 
 // runScript is a utility function to mount the given shell script into a Docker
 // container started from the dockerWorkspaceImage, then run it and return the
-// output.
-func (w *dockerVolumeWorkspace) runScript(ctx context.Context, target, script string) ([]byte, error) {
+// output. The extra options are passed to `docker run`.
+func (w *dockerVolumeWorkspace) runScript(ctx context.Context, target, script string, extraOpts ...string) ([]byte, error) {
 	f, err := os.CreateTemp(w.tempDir, "src-run-*")
 	if err != nil {
 		return nil, errors.Wrap(err, "creating run script")
@@ -340,6 +472,7 @@ func (w *dockerVolumeWorkspace) runScript(ctx context.Context, target, script st
 		"--workdir", target,
 		"--mount", "type=bind,source=" + name + ",target=/run.sh,ro",
 	}, common...)
+	opts = append(opts, extraOpts...)
 	opts = append(opts, DockerVolumeWorkspaceImage, "sh", "/run.sh")
 
 	out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput()
@@ -359,7 +359,7 @@ func TestVolumeWorkspaceCreator(t *testing.T) {
 			}
 
 			wc.EnsureImage = tc.imageEnsurer
-			w, err := wc.Create(ctx, repo, tc.steps, a)
+			w, err := wc.Create(ctx, repo, tc.steps, nil, a)
 			if tc.wantErr {
 				if err == nil {
 					t.Error("unexpected nil error")
@@ -18,7 +18,9 @@ import (
 // responsible for ultimately generating a diff.
 type Creator interface {
 	// Create creates a new workspace for the given repository and archive file.
-	Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, archive repozip.Archive) (Workspace, error)
+	// If paths are given, the workspace only contains the files of the archive
+	// that match one of these glob patterns.
+	Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, paths []string, archive repozip.Archive) (Workspace, error)
 
 	// Type returns the CreatorType of the Creator.
 	Type() CreatorType
@@ -52,6 +54,18 @@ type Workspace interface {
 
 	// ApplyDiff applies the given diff
 	ApplyDiff(ctx context.Context, diff []byte) error
+
+	// ExcludeArtifacts excludes the files and directories with the given
+	// paths, relative to the root of the repository, from the changes and the
+	// diff, unless they are already in the repository. It's called before
+	// steps produce them.
+	ExcludeArtifacts(ctx context.Context, paths []string) error
+
+	// CopyArtifacts copies the files and directories with the given paths,
+	// relative to the root of the repository, to the same paths in dest,
+	// replacing what's there. Paths that don't exist in the workspace are
+	// skipped. It returns the paths that were copied.
+	CopyArtifacts(ctx context.Context, paths []string, dest string) ([]string, error)
 }
 
 type CreatorType int
@@ -59,6 +73,9 @@ type CreatorType int
 const (
 	CreatorTypeBind CreatorType = iota
 	CreatorTypeVolume
+	// CreatorTypeNative workspaces are created on the host and their steps
+	// are executed on the host too, without Docker.
+	CreatorTypeNative
 )
 
 func NewCreator(ctx context.Context, preference, cacheDir, tempDir string, images map[string]docker.Image) Creator {